@@ -7,6 +7,12 @@
 //
 // See also ``A Layman's Guide to a Subset of ASN.1, BER, and DER,''
 // http://luca.ntop.org/Teaching/Appunti/asn1.html.
+//
+// Marshal and Unmarshal convert between DER and Go values described by
+// struct tags. Builder and Parser instead write and read DER one
+// element at a time, for structures that struct tags cannot express,
+// such as CHOICEs and implicitly tagged SETs, or where allocations
+// matter.
 package asn1
 
 // ASN.1 is a syntax for specifying abstract objects and BER, DER, PER, XER etc
@@ -0,0 +1,406 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package asn1
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// A Builder builds DER-encoded ASN.1 elements by appending them to a
+// byte slice. Elements are added in order with the Add methods, and
+// nested elements such as SEQUENCEs are built by functions that add
+// their contents to the same Builder, so that no intermediate
+// encodings are allocated.
+//
+// The first error encountered by a Builder is recorded and reported by
+// Bytes; once it is set, all further Add calls do nothing.
+type Builder struct {
+	buf []byte
+	err error
+
+	// implicit is the tag that replaces the class and number of the
+	// next element added, if hasImplicit is set.
+	implicit    Tag
+	hasImplicit bool
+}
+
+// NewBuilder returns a Builder that appends to buf.
+func NewBuilder(buf []byte) *Builder {
+	return &Builder{buf: buf}
+}
+
+// Bytes returns the encoding built so far, or the first error
+// encountered while building it.
+func (b *Builder) Bytes() ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.buf, nil
+}
+
+// header appends the identifier and length of an element with the
+// given tag, which is replaced by an implicit tag if one is pending.
+// It reports whether the Builder has not failed.
+func (b *Builder) header(tag Tag, length int) bool {
+	if b.err != nil {
+		return false
+	}
+	if b.hasImplicit {
+		tag.Class, tag.Number = b.implicit.Class, b.implicit.Number
+		b.hasImplicit = false
+	}
+	b.buf = appendTagAndLength(b.buf, tagAndLength{tag.Class, tag.Number, length, tag.IsCompound})
+	return true
+}
+
+// grow extends the encoding by n bytes and returns them.
+func (b *Builder) grow(n int) []byte {
+	b.buf = append(b.buf, make([]byte, n)...)
+	return b.buf[len(b.buf)-n:]
+}
+
+func (b *Builder) primitive(number int, contents []byte) {
+	if b.header(Tag{Number: number}, len(contents)) {
+		b.buf = append(b.buf, contents...)
+	}
+}
+
+func (b *Builder) primitiveString(number int, contents string) {
+	if b.header(Tag{Number: number}, len(contents)) {
+		b.buf = append(b.buf, contents...)
+	}
+}
+
+func (b *Builder) setError(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// AddElement adds an element with the given tag whose contents are
+// added by f.
+func (b *Builder) AddElement(tag Tag, f func(*Builder)) {
+	b.addElement(tag, f, false)
+}
+
+// AddSequence adds a SEQUENCE whose elements are added by f.
+func (b *Builder) AddSequence(f func(*Builder)) {
+	b.addElement(sequenceTag, f, false)
+}
+
+// AddSetOf adds a SET OF whose elements are added by f. As DER requires,
+// the elements are sorted by their encodings.
+func (b *Builder) AddSetOf(f func(*Builder)) {
+	b.addElement(setTag, f, true)
+}
+
+// AddSetOfWithTag is like AddSetOf but gives the SET OF the specified
+// tag instead of the universal SET tag.
+func (b *Builder) AddSetOfWithTag(tag Tag, f func(*Builder)) {
+	b.addElement(tag, f, true)
+}
+
+func (b *Builder) addElement(tag Tag, f func(*Builder), sorted bool) {
+	if b.err != nil {
+		return
+	}
+	if !tag.valid() {
+		b.setError(StructuralError{"invalid tag"})
+		return
+	}
+	// Reserve a single byte for the length and move the contents up
+	// if it turns out to need more.
+	b.header(tag, 0)
+	start := len(b.buf)
+	f(b)
+	if b.err != nil {
+		return
+	}
+	if sorted {
+		b.sortElements(start)
+	}
+	n := len(b.buf) - start
+	if n < 0x80 {
+		b.buf[start-1] = byte(n)
+		return
+	}
+	l := lengthLength(n)
+	b.grow(l)
+	copy(b.buf[start+l:], b.buf[start:len(b.buf)-l])
+	b.buf[start-1] = 0x80 | byte(l)
+	appendLength(b.buf[start:start], n)
+}
+
+// sortElements sorts the elements encoded after start by their
+// encodings.
+func (b *Builder) sortElements(start int) {
+	contents := append([]byte(nil), b.buf[start:]...)
+	var elems [][]byte
+	for rest := contents; len(rest) > 0; {
+		n, err := elementLength(rest)
+		if err != nil {
+			b.setError(err)
+			return
+		}
+		elems = append(elems, rest[:n])
+		rest = rest[n:]
+	}
+	sort.Slice(elems, func(i, j int) bool {
+		return bytes.Compare(elems[i], elems[j]) < 0
+	})
+	b.buf = b.buf[:start]
+	for _, e := range elems {
+		b.buf = append(b.buf, e...)
+	}
+}
+
+// elementLength returns the length of the encoding of the element at
+// the start of der.
+func elementLength(der []byte) (int, error) {
+	t, offset, err := parseTagAndLength(der, 0)
+	if err != nil {
+		return 0, err
+	}
+	if invalidLength(offset, t.length, len(der)) {
+		return 0, SyntaxError{"data truncated"}
+	}
+	return offset + t.length, nil
+}
+
+// AddImplicit adds the single element added by f with its tag replaced
+// by the IMPLICIT tag, whose class and number are used. Whether the
+// element is compound is kept from the element's own tag, so
+// tag.IsCompound is ignored.
+func (b *Builder) AddImplicit(tag Tag, f func(*Builder)) {
+	if b.err != nil {
+		return
+	}
+	if !tag.valid() {
+		b.setError(StructuralError{"invalid tag"})
+		return
+	}
+	start := len(b.buf)
+	b.implicit, b.hasImplicit = tag, true
+	f(b)
+	if b.err != nil {
+		return
+	}
+	if b.hasImplicit {
+		b.hasImplicit = false
+		b.setError(StructuralError{"no element added for implicit tag"})
+		return
+	}
+	if n, err := elementLength(b.buf[start:]); err != nil || start+n != len(b.buf) {
+		b.setError(StructuralError{"more than one element added for implicit tag"})
+	}
+}
+
+// AddRaw adds der, which must be the DER encoding of one or more
+// complete elements, such as a certificate being embedded in a larger
+// structure.
+func (b *Builder) AddRaw(der []byte) {
+	if b.err != nil {
+		return
+	}
+	if b.hasImplicit {
+		b.setError(StructuralError{"implicit tag on raw element"})
+		return
+	}
+	for rest := der; len(rest) > 0; {
+		n, err := elementLength(rest)
+		if err != nil {
+			b.setError(err)
+			return
+		}
+		rest = rest[n:]
+	}
+	b.buf = append(b.buf, der...)
+}
+
+// AddBoolean adds a BOOLEAN.
+func (b *Builder) AddBoolean(v bool) {
+	if b.header(Tag{Number: TagBoolean}, 1) {
+		if v {
+			b.buf = append(b.buf, 0xff)
+		} else {
+			b.buf = append(b.buf, 0)
+		}
+	}
+}
+
+// AddInt64 adds an INTEGER.
+func (b *Builder) AddInt64(v int64) {
+	e := int64Encoder(v)
+	if b.header(Tag{Number: TagInteger}, e.Len()) {
+		e.Encode(b.grow(e.Len()))
+	}
+}
+
+// AddUint64 adds an INTEGER.
+func (b *Builder) AddUint64(v uint64) {
+	if v <= 1<<63-1 {
+		b.AddInt64(int64(v))
+		return
+	}
+	// The top bit is set, so a leading zero keeps the value positive.
+	if b.header(Tag{Number: TagInteger}, 9) {
+		b.buf = append(b.buf, 0,
+			byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32),
+			byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+}
+
+// AddBigInt adds an INTEGER.
+func (b *Builder) AddBigInt(v *big.Int) {
+	if b.err != nil {
+		return
+	}
+	e, err := makeBigInt(v)
+	if err != nil {
+		b.setError(err)
+		return
+	}
+	if b.header(Tag{Number: TagInteger}, e.Len()) {
+		e.Encode(b.grow(e.Len()))
+	}
+}
+
+// AddEnum adds an ENUMERATED.
+func (b *Builder) AddEnum(v Enumerated) {
+	e := int64Encoder(v)
+	if b.header(Tag{Number: TagEnum}, e.Len()) {
+		e.Encode(b.grow(e.Len()))
+	}
+}
+
+// AddOctetString adds an OCTET STRING.
+func (b *Builder) AddOctetString(v []byte) {
+	b.primitive(TagOctetString, v)
+}
+
+// AddNull adds a NULL.
+func (b *Builder) AddNull() {
+	b.primitive(TagNull, nil)
+}
+
+// AddObjectIdentifier adds an OBJECT IDENTIFIER.
+func (b *Builder) AddObjectIdentifier(v ObjectIdentifier) {
+	if b.err != nil {
+		return
+	}
+	if len(v) < 2 || v[0] > 2 || (v[0] < 2 && v[1] >= 40) {
+		b.setError(StructuralError{"invalid object identifier"})
+		return
+	}
+	e := oidEncoder(v)
+	if b.header(Tag{Number: TagOID}, e.Len()) {
+		e.Encode(b.grow(e.Len()))
+	}
+}
+
+// AddBitString adds a BIT STRING. The length of v.Bytes must match
+// v.BitLength and, as DER requires, the padding bits must be zero.
+func (b *Builder) AddBitString(v BitString) {
+	if b.err != nil {
+		return
+	}
+	padding := uint(8-v.BitLength%8) % 8
+	if v.BitLength < 0 || len(v.Bytes) != (v.BitLength+7)/8 ||
+		len(v.Bytes) > 0 && v.Bytes[len(v.Bytes)-1]&(1<<padding-1) != 0 {
+		b.setError(StructuralError{"invalid BIT STRING"})
+		return
+	}
+	e := bitStringEncoder(v)
+	if b.header(Tag{Number: TagBitString}, e.Len()) {
+		e.Encode(b.grow(e.Len()))
+	}
+}
+
+// AddUTCTime adds a UTCTime holding t in UTC to the second.
+// The year of t must be from 1950 to 2049.
+func (b *Builder) AddUTCTime(t time.Time) {
+	if b.err != nil {
+		return
+	}
+	var arr [13]byte
+	v, err := appendUTCTime(arr[:0], t.UTC())
+	if err != nil {
+		b.setError(err)
+		return
+	}
+	b.primitive(TagUTCTime, v)
+}
+
+// AddGeneralizedTime adds a GeneralizedTime holding t in UTC to the
+// second.
+func (b *Builder) AddGeneralizedTime(t time.Time) {
+	if b.err != nil {
+		return
+	}
+	var arr [15]byte
+	v, err := appendGeneralizedTime(arr[:0], t.UTC())
+	if err != nil {
+		b.setError(err)
+		return
+	}
+	b.primitive(TagGeneralizedTime, v)
+}
+
+// AddTime adds t as a UTCTime if its year is from 1950 to 2049 and as
+// a GeneralizedTime otherwise, as RFC 5280 requires for certificate
+// validity periods.
+func (b *Builder) AddTime(t time.Time) {
+	if outsideUTCRange(t.UTC()) {
+		b.AddGeneralizedTime(t)
+	} else {
+		b.AddUTCTime(t)
+	}
+}
+
+// AddUTF8String adds a UTF8String. It fails if s is not valid UTF-8.
+func (b *Builder) AddUTF8String(s string) {
+	if b.err != nil {
+		return
+	}
+	if !utf8.ValidString(s) {
+		b.setError(StructuralError{"invalid UTF-8 string"})
+		return
+	}
+	b.primitiveString(TagUTF8String, s)
+}
+
+// AddPrintableString adds a PrintableString. It fails if s contains
+// characters that are not allowed in a PrintableString, except that
+// '*' is allowed as Marshal allows it.
+func (b *Builder) AddPrintableString(s string) {
+	if b.err != nil {
+		return
+	}
+	for i := 0; i < len(s); i++ {
+		if !isPrintable(s[i], allowAsterisk, rejectAmpersand) {
+			b.setError(StructuralError{"PrintableString contains invalid character"})
+			return
+		}
+	}
+	b.primitiveString(TagPrintableString, s)
+}
+
+// AddIA5String adds an IA5String. It fails if s is not ASCII.
+func (b *Builder) AddIA5String(s string) {
+	if b.err != nil {
+		return
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			b.setError(StructuralError{"IA5String contains invalid character"})
+			return
+		}
+	}
+	b.primitiveString(TagIA5String, s)
+}
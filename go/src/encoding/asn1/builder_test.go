@@ -0,0 +1,218 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package asn1
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type builderStruct struct {
+	Version int `asn1:"explicit,tag:0"`
+	Serial  *big.Int
+	Alg     ObjectIdentifier
+	Flag    bool
+	Data    []byte
+	Bits    BitString
+	Name    string `asn1:"utf8"`
+	Email   string `asn1:"ia5"`
+	Country string
+	Time    time.Time `asn1:"generalized"`
+	Tagged  int       `asn1:"tag:1"`
+	Seq     []int     `asn1:"tag:2"`
+	Set     []int     `asn1:"set"`
+	Enum    Enumerated
+	Null    RawValue
+}
+
+func TestBuilder(t *testing.T) {
+	serial, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	when := time.Date(2049, 12, 31, 23, 59, 59, 0, time.UTC)
+	data := bytes.Repeat([]byte{0xaa}, 200)
+	v := builderStruct{
+		Version: 2,
+		Serial:  serial,
+		Alg:     ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11},
+		Flag:    true,
+		Data:    data,
+		Bits:    BitString{Bytes: []byte{0xf0}, BitLength: 4},
+		Name:    "héllo",
+		Email:   "gopher@example.com",
+		Country: "US",
+		Time:    when,
+		Tagged:  -129,
+		Seq:     []int{1, 2},
+		Set:     []int{1, 2, 300},
+		Enum:    3,
+		Null:    NullRawValue,
+	}
+	want, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBuilder(nil)
+	b.AddSequence(func(b *Builder) {
+		b.AddElement(ContextSpecific(0).Constructed(), func(b *Builder) {
+			b.AddInt64(2)
+		})
+		b.AddBigInt(serial)
+		b.AddObjectIdentifier(v.Alg)
+		b.AddBoolean(true)
+		b.AddOctetString(data)
+		b.AddBitString(v.Bits)
+		b.AddUTF8String(v.Name)
+		b.AddIA5String(v.Email)
+		b.AddPrintableString(v.Country)
+		b.AddGeneralizedTime(when.In(time.FixedZone("", 3600)))
+		b.AddImplicit(ContextSpecific(1), func(b *Builder) {
+			b.AddInt64(-129)
+		})
+		b.AddImplicit(ContextSpecific(2), func(b *Builder) {
+			b.AddSequence(func(b *Builder) {
+				b.AddUint64(1)
+				b.AddUint64(2)
+			})
+		})
+		b.AddSetOf(func(b *Builder) {
+			b.AddInt64(300)
+			b.AddInt64(2)
+			b.AddInt64(1)
+		})
+		b.AddEnum(3)
+		b.AddRaw(NullBytes)
+	})
+	got, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Builder output differs from Marshal:\ngot  %x\nwant %x", got, want)
+	}
+
+	p := NewParser(got)
+	var seq, inner Parser
+	if err := p.ReadSequence(&seq); err != nil || !p.Empty() {
+		t.Fatalf("ReadSequence: %v", err)
+	}
+	var out builderStruct
+	var version int64
+	var enum Enumerated
+	var tagged int64
+	var seqElems []int
+	var setElems []int
+	out.Serial = new(big.Int)
+	for _, err := range []error{
+		seq.ReadElement(ContextSpecific(0).Constructed(), &inner),
+		inner.ReadInt64(&version),
+		seq.ReadBigInt(out.Serial),
+		seq.ReadObjectIdentifier(&out.Alg),
+		seq.ReadBoolean(&out.Flag),
+		seq.ReadOctetString(&out.Data),
+		seq.ReadBitString(&out.Bits),
+		seq.ReadUTF8String(&out.Name),
+		seq.ReadIA5String(&out.Email),
+		seq.ReadPrintableString(&out.Country),
+		seq.ReadTime(&out.Time),
+		seq.ReadImplicit(ContextSpecific(1), func(p *Parser) error {
+			return p.ReadInt64(&tagged)
+		}),
+		seq.ReadImplicit(ContextSpecific(2), func(p *Parser) error {
+			var elems Parser
+			if err := p.ReadSequence(&elems); err != nil {
+				return err
+			}
+			for !elems.Empty() {
+				var n uint64
+				if err := elems.ReadUint64(&n); err != nil {
+					return err
+				}
+				seqElems = append(seqElems, int(n))
+			}
+			return nil
+		}),
+		seq.ReadSetOf(&inner),
+		func() error {
+			for !inner.Empty() {
+				var n int64
+				if err := inner.ReadInt64(&n); err != nil {
+					return err
+				}
+				setElems = append(setElems, int(n))
+			}
+			return nil
+		}(),
+		seq.ReadEnum(&enum),
+		seq.ReadNull(),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !seq.Empty() {
+		t.Fatalf("%d bytes left unread", len(seq.Bytes()))
+	}
+	out.Version, out.Tagged, out.Seq, out.Set, out.Enum, out.Null = int(version), int(tagged), seqElems, setElems, enum, NullRawValue
+	if !reflect.DeepEqual(out, v) {
+		t.Errorf("Parser read:\n%+v\nwant:\n%+v", out, v)
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	for i, f := range []func(b *Builder){
+		func(b *Builder) { b.AddObjectIdentifier(ObjectIdentifier{3, 1}) },
+		func(b *Builder) { b.AddBitString(BitString{Bytes: []byte{0xff}, BitLength: 4}) },
+		func(b *Builder) { b.AddBitString(BitString{Bytes: []byte{0xff}, BitLength: 9}) },
+		func(b *Builder) { b.AddUTCTime(time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)) },
+		func(b *Builder) { b.AddUTF8String("\xff") },
+		func(b *Builder) { b.AddPrintableString("a@b") },
+		func(b *Builder) { b.AddIA5String("é") },
+		func(b *Builder) { b.AddBigInt(nil) },
+		func(b *Builder) { b.AddRaw([]byte{0x30, 0x03, 0x02, 0x01}) },
+		func(b *Builder) { b.AddElement(Tag{Class: 4}, func(*Builder) {}) },
+		func(b *Builder) { b.AddImplicit(ContextSpecific(0), func(*Builder) {}) },
+		func(b *Builder) {
+			b.AddImplicit(ContextSpecific(0), func(b *Builder) {
+				b.AddNull()
+				b.AddNull()
+			})
+		},
+	} {
+		b := NewBuilder(nil)
+		b.AddSequence(f)
+		if out, err := b.Bytes(); err == nil {
+			t.Errorf("#%d: got %x, want error", i, out)
+		}
+	}
+}
+
+func TestBuilderLongForm(t *testing.T) {
+	b := NewBuilder(nil)
+	b.AddElement(Tag{Class: ClassApplication, Number: 1000, IsCompound: true}, func(b *Builder) {
+		b.AddOctetString(make([]byte, 70000))
+	})
+	got, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// [APPLICATION 1000] constructed, length 70005, OCTET STRING of length 70000.
+	want := []byte{0x7f, 0x87, 0x68, 0x83, 0x01, 0x11, 0x75, 0x04, 0x83, 0x01, 0x11, 0x70}
+	if !bytes.HasPrefix(got, want) || len(got) != len(want)+70000 {
+		t.Errorf("got prefix %x and length %d", got[:len(want)], len(got))
+	}
+	var inner Parser
+	var data []byte
+	p := NewParser(got)
+	tag, err := p.ReadAnyElement(&inner)
+	if err != nil || tag != (Tag{Class: ClassApplication, Number: 1000, IsCompound: true}) {
+		t.Fatalf("ReadAnyElement = %v, %v", tag, err)
+	}
+	if err := inner.ReadOctetString(&data); err != nil || len(data) != 70000 {
+		t.Errorf("ReadOctetString: %d bytes, %v", len(data), err)
+	}
+}
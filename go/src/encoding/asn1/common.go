@@ -5,6 +5,7 @@
 package asn1
 
 import (
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
@@ -48,6 +49,57 @@ const (
 	ClassPrivate         = 3
 )
 
+// A Tag is the identifier of an ASN.1 element as written by a Builder
+// and read by a Parser: its class, its tag number and whether its
+// encoding is compound (constructed) rather than primitive.
+type Tag struct {
+	Class      int
+	Number     int
+	IsCompound bool
+}
+
+// ContextSpecific returns the primitive CONTEXT-SPECIFIC tag [n].
+func ContextSpecific(n int) Tag {
+	return Tag{Class: ClassContextSpecific, Number: n}
+}
+
+// Constructed returns t marked as compound, as needed for an EXPLICIT
+// tag or an IMPLICIT tag on a SEQUENCE or SET.
+func (t Tag) Constructed() Tag {
+	t.IsCompound = true
+	return t
+}
+
+func (t Tag) String() string {
+	var class string
+	switch t.Class {
+	case ClassUniversal:
+		class = "UNIVERSAL "
+	case ClassApplication:
+		class = "APPLICATION "
+	case ClassContextSpecific:
+	case ClassPrivate:
+		class = "PRIVATE "
+	default:
+		class = "class " + strconv.Itoa(t.Class) + " "
+	}
+	s := "[" + class + strconv.Itoa(t.Number) + "]"
+	if t.IsCompound {
+		s += " constructed"
+	}
+	return s
+}
+
+// valid reports whether t can be encoded.
+func (t Tag) valid() bool {
+	return 0 <= t.Class && t.Class <= ClassPrivate && 0 <= t.Number && t.Number <= math.MaxInt32
+}
+
+var (
+	sequenceTag = Tag{Number: TagSequence, IsCompound: true}
+	setTag      = Tag{Number: TagSet, IsCompound: true}
+)
+
 type tagAndLength struct {
 	class, tag, length int
 	isCompound         bool
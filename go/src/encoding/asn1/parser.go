@@ -0,0 +1,417 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package asn1
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// A Parser reads DER-encoded ASN.1 elements in order from a byte slice.
+// The contents of nested elements such as SEQUENCEs are read with
+// another Parser, and values such as OCTET STRINGs are returned as
+// slices of the input rather than copies.
+//
+// Parsing is strict: encodings that are valid BER but not DER, such as
+// indefinite or non-minimal lengths, non-minimal INTEGERs, BOOLEANs
+// other than 0x00 and 0xff, and times not in UTC, are rejected with a
+// SyntaxError or StructuralError. An element with an unexpected tag is
+// reported with a StructuralError. When a Read method fails, the
+// Parser is left unchanged.
+type Parser struct {
+	data []byte
+
+	// implicit is the tag whose class and number replace those expected
+	// for the next element read, if hasImplicit is set.
+	implicit    Tag
+	hasImplicit bool
+}
+
+// NewParser returns a Parser reading the elements in der.
+func NewParser(der []byte) *Parser {
+	return &Parser{data: der}
+}
+
+// Empty reports whether all of the input has been read.
+func (p *Parser) Empty() bool {
+	return len(p.data) == 0
+}
+
+// Bytes returns the input that has not been read yet.
+func (p *Parser) Bytes() []byte {
+	return p.data
+}
+
+// next parses the identifier and length of the next element and
+// returns its tag, the length of its identifier and length, and the
+// length of its whole encoding.
+func (p *Parser) next() (tag Tag, header, end int, err error) {
+	if len(p.data) == 0 {
+		err = SyntaxError{"data truncated"}
+		return
+	}
+	t, offset, err := parseTagAndLength(p.data, 0)
+	if err != nil {
+		return
+	}
+	if invalidLength(offset, t.length, len(p.data)) {
+		err = SyntaxError{"data truncated"}
+		return
+	}
+	return Tag{t.class, t.tag, t.isCompound}, offset, offset + t.length, nil
+}
+
+// read reads the next element, which must have the given tag, and
+// returns its contents and its whole encoding.
+func (p *Parser) read(tag Tag) (contents, full []byte, err error) {
+	if p.hasImplicit {
+		tag.Class, tag.Number = p.implicit.Class, p.implicit.Number
+	}
+	got, header, end, err := p.next()
+	if err != nil {
+		return nil, nil, err
+	}
+	if got != tag {
+		return nil, nil, StructuralError{fmt.Sprintf("tags don't match (want %v, got %v)", tag, got)}
+	}
+	contents, full = p.data[header:end], p.data[:end]
+	p.data = p.data[end:]
+	p.hasImplicit = false
+	return contents, full, nil
+}
+
+// PeekTag returns the tag of the next element without reading it. It
+// reports false if there is no input left or the next element is not
+// valid DER.
+func (p *Parser) PeekTag() (Tag, bool) {
+	tag, _, _, err := p.next()
+	return tag, err == nil
+}
+
+// ReadElement reads the next element, which must have the given tag,
+// and sets out to read its contents.
+func (p *Parser) ReadElement(tag Tag, out *Parser) error {
+	contents, _, err := p.read(tag)
+	if err != nil {
+		return err
+	}
+	*out = Parser{data: contents}
+	return nil
+}
+
+// ReadOptionalElement is like ReadElement but reports false, and reads
+// nothing, if there is no input left or the next element has another
+// tag.
+func (p *Parser) ReadOptionalElement(tag Tag, out *Parser) (present bool, err error) {
+	if p.Empty() {
+		return false, nil
+	}
+	got, _, _, err := p.next()
+	if err != nil {
+		return false, err
+	}
+	if got != tag {
+		return false, nil
+	}
+	return true, p.ReadElement(tag, out)
+}
+
+// ReadAnyElement reads the next element whatever its tag, returns the
+// tag and sets out to read its contents.
+func (p *Parser) ReadAnyElement(out *Parser) (Tag, error) {
+	tag, _, _, err := p.next()
+	if err != nil {
+		return Tag{}, err
+	}
+	return tag, p.ReadElement(tag, out)
+}
+
+// ReadRawValue reads the next element whatever its tag into out,
+// whose Bytes and FullBytes are set to its contents and its whole
+// encoding. The whole encoding is what is signed in structures such as
+// certificates and OCSP responses.
+func (p *Parser) ReadRawValue(out *RawValue) error {
+	tag, _, _, err := p.next()
+	if err != nil {
+		return err
+	}
+	contents, full, err := p.read(tag)
+	if err != nil {
+		return err
+	}
+	*out = RawValue{Class: tag.Class, Tag: tag.Number, IsCompound: tag.IsCompound, Bytes: contents, FullBytes: full}
+	return nil
+}
+
+// ReadSequence reads a SEQUENCE and sets out to read its elements.
+func (p *Parser) ReadSequence(out *Parser) error {
+	return p.ReadElement(sequenceTag, out)
+}
+
+// ReadSetOf reads a SET OF and sets out to read its elements, which
+// must be sorted by their encodings as DER requires.
+func (p *Parser) ReadSetOf(out *Parser) error {
+	return p.ReadSetOfWithTag(setTag, out)
+}
+
+// ReadSetOfWithTag is like ReadSetOf but expects the specified tag
+// instead of the universal SET tag.
+func (p *Parser) ReadSetOfWithTag(tag Tag, out *Parser) error {
+	saved := *p
+	contents, _, err := p.read(tag)
+	if err != nil {
+		return err
+	}
+	var prev []byte
+	for rest := contents; len(rest) > 0; {
+		n, err := elementLength(rest)
+		if err != nil {
+			*p = saved
+			return err
+		}
+		if prev != nil && bytes.Compare(prev, rest[:n]) > 0 {
+			*p = saved
+			return SyntaxError{"SET OF elements are not sorted"}
+		}
+		prev, rest = rest[:n], rest[n:]
+	}
+	*out = Parser{data: contents}
+	return nil
+}
+
+// ReadImplicit reads the next element, whose tag must have the class
+// and number of the IMPLICIT tag, by calling f with a Parser that
+// expects that tag in place of the one asked for by the first Read
+// method f calls. f must read exactly the one element.
+func (p *Parser) ReadImplicit(tag Tag, f func(*Parser) error) error {
+	got, _, end, err := p.next()
+	if err != nil {
+		return err
+	}
+	if got.Class != tag.Class || got.Number != tag.Number {
+		tag.IsCompound = got.IsCompound
+		return StructuralError{fmt.Sprintf("tags don't match (want %v, got %v)", tag, got)}
+	}
+	q := Parser{data: p.data[:end], implicit: tag, hasImplicit: true}
+	if err := f(&q); err != nil {
+		return err
+	}
+	if !q.Empty() {
+		return StructuralError{"implicitly tagged element not read"}
+	}
+	p.data = p.data[end:]
+	return nil
+}
+
+// readPrimitive reads the contents of the next element, which must have
+// the given universal tag number and be primitive.
+func (p *Parser) readPrimitive(number int) ([]byte, error) {
+	contents, _, err := p.read(Tag{Number: number})
+	return contents, err
+}
+
+// readWith reads the next element with the given universal tag number
+// and calls parse with its contents, leaving p unchanged if either
+// fails.
+func (p *Parser) readWith(number int, parse func([]byte) error) error {
+	saved := *p
+	contents, err := p.readPrimitive(number)
+	if err == nil {
+		err = parse(contents)
+	}
+	if err != nil {
+		*p = saved
+	}
+	return err
+}
+
+// ReadBoolean reads a BOOLEAN into out.
+func (p *Parser) ReadBoolean(out *bool) error {
+	return p.readWith(TagBoolean, func(b []byte) error {
+		v, err := parseBool(b)
+		if err == nil {
+			*out = v
+		}
+		return err
+	})
+}
+
+// ReadInt64 reads an INTEGER into out. It fails if the value does not
+// fit in an int64.
+func (p *Parser) ReadInt64(out *int64) error {
+	return p.readWith(TagInteger, func(b []byte) error {
+		v, err := parseInt64(b)
+		if err == nil {
+			*out = v
+		}
+		return err
+	})
+}
+
+// ReadUint64 reads an INTEGER into out. It fails if the value is
+// negative or does not fit in a uint64.
+func (p *Parser) ReadUint64(out *uint64) error {
+	return p.readWith(TagInteger, func(b []byte) error {
+		if err := checkInteger(b); err != nil {
+			return err
+		}
+		if b[0]&0x80 != 0 {
+			return StructuralError{"negative integer"}
+		}
+		if b[0] == 0 {
+			b = b[1:]
+		}
+		if len(b) > 8 {
+			return StructuralError{"integer too large"}
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		*out = v
+		return nil
+	})
+}
+
+// ReadBigInt reads an INTEGER into out.
+func (p *Parser) ReadBigInt(out *big.Int) error {
+	return p.readWith(TagInteger, func(b []byte) error {
+		v, err := parseBigInt(b)
+		if err == nil {
+			out.Set(v)
+		}
+		return err
+	})
+}
+
+// ReadEnum reads an ENUMERATED into out.
+func (p *Parser) ReadEnum(out *Enumerated) error {
+	return p.readWith(TagEnum, func(b []byte) error {
+		v, err := parseInt32(b)
+		if err == nil {
+			*out = Enumerated(v)
+		}
+		return err
+	})
+}
+
+// ReadOctetString reads an OCTET STRING and sets out to its contents,
+// which share memory with the input.
+func (p *Parser) ReadOctetString(out *[]byte) error {
+	contents, err := p.readPrimitive(TagOctetString)
+	if err == nil {
+		*out = contents
+	}
+	return err
+}
+
+// ReadNull reads a NULL.
+func (p *Parser) ReadNull() error {
+	return p.readWith(TagNull, func(b []byte) error {
+		if len(b) != 0 {
+			return SyntaxError{"invalid NULL"}
+		}
+		return nil
+	})
+}
+
+// ReadObjectIdentifier reads an OBJECT IDENTIFIER into out.
+func (p *Parser) ReadObjectIdentifier(out *ObjectIdentifier) error {
+	return p.readWith(TagOID, func(b []byte) error {
+		v, err := parseObjectIdentifier(b)
+		if err == nil {
+			*out = v
+		}
+		return err
+	})
+}
+
+// ReadBitString reads a BIT STRING into out. The bytes of out share
+// memory with the input.
+func (p *Parser) ReadBitString(out *BitString) error {
+	return p.readWith(TagBitString, func(b []byte) error {
+		v, err := parseBitString(b)
+		if err == nil {
+			*out = v
+		}
+		return err
+	})
+}
+
+// ReadUTCTime reads a UTCTime into out. As DER requires, it must be in
+// UTC and include seconds: YYMMDDHHMMSSZ.
+func (p *Parser) ReadUTCTime(out *time.Time) error {
+	return p.readWith(TagUTCTime, func(b []byte) error {
+		if len(b) != len("YYMMDDHHMMSSZ") || b[len(b)-1] != 'Z' {
+			return SyntaxError{"UTCTime is not in DER form"}
+		}
+		v, err := parseUTCTime(b)
+		if err == nil {
+			*out = v
+		}
+		return err
+	})
+}
+
+// ReadGeneralizedTime reads a GeneralizedTime into out. As RFC 5280
+// requires of DER, it must be in UTC without fractional seconds:
+// YYYYMMDDHHMMSSZ.
+func (p *Parser) ReadGeneralizedTime(out *time.Time) error {
+	return p.readWith(TagGeneralizedTime, func(b []byte) error {
+		if len(b) != len("YYYYMMDDHHMMSSZ") || b[len(b)-1] != 'Z' {
+			return SyntaxError{"GeneralizedTime is not in DER form"}
+		}
+		v, err := parseGeneralizedTime(b)
+		if err == nil {
+			*out = v
+		}
+		return err
+	})
+}
+
+// ReadTime reads a UTCTime or GeneralizedTime into out, as written by
+// Builder.AddTime.
+func (p *Parser) ReadTime(out *time.Time) error {
+	if tag, ok := p.PeekTag(); ok && !p.hasImplicit && tag == (Tag{Number: TagGeneralizedTime}) {
+		return p.ReadGeneralizedTime(out)
+	}
+	return p.ReadUTCTime(out)
+}
+
+// ReadUTF8String reads a UTF8String into out.
+func (p *Parser) ReadUTF8String(out *string) error {
+	return p.readWith(TagUTF8String, func(b []byte) error {
+		v, err := parseUTF8String(b)
+		if err == nil {
+			*out = v
+		}
+		return err
+	})
+}
+
+// ReadPrintableString reads a PrintableString into out. As with
+// Unmarshal, '*' and '&' are accepted.
+func (p *Parser) ReadPrintableString(out *string) error {
+	return p.readWith(TagPrintableString, func(b []byte) error {
+		v, err := parsePrintableString(b)
+		if err == nil {
+			*out = v
+		}
+		return err
+	})
+}
+
+// ReadIA5String reads an IA5String into out.
+func (p *Parser) ReadIA5String(out *string) error {
+	return p.readWith(TagIA5String, func(b []byte) error {
+		v, err := parseIA5String(b)
+		if err == nil {
+			*out = v
+		}
+		return err
+	})
+}
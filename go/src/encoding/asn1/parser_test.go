@@ -0,0 +1,101 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package asn1
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var parserErrorTests = []struct {
+	in   []byte
+	read func(p *Parser) error
+}{
+	// Encodings that are BER but not DER.
+	{[]byte{0x30, 0x80, 0x00, 0x00}, func(p *Parser) error { return p.ReadSequence(new(Parser)) }},
+	{[]byte{0x04, 0x81, 0x01, 0x00}, func(p *Parser) error { return p.ReadOctetString(new([]byte)) }},
+	{[]byte{0x02, 0x02, 0x00, 0x01}, func(p *Parser) error { return p.ReadInt64(new(int64)) }},
+	{[]byte{0x02, 0x02, 0xff, 0x80}, func(p *Parser) error { return p.ReadBigInt(new(big.Int)) }},
+	{[]byte{0x01, 0x01, 0x01}, func(p *Parser) error { return p.ReadBoolean(new(bool)) }},
+	{[]byte{0x03, 0x02, 0x07, 0x01}, func(p *Parser) error { return p.ReadBitString(new(BitString)) }},
+	{append([]byte{0x17, 0x11}, "910102030405+0100"...), func(p *Parser) error { return p.ReadUTCTime(new(time.Time)) }},
+	{append([]byte{0x17, 0x0b}, "9101020304Z"...), func(p *Parser) error { return p.ReadUTCTime(new(time.Time)) }},
+	{append([]byte{0x18, 0x11}, "20210102030405.5Z"...), func(p *Parser) error { return p.ReadGeneralizedTime(new(time.Time)) }},
+	{[]byte{0x31, 0x06, 0x02, 0x01, 0x02, 0x02, 0x01, 0x01}, func(p *Parser) error { return p.ReadSetOf(new(Parser)) }},
+	{[]byte{0x1f, 0x01, 0x00}, func(p *Parser) error { _, err := p.ReadAnyElement(new(Parser)); return err }},
+
+	// Malformed or unexpected elements.
+	{[]byte{0x02, 0x01, 0x01}, func(p *Parser) error { return p.ReadOctetString(new([]byte)) }},
+	{[]byte{0x22, 0x00}, func(p *Parser) error { return p.ReadOctetString(new([]byte)) }},
+	{[]byte{0x04, 0x05, 0x00}, func(p *Parser) error { return p.ReadOctetString(new([]byte)) }},
+	{[]byte{}, func(p *Parser) error { return p.ReadNull() }},
+	{[]byte{0x05, 0x01, 0x00}, func(p *Parser) error { return p.ReadNull() }},
+	{[]byte{0x02, 0x01, 0xff}, func(p *Parser) error { return p.ReadUint64(new(uint64)) }},
+	{[]byte{0x02, 0x09, 0x01, 0, 0, 0, 0, 0, 0, 0, 0}, func(p *Parser) error { return p.ReadInt64(new(int64)) }},
+	{[]byte{0x0c, 0x01, 0xff}, func(p *Parser) error { return p.ReadUTF8String(new(string)) }},
+	{[]byte{0x13, 0x01, '@'}, func(p *Parser) error { return p.ReadPrintableString(new(string)) }},
+	{[]byte{0x80, 0x01, 0x01}, func(p *Parser) error {
+		return p.ReadImplicit(ContextSpecific(1), func(p *Parser) error { return p.ReadInt64(new(int64)) })
+	}},
+	{[]byte{0x80, 0x01, 0x01}, func(p *Parser) error {
+		return p.ReadImplicit(ContextSpecific(0), func(p *Parser) error { return nil })
+	}},
+}
+
+func TestParserErrors(t *testing.T) {
+	for i, test := range parserErrorTests {
+		p := NewParser(test.in)
+		if err := test.read(p); err == nil {
+			t.Errorf("#%d: no error reading %x", i, test.in)
+		}
+		if !bytes.Equal(p.Bytes(), test.in) {
+			t.Errorf("#%d: failed read of %x consumed input", i, test.in)
+		}
+	}
+}
+
+func TestParserOptional(t *testing.T) {
+	b := NewBuilder(nil)
+	b.AddElement(ContextSpecific(1).Constructed(), func(b *Builder) {
+		b.AddInt64(7)
+	})
+	b.AddUint64(1<<64 - 1)
+	der, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewParser(der)
+	var inner Parser
+	if present, err := p.ReadOptionalElement(ContextSpecific(0).Constructed(), &inner); present || err != nil {
+		t.Fatalf("ReadOptionalElement of absent element = %v, %v", present, err)
+	}
+	if tag, ok := p.PeekTag(); !ok || tag != ContextSpecific(1).Constructed() {
+		t.Fatalf("PeekTag = %v, %v", tag, ok)
+	}
+	if present, err := p.ReadOptionalElement(ContextSpecific(1).Constructed(), &inner); !present || err != nil {
+		t.Fatalf("ReadOptionalElement of present element = %v, %v", present, err)
+	}
+	var n int64
+	if err := inner.ReadInt64(&n); err != nil || n != 7 {
+		t.Errorf("ReadInt64 = %d, %v", n, err)
+	}
+	var raw RawValue
+	if err := p.ReadRawValue(&raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw.Tag != TagInteger || !bytes.Equal(raw.FullBytes, der[len(der)-11:]) {
+		t.Errorf("ReadRawValue = %+v", raw)
+	}
+	var u uint64
+	if err := NewParser(raw.FullBytes).ReadUint64(&u); err != nil || u != 1<<64-1 {
+		t.Errorf("ReadUint64 = %d, %v", u, err)
+	}
+	if present, err := p.ReadOptionalElement(ContextSpecific(0), &inner); present || err != nil {
+		t.Errorf("ReadOptionalElement at end of input = %v, %v", present, err)
+	}
+}
@@ -15,6 +15,12 @@
 // For a specification, see
 // https://developers.google.com/protocol-buffers/docs/encoding.
 //
+// Append, Encode and Decode translate fixed-size values to and from
+// byte slices without going through an io.Reader or io.Writer. For
+// struct and array types, the translation is compiled once per type and
+// cached, so that encoding or decoding through a pointer does not
+// allocate.
+//
 // This package favors simplicity over efficiency. Clients that require
 // high-performance serialization, especially for large data structures,
 // should look at more advanced solutions such as the encoding/gob
@@ -41,10 +47,19 @@ type ByteOrder interface {
 	String() string
 }
 
-// LittleEndian is the little-endian implementation of ByteOrder.
+// AppendByteOrder specifies how to append 16-, 32-, or 64-bit unsigned
+// integers into a byte slice.
+type AppendByteOrder interface {
+	AppendUint16([]byte, uint16) []byte
+	AppendUint32([]byte, uint32) []byte
+	AppendUint64([]byte, uint64) []byte
+	String() string
+}
+
+// LittleEndian is the little-endian implementation of ByteOrder and AppendByteOrder.
 var LittleEndian littleEndian
 
-// BigEndian is the big-endian implementation of ByteOrder.
+// BigEndian is the big-endian implementation of ByteOrder and AppendByteOrder.
 var BigEndian bigEndian
 
 type littleEndian struct{}
@@ -91,6 +106,35 @@ func (littleEndian) PutUint64(b []byte, v uint64) {
 	b[7] = byte(v >> 56)
 }
 
+func (littleEndian) AppendUint16(b []byte, v uint16) []byte {
+	return append(b,
+		byte(v),
+		byte(v>>8),
+	)
+}
+
+func (littleEndian) AppendUint32(b []byte, v uint32) []byte {
+	return append(b,
+		byte(v),
+		byte(v>>8),
+		byte(v>>16),
+		byte(v>>24),
+	)
+}
+
+func (littleEndian) AppendUint64(b []byte, v uint64) []byte {
+	return append(b,
+		byte(v),
+		byte(v>>8),
+		byte(v>>16),
+		byte(v>>24),
+		byte(v>>32),
+		byte(v>>40),
+		byte(v>>48),
+		byte(v>>56),
+	)
+}
+
 func (littleEndian) String() string { return "LittleEndian" }
 
 func (littleEndian) GoString() string { return "binary.LittleEndian" }
@@ -139,6 +183,35 @@ func (bigEndian) PutUint64(b []byte, v uint64) {
 	b[7] = byte(v)
 }
 
+func (bigEndian) AppendUint16(b []byte, v uint16) []byte {
+	return append(b,
+		byte(v>>8),
+		byte(v),
+	)
+}
+
+func (bigEndian) AppendUint32(b []byte, v uint32) []byte {
+	return append(b,
+		byte(v>>24),
+		byte(v>>16),
+		byte(v>>8),
+		byte(v),
+	)
+}
+
+func (bigEndian) AppendUint64(b []byte, v uint64) []byte {
+	return append(b,
+		byte(v>>56),
+		byte(v>>48),
+		byte(v>>40),
+		byte(v>>32),
+		byte(v>>24),
+		byte(v>>16),
+		byte(v>>8),
+		byte(v),
+	)
+}
+
 func (bigEndian) String() string { return "BigEndian" }
 
 func (bigEndian) GoString() string { return "binary.BigEndian" }
@@ -166,79 +239,21 @@ func Read(r io.Reader, order ByteOrder, data interface{}) error {
 		if _, err := io.ReadFull(r, bs); err != nil {
 			return err
 		}
-		switch data := data.(type) {
-		case *bool:
-			*data = bs[0] != 0
-		case *int8:
-			*data = int8(bs[0])
-		case *uint8:
-			*data = bs[0]
-		case *int16:
-			*data = int16(order.Uint16(bs))
-		case *uint16:
-			*data = order.Uint16(bs)
-		case *int32:
-			*data = int32(order.Uint32(bs))
-		case *uint32:
-			*data = order.Uint32(bs)
-		case *int64:
-			*data = int64(order.Uint64(bs))
-		case *uint64:
-			*data = order.Uint64(bs)
-		case *float32:
-			*data = math.Float32frombits(order.Uint32(bs))
-		case *float64:
-			*data = math.Float64frombits(order.Uint64(bs))
-		case []bool:
-			for i, x := range bs { // Easier to loop over the input for 8-bit values.
-				data[i] = x != 0
-			}
-		case []int8:
-			for i, x := range bs {
-				data[i] = int8(x)
-			}
-		case []uint8:
-			copy(data, bs)
-		case []int16:
-			for i := range data {
-				data[i] = int16(order.Uint16(bs[2*i:]))
-			}
-		case []uint16:
-			for i := range data {
-				data[i] = order.Uint16(bs[2*i:])
-			}
-		case []int32:
-			for i := range data {
-				data[i] = int32(order.Uint32(bs[4*i:]))
-			}
-		case []uint32:
-			for i := range data {
-				data[i] = order.Uint32(bs[4*i:])
-			}
-		case []int64:
-			for i := range data {
-				data[i] = int64(order.Uint64(bs[8*i:]))
-			}
-		case []uint64:
-			for i := range data {
-				data[i] = order.Uint64(bs[8*i:])
-			}
-		case []float32:
-			for i := range data {
-				data[i] = math.Float32frombits(order.Uint32(bs[4*i:]))
-			}
-		case []float64:
-			for i := range data {
-				data[i] = math.Float64frombits(order.Uint64(bs[8*i:]))
-			}
-		default:
-			n = 0 // fast path doesn't apply
-		}
-		if n != 0 {
+		if decodeFast(bs, order, data) {
 			return nil
 		}
 	}
 
+	// Fast path for types with a cached codec.
+	if c, p, n := valueCodec(data, true); c != nil {
+		bs := make([]byte, c.size*n)
+		if _, err := io.ReadFull(r, bs); err != nil {
+			return err
+		}
+		c.decodeN(bs, order, p, n)
+		return nil
+	}
+
 	// Fallback to reflect-based decoding.
 	v := reflect.ValueOf(data)
 	size := -1
@@ -271,107 +286,20 @@ func Read(r io.Reader, order ByteOrder, data interface{}) error {
 func Write(w io.Writer, order ByteOrder, data interface{}) error {
 	// Fast path for basic types and slices.
 	if n := intDataSize(data); n != 0 {
-		bs := make([]byte, n)
-		switch v := data.(type) {
-		case *bool:
-			if *v {
-				bs[0] = 1
-			} else {
-				bs[0] = 0
-			}
-		case bool:
-			if v {
-				bs[0] = 1
-			} else {
-				bs[0] = 0
-			}
-		case []bool:
-			for i, x := range v {
-				if x {
-					bs[i] = 1
-				} else {
-					bs[i] = 0
-				}
-			}
-		case *int8:
-			bs[0] = byte(*v)
-		case int8:
-			bs[0] = byte(v)
-		case []int8:
-			for i, x := range v {
-				bs[i] = byte(x)
-			}
-		case *uint8:
-			bs[0] = *v
-		case uint8:
-			bs[0] = v
-		case []uint8:
-			bs = v
-		case *int16:
-			order.PutUint16(bs, uint16(*v))
-		case int16:
-			order.PutUint16(bs, uint16(v))
-		case []int16:
-			for i, x := range v {
-				order.PutUint16(bs[2*i:], uint16(x))
-			}
-		case *uint16:
-			order.PutUint16(bs, *v)
-		case uint16:
-			order.PutUint16(bs, v)
-		case []uint16:
-			for i, x := range v {
-				order.PutUint16(bs[2*i:], x)
-			}
-		case *int32:
-			order.PutUint32(bs, uint32(*v))
-		case int32:
-			order.PutUint32(bs, uint32(v))
-		case []int32:
-			for i, x := range v {
-				order.PutUint32(bs[4*i:], uint32(x))
-			}
-		case *uint32:
-			order.PutUint32(bs, *v)
-		case uint32:
-			order.PutUint32(bs, v)
-		case []uint32:
-			for i, x := range v {
-				order.PutUint32(bs[4*i:], x)
-			}
-		case *int64:
-			order.PutUint64(bs, uint64(*v))
-		case int64:
-			order.PutUint64(bs, uint64(v))
-		case []int64:
-			for i, x := range v {
-				order.PutUint64(bs[8*i:], uint64(x))
-			}
-		case *uint64:
-			order.PutUint64(bs, *v)
-		case uint64:
-			order.PutUint64(bs, v)
-		case []uint64:
-			for i, x := range v {
-				order.PutUint64(bs[8*i:], x)
-			}
-		case *float32:
-			order.PutUint32(bs, math.Float32bits(*v))
-		case float32:
-			order.PutUint32(bs, math.Float32bits(v))
-		case []float32:
-			for i, x := range v {
-				order.PutUint32(bs[4*i:], math.Float32bits(x))
-			}
-		case *float64:
-			order.PutUint64(bs, math.Float64bits(*v))
-		case float64:
-			order.PutUint64(bs, math.Float64bits(v))
-		case []float64:
-			for i, x := range v {
-				order.PutUint64(bs[8*i:], math.Float64bits(x))
-			}
+		if bs, ok := data.([]uint8); ok {
+			_, err := w.Write(bs)
+			return err
 		}
+		bs := make([]byte, n)
+		encodeFast(bs, order, data)
+		_, err := w.Write(bs)
+		return err
+	}
+
+	// Fast path for types with a cached codec.
+	if c, p, n := valueCodec(data, false); c != nil {
+		bs := make([]byte, c.size*n)
+		c.encodeN(bs, order, p, n)
 		_, err := w.Write(bs)
 		return err
 	}
@@ -389,6 +317,166 @@ func Write(w io.Writer, order ByteOrder, data interface{}) error {
 	return err
 }
 
+// Append appends the binary representation of data to buf.
+// Data must be a fixed-size value or a slice of fixed-size
+// values, or a pointer to such data, and is encoded as by Write.
+// Append returns the (possibly extended) buffer containing data or
+// an error.
+func Append(buf []byte, order ByteOrder, data interface{}) ([]byte, error) {
+	// Fast path for pointers to types with a cached codec.
+	if c, p := pointerCodec(data); c != nil {
+		buf, bs := grow(buf, c.size)
+		c.encode(bs, order, orderMode(order), p)
+		return buf, nil
+	}
+
+	// Fast path for basic types and slices.
+	if n := intDataSize(data); n != 0 {
+		buf, bs := grow(buf, n)
+		encodeFast(bs, order, data)
+		return buf, nil
+	}
+
+	// Fast path for types with a cached codec.
+	if c, p, n := valueCodec(data, false); c != nil {
+		buf, bs := grow(buf, c.size*n)
+		c.encodeN(bs, order, p, n)
+		return buf, nil
+	}
+
+	// Fallback to reflect-based encoding.
+	v := reflect.Indirect(reflect.ValueOf(data))
+	size := dataSize(v)
+	if size < 0 {
+		return nil, errors.New("binary.Append: invalid type " + reflect.TypeOf(data).String())
+	}
+	buf, bs := grow(buf, size)
+	e := &encoder{order: order, buf: bs}
+	e.value(v)
+	return buf, nil
+}
+
+// Encode encodes the binary representation of data into buf.
+// Data must be a fixed-size value or a slice of fixed-size
+// values, or a pointer to such data, and is encoded as by Write.
+// Encode returns the number of bytes written into buf, or an error
+// if buf is too small.
+//
+// Encoding through a pointer to a fixed-size value, or to a slice of
+// them, does not allocate.
+func Encode(buf []byte, order ByteOrder, data interface{}) (int, error) {
+	// Fast path for pointers to types with a cached codec.
+	if c, p := pointerCodec(data); c != nil {
+		if len(buf) < c.size {
+			return 0, errBufferTooSmall
+		}
+		c.encode(buf, order, orderMode(order), p)
+		return c.size, nil
+	}
+
+	// Fast path for basic types and slices.
+	if n := intDataSize(data); n != 0 {
+		if len(buf) < n {
+			return 0, errBufferTooSmall
+		}
+		encodeFast(buf[:n], order, data)
+		return n, nil
+	}
+
+	// Fast path for types with a cached codec.
+	if c, p, n := valueCodec(data, false); c != nil {
+		size := c.size * n
+		if len(buf) < size {
+			return 0, errBufferTooSmall
+		}
+		c.encodeN(buf, order, p, n)
+		return size, nil
+	}
+
+	// Fallback to reflect-based encoding.
+	v := reflect.Indirect(reflect.ValueOf(data))
+	size := dataSize(v)
+	if size < 0 {
+		return 0, errors.New("binary.Encode: invalid type " + reflect.TypeOf(data).String())
+	}
+	if len(buf) < size {
+		return 0, errBufferTooSmall
+	}
+	e := &encoder{order: order, buf: buf[:size]}
+	e.value(v)
+	return size, nil
+}
+
+// Decode decodes binary data from buf into data, which must be a
+// pointer to a fixed-size value or a slice of fixed-size values.
+// The data is decoded as by Read. Decode returns the number of bytes
+// consumed from buf, or an error if buf is too small.
+//
+// Decoding through a pointer does not allocate.
+func Decode(buf []byte, order ByteOrder, data interface{}) (int, error) {
+	// Fast path for pointers to types with a cached codec.
+	if c, p := pointerCodec(data); c != nil {
+		if len(buf) < c.size {
+			return 0, errBufferTooSmall
+		}
+		c.decode(buf, order, orderMode(order), p)
+		return c.size, nil
+	}
+
+	// Fast path for basic types and slices.
+	if n := intDataSize(data); n != 0 {
+		if len(buf) < n {
+			return 0, errBufferTooSmall
+		}
+		if decodeFast(buf[:n], order, data) {
+			return n, nil
+		}
+	}
+
+	// Fast path for types with a cached codec.
+	if c, p, n := valueCodec(data, true); c != nil {
+		size := c.size * n
+		if len(buf) < size {
+			return 0, errBufferTooSmall
+		}
+		c.decodeN(buf, order, p, n)
+		return size, nil
+	}
+
+	// Fallback to reflect-based decoding.
+	v := reflect.ValueOf(data)
+	size := -1
+	switch v.Kind() {
+	case reflect.Ptr:
+		v = v.Elem()
+		size = dataSize(v)
+	case reflect.Slice:
+		size = dataSize(v)
+	}
+	if size < 0 {
+		return 0, errors.New("binary.Decode: invalid type " + reflect.TypeOf(data).String())
+	}
+	if len(buf) < size {
+		return 0, errBufferTooSmall
+	}
+	d := &decoder{order: order, buf: buf[:size]}
+	d.value(v)
+	return size, nil
+}
+
+var errBufferTooSmall = errors.New("buffer too small")
+
+// grow returns buf extended by n bytes, and the extension.
+func grow(buf []byte, n int) (full, tail []byte) {
+	if cap(buf)-len(buf) < n {
+		nbuf := make([]byte, len(buf), 2*cap(buf)+n)
+		copy(nbuf, buf)
+		buf = nbuf
+	}
+	full = buf[:len(buf)+n]
+	return full, full[len(buf):]
+}
+
 // Size returns how many bytes Write would generate to encode the value v, which
 // must be a fixed-size value or a slice of fixed-size values, or a pointer to such data.
 // If v is neither of these, Size returns -1.
@@ -694,6 +782,186 @@ func (e *encoder) skip(v reflect.Value) {
 	e.offset += n
 }
 
+// decodeFast decodes bs into data, which must be one of the types
+// accepted by intDataSize, and reports whether it did. It does not
+// decode into non-pointer values.
+func decodeFast(bs []byte, order ByteOrder, data interface{}) bool {
+	switch data := data.(type) {
+	case *bool:
+		*data = bs[0] != 0
+	case *int8:
+		*data = int8(bs[0])
+	case *uint8:
+		*data = bs[0]
+	case *int16:
+		*data = int16(order.Uint16(bs))
+	case *uint16:
+		*data = order.Uint16(bs)
+	case *int32:
+		*data = int32(order.Uint32(bs))
+	case *uint32:
+		*data = order.Uint32(bs)
+	case *int64:
+		*data = int64(order.Uint64(bs))
+	case *uint64:
+		*data = order.Uint64(bs)
+	case *float32:
+		*data = math.Float32frombits(order.Uint32(bs))
+	case *float64:
+		*data = math.Float64frombits(order.Uint64(bs))
+	case []bool:
+		for i, x := range bs { // Easier to loop over the input for 8-bit values.
+			data[i] = x != 0
+		}
+	case []int8:
+		for i, x := range bs {
+			data[i] = int8(x)
+		}
+	case []uint8:
+		copy(data, bs)
+	case []int16:
+		for i := range data {
+			data[i] = int16(order.Uint16(bs[2*i:]))
+		}
+	case []uint16:
+		for i := range data {
+			data[i] = order.Uint16(bs[2*i:])
+		}
+	case []int32:
+		for i := range data {
+			data[i] = int32(order.Uint32(bs[4*i:]))
+		}
+	case []uint32:
+		for i := range data {
+			data[i] = order.Uint32(bs[4*i:])
+		}
+	case []int64:
+		for i := range data {
+			data[i] = int64(order.Uint64(bs[8*i:]))
+		}
+	case []uint64:
+		for i := range data {
+			data[i] = order.Uint64(bs[8*i:])
+		}
+	case []float32:
+		for i := range data {
+			data[i] = math.Float32frombits(order.Uint32(bs[4*i:]))
+		}
+	case []float64:
+		for i := range data {
+			data[i] = math.Float64frombits(order.Uint64(bs[8*i:]))
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// encodeFast encodes data, which must be one of the types accepted by
+// intDataSize, into bs.
+func encodeFast(bs []byte, order ByteOrder, data interface{}) {
+	switch v := data.(type) {
+	case *bool:
+		if *v {
+			bs[0] = 1
+		} else {
+			bs[0] = 0
+		}
+	case bool:
+		if v {
+			bs[0] = 1
+		} else {
+			bs[0] = 0
+		}
+	case []bool:
+		for i, x := range v {
+			if x {
+				bs[i] = 1
+			} else {
+				bs[i] = 0
+			}
+		}
+	case *int8:
+		bs[0] = byte(*v)
+	case int8:
+		bs[0] = byte(v)
+	case []int8:
+		for i, x := range v {
+			bs[i] = byte(x)
+		}
+	case *uint8:
+		bs[0] = *v
+	case uint8:
+		bs[0] = v
+	case []uint8:
+		copy(bs, v)
+	case *int16:
+		order.PutUint16(bs, uint16(*v))
+	case int16:
+		order.PutUint16(bs, uint16(v))
+	case []int16:
+		for i, x := range v {
+			order.PutUint16(bs[2*i:], uint16(x))
+		}
+	case *uint16:
+		order.PutUint16(bs, *v)
+	case uint16:
+		order.PutUint16(bs, v)
+	case []uint16:
+		for i, x := range v {
+			order.PutUint16(bs[2*i:], x)
+		}
+	case *int32:
+		order.PutUint32(bs, uint32(*v))
+	case int32:
+		order.PutUint32(bs, uint32(v))
+	case []int32:
+		for i, x := range v {
+			order.PutUint32(bs[4*i:], uint32(x))
+		}
+	case *uint32:
+		order.PutUint32(bs, *v)
+	case uint32:
+		order.PutUint32(bs, v)
+	case []uint32:
+		for i, x := range v {
+			order.PutUint32(bs[4*i:], x)
+		}
+	case *int64:
+		order.PutUint64(bs, uint64(*v))
+	case int64:
+		order.PutUint64(bs, uint64(v))
+	case []int64:
+		for i, x := range v {
+			order.PutUint64(bs[8*i:], uint64(x))
+		}
+	case *uint64:
+		order.PutUint64(bs, *v)
+	case uint64:
+		order.PutUint64(bs, v)
+	case []uint64:
+		for i, x := range v {
+			order.PutUint64(bs[8*i:], x)
+		}
+	case *float32:
+		order.PutUint32(bs, math.Float32bits(*v))
+	case float32:
+		order.PutUint32(bs, math.Float32bits(v))
+	case []float32:
+		for i, x := range v {
+			order.PutUint32(bs[4*i:], math.Float32bits(x))
+		}
+	case *float64:
+		order.PutUint64(bs, math.Float64bits(*v))
+	case float64:
+		order.PutUint64(bs, math.Float64bits(v))
+	case []float64:
+		for i, x := range v {
+			order.PutUint64(bs[8*i:], math.Float64bits(x))
+		}
+	}
+}
+
 // intDataSize returns the size of the data required to represent the data when encoded.
 // It returns zero if the type cannot be implemented by the fast path in Read or Write.
 func intDataSize(data interface{}) int {
@@ -13,6 +13,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"unsafe"
 )
 
 type Struct struct {
@@ -480,6 +481,155 @@ func testReadInvalidDestination(t *testing.T, order ByteOrder) {
 	}
 }
 
+func TestAppendByteOrder(t *testing.T) {
+	for _, order := range []interface {
+		ByteOrder
+		AppendByteOrder
+	}{LittleEndian, BigEndian} {
+		prefix := []byte{0xff}
+		want := make([]byte, 15)
+		want[0] = 0xff
+		order.PutUint16(want[1:], 0x0102)
+		order.PutUint32(want[3:], 0x03040506)
+		order.PutUint64(want[7:], 0x0708090a0b0c0d0e)
+
+		got := order.AppendUint16(prefix, 0x0102)
+		got = order.AppendUint32(got, 0x03040506)
+		got = order.AppendUint64(got, 0x0708090a0b0c0d0e)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%v: got %x, want %x", order, got, want)
+		}
+	}
+}
+
+func TestAppend(t *testing.T) {
+	for _, tt := range []struct {
+		order ByteOrder
+		want  []byte
+	}{{LittleEndian, little}, {BigEndian, big}} {
+		for _, data := range []interface{}{s, &s} {
+			prefix := []byte{0xee}
+			got, err := Append(prefix, tt.order, data)
+			checkResult(t, "Append", tt.order, err, got, append([]byte{0xee}, tt.want...))
+		}
+	}
+	got, err := Append(nil, BigEndian, res)
+	checkResult(t, "AppendSlice", BigEndian, err, got, src)
+	if _, err := Append(nil, LittleEndian, []int{1}); err == nil || err.Error() != "binary.Append: invalid type []int" {
+		t.Errorf("Append([]int) = %v", err)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	for _, tt := range []struct {
+		order ByteOrder
+		want  []byte
+	}{{LittleEndian, little}, {BigEndian, big}} {
+		buf := make([]byte, len(tt.want)+1)
+		n, err := Encode(buf, tt.order, &s)
+		checkResult(t, "Encode", tt.order, err, buf[:n], tt.want)
+
+		var s2 Struct
+		n, err = Decode(buf, tt.order, &s2)
+		checkResult(t, "Decode", tt.order, err, s2, s)
+		if n != len(tt.want) {
+			t.Errorf("Decode consumed %d bytes, want %d", n, len(tt.want))
+		}
+
+		if _, err := Encode(buf[:len(tt.want)-1], tt.order, &s); err != errBufferTooSmall {
+			t.Errorf("Encode into short buffer: got %v, want %v", err, errBufferTooSmall)
+		}
+		if _, err := Decode(tt.want[:len(tt.want)-1], tt.order, &s2); err != errBufferTooSmall {
+			t.Errorf("Decode of short buffer: got %v, want %v", err, errBufferTooSmall)
+		}
+	}
+
+	// Slices of structs, with and without a pointer to the slice.
+	in := []BlankFields{{A: 1, B: 2, C: 3}, {A: 4, B: 5, C: 6}}
+	buf, err := Append(nil, BigEndian, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]BlankFields, 2)
+	if n, err := Decode(buf, BigEndian, &out); err != nil || n != len(buf) {
+		t.Fatalf("Decode = %d, %v", n, err)
+	}
+	for i := range in {
+		if in[i].A != out[i].A || in[i].B != out[i].B || in[i].C != out[i].C {
+			t.Errorf("element %d: got %+v, want %+v", i, out[i], in[i])
+		}
+	}
+
+	// Types without a codec use the reflect-based path.
+	var u Unexported
+	if n, err := Encode(buf, LittleEndian, Unexported{a: 7}); err != nil || n != 4 || buf[0] != 7 {
+		t.Errorf("Encode(Unexported) = %d, %v", n, err)
+	}
+	if _, err := Decode(buf, LittleEndian, u); err == nil || err.Error() != "binary.Decode: invalid type binary.Unexported" {
+		t.Errorf("Decode into non-pointer: got %v", err)
+	}
+}
+
+func TestEncodeDecodeAllocs(t *testing.T) {
+	buf := make([]byte, Size(&s))
+	var s2 Struct
+	slice := make([]Struct, 4)
+	sliceBuf := make([]byte, Size(slice))
+	allocs := testing.AllocsPerRun(100, func() {
+		Encode(buf, BigEndian, &s)
+		Decode(buf, BigEndian, &s2)
+		Encode(sliceBuf, LittleEndian, &slice)
+		Decode(sliceBuf, LittleEndian, &slice)
+		buf, _ = Append(buf[:0], BigEndian, &s)
+	})
+	if allocs != 0 {
+		t.Errorf("got %v allocs, want 0", allocs)
+	}
+}
+
+// wrappedOrder is a ByteOrder other than LittleEndian and BigEndian.
+type wrappedOrder struct{ ByteOrder }
+
+type LargeElem struct {
+	A uint16
+	B bool
+	_ [3]byte
+	C int64
+}
+
+type Large struct {
+	Words  [100]uint32
+	Elems  [40]LargeElem
+	Bools  [5]bool
+	Floats [2]complex64
+}
+
+func TestEncodeDecodeLarge(t *testing.T) {
+	var in Large
+	for i := range in.Words {
+		in.Words[i] = uint32(i) * 0x01010101
+	}
+	for i := range in.Elems {
+		in.Elems[i] = LargeElem{A: uint16(i) << 4, B: i%3 == 0, C: -int64(i) << 40}
+	}
+	in.Bools = [5]bool{true, false, true, true, false}
+	in.Floats = [2]complex64{1 + 2i, -3.5i}
+
+	for _, order := range []ByteOrder{LittleEndian, BigEndian, wrappedOrder{BigEndian}} {
+		var want bytes.Buffer
+		if err := Write(&want, order, &in); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, want.Len())
+		n, err := Encode(buf, order, &in)
+		checkResult(t, "Encode", order, err, buf[:n], want.Bytes())
+
+		var out Large
+		_, err = Decode(buf, order, &out)
+		checkResult(t, "Decode", order, err, out, in)
+	}
+}
+
 type byteSliceReader struct {
 	remain []byte
 }
@@ -729,3 +879,106 @@ func BenchmarkWriteSlice1000Uint8s(b *testing.B) {
 		Write(w, BigEndian, slice)
 	}
 }
+
+// header is a typical fixed-size wire protocol header, used to compare
+// Encode and Decode with hand-written code.
+type header struct {
+	Magic   uint32
+	Version uint16
+	Flags   uint16
+	Length  uint64
+	ID      [16]byte
+}
+
+var hdr = header{Magic: 0xcafef00d, Version: 2, Flags: 0x8001, Length: 1 << 40, ID: [16]byte{1, 2, 3}}
+
+// TestHeaderHotPath checks that once a pointer type has been encoded,
+// Encode and Decode find its codec with a single table lookup, so
+// that BenchmarkEncodeHeader and BenchmarkDecodeHeader measure the
+// plan, not the lookup.
+func TestHeaderHotPath(t *testing.T) {
+	buf := make([]byte, Size(&hdr))
+	if _, err := Encode(buf, BigEndian, &hdr); err != nil {
+		t.Fatal(err)
+	}
+	var h header
+	if c, p := pointerCodec(&h); c == nil || p != unsafe.Pointer(&h) {
+		t.Fatalf("pointerCodec(*header) = %v, %v; want the cached codec and &h", c, p)
+	}
+	if _, err := Decode(buf, BigEndian, &h); err != nil {
+		t.Fatal(err)
+	}
+	if h != hdr {
+		t.Errorf("Decode = %+v, want %+v", h, hdr)
+	}
+}
+
+func BenchmarkEncodeStruct(b *testing.B) {
+	buf := make([]byte, Size(&s))
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		Encode(buf, BigEndian, &s)
+	}
+}
+
+func BenchmarkDecodeStruct(b *testing.B) {
+	buf := make([]byte, Size(&s))
+	Encode(buf, BigEndian, &s)
+	var t Struct
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		Decode(buf, BigEndian, &t)
+	}
+}
+
+func BenchmarkAppendStruct(b *testing.B) {
+	buf := make([]byte, 0, Size(&s))
+	b.SetBytes(int64(cap(buf)))
+	for i := 0; i < b.N; i++ {
+		Append(buf, BigEndian, &s)
+	}
+}
+
+func BenchmarkEncodeHeader(b *testing.B) {
+	buf := make([]byte, Size(&hdr))
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		Encode(buf, BigEndian, &hdr)
+	}
+}
+
+func BenchmarkEncodeHeaderByHand(b *testing.B) {
+	buf := make([]byte, Size(&hdr))
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		BigEndian.PutUint32(buf[0:], hdr.Magic)
+		BigEndian.PutUint16(buf[4:], hdr.Version)
+		BigEndian.PutUint16(buf[6:], hdr.Flags)
+		BigEndian.PutUint64(buf[8:], hdr.Length)
+		copy(buf[16:], hdr.ID[:])
+	}
+}
+
+func BenchmarkDecodeHeader(b *testing.B) {
+	buf := make([]byte, Size(&hdr))
+	Encode(buf, BigEndian, &hdr)
+	var h header
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		Decode(buf, BigEndian, &h)
+	}
+}
+
+func BenchmarkDecodeHeaderByHand(b *testing.B) {
+	buf := make([]byte, Size(&hdr))
+	Encode(buf, BigEndian, &hdr)
+	var h header
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		h.Magic = BigEndian.Uint32(buf[0:])
+		h.Version = BigEndian.Uint16(buf[4:])
+		h.Flags = BigEndian.Uint16(buf[6:])
+		h.Length = BigEndian.Uint64(buf[8:])
+		copy(h.ID[:], buf[16:])
+	}
+}
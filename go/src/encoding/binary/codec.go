@@ -0,0 +1,598 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package binary
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// A codec encodes and decodes values of one fixed-size type, reading
+// and writing memory directly instead of through reflect.Value.
+//
+// In the native byte order, a value is encoded by copying its memory
+// into the encoding, which is all there is to do for a type without
+// padding. In any other byte order, its single bytes are copied and
+// its multi-byte values are encoded one by one, through the ByteOrder's
+// methods unless it is LittleEndian or BigEndian. Booleans and blank
+// fields are fixed up last.
+//
+// Codecs are only compiled for types whose fields are all exported or
+// blank, so that decoding through a codec behaves exactly like the
+// reflect-based decoder, which cannot set unexported fields.
+type codec struct {
+	size   int     // encoded size of a value
+	stride uintptr // size of a value in memory
+	plan
+}
+
+// A run is n consecutive values of one kind, at offset mem of a value
+// in memory and at offset wire of its encoding.
+type run struct {
+	mem  uintptr
+	wire int
+	n    int
+}
+
+// A word is a multi-byte value width bytes wide.
+type word struct {
+	mem   uintptr
+	wire  int
+	width int
+}
+
+// A plan describes how a value is laid out in memory and in its
+// encoding.
+type plan struct {
+	spans []run  // bytes that are copied as they are in the native order
+	bytes []run  // single-byte values, also included in spans
+	words []word // multi-byte values, also included in spans
+	more  *more  // the rest, which most types do not need
+}
+
+// more is the part of a plan that most types do not need, kept apart
+// so that encoding a value that needs none of it costs nothing.
+type more struct {
+	wordRuns []wordRun // arrays of more than maxUnroll words, also included in spans
+	bools    []run     // booleans, also included in spans
+	pads     []run     // blank fields of n bytes: zero when encoding, skipped when decoding
+	arrays   []arrayRun
+}
+
+// A wordRun is a run of multi-byte values width bytes wide.
+type wordRun struct {
+	run
+	width int
+}
+
+// An arrayRun is n struct values encoded by plan, stride bytes apart
+// in memory and size bytes apart in the encoding. Arrays of structs
+// that add no more than maxUnroll entries to the enclosing plan are
+// unrolled into it instead.
+type arrayRun struct {
+	run
+	stride uintptr
+	size   int
+	plan   *plan
+}
+
+// maxUnroll is the largest number of entries an array is unrolled
+// into.
+const maxUnroll = 32
+
+var codecCache sync.Map // map[reflect.Type]*codec
+
+// codecTable is a direct-mapped cache in front of codecCache, indexed
+// by the address of the type. Looking a type up in it avoids hashing
+// the reflect.Type interface, which costs more than encoding a small
+// struct does.
+var codecTable [256]atomic.Value // of codecEntry
+
+type codecEntry struct {
+	key uintptr // address of the type
+	c   *codec
+}
+
+// cachedCodec returns the codec for t, or nil if t has none.
+func cachedCodec(t reflect.Type) *codec {
+	key := reflect.ValueOf(t).Pointer()
+	slot := &codecTable[key>>3%uintptr(len(codecTable))]
+	if e, ok := slot.Load().(codecEntry); ok && e.key == key {
+		return e.c
+	}
+	var c *codec
+	if ci, ok := codecCache.Load(t); ok {
+		c = ci.(*codec)
+	} else {
+		c = &codec{size: sizeof(t), stride: t.Size()}
+		if !c.add(t, 0, 0, 1) {
+			c = nil
+		}
+		ci, _ := codecCache.LoadOrStore(t, c)
+		c = ci.(*codec)
+	}
+	slot.Store(codecEntry{key, c})
+	return c
+}
+
+// add adds to pl the runs encoding n consecutive values of type t at
+// offset mem in memory and wire in the encoding. It reports false if t
+// is not fixed-size or has unexported fields.
+func (pl *plan) add(t reflect.Type, mem uintptr, wire, n int) bool {
+	switch t.Kind() {
+	case reflect.Bool:
+		pl.addBytes(mem, wire, n)
+		m := pl.rest()
+		m.bools = appendRun(m.bools, run{mem, wire, n}, 1)
+	case reflect.Int8, reflect.Uint8:
+		pl.addBytes(mem, wire, n)
+	case reflect.Int16, reflect.Uint16:
+		pl.addWords(mem, wire, n, 2)
+	case reflect.Int32, reflect.Uint32, reflect.Float32:
+		pl.addWords(mem, wire, n, 4)
+	case reflect.Int64, reflect.Uint64, reflect.Float64:
+		pl.addWords(mem, wire, n, 8)
+	case reflect.Complex64:
+		pl.addWords(mem, wire, 2*n, 4)
+	case reflect.Complex128:
+		pl.addWords(mem, wire, 2*n, 8)
+
+	case reflect.Array:
+		// Elements of nested arrays are consecutive too.
+		return pl.add(t.Elem(), mem, wire, n*t.Len())
+
+	case reflect.Struct:
+		if n != 1 {
+			elem := new(plan)
+			if !elem.add(t, 0, 0, 1) {
+				return false
+			}
+			size := sizeof(t)
+			if n*elem.runs() > maxUnroll {
+				m := pl.rest()
+				m.arrays = append(m.arrays, arrayRun{run{mem, wire, n}, t.Size(), size, elem})
+				return true
+			}
+			for i := 0; i < n; i++ {
+				pl.add(t, mem+uintptr(i)*t.Size(), wire+i*size, 1)
+			}
+			return true
+		}
+		for i, nf := 0, t.NumField(); i < nf; i++ {
+			f := t.Field(i)
+			size := sizeof(f.Type)
+			if size < 0 {
+				return false
+			}
+			if f.Name == "_" {
+				if size > 0 {
+					m := pl.rest()
+					m.pads = append(m.pads, run{wire: wire, n: size})
+				}
+			} else if !f.IsExported() || !pl.add(f.Type, mem+f.Offset, wire, 1) {
+				return false
+			}
+			wire += size
+		}
+
+	default:
+		return false
+	}
+	return true
+}
+
+// addBytes adds n consecutive single-byte values.
+func (pl *plan) addBytes(mem uintptr, wire, n int) {
+	pl.spans = appendRun(pl.spans, run{mem, wire, n}, 1)
+	pl.bytes = appendRun(pl.bytes, run{mem, wire, n}, 1)
+}
+
+// addWords adds n consecutive values width bytes wide.
+func (pl *plan) addWords(mem uintptr, wire, n, width int) {
+	pl.spans = appendRun(pl.spans, run{mem, wire, n * width}, 1)
+	if n > maxUnroll {
+		m := pl.rest()
+		m.wordRuns = append(m.wordRuns, wordRun{run{mem, wire, n}, width})
+		return
+	}
+	for i := 0; i < n; i++ {
+		pl.words = append(pl.words, word{mem + uintptr(i*width), wire + i*width, width})
+	}
+}
+
+// rest returns pl.more, allocating it if needed.
+func (pl *plan) rest() *more {
+	if pl.more == nil {
+		pl.more = new(more)
+	}
+	return pl.more
+}
+
+// appendRun appends r, a run of values width bytes wide, to runs,
+// extending the last run instead if r follows it both in memory and in
+// the encoding.
+func appendRun(runs []run, r run, width int) []run {
+	if r.n == 0 {
+		return runs
+	}
+	if len(runs) > 0 {
+		last := &runs[len(runs)-1]
+		if last.mem+uintptr(last.n*width) == r.mem && last.wire+last.n*width == r.wire {
+			last.n += r.n
+			return runs
+		}
+	}
+	return append(runs, r)
+}
+
+// runs returns the number of runs in pl.
+func (pl *plan) runs() int {
+	n := len(pl.spans) + len(pl.bytes) + len(pl.words)
+	if m := pl.more; m != nil {
+		n += len(m.wordRuns) + len(m.bools) + len(m.pads) + len(m.arrays)
+	}
+	return n
+}
+
+// eface is the layout of an interface{} value.
+type eface struct {
+	typ  unsafe.Pointer
+	data unsafe.Pointer
+}
+
+// ptrCodecTable is a direct-mapped cache of the codecs of the values
+// that pointers point to, indexed by the type word of the interface
+// holding the pointer. Looking data up in it takes neither reflection
+// nor hashing.
+var ptrCodecTable [256]unsafe.Pointer // of *ptrCodec
+
+type ptrCodec struct {
+	typ unsafe.Pointer // type word of the pointer type
+	c   *codec         // codec of the element type, or nil
+}
+
+// pointerCodec returns the codec for data and the pointer it holds if
+// data is a non-nil pointer to a value of a type whose codec
+// valueCodec has cached in ptrCodecTable, or a nil codec otherwise. It
+// is small enough to be inlined, so that Append, Encode and Decode can
+// check it before any other fast path.
+func pointerCodec(data interface{}) (*codec, unsafe.Pointer) {
+	e := (*eface)(unsafe.Pointer(&data))
+	pc := (*ptrCodec)(atomic.LoadPointer(&ptrCodecTable[uintptr(e.typ)>>3%uintptr(len(ptrCodecTable))]))
+	if pc == nil || pc.typ != e.typ || e.data == nil {
+		return nil, nil
+	}
+	return pc.c, e.data
+}
+
+// valueCodec returns the codec for data together with a pointer to the
+// first value to encode or decode and the number of values, or a nil
+// codec if data has none. Data may be a pointer to a fixed-size value,
+// a slice of fixed-size values or a pointer to such a slice and, if
+// not decoding, a fixed-size value, which is copied.
+func valueCodec(data interface{}, decoding bool) (c *codec, p unsafe.Pointer, n int) {
+	e := (*eface)(unsafe.Pointer(&data))
+	slot := &ptrCodecTable[uintptr(e.typ)>>3%uintptr(len(ptrCodecTable))]
+	if pc := (*ptrCodec)(atomic.LoadPointer(slot)); pc != nil && pc.typ == e.typ {
+		if pc.c == nil || e.data == nil {
+			return nil, nil, 0
+		}
+		// A pointer is stored directly in the interface.
+		return pc.c, e.data, 1
+	}
+
+	v := reflect.ValueOf(data)
+	switch v.Kind() {
+	case reflect.Ptr:
+		if elem := v.Type().Elem(); elem.Kind() != reflect.Slice {
+			c := cachedCodec(elem)
+			atomic.StorePointer(slot, unsafe.Pointer(&ptrCodec{typ: e.typ, c: c}))
+			if c == nil || v.IsNil() {
+				return nil, nil, 0
+			}
+			return c, unsafe.Pointer(v.Pointer()), 1
+		}
+		if v.IsNil() {
+			return nil, nil, 0
+		}
+		v = v.Elem()
+	case reflect.Slice:
+	case reflect.Invalid:
+		return nil, nil, 0
+	default:
+		if decoding {
+			return nil, nil, 0
+		}
+		c := cachedCodec(v.Type())
+		if c == nil {
+			return nil, nil, 0
+		}
+		pv := reflect.New(v.Type())
+		pv.Elem().Set(v)
+		return c, unsafe.Pointer(pv.Pointer()), 1
+	}
+	return cachedCodec(v.Type().Elem()), unsafe.Pointer(v.Pointer()), v.Len()
+}
+
+// How the multi-byte values of a plan are encoded, depending on the
+// byte order.
+const (
+	orderNative = iota // as they are in memory
+	orderLittle        // by LittleEndian
+	orderBig           // by BigEndian
+	orderOther         // by the ByteOrder's methods
+)
+
+// littleMode and bigMode are the modes of LittleEndian and BigEndian
+// on this machine.
+var littleMode, bigMode = func() (int, int) {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return orderNative, orderBig
+	}
+	return orderLittle, orderNative
+}()
+
+// orderMode returns the mode in which order encodes multi-byte values.
+func orderMode(order ByteOrder) int {
+	switch order.(type) {
+	case littleEndian:
+		return littleMode
+	case bigEndian:
+		return bigMode
+	}
+	return orderOther
+}
+
+// encodeN encodes the n values at p into b.
+func (c *codec) encodeN(b []byte, order ByteOrder, p unsafe.Pointer, n int) {
+	mode := orderMode(order)
+	for i := 0; i < n; i++ {
+		c.encode(b[i*c.size:], order, mode, unsafe.Add(p, uintptr(i)*c.stride))
+	}
+}
+
+// decodeN decodes n values from b into p.
+func (c *codec) decodeN(b []byte, order ByteOrder, p unsafe.Pointer, n int) {
+	mode := orderMode(order)
+	for i := 0; i < n; i++ {
+		c.decode(b[i*c.size:], order, mode, unsafe.Add(p, uintptr(i)*c.stride))
+	}
+}
+
+// bytesAt returns the n bytes at p as a slice.
+func bytesAt(p unsafe.Pointer, n int) []byte {
+	return unsafe.Slice((*byte)(p), n)
+}
+
+// encode encodes the value at p into b.
+func (pl *plan) encode(b []byte, order ByteOrder, mode int, p unsafe.Pointer) {
+	if mode == orderNative {
+		for _, r := range pl.spans {
+			copy(b[r.wire:r.wire+r.n], bytesAt(unsafe.Add(p, r.mem), r.n))
+		}
+	} else {
+		for _, r := range pl.bytes {
+			copy(b[r.wire:r.wire+r.n], bytesAt(unsafe.Add(p, r.mem), r.n))
+		}
+		switch mode {
+		case orderLittle:
+			for _, w := range pl.words {
+				x, q := b[w.wire:], unsafe.Add(p, w.mem)
+				switch w.width {
+				case 2:
+					LittleEndian.PutUint16(x, *(*uint16)(q))
+				case 4:
+					LittleEndian.PutUint32(x, *(*uint32)(q))
+				case 8:
+					LittleEndian.PutUint64(x, *(*uint64)(q))
+				}
+			}
+		case orderBig:
+			for _, w := range pl.words {
+				x, q := b[w.wire:], unsafe.Add(p, w.mem)
+				switch w.width {
+				case 2:
+					BigEndian.PutUint16(x, *(*uint16)(q))
+				case 4:
+					BigEndian.PutUint32(x, *(*uint32)(q))
+				case 8:
+					BigEndian.PutUint64(x, *(*uint64)(q))
+				}
+			}
+		default:
+			for _, w := range pl.words {
+				put(b[w.wire:], order, unsafe.Add(p, w.mem), w.width)
+			}
+		}
+	}
+	if pl.more != nil {
+		pl.more.encode(b, order, mode, p)
+	}
+}
+
+func (m *more) encode(b []byte, order ByteOrder, mode int, p unsafe.Pointer) {
+	if mode != orderNative {
+		for _, r := range m.wordRuns {
+			for i := 0; i < r.n; i++ {
+				x, q := b[r.wire+i*r.width:], unsafe.Add(p, r.mem+uintptr(i*r.width))
+				switch mode {
+				case orderLittle:
+					putLittle(x, q, r.width)
+				case orderBig:
+					putBig(x, q, r.width)
+				default:
+					put(x, order, q, r.width)
+				}
+			}
+		}
+	}
+	for _, r := range m.bools {
+		for i, x := range b[r.wire : r.wire+r.n] {
+			if x != 0 {
+				b[r.wire+i] = 1
+			}
+		}
+	}
+	for _, r := range m.pads {
+		zero := b[r.wire : r.wire+r.n]
+		for i := range zero {
+			zero[i] = 0
+		}
+	}
+	for _, a := range m.arrays {
+		for i := 0; i < a.n; i++ {
+			a.plan.encode(b[a.wire+i*a.size:], order, mode, unsafe.Add(p, a.mem+uintptr(i)*a.stride))
+		}
+	}
+}
+
+// decode decodes b into the value at p.
+func (pl *plan) decode(b []byte, order ByteOrder, mode int, p unsafe.Pointer) {
+	if mode == orderNative {
+		for _, r := range pl.spans {
+			copy(bytesAt(unsafe.Add(p, r.mem), r.n), b[r.wire:r.wire+r.n])
+		}
+	} else {
+		for _, r := range pl.bytes {
+			copy(bytesAt(unsafe.Add(p, r.mem), r.n), b[r.wire:r.wire+r.n])
+		}
+		switch mode {
+		case orderLittle:
+			for _, w := range pl.words {
+				q, x := unsafe.Add(p, w.mem), b[w.wire:]
+				switch w.width {
+				case 2:
+					*(*uint16)(q) = LittleEndian.Uint16(x)
+				case 4:
+					*(*uint32)(q) = LittleEndian.Uint32(x)
+				case 8:
+					*(*uint64)(q) = LittleEndian.Uint64(x)
+				}
+			}
+		case orderBig:
+			for _, w := range pl.words {
+				q, x := unsafe.Add(p, w.mem), b[w.wire:]
+				switch w.width {
+				case 2:
+					*(*uint16)(q) = BigEndian.Uint16(x)
+				case 4:
+					*(*uint32)(q) = BigEndian.Uint32(x)
+				case 8:
+					*(*uint64)(q) = BigEndian.Uint64(x)
+				}
+			}
+		default:
+			for _, w := range pl.words {
+				get(unsafe.Add(p, w.mem), order, b[w.wire:], w.width)
+			}
+		}
+	}
+	if pl.more != nil {
+		pl.more.decode(b, order, mode, p)
+	}
+}
+
+func (m *more) decode(b []byte, order ByteOrder, mode int, p unsafe.Pointer) {
+	if mode != orderNative {
+		for _, r := range m.wordRuns {
+			for i := 0; i < r.n; i++ {
+				q, x := unsafe.Add(p, r.mem+uintptr(i*r.width)), b[r.wire+i*r.width:]
+				switch mode {
+				case orderLittle:
+					getLittle(q, x, r.width)
+				case orderBig:
+					getBig(q, x, r.width)
+				default:
+					get(q, order, x, r.width)
+				}
+			}
+		}
+	}
+	for _, r := range m.bools {
+		q := unsafe.Add(p, r.mem)
+		for i, x := range b[r.wire : r.wire+r.n] {
+			*(*bool)(unsafe.Add(q, i)) = x != 0
+		}
+	}
+	for _, a := range m.arrays {
+		for i := 0; i < a.n; i++ {
+			a.plan.decode(b[a.wire+i*a.size:], order, mode, unsafe.Add(p, a.mem+uintptr(i)*a.stride))
+		}
+	}
+}
+
+// The functions below encode and decode a multi-byte value width bytes
+// wide at p. The loops over the words of a plan repeat the switches of
+// putLittle, putBig, getLittle and getBig, which are too large to be
+// inlined.
+
+func putLittle(b []byte, p unsafe.Pointer, width int) {
+	switch width {
+	case 2:
+		LittleEndian.PutUint16(b, *(*uint16)(p))
+	case 4:
+		LittleEndian.PutUint32(b, *(*uint32)(p))
+	case 8:
+		LittleEndian.PutUint64(b, *(*uint64)(p))
+	}
+}
+
+func putBig(b []byte, p unsafe.Pointer, width int) {
+	switch width {
+	case 2:
+		BigEndian.PutUint16(b, *(*uint16)(p))
+	case 4:
+		BigEndian.PutUint32(b, *(*uint32)(p))
+	case 8:
+		BigEndian.PutUint64(b, *(*uint64)(p))
+	}
+}
+
+func put(b []byte, order ByteOrder, p unsafe.Pointer, width int) {
+	switch width {
+	case 2:
+		order.PutUint16(b, *(*uint16)(p))
+	case 4:
+		order.PutUint32(b, *(*uint32)(p))
+	case 8:
+		order.PutUint64(b, *(*uint64)(p))
+	}
+}
+
+func getLittle(p unsafe.Pointer, b []byte, width int) {
+	switch width {
+	case 2:
+		*(*uint16)(p) = LittleEndian.Uint16(b)
+	case 4:
+		*(*uint32)(p) = LittleEndian.Uint32(b)
+	case 8:
+		*(*uint64)(p) = LittleEndian.Uint64(b)
+	}
+}
+
+func getBig(p unsafe.Pointer, b []byte, width int) {
+	switch width {
+	case 2:
+		*(*uint16)(p) = BigEndian.Uint16(b)
+	case 4:
+		*(*uint32)(p) = BigEndian.Uint32(b)
+	case 8:
+		*(*uint64)(p) = BigEndian.Uint64(b)
+	}
+}
+
+func get(p unsafe.Pointer, order ByteOrder, b []byte, width int) {
+	switch width {
+	case 2:
+		*(*uint16)(p) = order.Uint16(b)
+	case 4:
+		*(*uint32)(p) = order.Uint32(b)
+	case 8:
+		*(*uint64)(p) = order.Uint64(b)
+	}
+}
@@ -0,0 +1,1177 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cbor
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Unmarshal parses the CBOR-encoded data and stores the result
+// in the value pointed to by v. If v is nil or not a pointer,
+// Unmarshal returns an InvalidUnmarshalError. It is
+//
+//	UnmarshalOptions{}.Unmarshal(data, v)
+//
+// The data must hold exactly one well-formed CBOR data item. Before
+// decoding anything, Unmarshal checks that it does and that it stays
+// within the limits described for UnmarshalOptions, so that hostile
+// input cannot cause unbounded recursion or allocation.
+//
+// Unmarshal uses the inverse of the encodings that Marshal uses,
+// allocating maps, slices, and pointers as necessary, with the
+// following additional rules:
+//
+// To unmarshal CBOR null or undefined into a pointer, interface, map
+// or slice, Unmarshal sets it to nil. Otherwise null and undefined
+// leave the value unchanged. To unmarshal CBOR into a pointer,
+// Unmarshal unmarshals into the value pointed at by the pointer,
+// allocating it if the pointer is nil.
+//
+// To unmarshal CBOR into a value implementing the Unmarshaler
+// interface, Unmarshal calls that value's UnmarshalCBOR method with
+// the encoded data item, including when the input is null.
+//
+// To unmarshal a CBOR map into a struct, Unmarshal matches text string
+// keys to the field names or tags used by Marshal, preferring an exact
+// match but also accepting a case-insensitive one, and integer keys to
+// fields with the "keyasint" option. Keys that match no field are
+// ignored. A CBOR array is unmarshaled into a struct with the
+// "toarray" option element by element.
+//
+// Integers unmarshal into Go integers if they fit, and into floating
+// point numbers. Bignums (tags 2 and 3) unmarshal into Go integers
+// if they fit and into big.Int. A CBOR byte string unmarshals into a
+// byte slice, or into a byte array, which must be large enough to hold
+// it; the rest of the array is zeroed.
+//
+// To unmarshal CBOR into an interface value,
+// Unmarshal stores one of these in the interface value:
+//
+//	bool, for CBOR false and true
+//	uint64, for CBOR unsigned integers
+//	int64, for CBOR negative integers that fit in an int64
+//	*big.Int, for other negative integers and for bignums
+//	float64, for CBOR floating point numbers
+//	[]byte, for CBOR byte strings
+//	string, for CBOR text strings
+//	[]interface{}, for CBOR arrays
+//	map[interface{}]interface{}, for CBOR maps
+//	time.Time, for tags 0 and 1
+//	Tag, for other tags
+//	Simple, for other simple values
+//
+// A map key that cannot be a Go map key, such as a byte string or an
+// array, is reported as an UnmarshalTypeError when unmarshaling into
+// an interface value. Tag 1 times are returned in UTC; tag 0 times
+// keep the offset given in the text.
+//
+// If a CBOR value is not appropriate for a given target type, Unmarshal
+// skips that value and completes the unmarshaling as best it can. If no
+// more serious errors are encountered, Unmarshal returns an
+// UnmarshalTypeError describing the earliest such error.
+func Unmarshal(data []byte, v interface{}) error {
+	return UnmarshalOptions{}.Unmarshal(data, v)
+}
+
+// Unmarshaler is the interface implemented by types
+// that can unmarshal a CBOR description of themselves.
+// The input is a single well-formed CBOR data item.
+// UnmarshalCBOR must copy the CBOR data if it wishes
+// to retain the data after returning.
+type Unmarshaler interface {
+	UnmarshalCBOR([]byte) error
+}
+
+// Default limits applied by UnmarshalOptions.
+const (
+	DefaultMaxDepth    = 32
+	DefaultMaxElements = 131072
+	DefaultMaxItemSize = 64 << 20
+)
+
+// UnmarshalOptions configures the decoding of CBOR into Go values.
+// The zero UnmarshalOptions decodes like Unmarshal.
+//
+// UnmarshalOptions配置CBOR到Go值的解码方式。
+type UnmarshalOptions struct {
+	// MaxDepth is the maximum nesting depth of arrays, maps and tags
+	// in a data item. If zero, DefaultMaxDepth is used.
+	MaxDepth int
+
+	// MaxElements is the maximum total number of array elements, map
+	// entries and string chunks in a data item. It bounds the memory
+	// allocated for the decoded value independently of the length the
+	// input claims for its arrays and maps. If zero,
+	// DefaultMaxElements is used.
+	MaxElements int
+
+	// MaxItemSize is the maximum length in bytes of the encoding of a
+	// data item. A data item is rejected as soon as it is known to be
+	// longer, which for a byte or text string is as soon as its
+	// declared length is read, so that a Decoder does not buffer the
+	// rest of it. If zero, DefaultMaxItemSize is used.
+	MaxItemSize int
+
+	// DisallowUnknownFields causes an error to be returned when the
+	// destination is a struct and the input contains map keys which
+	// match no non-ignored, exported field of the destination.
+	DisallowUnknownFields bool
+}
+
+// Unmarshal is like the package-level Unmarshal but uses the options in o.
+func (o UnmarshalOptions) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+	c := o.checker(data)
+	end, err := c.item(0)
+	if err == errTruncated {
+		return &SyntaxError{"unexpected end of CBOR input", int64(len(data))}
+	}
+	if err != nil {
+		return err
+	}
+	if end != len(data) {
+		return &SyntaxError{"extra data after top-level value", int64(end)}
+	}
+	d := &decodeState{data: data, opts: o}
+	return d.unmarshal(rv)
+}
+
+func (o UnmarshalOptions) checker(data []byte) checker {
+	c := checker{data: data, maxDepth: o.MaxDepth, maxElements: o.MaxElements}
+	if c.maxDepth <= 0 {
+		c.maxDepth = DefaultMaxDepth
+	}
+	if c.maxElements <= 0 {
+		c.maxElements = DefaultMaxElements
+	}
+	c.maxSize = o.MaxItemSize
+	if c.maxSize <= 0 {
+		c.maxSize = DefaultMaxItemSize
+	}
+	return c
+}
+
+// An UnmarshalTypeError describes a CBOR value that was
+// not appropriate for a value of a specific Go type.
+type UnmarshalTypeError struct {
+	Value  string       // description of CBOR value - "text string", "array", "integer -5"
+	Type   reflect.Type // type of Go value it could not be assigned to
+	Offset int64        // error occurred after reading Offset bytes
+	Struct string       // name of the struct type containing the field
+	Field  string       // the full path from root node to the field
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	if e.Struct != "" || e.Field != "" {
+		return "cbor: cannot unmarshal " + e.Value + " into Go struct field " + e.Struct + "." + e.Field + " of type " + e.Type.String()
+	}
+	return "cbor: cannot unmarshal " + e.Value + " into Go value of type " + e.Type.String()
+}
+
+// An InvalidUnmarshalError describes an invalid argument passed to Unmarshal.
+// (The argument to Unmarshal must be a non-nil pointer.)
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "cbor: Unmarshal(nil)"
+	}
+
+	if e.Type.Kind() != reflect.Ptr {
+		return "cbor: Unmarshal(non-pointer " + e.Type.String() + ")"
+	}
+	return "cbor: Unmarshal(nil " + e.Type.String() + ")"
+}
+
+// A SyntaxError is a description of a CBOR syntax error,
+// or of input that exceeds the limits set by UnmarshalOptions.
+type SyntaxError struct {
+	msg    string // description of error
+	Offset int64  // error occurred after reading Offset bytes
+}
+
+func (e *SyntaxError) Error() string { return "cbor: " + e.msg }
+
+// errTruncated is returned by the checker when a data item continues
+// past the end of its input. Unmarshal reports it as a SyntaxError,
+// while a Decoder reads more input and tries again.
+var errTruncated = errors.New("cbor: truncated data item")
+
+// A checker checks that a data item is well-formed and within limits.
+// It keeps its place when the data item is truncated, so that a Decoder
+// can resume checking once it has read more input instead of starting
+// over.
+type checker struct {
+	data        []byte
+	maxDepth    int
+	maxElements int
+	maxSize     int
+	elements    int
+	start       int     // offset of the data item
+	off         int     // offset of the next head to check
+	stack       []frame // enclosing data items not yet complete
+}
+
+// A frame is an array, map, tag or indefinite-length string whose
+// content is being checked.
+type frame struct {
+	major      byte
+	indefinite bool
+	left       uint64 // data items left, if not indefinite
+	value      bool   // an indefinite-length map expects a value next
+}
+
+// avail reports whether the n bytes at off are part of the input and
+// within the maximum size of the data item.
+func (c *checker) avail(off, n int) error {
+	if n > c.maxSize-(off-c.start) {
+		return &SyntaxError{"exceeded max item size", int64(off)}
+	}
+	if n > len(c.data)-off {
+		return errTruncated
+	}
+	return nil
+}
+
+// head decodes the head of the data item at off, returning its major
+// type, additional information, argument and the offset after it.
+func (c *checker) head(off int) (major, ai byte, arg uint64, next int, err error) {
+	if err := c.avail(off, 1); err != nil {
+		return 0, 0, 0, 0, err
+	}
+	major, ai = c.data[off]>>5, c.data[off]&0x1f
+	off++
+	switch {
+	case ai < 24:
+		return major, ai, uint64(ai), off, nil
+	case ai <= 27:
+		n := 1 << (ai - 24)
+		if err := c.avail(off, n); err != nil {
+			return 0, 0, 0, 0, err
+		}
+		for _, b := range c.data[off : off+n] {
+			arg = arg<<8 | uint64(b)
+		}
+		return major, ai, arg, off + n, nil
+	case ai == 31:
+		return major, ai, 0, off, nil
+	}
+	return 0, 0, 0, 0, &SyntaxError{"reserved additional information " + strconv.Itoa(int(ai)), int64(off - 1)}
+}
+
+// count records n more elements, reporting an error at off if there
+// are more than allowed.
+func (c *checker) count(n uint64, off int) error {
+	if n > uint64(c.maxElements-c.elements) {
+		return &SyntaxError{"exceeded max elements", int64(off)}
+	}
+	c.elements += int(n)
+	return nil
+}
+
+// item checks the data item at off and returns the offset after it.
+func (c *checker) item(off int) (int, error) {
+	c.start, c.off, c.elements = off, off, 0
+	c.stack = c.stack[:0]
+	return c.resume()
+}
+
+// resume continues checking the data item begun by item, after the
+// last head that was complete, and returns the offset after it. It
+// changes no state before returning errTruncated, so it can be called
+// again once c.data has been extended.
+func (c *checker) resume() (int, error) {
+	for {
+		off := c.off
+		var f *frame
+		if n := len(c.stack); n > 0 {
+			f = &c.stack[n-1]
+		}
+		if f != nil && f.indefinite && !f.value {
+			if err := c.avail(off, 1); err != nil {
+				return 0, err
+			}
+			if c.data[off] == cborBreak {
+				c.off = off + 1
+				c.stack = c.stack[:len(c.stack)-1]
+				if c.pop() {
+					return c.off, nil
+				}
+				continue
+			}
+		}
+		if len(c.stack) > c.maxDepth {
+			return 0, &SyntaxError{"exceeded max depth", int64(off)}
+		}
+		major, ai, arg, next, err := c.head(off)
+		if err != nil {
+			return 0, err
+		}
+		end := next
+		if ai != 31 && (major == majorBytes || major == majorText) {
+			if arg > uint64(c.maxSize-(next-c.start)) {
+				return 0, &SyntaxError{"exceeded max item size", int64(off)}
+			}
+			if arg > uint64(len(c.data)-next) {
+				return 0, errTruncated
+			}
+			end = next + int(arg)
+			if major == majorText && !utf8.Valid(c.data[next:end]) {
+				return 0, &SyntaxError{"invalid UTF-8 in text string", int64(off)}
+			}
+		}
+
+		// The whole head is there: account for it in the enclosing
+		// data item.
+		if f != nil && f.indefinite {
+			if (f.major == majorBytes || f.major == majorText) && (major != f.major || ai == 31) {
+				return 0, &SyntaxError{"invalid chunk in indefinite-length string", int64(off)}
+			}
+			if !f.value {
+				if err := c.count(1, off); err != nil {
+					return 0, err
+				}
+			}
+			if f.major == majorMap {
+				f.value = !f.value
+			}
+		} else if f != nil {
+			f.left--
+		}
+		c.off = end
+
+		if ai == 31 {
+			switch major {
+			case majorBytes, majorText, majorArray, majorMap:
+				c.stack = append(c.stack, frame{major: major, indefinite: true})
+				continue
+			case majorSimple:
+				return 0, &SyntaxError{"unexpected break", int64(off)}
+			}
+			return 0, &SyntaxError{"invalid indefinite length", int64(off)}
+		}
+		switch major {
+		case majorArray, majorMap:
+			n := arg
+			if major == majorMap {
+				if n > math.MaxUint64/2 {
+					return 0, &SyntaxError{"exceeded max elements", int64(off)}
+				}
+				n *= 2
+			}
+			if err := c.count(arg, off); err != nil {
+				return 0, err
+			}
+			c.stack = append(c.stack, frame{major: major, left: n})
+		case majorTag:
+			c.stack = append(c.stack, frame{major: major, left: 1})
+		case majorSimple:
+			if ai == 24 && arg < 32 {
+				return 0, &SyntaxError{"invalid simple value " + strconv.FormatUint(arg, 10), int64(off)}
+			}
+		}
+		if c.pop() {
+			return c.off, nil
+		}
+	}
+}
+
+// pop removes the definite-length data items that are complete from
+// the top of the stack and reports whether the data item begun by item
+// is complete.
+func (c *checker) pop() bool {
+	for n := len(c.stack); n > 0 && !c.stack[n-1].indefinite && c.stack[n-1].left == 0; n-- {
+		c.stack = c.stack[:n-1]
+	}
+	return len(c.stack) == 0
+}
+
+// skipItem returns the offset after the well-formed data item at off.
+func skipItem(data []byte, off int) int {
+	c := checker{data: data, maxDepth: math.MaxInt32, maxElements: math.MaxInt32, maxSize: math.MaxInt}
+	end, err := c.item(off)
+	if err != nil {
+		panic("cbor: skipping malformed data item: " + err.Error())
+	}
+	return end
+}
+
+// decodeState represents the state while decoding a CBOR value.
+// Its data has been checked to be well-formed.
+type decodeState struct {
+	data       []byte
+	off        int // next read offset in data
+	opts       UnmarshalOptions
+	savedError error
+
+	// errorContext is the struct and field path of the value being
+	// decoded, used to annotate UnmarshalTypeErrors.
+	errStruct  reflect.Type
+	fieldStack []string
+}
+
+func (d *decodeState) unmarshal(rv reflect.Value) error {
+	if err := d.value(rv); err != nil {
+		return err
+	}
+	return d.savedError
+}
+
+// saveError saves the first err it is called with,
+// for reporting at the end of the unmarshal.
+func (d *decodeState) saveError(err error) {
+	if d.savedError == nil {
+		if err, ok := err.(*UnmarshalTypeError); ok && (d.errStruct != nil || len(d.fieldStack) > 0) {
+			if d.errStruct != nil {
+				err.Struct = d.errStruct.Name()
+			}
+			err.Field = strings.Join(d.fieldStack, ".")
+		}
+		d.savedError = err
+	}
+}
+
+// typeError records that the data item at d.off, described by what,
+// cannot be stored in v, and skips it.
+func (d *decodeState) typeError(what string, v reflect.Value) {
+	d.saveError(&UnmarshalTypeError{Value: what, Type: v.Type(), Offset: int64(d.off)})
+	d.off = skipItem(d.data, d.off)
+}
+
+// head decodes the head of the data item at d.off without consuming it.
+func (d *decodeState) head() (major, ai byte, arg uint64, next int) {
+	c := checker{data: d.data, maxSize: math.MaxInt}
+	major, ai, arg, next, _ = c.head(d.off)
+	return major, ai, arg, next
+}
+
+// describe returns a description of the data item at d.off for errors.
+func (d *decodeState) describe() string {
+	major, ai, arg, _ := d.head()
+	switch major {
+	case majorUint:
+		return "integer " + strconv.FormatUint(arg, 10)
+	case majorNegInt:
+		return "negative integer"
+	case majorBytes:
+		return "byte string"
+	case majorText:
+		return "text string"
+	case majorArray:
+		return "array"
+	case majorMap:
+		return "map"
+	case majorTag:
+		return "tag " + strconv.FormatUint(arg, 10)
+	}
+	switch {
+	case ai == 20 || ai == 21:
+		return "bool"
+	case ai >= 25 && ai <= 27:
+		return "float"
+	}
+	return Simple(arg).String()
+}
+
+// indirect walks down v allocating pointers as needed,
+// until it gets to a non-pointer.
+// If it encounters an Unmarshaler, indirect stops and returns that.
+// If decodingNull is true, indirect stops at the first settable pointer so it
+// can be set to nil.
+func indirect(v reflect.Value, decodingNull bool) (Unmarshaler, reflect.Value) {
+	// Issue #24153 indicates that it is generally not a guaranteed property
+	// that you may round-trip a reflect.Value by calling Value.Addr().Elem()
+	// and expect the value to still be settable for values derived from
+	// unexported embedded struct fields.
+	//
+	// The logic below effectively does this when it first addresses the value
+	// (to satisfy possible pointer methods) and continues to dereference
+	// subsequent pointers as necessary.
+	//
+	// After the first round-trip, we set v back to the original value to
+	// preserve the original RW flags contained in reflect.Value.
+	v0 := v
+	haveAddr := false
+
+	// If v is a named type and is addressable,
+	// start with its address, so that if the type has pointer methods,
+	// we find them.
+	if v.Kind() != reflect.Ptr && v.Type().Name() != "" && v.CanAddr() {
+		haveAddr = true
+		v = v.Addr()
+	}
+	for {
+		// Load value from interface, but only if the result will be
+		// usefully addressable.
+		if v.Kind() == reflect.Interface && !v.IsNil() {
+			e := v.Elem()
+			if e.Kind() == reflect.Ptr && !e.IsNil() && (!decodingNull || e.Elem().Kind() == reflect.Ptr) {
+				haveAddr = false
+				v = e
+				continue
+			}
+		}
+
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+
+		if decodingNull && v.CanSet() {
+			break
+		}
+
+		// Prevent infinite loop if v is an interface pointing to its own address:
+		//     var v interface{}
+		//     v = &v
+		if v.Elem().Kind() == reflect.Interface && v.Elem().Elem() == v {
+			v = v.Elem()
+			break
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		if v.Type().NumMethod() > 0 && v.CanInterface() {
+			if u, ok := v.Interface().(Unmarshaler); ok {
+				return u, reflect.Value{}
+			}
+		}
+
+		if haveAddr {
+			v = v0 // restore original value after round-trip Value.Addr().Elem()
+			haveAddr = false
+		} else {
+			v = v.Elem()
+		}
+	}
+	return nil, v
+}
+
+// value decodes the data item at d.off into v and consumes it.
+func (d *decodeState) value(v reflect.Value) error {
+	if !v.IsValid() {
+		d.off = skipItem(d.data, d.off)
+		return nil
+	}
+	if b := d.data[d.off]; b == cborNull || b == cborNull+1 {
+		u, pv := indirect(v, true)
+		if u != nil {
+			return d.unmarshaler(u)
+		}
+		d.off++
+		switch pv.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			pv.Set(reflect.Zero(pv.Type()))
+		}
+		return nil
+	}
+	u, pv := indirect(v, false)
+	if u != nil {
+		return d.unmarshaler(u)
+	}
+	v = pv
+
+	switch v.Type() {
+	case timeType:
+		return d.time(v)
+	case bigIntType:
+		return d.bigInt(v)
+	case tagType:
+		major, _, num, next := d.head()
+		if major != majorTag {
+			d.typeError(d.describe(), v)
+			return nil
+		}
+		d.off = next
+		var content interface{}
+		if err := d.value(reflect.ValueOf(&content).Elem()); err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(Tag{num, content}))
+		return nil
+	}
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		x, err := d.valueInterface()
+		if err != nil {
+			return err
+		}
+		if x != nil {
+			v.Set(reflect.ValueOf(x))
+		} else {
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	major, ai, arg, next := d.head()
+	switch major {
+	case majorUint, majorNegInt:
+		d.integer(v, major, arg, next)
+	case majorBytes:
+		d.byteString(v)
+	case majorText:
+		if v.Kind() != reflect.String {
+			d.typeError("text string", v)
+			return nil
+		}
+		v.SetString(string(d.readString()))
+	case majorArray:
+		return d.array(v)
+	case majorMap:
+		return d.mapValue(v)
+	case majorTag:
+		if (arg == tagPosBignum || arg == tagNegBignum) && isNumberKind(v.Kind()) {
+			d.bignum(v)
+			return nil
+		}
+		// Other tags are ignored and their content decoded into v.
+		d.off = next
+		return d.value(v)
+	case majorSimple:
+		d.simple(v, ai, arg, next)
+	}
+	return nil
+}
+
+func (d *decodeState) unmarshaler(u Unmarshaler) error {
+	start := d.off
+	d.off = skipItem(d.data, d.off)
+	return u.UnmarshalCBOR(d.data[start:d.off])
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// integer decodes the integer at d.off, with the given major type and
+// argument and ending at next, into v.
+func (d *decodeState) integer(v reflect.Value, major byte, arg uint64, next int) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := int64(arg)
+		if major == majorNegInt {
+			n = -1 - n
+		}
+		if arg > math.MaxInt64 || v.OverflowInt(n) {
+			d.typeError(d.describe(), v)
+			return
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if major == majorNegInt || v.OverflowUint(arg) {
+			d.typeError(d.describe(), v)
+			return
+		}
+		v.SetUint(arg)
+	case reflect.Float32, reflect.Float64:
+		f := float64(arg)
+		if major == majorNegInt {
+			f = -1 - f
+		}
+		v.SetFloat(f)
+	default:
+		d.typeError(d.describe(), v)
+		return
+	}
+	d.off = next
+}
+
+// bignum decodes the bignum at d.off into v, which is of a number kind.
+func (d *decodeState) bignum(v reflect.Value) {
+	start := d.off
+	n := d.readBignum()
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n.IsInt64() && !v.OverflowInt(n.Int64()) {
+			v.SetInt(n.Int64())
+			return
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n.IsUint64() && !v.OverflowUint(n.Uint64()) {
+			v.SetUint(n.Uint64())
+			return
+		}
+	case reflect.Float32, reflect.Float64:
+		f, _ := new(big.Float).SetInt(n).Float64()
+		v.SetFloat(f)
+		return
+	}
+	d.off = start
+	d.typeError("bignum", v)
+}
+
+// readBignum decodes the bignum at d.off.
+func (d *decodeState) readBignum() *big.Int {
+	_, _, tag, next := d.head()
+	d.off = next
+	n := new(big.Int)
+	if major, _, _, _ := d.head(); major != majorBytes {
+		// A bignum must hold a byte string; anything else is zero.
+		d.off = skipItem(d.data, d.off)
+	} else {
+		n.SetBytes(d.readString())
+	}
+	if tag == tagNegBignum {
+		n.Not(n)
+	}
+	return n
+}
+
+func (d *decodeState) bigInt(v reflect.Value) error {
+	major, _, arg, next := d.head()
+	n := v.Addr().Interface().(*big.Int)
+	switch {
+	case major == majorUint:
+		n.SetUint64(arg)
+	case major == majorNegInt:
+		n.SetUint64(arg)
+		n.Not(n)
+	case major == majorTag && (arg == tagPosBignum || arg == tagNegBignum):
+		n.Set(d.readBignum())
+		return nil
+	default:
+		d.typeError(d.describe(), v)
+		return nil
+	}
+	d.off = next
+	return nil
+}
+
+func (d *decodeState) time(v reflect.Value) error {
+	start := d.off
+	major, _, tag, next := d.head()
+	if major != majorTag || tag != tagTimeString && tag != tagTimeEpoch {
+		d.typeError(d.describe(), v)
+		return nil
+	}
+	d.off = next
+	var content interface{}
+	if err := d.value(reflect.ValueOf(&content).Elem()); err != nil {
+		return err
+	}
+	t, ok := toTime(tag, content)
+	if !ok {
+		d.off = start
+		d.typeError("tag "+strconv.FormatUint(tag, 10), v)
+		return nil
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// toTime converts the content of a tag 0 or tag 1 data item to a time.
+func toTime(tag uint64, content interface{}) (time.Time, bool) {
+	switch x := content.(type) {
+	case string:
+		if tag == tagTimeString {
+			t, err := time.Parse(time.RFC3339, x)
+			return t, err == nil
+		}
+	case uint64:
+		if tag == tagTimeEpoch && x <= math.MaxInt64 {
+			return time.Unix(int64(x), 0).UTC(), true
+		}
+	case int64:
+		if tag == tagTimeEpoch {
+			return time.Unix(x, 0).UTC(), true
+		}
+	case float64:
+		if tag == tagTimeEpoch && !math.IsNaN(x) && !math.IsInf(x, 0) {
+			sec, frac := math.Modf(x)
+			return time.Unix(int64(sec), int64(frac*1e9)).UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// readString returns the contents of the byte or text string at d.off,
+// and consumes it. The result is a copy that the caller may retain.
+func (d *decodeState) readString() []byte {
+	_, ai, arg, next := d.head()
+	if ai != 31 {
+		d.off = next + int(arg)
+		b := make([]byte, arg)
+		copy(b, d.data[next:d.off])
+		return b
+	}
+	d.off = next
+	b := []byte{}
+	for d.data[d.off] != cborBreak {
+		_, _, arg, next := d.head()
+		d.off = next + int(arg)
+		b = append(b, d.data[next:d.off]...)
+	}
+	d.off++
+	return b
+}
+
+func (d *decodeState) byteString(v reflect.Value) {
+	switch {
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		b := d.readString()
+		v.SetBytes(b)
+	case v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8:
+		start := d.off
+		b := d.readString()
+		if len(b) > v.Len() {
+			d.off = start
+			d.typeError("byte string of length "+strconv.Itoa(len(b)), v)
+			return
+		}
+		reflect.Copy(v, reflect.ValueOf(b))
+		for i := len(b); i < v.Len(); i++ {
+			v.Index(i).SetUint(0)
+		}
+	default:
+		d.typeError("byte string", v)
+	}
+}
+
+// containerHead consumes the head of the array or map at d.off and
+// returns its length, or -1 if it has indefinite length.
+func (d *decodeState) containerHead() int {
+	_, ai, arg, next := d.head()
+	d.off = next
+	if ai == 31 {
+		return -1
+	}
+	return int(arg)
+}
+
+// more reports whether the array or map being decoded, which has n
+// elements or indefinite length if n < 0, has more elements after the
+// first i. It consumes the break ending an indefinite-length one.
+func (d *decodeState) more(i, n int) bool {
+	if n >= 0 {
+		return i < n
+	}
+	if d.data[d.off] == cborBreak {
+		d.off++
+		return false
+	}
+	return true
+}
+
+func (d *decodeState) array(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+	case reflect.Struct:
+		if cachedTypeFields(v.Type()).toArray {
+			return d.structArray(v)
+		}
+		fallthrough
+	default:
+		d.typeError("array", v)
+		return nil
+	}
+
+	n := d.containerHead()
+	if v.Kind() == reflect.Slice {
+		if n >= 0 {
+			// The checker has limited n, so this allocation is bounded.
+			v.Set(reflect.MakeSlice(v.Type(), n, n))
+		} else {
+			v.SetLen(0)
+		}
+	}
+	i := 0
+	for ; d.more(i, n); i++ {
+		if v.Kind() == reflect.Slice && i >= v.Len() {
+			v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+		}
+		var elem reflect.Value
+		if i < v.Len() {
+			elem = v.Index(i)
+		}
+		if err := d.value(elem); err != nil {
+			return err
+		}
+	}
+	if v.Kind() == reflect.Array {
+		z := reflect.Zero(v.Type().Elem())
+		for ; i < v.Len(); i++ {
+			v.Index(i).Set(z)
+		}
+	}
+	return nil
+}
+
+func (d *decodeState) structArray(v reflect.Value) error {
+	fields := cachedTypeFields(v.Type())
+	n := d.containerHead()
+	for i := 0; d.more(i, n); i++ {
+		var fv reflect.Value
+		if i < len(fields.list) {
+			fv, _ = fieldByIndex(v, fields.list[i].index, true)
+		}
+		if err := d.value(fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *decodeState) mapValue(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Map:
+	case reflect.Struct:
+		return d.structMap(v)
+	default:
+		d.typeError("map", v)
+		return nil
+	}
+
+	t := v.Type()
+	n := d.containerHead()
+	if v.IsNil() {
+		if n < 0 {
+			n = 0
+		}
+		v.Set(reflect.MakeMapWithSize(t, n))
+	}
+	kt, et := t.Key(), t.Elem()
+	for i := 0; d.more(i, n); i++ {
+		kv := reflect.New(kt).Elem()
+		keyStart := d.off
+		if err := d.value(kv); err != nil {
+			return err
+		}
+		if kt.Kind() == reflect.Interface && kv.Elem().IsValid() && !hashable(kv.Interface()) {
+			d.off = keyStart
+			d.typeError(d.describe()+" map key", kv)
+			d.off = skipItem(d.data, d.off)
+			continue
+		}
+		ev := reflect.New(et).Elem()
+		if err := d.value(ev); err != nil {
+			return err
+		}
+		v.SetMapIndex(kv, ev)
+	}
+	return nil
+}
+
+// hashable reports whether x, decoded into an interface{}, can be used
+// as a map key.
+func hashable(x interface{}) bool {
+	switch x := x.(type) {
+	case []byte, []interface{}, map[interface{}]interface{}:
+		return false
+	case Tag:
+		return hashable(x.Content)
+	}
+	return true
+}
+
+func (d *decodeState) structMap(v reflect.Value) error {
+	t := v.Type()
+	fields := cachedTypeFields(t)
+	n := d.containerHead()
+	for i := 0; d.more(i, n); i++ {
+		f := -1
+		var name string
+		switch major, _, arg, next := d.head(); major {
+		case majorText:
+			name = string(d.readString())
+			var ok bool
+			if f, ok = fields.byName[name]; !ok {
+				if f, ok = fields.byFoldedName[string(foldName([]byte(name)))]; !ok {
+					f = -1
+				}
+			}
+		case majorUint, majorNegInt:
+			k := int64(arg)
+			if major == majorNegInt {
+				k = -1 - k
+			}
+			name = strconv.FormatInt(k, 10)
+			if i, ok := fields.byInt[k]; ok && arg <= math.MaxInt64 {
+				f = i
+			}
+			d.off = next
+		default:
+			name = d.describe()
+			d.off = skipItem(d.data, d.off)
+		}
+
+		if f < 0 {
+			if d.opts.DisallowUnknownFields {
+				d.saveError(errors.New("cbor: unknown field " + strconv.Quote(name)))
+			}
+			d.off = skipItem(d.data, d.off)
+			continue
+		}
+		fv, ok := fieldByIndex(v, fields.list[f].index, true)
+		if !ok {
+			// The field is promoted through a nil pointer to an
+			// unexported embedded struct, which cannot be allocated.
+			d.saveError(errors.New("cbor: cannot set embedded pointer to unexported struct: " + v.Type().String()))
+			d.off = skipItem(d.data, d.off)
+			continue
+		}
+		prevStruct, depth := d.errStruct, len(d.fieldStack)
+		d.errStruct = t
+		d.fieldStack = append(d.fieldStack, fields.list[f].name)
+		err := d.value(fv)
+		d.errStruct, d.fieldStack = prevStruct, d.fieldStack[:depth]
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *decodeState) simple(v reflect.Value, ai byte, arg uint64, next int) {
+	switch {
+	case ai == 20 || ai == 21:
+		if v.Kind() != reflect.Bool {
+			d.typeError("bool", v)
+			return
+		}
+		v.SetBool(ai == 21)
+	case ai >= 25 && ai <= 27:
+		if v.Kind() != reflect.Float32 && v.Kind() != reflect.Float64 {
+			d.typeError("float", v)
+			return
+		}
+		f := decodeFloat(ai, arg)
+		if v.OverflowFloat(f) {
+			d.typeError("float "+strconv.FormatFloat(f, 'g', -1, 64), v)
+			return
+		}
+		v.SetFloat(f)
+	default:
+		if v.Type() != simpleType {
+			d.typeError(Simple(arg).String(), v)
+			return
+		}
+		v.SetUint(arg)
+	}
+	d.off = next
+}
+
+// decodeFloat returns the value of the half, single or double precision
+// float with additional information ai and argument arg.
+func decodeFloat(ai byte, arg uint64) float64 {
+	switch ai {
+	case 25:
+		return float16ToFloat64(uint16(arg))
+	case 26:
+		return float64(math.Float32frombits(uint32(arg)))
+	}
+	return math.Float64frombits(arg)
+}
+
+func float16ToFloat64(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant != 0 {
+			f = math.NaN()
+		} else {
+			f = math.Inf(1)
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+// valueInterface decodes the data item at d.off into the value
+// Unmarshal stores in an interface{}, and consumes it.
+func (d *decodeState) valueInterface() (interface{}, error) {
+	major, ai, arg, next := d.head()
+	switch major {
+	case majorUint:
+		d.off = next
+		return arg, nil
+	case majorNegInt:
+		d.off = next
+		if arg <= math.MaxInt64 {
+			return -1 - int64(arg), nil
+		}
+		n := new(big.Int).SetUint64(arg)
+		return n.Not(n), nil
+	case majorBytes:
+		return d.readString(), nil
+	case majorText:
+		return string(d.readString()), nil
+	case majorArray:
+		n := d.containerHead()
+		var a []interface{}
+		if n >= 0 {
+			a = make([]interface{}, 0, n)
+		} else {
+			a = []interface{}{}
+		}
+		for i := 0; d.more(i, n); i++ {
+			x, err := d.valueInterface()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, x)
+		}
+		return a, nil
+	case majorMap:
+		m := make(map[interface{}]interface{})
+		return m, d.value(reflect.ValueOf(&m).Elem())
+	case majorTag:
+		switch arg {
+		case tagPosBignum, tagNegBignum:
+			return d.readBignum(), nil
+		}
+		start := d.off
+		d.off = next
+		content, err := d.valueInterface()
+		if err != nil {
+			return nil, err
+		}
+		if arg == tagTimeString || arg == tagTimeEpoch {
+			if t, ok := toTime(arg, content); ok {
+				return t, nil
+			}
+			end := d.off
+			d.off = start
+			d.saveError(&UnmarshalTypeError{Value: d.describe(), Type: timeType, Offset: int64(start)})
+			d.off = end
+			return nil, nil
+		}
+		return Tag{arg, content}, nil
+	}
+	d.off = next
+	switch {
+	case ai == 20 || ai == 21:
+		return ai == 21, nil
+	case ai == 22 || ai == 23:
+		return nil, nil
+	case ai >= 25 && ai <= 27:
+		return decodeFloat(ai, arg), nil
+	}
+	return Simple(arg), nil
+}
@@ -0,0 +1,430 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func bigInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("bad big.Int " + s)
+	}
+	return n
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// rfcExamples are the examples of RFC 8949 Appendix A, with the values
+// Unmarshal stores for them in an interface{}. If canonical is set, the
+// encoding is also the one Marshal produces for the value.
+var rfcExamples = []struct {
+	hex       string
+	value     interface{}
+	canonical bool
+}{
+	{"00", uint64(0), true},
+	{"01", uint64(1), true},
+	{"0a", uint64(10), true},
+	{"17", uint64(23), true},
+	{"1818", uint64(24), true},
+	{"1819", uint64(25), true},
+	{"1864", uint64(100), true},
+	{"1903e8", uint64(1000), true},
+	{"1a000f4240", uint64(1000000), true},
+	{"1b000000e8d4a51000", uint64(1000000000000), true},
+	{"1bffffffffffffffff", uint64(18446744073709551615), true},
+	{"c249010000000000000000", bigInt("18446744073709551616"), true},
+	{"3bffffffffffffffff", bigInt("-18446744073709551616"), true},
+	{"c349010000000000000000", bigInt("-18446744073709551617"), true},
+	{"20", int64(-1), true},
+	{"29", int64(-10), true},
+	{"3863", int64(-100), true},
+	{"3903e7", int64(-1000), true},
+	{"f90000", 0.0, true},
+	{"f98000", math.Copysign(0, -1), true},
+	{"f93c00", 1.0, true},
+	{"fb3ff199999999999a", 1.1, true},
+	{"f93e00", 1.5, true},
+	{"f97bff", 65504.0, true},
+	{"fa47c35000", 100000.0, true},
+	{"fa7f7fffff", 3.4028234663852886e+38, true},
+	{"fb7e37e43c8800759c", 1.0e+300, true},
+	{"f90001", 5.960464477539063e-8, true},
+	{"f90400", 0.00006103515625, true},
+	{"f9c400", -4.0, true},
+	{"fbc010666666666666", -4.1, true},
+	{"f97c00", math.Inf(1), true},
+	{"f97e00", math.NaN(), true},
+	{"f9fc00", math.Inf(-1), true},
+	{"fa7f800000", math.Inf(1), false},
+	{"fa7fc00000", math.NaN(), false},
+	{"faff800000", math.Inf(-1), false},
+	{"fb7ff0000000000000", math.Inf(1), false},
+	{"fb7ff8000000000000", math.NaN(), false},
+	{"fbfff0000000000000", math.Inf(-1), false},
+	{"f4", false, true},
+	{"f5", true, true},
+	{"f6", nil, true},
+	{"f7", nil, false},
+	{"f0", Simple(16), true},
+	{"f8ff", Simple(255), true},
+	{"c074323031332d30332d32315432303a30343a30305a", time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC), true},
+	{"c11a514b67b0", time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC), false},
+	{"c1fb41d452d9ec200000", time.Date(2013, 3, 21, 20, 4, 0, 500000000, time.UTC), false},
+	{"d74401020304", Tag{23, []byte{1, 2, 3, 4}}, true},
+	{"d818456449455446", Tag{24, []byte("dIETF")}, true},
+	{"d82076687474703a2f2f7777772e6578616d706c652e636f6d", Tag{32, "http://www.example.com"}, true},
+	{"40", []byte{}, true},
+	{"4401020304", []byte{1, 2, 3, 4}, true},
+	{"60", "", true},
+	{"6161", "a", true},
+	{"6449455446", "IETF", true},
+	{"62225c", "\"\\", true},
+	{"62c3bc", "ü", true},
+	{"63e6b0b4", "水", true},
+	{"64f0908591", "\U00010151", true},
+	{"80", []interface{}{}, true},
+	{"83010203", []interface{}{uint64(1), uint64(2), uint64(3)}, true},
+	{"8301820203820405", []interface{}{uint64(1), []interface{}{uint64(2), uint64(3)}, []interface{}{uint64(4), uint64(5)}}, true},
+	{"98190102030405060708090a0b0c0d0e0f101112131415161718181819", rfcSeq(25), true},
+	{"a0", map[interface{}]interface{}{}, true},
+	{"a201020304", map[interface{}]interface{}{uint64(1): uint64(2), uint64(3): uint64(4)}, true},
+	{"a26161016162820203", map[interface{}]interface{}{"a": uint64(1), "b": []interface{}{uint64(2), uint64(3)}}, true},
+	{"826161a161626163", []interface{}{"a", map[interface{}]interface{}{"b": "c"}}, true},
+	{"a56161614161626142616361436164614461656145", map[interface{}]interface{}{"a": "A", "b": "B", "c": "C", "d": "D", "e": "E"}, true},
+	{"5f42010243030405ff", []byte{1, 2, 3, 4, 5}, false},
+	{"7f657374726561646d696e67ff", "streaming", false},
+	{"9fff", []interface{}{}, false},
+	{"9f018202039f0405ffff", []interface{}{uint64(1), []interface{}{uint64(2), uint64(3)}, []interface{}{uint64(4), uint64(5)}}, false},
+	{"9f01820203820405ff", []interface{}{uint64(1), []interface{}{uint64(2), uint64(3)}, []interface{}{uint64(4), uint64(5)}}, false},
+	{"83018202039f0405ff", []interface{}{uint64(1), []interface{}{uint64(2), uint64(3)}, []interface{}{uint64(4), uint64(5)}}, false},
+	{"83019f0203ff820405", []interface{}{uint64(1), []interface{}{uint64(2), uint64(3)}, []interface{}{uint64(4), uint64(5)}}, false},
+	{"9f0102030405060708090a0b0c0d0e0f101112131415161718181819ff", rfcSeq(25), false},
+	{"bf61610161629f0203ffff", map[interface{}]interface{}{"a": uint64(1), "b": []interface{}{uint64(2), uint64(3)}}, false},
+	{"826161bf61626163ff", []interface{}{"a", map[interface{}]interface{}{"b": "c"}}, false},
+	{"bf6346756ef563416d7421ff", map[interface{}]interface{}{"Fun": true, "Amt": int64(-2)}, false},
+}
+
+func rfcSeq(n int) []interface{} {
+	a := make([]interface{}, n)
+	for i := range a {
+		a[i] = uint64(i + 1)
+	}
+	return a
+}
+
+// equal is like reflect.DeepEqual but treats NaNs as equal and
+// compares big.Ints and times by value.
+func equal(x, y interface{}) bool {
+	switch x := x.(type) {
+	case float64:
+		if y, ok := y.(float64); ok && math.IsNaN(x) && math.IsNaN(y) {
+			return true
+		}
+		y, ok := y.(float64)
+		return ok && x == y && math.Signbit(x) == math.Signbit(y)
+	case *big.Int:
+		y, ok := y.(*big.Int)
+		return ok && x.Cmp(y) == 0
+	case time.Time:
+		y, ok := y.(time.Time)
+		return ok && x.Equal(y)
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+func TestUnmarshalRFCExamples(t *testing.T) {
+	for _, tt := range rfcExamples {
+		var v interface{}
+		if err := Unmarshal(mustHex(tt.hex), &v); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.hex, err)
+			continue
+		}
+		if !equal(v, tt.value) {
+			t.Errorf("Unmarshal(%s) = %#v, want %#v", tt.hex, v, tt.value)
+		}
+	}
+}
+
+type decodeStruct struct {
+	A      int
+	B      string `cbor:"b"`
+	Kty    int    `cbor:"1,keyasint"`
+	Alg    int    `cbor:"-7,keyasint"`
+	Ptr    *uint16
+	Bytes  [4]byte
+	Big    *big.Int
+	When   time.Time
+	Raw    RawMessage
+	Nested *decodeStruct `cbor:",omitempty"`
+	Any    interface{}
+	Tagged Tag
+}
+
+func TestUnmarshalStruct(t *testing.T) {
+	in := map[interface{}]interface{}{
+		"a":       uint64(5),
+		"b":       "hello",
+		uint64(1): uint64(2),
+		int64(-7): int64(-8),
+		"Ptr":     uint64(300),
+		"Bytes":   []byte{1, 2},
+		"Big":     bigInt("-100000000000000000000"),
+		"When":    time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+		"Raw":     []interface{}{uint64(1), "x"},
+		"Nested":  map[interface{}]interface{}{"A": int64(-1)},
+		"Any":     Tag{100, "x"},
+		"Tagged":  Tag{200, uint64(1)},
+		"Extra":   "ignored",
+	}
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got decodeStruct
+	if err := Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	ptr := uint16(300)
+	want := decodeStruct{
+		A:      5,
+		B:      "hello",
+		Kty:    2,
+		Alg:    -8,
+		Ptr:    &ptr,
+		Bytes:  [4]byte{1, 2},
+		Big:    bigInt("-100000000000000000000"),
+		When:   time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+		Raw:    RawMessage(mustHex("82016178")),
+		Nested: &decodeStruct{A: -1},
+		Any:    Tag{100, "x"},
+		Tagged: Tag{200, uint64(1)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	err = UnmarshalOptions{DisallowUnknownFields: true}.Unmarshal(data, new(decodeStruct))
+	if err == nil || !strings.Contains(err.Error(), `unknown field "Extra"`) {
+		t.Errorf("DisallowUnknownFields: got error %v", err)
+	}
+}
+
+func TestUnmarshalTypeError(t *testing.T) {
+	var v struct {
+		A int8
+		B string
+		C []int
+	}
+	// {"A": 1000, "B": 1, "C": [1, -1]}
+	data := mustHex("a3614119" + "03e8" + "614201" + "6143820120")
+	err := Unmarshal(data, &v)
+	var te *UnmarshalTypeError
+	if !errors.As(err, &te) {
+		t.Fatalf("got error %v, want UnmarshalTypeError", err)
+	}
+	if te.Field != "A" || te.Type != reflect.TypeOf(int8(0)) || te.Value != "integer 1000" {
+		t.Errorf("got %+v", te)
+	}
+	if v.C == nil || len(v.C) != 2 || v.C[0] != 1 || v.C[1] != -1 {
+		t.Errorf("decoding did not continue after type error: %+v", v)
+	}
+}
+
+var syntaxErrorTests = []struct {
+	hex  string
+	opts UnmarshalOptions
+	msg  string
+}{
+	{"", UnmarshalOptions{}, "unexpected end"},
+	{"18", UnmarshalOptions{}, "unexpected end"},
+	{"6261", UnmarshalOptions{}, "unexpected end"},
+	{"5b00000000ffffffff00", UnmarshalOptions{}, "exceeded max item size"},
+	{"5b00000000000000ff00", UnmarshalOptions{}, "unexpected end"},
+	{"9f01", UnmarshalOptions{}, "unexpected end"},
+	{"0000", UnmarshalOptions{}, "extra data"},
+	{"1c", UnmarshalOptions{}, "reserved additional information"},
+	{"ff", UnmarshalOptions{}, "unexpected break"},
+	{"1f", UnmarshalOptions{}, "invalid indefinite length"},
+	{"f818", UnmarshalOptions{}, "invalid simple value"},
+	{"61ff", UnmarshalOptions{}, "invalid UTF-8"},
+	{"5f6161ff", UnmarshalOptions{}, "invalid chunk"},
+	{"5f5f4100ffff", UnmarshalOptions{}, "invalid chunk"},
+	{"bf01ff", UnmarshalOptions{}, "unexpected break"},
+	{"818181818180", UnmarshalOptions{MaxDepth: 4}, "exceeded max depth"},
+	{"d8ffd8ff00", UnmarshalOptions{MaxDepth: 1}, "exceeded max depth"},
+	{"9bffffffffffffffff", UnmarshalOptions{}, "exceeded max elements"},
+	{"bbffffffffffffffff", UnmarshalOptions{}, "exceeded max elements"},
+	{"83010283010203", UnmarshalOptions{MaxElements: 5}, "exceeded max elements"},
+	{"9f010203ff", UnmarshalOptions{MaxElements: 2}, "exceeded max elements"},
+	{"4401020304", UnmarshalOptions{MaxItemSize: 4}, "exceeded max item size"},
+	{"83010203", UnmarshalOptions{MaxItemSize: 3}, "exceeded max item size"},
+}
+
+func TestUnmarshalSyntaxError(t *testing.T) {
+	for _, tt := range syntaxErrorTests {
+		var v interface{}
+		err := tt.opts.Unmarshal(mustHex(tt.hex), &v)
+		if _, ok := err.(*SyntaxError); !ok || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("Unmarshal(%s) = %v, want SyntaxError containing %q", tt.hex, err, tt.msg)
+		}
+	}
+}
+
+func TestUnmarshalNull(t *testing.T) {
+	s := []int{1}
+	m := map[string]int{"a": 1}
+	n := 5
+	p := &n
+	var x interface{} = 1
+	for _, v := range []interface{}{&s, &m, &p, &x, &n} {
+		if err := Unmarshal([]byte{0xf6}, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s != nil || m != nil || p != nil || x != nil || n != 5 {
+		t.Errorf("after null: %v %v %v %v %v", s, m, p, x, n)
+	}
+}
+
+func TestUnmarshalUnhashableKey(t *testing.T) {
+	var v interface{}
+	// {h'01': 1, 2: 3}
+	err := Unmarshal(mustHex("a2410101"+"0203"), &v)
+	if _, ok := err.(*UnmarshalTypeError); !ok {
+		t.Errorf("got error %v, want UnmarshalTypeError", err)
+	}
+	if want := map[interface{}]interface{}{uint64(2): uint64(3)}; !reflect.DeepEqual(v, want) {
+		t.Errorf("got %v, want %v", v, want)
+	}
+}
+
+func TestInvalidUnmarshal(t *testing.T) {
+	for _, v := range []interface{}{nil, 1, (*int)(nil)} {
+		if err := Unmarshal([]byte{0}, v); err == nil {
+			t.Errorf("Unmarshal into %T: no error", v)
+		} else if _, ok := err.(*InvalidUnmarshalError); !ok {
+			t.Errorf("Unmarshal into %T: got %T, want InvalidUnmarshalError", v, err)
+		}
+	}
+}
+
+// oneByteReader returns its data one byte per Read.
+type oneByteReader struct{ data []byte }
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	p[0] = r.data[0]
+	r.data = r.data[1:]
+	return 1, nil
+}
+
+func TestDecoder(t *testing.T) {
+	var stream []byte
+	for _, tt := range rfcExamples {
+		stream = append(stream, mustHex(tt.hex)...)
+	}
+	for _, r := range []io.Reader{bytes.NewReader(stream), &oneByteReader{stream}} {
+		dec := NewDecoder(r)
+		for _, tt := range rfcExamples {
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				t.Fatalf("Decode(%s): %v", tt.hex, err)
+			}
+			if !equal(v, tt.value) {
+				t.Errorf("Decode(%s) = %#v, want %#v", tt.hex, v, tt.value)
+			}
+		}
+		if err := dec.Decode(new(interface{})); err != io.EOF {
+			t.Errorf("Decode at end of input = %v, want io.EOF", err)
+		}
+	}
+
+	dec := NewDecoder(bytes.NewReader(mustHex("01830102")))
+	var v interface{}
+	if err := dec.Decode(&v); err != nil || v != uint64(1) {
+		t.Errorf("Decode = %v, %v", v, err)
+	}
+	if err := dec.Decode(&v); err != io.ErrUnexpectedEOF {
+		t.Errorf("Decode of truncated item = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+// endlessReader reads its prefix followed by endless zero bytes,
+// counting the bytes read.
+type endlessReader struct {
+	prefix []byte
+	n      int
+}
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	n := copy(p, r.prefix)
+	r.prefix = r.prefix[n:]
+	for i := range p[n:] {
+		p[n+i] = 0
+	}
+	r.n += len(p)
+	return len(p), nil
+}
+
+func TestDecoderMaxItemSize(t *testing.T) {
+	for _, tt := range []struct {
+		prefix string
+		opts   UnmarshalOptions
+		max    int
+	}{
+		// A byte string declaring a length past the limit fails at once.
+		{"5b7fffffffffffffff", UnmarshalOptions{}, 4096},
+		{"5a00100000", UnmarshalOptions{MaxItemSize: 1 << 20}, 4096},
+		// An indefinite-length array fails once it exceeds the limit.
+		{"9f", UnmarshalOptions{MaxItemSize: 1 << 16, MaxElements: 1 << 20}, 1 << 18},
+	} {
+		r := &endlessReader{prefix: mustHex(tt.prefix)}
+		dec := NewDecoder(r)
+		dec.SetOptions(tt.opts)
+		err := dec.Decode(new(interface{}))
+		if _, ok := err.(*SyntaxError); !ok || !strings.Contains(err.Error(), "exceeded max item size") {
+			t.Errorf("Decode(%s...) = %v, want SyntaxError exceeding max item size", tt.prefix, err)
+		}
+		if r.n > tt.max {
+			t.Errorf("Decode(%s...) read %d bytes, want at most %d", tt.prefix, r.n, tt.max)
+		}
+	}
+}
+
+func TestDecoderLargeItem(t *testing.T) {
+	// An array of many small elements read a byte at a time is checked
+	// once, not again from the start after each read.
+	const n = 100000
+	data := append(mustHex("9a000186a0"), make([]byte, n)...)
+	dec := NewDecoder(&oneByteReader{data})
+	dec.SetOptions(UnmarshalOptions{MaxElements: n})
+	var v []int
+	if err := dec.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v) != n {
+		t.Errorf("Decode: got %d elements, want %d", len(v), n)
+	}
+}
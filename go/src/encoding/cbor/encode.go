@@ -0,0 +1,895 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cbor implements encoding and decoding of CBOR, the Concise
+// Binary Object Representation, as defined in RFC 8949. The mapping
+// between CBOR and Go values is described in the documentation for the
+// Marshal and Unmarshal functions, and follows that of encoding/json
+// where the two formats allow.
+package cbor
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Major types of CBOR data items (RFC 8949 section 3.1).
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+// Initial bytes of CBOR data items with no argument.
+const (
+	cborFalse = 0xf4
+	cborTrue  = 0xf5
+	cborNull  = 0xf6
+	cborBreak = 0xff
+)
+
+// Tag numbers with a meaning to Marshal and Unmarshal.
+const (
+	tagTimeString = 0
+	tagTimeEpoch  = 1
+	tagPosBignum  = 2
+	tagNegBignum  = 3
+)
+
+// Marshal returns the CBOR encoding of v, in the core deterministic
+// encoding of RFC 8949 section 4.2.1. It is
+//
+//	MarshalOptions{Deterministic: true}.Marshal(v)
+//
+// Marshal traverses the value v recursively.
+// If an encountered value implements the Marshaler interface
+// and is not a nil pointer, Marshal calls its MarshalCBOR method
+// to produce CBOR. The result must be a single well-formed data item.
+//
+// Otherwise, Marshal uses the following type-dependent default encodings.
+// Every number, length and tag is written in its preferred serialization:
+// the shortest form that holds its value.
+//
+// Boolean values encode as CBOR false and true.
+//
+// Integer values encode as CBOR unsigned or negative integers, and
+// big.Int values as integers if they fit in 64 bits and as bignums
+// (tags 2 and 3) otherwise.
+//
+// Floating point values encode as the shortest of the CBOR half,
+// single and double precision floats that holds the value exactly.
+// NaN encodes as the half precision quiet NaN 0xf97e00.
+//
+// String values encode as CBOR text strings. A string holding invalid
+// UTF-8 is reported as an UnsupportedValueError.
+//
+// Slices and arrays of bytes encode as CBOR byte strings, and other
+// slices and arrays as CBOR arrays. A nil slice encodes as CBOR null.
+//
+// Map values encode as CBOR maps with the encoded keys and values of
+// the map. Any key type that can be encoded is allowed. A nil map
+// encodes as CBOR null.
+//
+// Struct values encode as CBOR maps. Each exported struct field becomes
+// a member of the map, using the field name as a text string key,
+// unless the field is omitted for one of the reasons given below.
+// The encoding of each struct field can be customized by the format
+// string stored under the "cbor" key in the struct field's tag. The
+// format string gives the name of the field, possibly followed by a
+// comma-separated list of options. The name may be empty in order to
+// specify options without overriding the default field name.
+//
+// The "omitempty" option specifies that the field should be omitted
+// from the encoding if the field has an empty value, defined as false,
+// 0, a nil pointer, a nil interface value, and any empty array, slice,
+// map, or string.
+//
+// The "keyasint" option specifies that the field's key is the integer
+// given as its name, rather than a text string, as in COSE keys:
+//
+//	// Field appears in CBOR as key 1.
+//	Kty int `cbor:"1,keyasint"`
+//
+// As a special case, if the field tag is "-", the field is always
+// omitted. Embedded struct fields are handled as by encoding/json.
+//
+// A struct with a blank field tagged ",toarray", such as
+//
+//	_ struct{} `cbor:",toarray"`
+//
+// encodes as a CBOR array of its fields in order instead of as a map.
+//
+// A time.Time encodes as a text string in RFC 3339 format with tag 0.
+// Times with years outside [0,9999] are reported as an
+// UnsupportedValueError.
+// A Tag encodes as its Content tagged with its Number, and a Simple
+// value as a CBOR simple value.
+//
+// Pointer values encode as the value pointed to, and interface values
+// as the value contained in the interface. A nil pointer or interface
+// value encodes as CBOR null.
+//
+// Channel, complex, and function values cannot be encoded in CBOR.
+// Attempting to encode such a value causes Marshal to return
+// an UnsupportedTypeError.
+//
+// CBOR cannot represent cyclic data structures and Marshal does not
+// handle them. Passing cyclic structures to Marshal will result in
+// an error.
+func Marshal(v interface{}) ([]byte, error) {
+	return MarshalOptions{Deterministic: true}.Marshal(v)
+}
+
+// Marshaler is the interface implemented by types that
+// can marshal themselves into valid CBOR.
+type Marshaler interface {
+	MarshalCBOR() ([]byte, error)
+}
+
+// MarshalOptions configures the encoding of Go values as CBOR.
+// Marshal and a new Encoder use
+//
+//	MarshalOptions{Deterministic: true}
+//
+// MarshalOptions配置Go值到CBOR的编码方式。
+type MarshalOptions struct {
+	// Deterministic causes the entries of maps and of structs encoded
+	// as maps to be sorted by the bytewise lexicographic order of
+	// their encoded keys. Together with the preferred serialization
+	// always used, this gives the core deterministic encoding of
+	// RFC 8949 section 4.2.1, so that equal values always encode to
+	// the same bytes. Otherwise map entries are encoded in map
+	// iteration order and struct fields in the order they are declared.
+	Deterministic bool
+}
+
+// Marshal is like the package-level Marshal but uses the options in o.
+func (o MarshalOptions) Marshal(v interface{}) ([]byte, error) {
+	e := &encodeState{opts: o}
+	if err := e.marshal(v); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// An UnsupportedTypeError is returned by Marshal when attempting
+// to encode an unsupported value type.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "cbor: unsupported type: " + e.Type.String()
+}
+
+// An UnsupportedValueError is returned by Marshal when attempting
+// to encode an unsupported value.
+type UnsupportedValueError struct {
+	Value reflect.Value
+	Str   string
+}
+
+func (e *UnsupportedValueError) Error() string {
+	return "cbor: unsupported value: " + e.Str
+}
+
+// A MarshalerError represents an error from calling a MarshalCBOR method.
+type MarshalerError struct {
+	Type reflect.Type
+	Err  error
+}
+
+func (e *MarshalerError) Error() string {
+	return "cbor: error calling MarshalCBOR for type " + e.Type.String() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *MarshalerError) Unwrap() error { return e.Err }
+
+// An encodeState encodes CBOR into a byte slice.
+type encodeState struct {
+	buf  []byte
+	opts MarshalOptions
+
+	// Keep track of what pointers we've seen in the current recursive call
+	// path, to avoid cycles that could lead to a stack overflow. Only do
+	// the relatively expensive map operations if ptrLevel is larger than
+	// startDetectingCyclesAfter, so that we skip the work if we're within a
+	// reasonable amount of nested pointers deep.
+	ptrLevel uint
+	ptrSeen  map[interface{}]struct{}
+}
+
+const startDetectingCyclesAfter = 1000
+
+func (e *encodeState) marshal(v interface{}) error {
+	return e.encode(reflect.ValueOf(v))
+}
+
+var (
+	marshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	timeType      = reflect.TypeOf(time.Time{})
+	bigIntType    = reflect.TypeOf(big.Int{})
+	tagType       = reflect.TypeOf(Tag{})
+	simpleType    = reflect.TypeOf(Simple(0))
+)
+
+// appendHead appends the head of a data item with the given major type
+// and argument, in its preferred serialization.
+func appendHead(b []byte, major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return append(b, major|byte(arg))
+	case arg <= math.MaxUint8:
+		return append(b, major|24, byte(arg))
+	case arg <= math.MaxUint16:
+		return append(b, major|25, byte(arg>>8), byte(arg))
+	case arg <= math.MaxUint32:
+		return append(b, major|26, byte(arg>>24), byte(arg>>16), byte(arg>>8), byte(arg))
+	}
+	return append(b, major|27,
+		byte(arg>>56), byte(arg>>48), byte(arg>>40), byte(arg>>32),
+		byte(arg>>24), byte(arg>>16), byte(arg>>8), byte(arg))
+}
+
+func (e *encodeState) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf = append(e.buf, cborNull)
+		return nil
+	}
+	t := v.Type()
+	if t.Implements(marshalerType) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			e.buf = append(e.buf, cborNull)
+			return nil
+		}
+		return e.encodeMarshaler(v.Interface().(Marshaler), t)
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(t).Implements(marshalerType) {
+		return e.encodeMarshaler(v.Addr().Interface().(Marshaler), t)
+	}
+
+	switch t {
+	case timeType:
+		// MarshalText rejects years that RFC 3339 cannot represent.
+		s, err := v.Interface().(time.Time).MarshalText()
+		if err != nil {
+			return &UnsupportedValueError{v, err.Error()}
+		}
+		e.buf = appendHead(e.buf, majorTag, tagTimeString)
+		e.buf = appendHead(e.buf, majorText, uint64(len(s)))
+		e.buf = append(e.buf, s...)
+		return nil
+	case bigIntType:
+		n := v.Interface().(big.Int)
+		e.encodeBigInt(&n)
+		return nil
+	case tagType:
+		tag := v.Interface().(Tag)
+		e.buf = appendHead(e.buf, majorTag, tag.Number)
+		return e.encode(reflect.ValueOf(tag.Content))
+	case simpleType:
+		s := Simple(v.Uint())
+		if 24 <= s && s < 32 {
+			return &UnsupportedValueError{v, "reserved simple value " + strconv.Itoa(int(s))}
+		}
+		e.buf = appendHead(e.buf, majorSimple, uint64(s))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, cborTrue)
+		} else {
+			e.buf = append(e.buf, cborFalse)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := v.Int(); n >= 0 {
+			e.buf = appendHead(e.buf, majorUint, uint64(n))
+		} else {
+			e.buf = appendHead(e.buf, majorNegInt, uint64(-1-n))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.buf = appendHead(e.buf, majorUint, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		e.buf = appendFloat(e.buf, v.Float())
+	case reflect.String:
+		s := v.String()
+		if !utf8.ValidString(s) {
+			return &UnsupportedValueError{v, "invalid UTF-8 in string " + strconv.Quote(s)}
+		}
+		e.buf = appendHead(e.buf, majorText, uint64(len(s)))
+		e.buf = append(e.buf, s...)
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf = append(e.buf, cborNull)
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			b := v.Bytes()
+			e.buf = appendHead(e.buf, majorBytes, uint64(len(b)))
+			e.buf = append(e.buf, b...)
+			return nil
+		}
+		return e.cycleCheck(v, struct {
+			ptr uintptr
+			len int
+		}{v.Pointer(), v.Len()}, e.encodeArray)
+	case reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			e.buf = appendHead(e.buf, majorBytes, uint64(v.Len()))
+			n := len(e.buf)
+			e.buf = append(e.buf, make([]byte, v.Len())...)
+			reflect.Copy(reflect.ValueOf(e.buf[n:]), v)
+			return nil
+		}
+		return e.encodeArray(v)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf = append(e.buf, cborNull)
+			return nil
+		}
+		return e.cycleCheck(v, v.Pointer(), e.encodeMap)
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	case reflect.Ptr:
+		if v.IsNil() {
+			e.buf = append(e.buf, cborNull)
+			return nil
+		}
+		return e.cycleCheck(v, v.Interface(), func(v reflect.Value) error {
+			return e.encode(v.Elem())
+		})
+	case reflect.Interface:
+		if v.IsNil() {
+			e.buf = append(e.buf, cborNull)
+			return nil
+		}
+		return e.encode(v.Elem())
+	default:
+		return &UnsupportedTypeError{t}
+	}
+	return nil
+}
+
+// cycleCheck calls f with v, which is identified by ptr, reporting an
+// error instead if v is already being encoded.
+func (e *encodeState) cycleCheck(v reflect.Value, ptr interface{}, f func(reflect.Value) error) error {
+	if e.ptrLevel++; e.ptrLevel > startDetectingCyclesAfter {
+		if e.ptrSeen == nil {
+			e.ptrSeen = make(map[interface{}]struct{})
+		}
+		if _, ok := e.ptrSeen[ptr]; ok {
+			return &UnsupportedValueError{v, fmt.Sprintf("encountered a cycle via %s", v.Type())}
+		}
+		e.ptrSeen[ptr] = struct{}{}
+		defer delete(e.ptrSeen, ptr)
+	}
+	err := f(v)
+	e.ptrLevel--
+	return err
+}
+
+func (e *encodeState) encodeMarshaler(m Marshaler, t reflect.Type) error {
+	b, err := m.MarshalCBOR()
+	if err == nil {
+		c := checker{data: b, maxDepth: math.MaxInt32, maxElements: math.MaxInt32, maxSize: math.MaxInt}
+		var end int
+		if end, err = c.item(0); err == nil && end != len(b) {
+			err = &SyntaxError{"extra data after data item", int64(end)}
+		}
+		if err == errTruncated {
+			err = &SyntaxError{"unexpected end of CBOR input", int64(len(b))}
+		}
+	}
+	if err != nil {
+		return &MarshalerError{t, err}
+	}
+	e.buf = append(e.buf, b...)
+	return nil
+}
+
+func (e *encodeState) encodeBigInt(n *big.Int) {
+	major, tag := byte(majorUint), uint64(tagPosBignum)
+	if n.Sign() < 0 {
+		// A negative n is encoded as -1-n.
+		n = new(big.Int).Not(n)
+		major, tag = majorNegInt, tagNegBignum
+	}
+	if n.IsUint64() {
+		e.buf = appendHead(e.buf, major, n.Uint64())
+		return
+	}
+	b := n.Bytes()
+	e.buf = appendHead(e.buf, majorTag, tag)
+	e.buf = appendHead(e.buf, majorBytes, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// appendFloat appends f as the shortest CBOR float that holds it exactly.
+func appendFloat(b []byte, f float64) []byte {
+	if math.IsNaN(f) {
+		return append(b, 0xf9, 0x7e, 0x00)
+	}
+	f32 := float32(f)
+	if float64(f32) != f && !math.IsInf(f, 0) {
+		bits := math.Float64bits(f)
+		return appendHead8(b, 0xfb, bits)
+	}
+	if h, ok := float16Bits(f32); ok {
+		return append(b, 0xf9, byte(h>>8), byte(h))
+	}
+	bits := math.Float32bits(f32)
+	return append(b, 0xfa, byte(bits>>24), byte(bits>>16), byte(bits>>8), byte(bits))
+}
+
+func appendHead8(b []byte, c byte, x uint64) []byte {
+	return append(b, c,
+		byte(x>>56), byte(x>>48), byte(x>>40), byte(x>>32),
+		byte(x>>24), byte(x>>16), byte(x>>8), byte(x))
+}
+
+// float16Bits returns the IEEE 754 half precision encoding of f and
+// whether it holds f exactly. f must not be NaN.
+func float16Bits(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+	switch {
+	case exp == 0xff:
+		return sign | 0x7c00, true
+	case exp == 0 && mant == 0:
+		return sign, true
+	case exp == 0:
+		// Single precision subnormals are too small for half precision.
+		return 0, false
+	}
+	e := exp - 127
+	switch {
+	case -14 <= e && e <= 15:
+		if mant&0x1fff != 0 {
+			return 0, false
+		}
+		return sign | uint16(e+15)<<10 | uint16(mant>>13), true
+	case -24 <= e && e < -14:
+		// A half precision subnormal is m * 2**-24.
+		m := mant | 0x800000
+		shift := uint(-(e + 1))
+		if m&(1<<shift-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(m>>shift), true
+	}
+	return 0, false
+}
+
+func (e *encodeState) encodeArray(v reflect.Value) error {
+	n := v.Len()
+	e.buf = appendHead(e.buf, majorArray, uint64(n))
+	for i := 0; i < n; i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// An entry is the location in encodeState.buf of an encoded map entry.
+type entry struct {
+	start, keyEnd, end int
+}
+
+func (e *encodeState) encodeMap(v reflect.Value) error {
+	e.buf = appendHead(e.buf, majorMap, uint64(v.Len()))
+	start := len(e.buf)
+	var entries []entry
+	if e.opts.Deterministic {
+		entries = make([]entry, 0, v.Len())
+	}
+	iter := v.MapRange()
+	for iter.Next() {
+		en := entry{start: len(e.buf)}
+		if err := e.encode(iter.Key()); err != nil {
+			return err
+		}
+		en.keyEnd = len(e.buf)
+		if err := e.encode(iter.Value()); err != nil {
+			return err
+		}
+		en.end = len(e.buf)
+		if e.opts.Deterministic {
+			entries = append(entries, en)
+		}
+	}
+	if e.opts.Deterministic {
+		e.sortEntries(start, entries)
+	}
+	return nil
+}
+
+// sortEntries sorts the map entries encoded in e.buf after start by
+// their encoded keys.
+func (e *encodeState) sortEntries(start int, entries []entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		return bytes.Compare(e.buf[a.start:a.keyEnd], e.buf[b.start:b.keyEnd]) < 0
+	})
+	encoded := append([]byte(nil), e.buf[start:]...)
+	e.buf = e.buf[:start]
+	for _, en := range entries {
+		e.buf = append(e.buf, encoded[en.start-start:en.end-start]...)
+	}
+}
+
+func (e *encodeState) encodeStruct(v reflect.Value) error {
+	fields := cachedTypeFields(v.Type())
+	if fields.toArray {
+		e.buf = appendHead(e.buf, majorArray, uint64(len(fields.list)))
+		for i := range fields.list {
+			fv, _ := fieldByIndex(v, fields.list[i].index, false)
+			if err := e.encode(fv); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	order := fields.list
+	if e.opts.Deterministic {
+		order = fields.sorted
+	}
+	// The number of members is only known once empty fields have been
+	// found, so the head is written afterwards.
+	var n uint64
+	start := len(e.buf)
+	for i := range order {
+		f := &order[i]
+		fv, ok := fieldByIndex(v, f.index, false)
+		if !ok || f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		e.buf = append(e.buf, f.key...)
+		if err := e.encode(fv); err != nil {
+			return err
+		}
+		n++
+	}
+	head := appendHead(nil, majorMap, n)
+	e.buf = append(e.buf, head...)
+	copy(e.buf[start+len(head):], e.buf[start:])
+	copy(e.buf[start:], head)
+	return nil
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// fieldByIndex returns the nested field of the struct v with the given
+// index. If alloc is set, nil embedded struct pointers are allocated on
+// the way; otherwise fieldByIndex reports false on reaching one.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// A field represents a single field found in a struct.
+type field struct {
+	name      string
+	key       []byte // the encoded map key
+	tag       bool
+	index     []int
+	typ       reflect.Type
+	omitEmpty bool
+	keyAsInt  bool
+	intKey    int64
+}
+
+type structFields struct {
+	list    []field // in declaration order
+	sorted  []field // in order of encoded keys
+	toArray bool
+
+	// For decoding, the indexes in list of the fields with text keys,
+	// by name and by name folded with foldName, and with integer keys.
+	byName       map[string]int
+	byFoldedName map[string]int
+	byInt        map[int64]int
+}
+
+func isValidTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+			// Backslash and quote chars are reserved, but
+			// otherwise any punctuation chars are allowed
+			// in a tag name.
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
+
+// typeFields returns a list of fields that CBOR should recognize for the
+// given type. The algorithm is breadth-first search over the set of
+// structs to include - the top struct and then any reachable anonymous
+// structs, as in encoding/json.
+func typeFields(t reflect.Type) structFields {
+	// Anonymous fields to explore at the current level and the next.
+	current := []field{}
+	next := []field{{typ: t}}
+
+	// Count of queued names for current level and the next.
+	var count, nextCount map[reflect.Type]int
+
+	// Types already visited at an earlier level.
+	visited := map[reflect.Type]bool{}
+
+	// Fields found.
+	var fields []field
+	var toArray bool
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+
+			// Scan f.typ for fields to include.
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				tag := sf.Tag.Get("cbor")
+				if sf.Name == "_" {
+					if _, opts := parseTag(tag); opts.Contains("toarray") && len(f.index) == 0 {
+						toArray = true
+					}
+					continue
+				}
+				if sf.Anonymous {
+					t := sf.Type
+					if t.Kind() == reflect.Ptr {
+						t = t.Elem()
+					}
+					if !sf.IsExported() && t.Kind() != reflect.Struct {
+						// Ignore embedded fields of unexported non-struct types.
+						continue
+					}
+					// Do not ignore embedded fields of unexported struct types
+					// since they may have exported fields.
+				} else if !sf.IsExported() {
+					// Ignore unexported non-embedded fields.
+					continue
+				}
+				if tag == "-" {
+					continue
+				}
+				name, opts := parseTag(tag)
+				if !isValidTag(name) {
+					name = ""
+				}
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					// Follow pointer.
+					ft = ft.Elem()
+				}
+
+				// Record found field and index sequence.
+				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct || ft == timeType || ft == bigIntType || ft == tagType {
+					tagged := name != ""
+					if name == "" {
+						name = sf.Name
+					}
+					field := field{
+						name:      name,
+						tag:       tagged,
+						index:     index,
+						typ:       ft,
+						omitEmpty: opts.Contains("omitempty"),
+					}
+					if opts.Contains("keyasint") {
+						n, err := strconv.ParseInt(name, 10, 64)
+						field.keyAsInt, field.intKey = err == nil, n
+					}
+					if field.keyAsInt {
+						if field.intKey >= 0 {
+							field.key = appendHead(nil, majorUint, uint64(field.intKey))
+						} else {
+							field.key = appendHead(nil, majorNegInt, uint64(-1-field.intKey))
+						}
+					} else {
+						field.key = appendHead(nil, majorText, uint64(len(name)))
+						field.key = append(field.key, name...)
+					}
+
+					fields = append(fields, field)
+					if count[f.typ] > 1 {
+						// If there were multiple instances, add a second,
+						// so that the annihilation code will see a duplicate.
+						// It only cares about the distinction between 1 or 2,
+						// so don't bother generating any more copies.
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+
+				// Record new anonymous struct to explore in next round.
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, field{name: ft.Name(), index: index, typ: ft})
+				}
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		x := fields
+		// sort field by key, breaking ties with depth, then
+		// breaking ties with "name came from cbor tag", then
+		// breaking ties with index sequence.
+		if c := bytes.Compare(x[i].key, x[j].key); c != 0 {
+			return c < 0
+		}
+		if len(x[i].index) != len(x[j].index) {
+			return len(x[i].index) < len(x[j].index)
+		}
+		if x[i].tag != x[j].tag {
+			return x[i].tag
+		}
+		return byIndex(x).Less(i, j)
+	})
+
+	// Delete all fields that are hidden by the Go rules for embedded fields,
+	// except that fields with CBOR tags are promoted.
+
+	// The fields are sorted in primary order of key, secondary order
+	// of field index length. Loop over keys; for each key, delete
+	// hidden fields by choosing the one dominant field that survives.
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		// One iteration per key.
+		// Find the sequence of fields with the key of this first field.
+		fi := fields[i]
+		for advance = 1; i+advance < len(fields); advance++ {
+			fj := fields[i+advance]
+			if !bytes.Equal(fj.key, fi.key) {
+				break
+			}
+		}
+		if advance == 1 { // Only one field with this key
+			out = append(out, fi)
+			continue
+		}
+		dominant, ok := dominantField(fields[i : i+advance])
+		if ok {
+			out = append(out, dominant)
+		}
+	}
+	sorted := append([]field(nil), out...)
+
+	fields = out
+	sort.Sort(byIndex(fields))
+
+	sf := structFields{
+		list:         fields,
+		sorted:       sorted,
+		toArray:      toArray,
+		byName:       make(map[string]int),
+		byFoldedName: make(map[string]int),
+		byInt:        make(map[int64]int),
+	}
+	for i, f := range fields {
+		if f.keyAsInt {
+			sf.byInt[f.intKey] = i
+			continue
+		}
+		sf.byName[f.name] = i
+		folded := string(foldName([]byte(f.name)))
+		if _, ok := sf.byFoldedName[folded]; !ok {
+			sf.byFoldedName[folded] = i
+		}
+	}
+	return sf
+}
+
+// dominantField looks through the fields, all of which are known to
+// have the same key, to find the single field that dominates the
+// others using Go's embedding rules, modified by the presence of
+// CBOR tags. If there are multiple top-level fields, the boolean
+// will be false: This condition is an error in Go and we skip all
+// the fields.
+func dominantField(fields []field) (field, bool) {
+	// The fields are sorted in increasing index-length order, then by presence of tag.
+	// That means that the first field is the dominant one. We need only check
+	// for error cases: two fields at top level, either both tagged or neither tagged.
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tag == fields[1].tag {
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+// byIndex sorts field by index sequence.
+type byIndex []field
+
+func (x byIndex) Len() int { return len(x) }
+
+func (x byIndex) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
+
+func (x byIndex) Less(i, j int) bool {
+	for k, xik := range x[i].index {
+		if k >= len(x[j].index) {
+			return false
+		}
+		if xik != x[j].index[k] {
+			return xik < x[j].index[k]
+		}
+	}
+	return len(x[i].index) < len(x[j].index)
+}
+
+var fieldCache sync.Map // map[reflect.Type]*structFields
+
+// cachedTypeFields is like typeFields but uses a cache to avoid repeated work.
+func cachedTypeFields(t reflect.Type) *structFields {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.(*structFields)
+	}
+	f := typeFields(t)
+	fi, _ := fieldCache.LoadOrStore(t, &f)
+	return fi.(*structFields)
+}
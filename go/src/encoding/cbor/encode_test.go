@@ -0,0 +1,197 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalRFCExamples(t *testing.T) {
+	for _, tt := range rfcExamples {
+		if !tt.canonical {
+			continue
+		}
+		got, err := Marshal(tt.value)
+		if err != nil {
+			t.Errorf("Marshal(%#v): %v", tt.value, err)
+			continue
+		}
+		if h := hex.EncodeToString(got); h != tt.hex {
+			t.Errorf("Marshal(%#v) = %s, want %s", tt.value, h, tt.hex)
+		}
+	}
+}
+
+var floatTests = []struct {
+	in  float64
+	hex string
+}{
+	{0.5, "f93800"},
+	{-2, "f9c000"},
+	{1.0 / 3, "fb3fd5555555555555"},
+	{float64(float32(1.0 / 3)), "fa3eaaaaab"},
+	{65536, "fa47800000"},
+	{math.Ldexp(1, -14), "f90400"},
+	{math.Ldexp(3, -24), "f90003"},
+	{math.Ldexp(1, -25), "fa33000000"},
+	{math.Ldexp(1, -149), "fa00000001"},
+	{math.SmallestNonzeroFloat64, "fb0000000000000001"},
+	{math.MaxFloat64, "fb7fefffffffffffff"},
+}
+
+func TestMarshalFloat(t *testing.T) {
+	for _, tt := range floatTests {
+		got, err := Marshal(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h := hex.EncodeToString(got); h != tt.hex {
+			t.Errorf("Marshal(%g) = %s, want %s", tt.in, h, tt.hex)
+		}
+		var f float64
+		if err := Unmarshal(got, &f); err != nil || f != tt.in {
+			t.Errorf("Unmarshal(%s) = %g, %v, want %g", tt.hex, f, err, tt.in)
+		}
+	}
+}
+
+type Embedded struct {
+	X int
+	Y int `cbor:"y,omitempty"`
+}
+
+type marshalStruct struct {
+	Embedded
+	Kty     int    `cbor:"1,keyasint"`
+	Crv     int    `cbor:"-1,keyasint"`
+	Name    string `cbor:"name"`
+	Skip    int    `cbor:"-"`
+	Empty   []int  `cbor:",omitempty"`
+	Long    int
+	private int
+}
+
+type arrayStruct struct {
+	_ struct{} `cbor:",toarray"`
+	A uint
+	B string
+	C []byte
+}
+
+func TestMarshalStruct(t *testing.T) {
+	v := marshalStruct{Embedded: Embedded{X: 1}, Kty: 2, Crv: 1, Name: "n", Skip: 9, Long: 3, private: 4}
+	got, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys in deterministic order: 1, -1, "X", "Long", "name".
+	want := "a5" + "0102" + "2001" + "615801" + "644c6f6e6703" + "646e616d65616e"
+	if h := hex.EncodeToString(got); h != want {
+		t.Errorf("Marshal = %s, want %s", h, want)
+	}
+	var back marshalStruct
+	if err := Unmarshal(got, &back); err != nil {
+		t.Fatal(err)
+	}
+	v.Skip, v.private = 0, 0
+	if !reflect.DeepEqual(back, v) {
+		t.Errorf("round trip = %+v, want %+v", back, v)
+	}
+
+	got, err = MarshalOptions{}.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys in declaration order.
+	want = "a5" + "615801" + "0102" + "2001" + "646e616d65616e" + "644c6f6e6703"
+	if h := hex.EncodeToString(got); h != want {
+		t.Errorf("MarshalOptions{}.Marshal = %s, want %s", h, want)
+	}
+}
+
+func TestMarshalToArray(t *testing.T) {
+	v := arrayStruct{A: 1, B: "b", C: []byte{2}}
+	got, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, want := hex.EncodeToString(got), "8301616241"+"02"; h != want {
+		t.Errorf("Marshal = %s, want %s", h, want)
+	}
+	var back arrayStruct
+	if err := Unmarshal(got, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.A != 1 || back.B != "b" || !bytes.Equal(back.C, []byte{2}) {
+		t.Errorf("round trip = %+v", back)
+	}
+}
+
+func TestMarshalDeterministicMap(t *testing.T) {
+	m := map[interface{}]int{"aa": 1, "b": 2, 10: 3, 100: 4, -1: 5, false: 6}
+	want := "a6" + "0a03" + "186404" + "2005" + "616202" + "62616101" + "f406"
+	for i := 0; i < 10; i++ {
+		got, err := Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h := hex.EncodeToString(got); h != want {
+			t.Fatalf("Marshal = %s, want %s", h, want)
+		}
+	}
+}
+
+type badMarshaler struct{}
+
+func (badMarshaler) MarshalCBOR() ([]byte, error) { return []byte{0x82, 0x01}, nil }
+
+type errMarshaler struct{}
+
+func (errMarshaler) MarshalCBOR() ([]byte, error) { return nil, errors.New("boom") }
+
+type cyclic struct {
+	Next *cyclic
+}
+
+func TestMarshalErrors(t *testing.T) {
+	c := &cyclic{}
+	c.Next = c
+	for _, tt := range []struct {
+		v   interface{}
+		msg string
+	}{
+		{make(chan int), "unsupported type"},
+		{complex(1, 2), "unsupported type"},
+		{"\xff", "invalid UTF-8"},
+		{Simple(24), "reserved simple value"},
+		{badMarshaler{}, "unexpected end"},
+		{errMarshaler{}, "boom"},
+		{c, "encountered a cycle"},
+	} {
+		_, err := Marshal(tt.v)
+		if err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("Marshal(%T) = %v, want error containing %q", tt.v, err, tt.msg)
+		}
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, v := range []interface{}{1, "a", []int{1, 2}, RawMessage{0xf5}} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if h, want := hex.EncodeToString(buf.Bytes()), "01616182"+"0102"+"f5"; h != want {
+		t.Errorf("Encoder output = %s, want %s", h, want)
+	}
+}
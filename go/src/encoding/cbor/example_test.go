@@ -0,0 +1,41 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cbor_test
+
+import (
+	"encoding/cbor"
+	"fmt"
+	"log"
+)
+
+func ExampleMarshal() {
+	type Key struct {
+		Kty int    `cbor:"1,keyasint"`
+		Kid []byte `cbor:"2,keyasint"`
+		Crv int    `cbor:"-1,keyasint"`
+	}
+	b, err := cbor.Marshal(Key{Kty: 2, Kid: []byte("k1"), Crv: 1})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%x\n", b)
+	// Output:
+	// a3010202426b312001
+}
+
+func ExampleUnmarshal() {
+	// {"name": "gopher", "tags": ["a", "b"]}
+	data := []byte("\xa2\x64name\x66gopher\x64tags\x82\x61a\x61b")
+	var v struct {
+		Name string
+		Tags []string
+	}
+	if err := cbor.Unmarshal(data, &v); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%+v\n", v)
+	// Output:
+	// {Name:gopher Tags:[a b]}
+}
@@ -0,0 +1,193 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// A Decoder reads and decodes CBOR data items from an input stream.
+type Decoder struct {
+	r    io.Reader
+	buf  []byte
+	scan int // start of unread data in buf
+	err  error
+	opts UnmarshalOptions
+
+	check    checker // state of checking the data item at scan
+	checking bool    // whether check has begun checking it
+}
+
+// NewDecoder returns a new decoder that reads from r.
+//
+// The decoder introduces its own buffering and may
+// read data from r beyond the CBOR data items requested.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// SetOptions sets the options used to decode subsequent data items.
+func (dec *Decoder) SetOptions(o UnmarshalOptions) { dec.opts = o }
+
+// Decode reads the next CBOR data item from its
+// input and stores it in the value pointed to by v.
+//
+// See the documentation for Unmarshal for details about
+// the conversion of CBOR into a Go value.
+func (dec *Decoder) Decode(v interface{}) error {
+	if dec.err != nil {
+		return dec.err
+	}
+	n, err := dec.readItem()
+	if err != nil {
+		return err
+	}
+	data := dec.buf[dec.scan : dec.scan+n]
+	// Don't save err from unmarshal into dec.err:
+	// the connection is still usable since we read a complete item
+	// from it before the error happened.
+	err = dec.opts.Unmarshal(data, v)
+	dec.scan += n
+	return err
+}
+
+// Buffered returns a reader of the data remaining in the Decoder's
+// buffer. The reader is valid until the next call to Decode.
+func (dec *Decoder) Buffered() io.Reader {
+	return bytes.NewReader(dec.buf[dec.scan:])
+}
+
+// readItem reads a CBOR data item into dec.buf.
+// It returns the length of the encoding.
+//
+// When the data item is not all in dec.buf yet, the checker keeps its
+// place, so that after a refill it checks only what was read.
+func (dec *Decoder) readItem() (int, error) {
+	for {
+		if dec.scan < len(dec.buf) {
+			var n int
+			var err error
+			if dec.checking {
+				dec.check.data = dec.buf[dec.scan:]
+				n, err = dec.check.resume()
+			} else {
+				stack := dec.check.stack
+				dec.check = dec.opts.checker(dec.buf[dec.scan:])
+				dec.check.stack = stack
+				dec.checking = true
+				n, err = dec.check.item(0)
+			}
+			if err == nil {
+				dec.checking = false
+				return n, nil
+			}
+			if err != errTruncated {
+				dec.checking = false
+				if serr, ok := err.(*SyntaxError); ok {
+					serr.Offset += int64(dec.scan)
+				}
+				dec.err = err
+				return 0, err
+			}
+		}
+
+		// Did the last read have an error?
+		// Delayed until now to allow buffer scan.
+		if dec.err != nil {
+			if dec.err == io.EOF && dec.scan < len(dec.buf) {
+				dec.err = io.ErrUnexpectedEOF
+			}
+			return 0, dec.err
+		}
+
+		dec.err = dec.refill()
+	}
+}
+
+func (dec *Decoder) refill() error {
+	// Make room to read more into the buffer.
+	// First slide down data already consumed.
+	if dec.scan > 0 {
+		n := copy(dec.buf, dec.buf[dec.scan:])
+		dec.buf = dec.buf[:n]
+		dec.scan = 0
+	}
+
+	// Grow buffer if not large enough.
+	const minRead = 512
+	if cap(dec.buf)-len(dec.buf) < minRead {
+		newBuf := make([]byte, len(dec.buf), 2*cap(dec.buf)+minRead)
+		copy(newBuf, dec.buf)
+		dec.buf = newBuf
+	}
+
+	// Read. Delay error for next iteration (after scan).
+	n, err := dec.r.Read(dec.buf[len(dec.buf):cap(dec.buf)])
+	dec.buf = dec.buf[0 : len(dec.buf)+n]
+
+	return err
+}
+
+// An Encoder writes CBOR data items to an output stream.
+type Encoder struct {
+	w    io.Writer
+	err  error
+	opts MarshalOptions
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, opts: MarshalOptions{Deterministic: true}}
+}
+
+// SetOptions sets the options used to encode subsequent values.
+func (enc *Encoder) SetOptions(o MarshalOptions) { enc.opts = o }
+
+// Encode writes the CBOR encoding of v to the stream.
+// Unlike encoding/json, no separator is written between data items,
+// since a CBOR data item is self-delimiting.
+//
+// See the documentation for Marshal for details about the
+// conversion of Go values to CBOR.
+func (enc *Encoder) Encode(v interface{}) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	b, err := enc.opts.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err = enc.w.Write(b); err != nil {
+		enc.err = err
+	}
+	return err
+}
+
+// RawMessage is a raw encoded CBOR data item.
+// It implements Marshaler and Unmarshaler and can
+// be used to delay CBOR decoding or precompute a CBOR encoding.
+type RawMessage []byte
+
+// MarshalCBOR returns m as the CBOR encoding of m.
+func (m RawMessage) MarshalCBOR() ([]byte, error) {
+	if m == nil {
+		return []byte{cborNull}, nil
+	}
+	return m, nil
+}
+
+// UnmarshalCBOR sets *m to a copy of data.
+func (m *RawMessage) UnmarshalCBOR(data []byte) error {
+	if m == nil {
+		return errors.New("cbor.RawMessage: UnmarshalCBOR on nil pointer")
+	}
+	*m = append((*m)[0:0], data...)
+	return nil
+}
+
+var _ Marshaler = (*RawMessage)(nil)
+var _ Unmarshaler = (*RawMessage)(nil)
@@ -0,0 +1,43 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cbor
+
+import "strconv"
+
+// A Tag is a CBOR tag (major type 6): a data item Content marked with
+// the tag Number, which gives it additional semantics (RFC 8949
+// section 3.4). Unmarshal stores tags it does not interpret itself in
+// an interface{} value as a Tag.
+type Tag struct {
+	Number  uint64
+	Content interface{}
+}
+
+// A Simple is a CBOR simple value (major type 7) other than false, true,
+// null, undefined and the floating point numbers. Simple values 24
+// through 31 are reserved and cannot be encoded.
+type Simple uint8
+
+// Simple values with a meaning defined by RFC 8949.
+const (
+	SimpleFalse     Simple = 20
+	SimpleTrue      Simple = 21
+	SimpleNull      Simple = 22
+	SimpleUndefined Simple = 23
+)
+
+func (s Simple) String() string {
+	switch s {
+	case SimpleFalse:
+		return "false"
+	case SimpleTrue:
+		return "true"
+	case SimpleNull:
+		return "null"
+	case SimpleUndefined:
+		return "undefined"
+	}
+	return "simple(" + strconv.Itoa(int(s)) + ")"
+}
@@ -129,3 +129,44 @@ func ExampleWriter_WriteAll() {
 	// Ken,Thompson,ken
 	// Robert,Griesemer,gri
 }
+
+func ExampleUnmarshal() {
+	in := `first_name,last_name,username,commits
+"Rob","Pike",rob,2
+Ken,Thompson,ken,
+`
+	type User struct {
+		FirstName string `csv:"first_name"`
+		LastName  string `csv:"last_name"`
+		Username  string `csv:"username"`
+		Commits   int    `csv:"commits"`
+	}
+	var users []User
+	if err := csv.Unmarshal(csv.NewReader(strings.NewReader(in)), &users); err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%+v\n", users)
+	// Output:
+	// [{FirstName:Rob LastName:Pike Username:rob Commits:2} {FirstName:Ken LastName:Thompson Username:ken Commits:0}]
+}
+
+func ExampleMarshal() {
+	type User struct {
+		FirstName string `csv:"first_name"`
+		LastName  string `csv:"last_name"`
+		Username  string `csv:"username"`
+	}
+	users := []User{
+		{"Rob", "Pike", "rob"},
+		{"Robert", "Griesemer", "gri"},
+	}
+
+	if err := csv.Marshal(csv.NewWriter(os.Stdout), users); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// first_name,last_name,username
+	// Rob,Pike,rob
+	// Robert,Griesemer,gri
+}
@@ -0,0 +1,419 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// An UnmarshalTypeError describes a CSV field that could not be
+// converted to the type of the struct field it is stored in.
+// Unmarshal returns it as the Err of a ParseError giving its position.
+type UnmarshalTypeError struct {
+	Value  string       // the field value
+	Column string       // the header name of the field's column
+	Type   reflect.Type // type of the struct field
+	Err    error        // the conversion error, if any
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	s := "cannot unmarshal " + strconv.Quote(e.Value) + " in column " + strconv.Quote(e.Column) + " into Go value of type " + e.Type.String()
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+func (e *UnmarshalTypeError) Unwrap() error { return e.Err }
+
+// An UnsupportedTypeError is returned by Marshal and Unmarshal when
+// they are given a value, or a struct with a field, whose type they
+// cannot convert.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "csv: unsupported type: " + e.Type.String()
+}
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Unmarshal reads all the remaining records from r and appends them to
+// the slice pointed to by v, whose elements must be structs or pointers
+// to structs. The first record read is the header: each later field is
+// stored in the struct field named by the header of its column.
+//
+// By default a struct field is named by its Go name. The "csv" key in
+// the struct field's tag value gives another name, and a field with
+// tag "-" is ignored. A header is matched to a field name exactly if
+// possible, and otherwise without regard to case. Columns that match
+// no field are ignored, and fields that match no column are left
+// zero. The fields of an anonymous struct field without a name in its
+// tag are treated as if they were in the outer struct.
+//
+// A field is stored in a struct field of a type implementing
+// encoding.TextUnmarshaler by calling its UnmarshalText method, and in
+// a string, boolean, integer or floating point struct field by
+// converting it with the strconv package. An empty field leaves any
+// struct field that is not a string zero, without calling UnmarshalText;
+// in particular a pointer struct field stays nil. Otherwise a pointer
+// struct field is set to a new value holding the field.
+//
+// If a field cannot be converted, Unmarshal returns a ParseError with
+// the position of the field as reported by FieldPos and an Err of type
+// *UnmarshalTypeError. Records read before the error are kept in v.
+// Reaching the end of r is not treated as an error.
+func Unmarshal(r *Reader, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return errors.New("csv: Unmarshal requires a non-nil pointer to a slice")
+	}
+	slice := rv.Elem()
+	elemType := slice.Type().Elem()
+	t := elemType
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return &UnsupportedTypeError{elemType}
+	}
+	fields, err := structFields(t, false)
+	if err != nil {
+		return err
+	}
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	columns := make([]*structField, len(header))
+	for i, name := range header {
+		if i == 0 {
+			// Spreadsheet programs often start files with a byte order mark.
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[i] = fieldByName(fields, name)
+	}
+	// header is owned by r if ReuseRecord is set.
+	header = append([]string(nil), header...)
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		elem := reflect.New(t).Elem()
+		for i, f := range columns {
+			if f == nil || i >= len(record) {
+				continue
+			}
+			fv, _ := fieldByIndex(elem, f.index, true)
+			if err := setField(fv, record[i]); err != nil {
+				startLine, _ := r.FieldPos(0)
+				line, column := r.FieldPos(i)
+				return &ParseError{
+					StartLine: startLine,
+					Line:      line,
+					Column:    column,
+					Err:       &UnmarshalTypeError{Value: record[i], Column: header[i], Type: fv.Type(), Err: err},
+				}
+			}
+		}
+		if elemType.Kind() == reflect.Ptr {
+			elem = elem.Addr()
+		}
+		slice.Set(reflect.Append(slice, elem))
+	}
+}
+
+// Marshal writes v, a slice or array of structs or pointers to
+// structs, to w as a header record followed by one record for each
+// element, and then calls Flush, returning any error from the Flush.
+//
+// The header holds the names of the struct fields, as described for
+// Unmarshal, in the order they are declared. A field holding a type
+// implementing encoding.TextMarshaler is written using its MarshalText
+// method, and strings, booleans, integers and floating point numbers
+// are formatted with the strconv package. Nil pointers, including nil
+// elements of v, are written as empty fields.
+func Marshal(w *Writer, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return errors.New("csv: Marshal requires a slice or array")
+	}
+	elemType := rv.Type().Elem()
+	t := elemType
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return &UnsupportedTypeError{elemType}
+	}
+	fields, err := structFields(t, true)
+	if err != nil {
+		return err
+	}
+
+	record := make([]string, len(fields))
+	for i, f := range fields {
+		record[i] = f.name
+	}
+	if err := w.Write(record); err != nil {
+		return err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		elem := rv.Index(i)
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		} else if !elem.CanAddr() {
+			// Make pointer methods of the fields available.
+			e := reflect.New(t).Elem()
+			e.Set(elem)
+			elem = e
+		}
+		for j, f := range fields {
+			record[j] = ""
+			if !elem.IsValid() {
+				continue
+			}
+			if fv, ok := fieldByIndex(elem, f.index, false); ok {
+				if record[j], err = formatField(fv); err != nil {
+					return err
+				}
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// A structField is a struct field that maps to a CSV column.
+type structField struct {
+	name  string
+	index []int
+}
+
+// structFields returns the fields of the struct type t that map to CSV
+// columns, in the order they are declared. Their types must be
+// convertible in the direction given by marshal.
+func structFields(t reflect.Type, marshal bool) ([]structField, error) {
+	var fields []structField
+	seen := make(map[string]bool)
+	var walk func(t reflect.Type, index []int) error
+	walk = func(t reflect.Type, index []int) error {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("csv")
+			if tag == "-" {
+				continue
+			}
+			name := tag
+			if i := strings.Index(name, ","); i >= 0 {
+				name = name[:i]
+			}
+			ft := sf.Type
+			if sf.Anonymous && name == "" {
+				st := ft
+				if st.Kind() == reflect.Ptr {
+					st = st.Elem()
+				}
+				if st.Kind() == reflect.Struct && !isText(ft, marshal) {
+					if ft.Kind() == reflect.Ptr && !sf.IsExported() {
+						// Unexported pointers cannot be allocated.
+						continue
+					}
+					if err := walk(st, append(index[:len(index):len(index)], i)); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			if !isSupported(ft, marshal) {
+				return &UnsupportedTypeError{ft}
+			}
+			if seen[name] {
+				return fmt.Errorf("csv: duplicate column name %q in type %s", name, t)
+			}
+			seen[name] = true
+			fields = append(fields, structField{
+				name:  name,
+				index: append(index[:len(index):len(index)], i),
+			})
+		}
+		return nil
+	}
+	if err := walk(t, nil); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// fieldByName returns the field of fields named name, preferring an
+// exact match to one ignoring case, or nil if there is none.
+func fieldByName(fields []structField, name string) *structField {
+	var fold *structField
+	for i := range fields {
+		f := &fields[i]
+		if f.name == name {
+			return f
+		}
+		if fold == nil && strings.EqualFold(f.name, name) {
+			fold = f
+		}
+	}
+	return fold
+}
+
+// fieldByIndex returns the nested field of the struct v with the given
+// index. If alloc is set, nil embedded struct pointers are allocated on
+// the way; otherwise fieldByIndex reports false on reaching one.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isText reports whether values of type t are converted by their
+// MarshalText method, if marshal is set, or UnmarshalText method.
+func isText(t reflect.Type, marshal bool) bool {
+	if marshal {
+		return t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)
+	}
+	return reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// isSupported reports whether values of type t can be converted in the
+// direction given by marshal.
+func isSupported(t reflect.Type, marshal bool) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if isText(t, marshal) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// setField stores the CSV field s in v.
+func setField(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if s == "" {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	if s == "" && v.Kind() != reflect.String {
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	}
+	return nil
+}
+
+// formatField returns v formatted as a CSV field.
+func formatField(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if v.CanAddr() {
+		if m, ok := v.Addr().Interface().(encoding.TextMarshaler); ok {
+			b, err := m.MarshalText()
+			return string(b), err
+		}
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	return "", &UnsupportedTypeError{v.Type()}
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csv
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Base struct {
+	ID int `csv:"id"`
+}
+
+type Level int
+
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(strings.Repeat("*", int(l))), nil
+}
+
+func (l *Level) UnmarshalText(b []byte) error {
+	if strings.Trim(string(b), "*") != "" {
+		return errors.New("not a level")
+	}
+	*l = Level(len(b))
+	return nil
+}
+
+type Person struct {
+	Base
+	Name    string    `csv:"name"`
+	Age     uint8     `csv:"age"`
+	Score   float64   `csv:"score"`
+	Active  bool      `csv:"active"`
+	Level   Level     `csv:"level"`
+	Born    time.Time `csv:"born"`
+	Left    *time.Time
+	Ignored string `csv:"-"`
+	private int
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestUnmarshal(t *testing.T) {
+	const in = "\ufeffid,name,extra,AGE,score,active,level,born,left\n" +
+		"1,Ann,x,30,1.5,true,**,1990-01-02T00:00:00Z,\n" +
+		"2,\"Bob, Jr.\",,,,,,2000-03-04T00:00:00Z,2020-05-06T00:00:00Z\n"
+	var got []Person
+	if err := Unmarshal(NewReader(strings.NewReader(in)), &got); err != nil {
+		t.Fatal(err)
+	}
+	left := date(2020, 5, 6)
+	want := []Person{
+		{Base: Base{1}, Name: "Ann", Age: 30, Score: 1.5, Active: true, Level: 2, Born: date(1990, 1, 2)},
+		{Base: Base{2}, Name: "Bob, Jr.", Born: date(2000, 3, 4), Left: &left},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	var ptrs []*Base
+	if err := Unmarshal(NewReader(strings.NewReader("id\n7\n")), &ptrs); err != nil {
+		t.Fatal(err)
+	}
+	if len(ptrs) != 1 || ptrs[0].ID != 7 {
+		t.Errorf("Unmarshal into []*Base = %v", ptrs)
+	}
+}
+
+func TestUnmarshalError(t *testing.T) {
+	for _, tt := range []struct {
+		in           string
+		value        string
+		line, column int
+		err          error
+	}{
+		{"id,age\n1,2\n3,300\n", "300", 3, 3, strconv.ErrRange},
+		{"name,\"level\"\n\"a\nb\",\"x\"\n", "x", 3, 4, nil},
+		{"id\n1x\n", "1x", 2, 1, strconv.ErrSyntax},
+	} {
+		var got []Person
+		err := Unmarshal(NewReader(strings.NewReader(tt.in)), &got)
+		var pe *ParseError
+		var ue *UnmarshalTypeError
+		if !errors.As(err, &pe) || !errors.As(err, &ue) {
+			t.Errorf("Unmarshal(%q): got error %v, want *UnmarshalTypeError in *ParseError", tt.in, err)
+			continue
+		}
+		if pe.Line != tt.line || pe.Column != tt.column || ue.Value != tt.value {
+			t.Errorf("Unmarshal(%q): got error at %d:%d for %q, want %d:%d for %q", tt.in, pe.Line, pe.Column, ue.Value, tt.line, tt.column, tt.value)
+		}
+		if tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("Unmarshal(%q): got error %v, want %v", tt.in, err, tt.err)
+		}
+	}
+
+	var got []Person
+	err := Unmarshal(NewReader(strings.NewReader("id,age\n1,2\n3\n")), &got)
+	if !errors.Is(err, ErrFieldCount) || len(got) != 1 {
+		t.Errorf("Unmarshal with short record: got %v, %v", got, err)
+	}
+	var bad []struct{ C chan int }
+	if err := Unmarshal(NewReader(strings.NewReader("C\n")), &bad); !errors.As(err, new(*UnsupportedTypeError)) {
+		t.Errorf("Unmarshal into chan field: got error %v", err)
+	}
+	var dup []struct {
+		A int `csv:"x"`
+		B int `csv:"x"`
+	}
+	if err := Unmarshal(NewReader(strings.NewReader("x\n")), &dup); err == nil {
+		t.Error("Unmarshal with duplicate column names: no error")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	left := date(2020, 5, 6)
+	in := []*Person{
+		{Base: Base{1}, Name: "Ann", Age: 30, Score: 1.5, Active: true, Level: 2, Born: date(1990, 1, 2)},
+		nil,
+		{Base: Base{2}, Name: "Bob, Jr.", Born: date(2000, 3, 4), Left: &left, Ignored: "x"},
+	}
+	var b strings.Builder
+	if err := Marshal(NewWriter(&b), in); err != nil {
+		t.Fatal(err)
+	}
+	const want = "id,name,age,score,active,level,born,Left\n" +
+		"1,Ann,30,1.5,true,**,1990-01-02T00:00:00Z,\n" +
+		",,,,,,,\n" +
+		"2,\"Bob, Jr.\",0,0,false,,2000-03-04T00:00:00Z,2020-05-06T00:00:00Z\n"
+	if b.String() != want {
+		t.Errorf("Marshal:\ngot  %q\nwant %q", b.String(), want)
+	}
+
+	var out []Person
+	if err := Unmarshal(NewReader(strings.NewReader(b.String())), &out); err != nil {
+		t.Fatal(err)
+	}
+	in[2].Ignored = ""
+	if want := []Person{*in[0], {}, *in[2]}; !reflect.DeepEqual(out, want) {
+		t.Errorf("round trip:\ngot  %+v\nwant %+v", out, want)
+	}
+
+	if err := Marshal(NewWriter(&b), [1]struct{ F func() }{}); !errors.As(err, new(*UnsupportedTypeError)) {
+		t.Errorf("Marshal of func field: got error %v", err)
+	}
+}
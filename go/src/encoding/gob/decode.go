@@ -420,7 +420,8 @@ func ignoreUint8Array(i *decInstr, state *decoderState, value reflect.Value) {
 // decoder. It is executed with random access according to field number.
 type decEngine struct {
 	instr    []decInstr
-	numInstr int // the number of active instructions
+	numInstr int         // the number of active instructions
+	fields   *FieldError // fields that differ between the wire and local structs, if any
 }
 
 // decodeSingle decodes a top-level value that is not a struct and stores it in value.
@@ -443,6 +444,9 @@ func (dec *Decoder) decodeSingle(engine *decEngine, value reflect.Value) {
 // This state cannot arise for decodeSingle, which is called directly
 // from the user's value, not from the innards of an engine.
 func (dec *Decoder) decodeStruct(engine *decEngine, value reflect.Value) {
+	if engine.fields != nil && dec.reportFields && dec.fieldErr == nil {
+		dec.fieldErr = engine.fields
+	}
 	state := dec.newDecoderState(&dec.buf)
 	defer dec.freeDecoderState(state)
 	state.fieldnum = -1
@@ -1129,6 +1133,9 @@ func (dec *Decoder) compileDec(remoteId typeId, ut *userTypeInfo) (engine *decEn
 		engine.instr[fieldnum] = decInstr{*op, fieldnum, localField.Index, ovfl}
 		engine.numInstr++
 	}
+	if srt != emptyStructType {
+		engine.fields = fieldError(wireStruct, srt)
+	}
 	return
 }
 
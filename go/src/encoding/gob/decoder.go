@@ -33,6 +33,10 @@ type Decoder struct {
 	ignorerCache map[typeId]**decEngine                  // ditto for ignored objects
 	freeList     *decoderState                           // list of free decoderStates; avoids reallocation
 	countBuf     []byte                                  // used for decoding integers while parsing messages
+	valueTypes   []typeId                                // types of the top-level values received, for Schema
+	valueTypeSet map[typeId]bool                         // the elements of valueTypes
+	reportFields bool                                    // whether to report struct fields that do not match
+	fieldErr     *FieldError                             // first mismatch found in the current value
 	err          error
 }
 
@@ -211,13 +215,47 @@ func (dec *Decoder) DecodeValue(v reflect.Value) error {
 
 	dec.buf.Reset() // In case data lingers from previous invocation.
 	dec.err = nil
+	dec.fieldErr = nil
 	id := dec.decodeTypeSequence(false)
 	if dec.err == nil {
+		dec.recordValueType(id)
 		dec.decodeValue(id, v)
 	}
+	if dec.err == nil && dec.fieldErr != nil {
+		// The engine caches the error; give the caller its own copy.
+		fe := *dec.fieldErr
+		fe.Ignored = append([]string(nil), fe.Ignored...)
+		fe.Missing = append([]string(nil), fe.Missing...)
+		return &fe
+	}
 	return dec.err
 }
 
+// recordValueType notes that a top-level value of type id was received.
+func (dec *Decoder) recordValueType(id typeId) {
+	if dec.valueTypeSet[id] {
+		return
+	}
+	if dec.valueTypeSet == nil {
+		dec.valueTypeSet = make(map[typeId]bool)
+	}
+	dec.valueTypeSet[id] = true
+	dec.valueTypes = append(dec.valueTypes, id)
+}
+
+// ReportFieldMismatches causes Decode and DecodeValue to return a
+// *FieldError when a struct in the stream has fields that the Go
+// struct it is decoded into lacks, or the Go struct has fields the
+// stream does not send. By default such fields are silently ignored.
+// The value is decoded in full before the error is returned, and the
+// stream may be read further. Only the first mismatch in each value
+// is reported.
+func (dec *Decoder) ReportFieldMismatches() {
+	dec.mutex.Lock()
+	dec.reportFields = true
+	dec.mutex.Unlock()
+}
+
 // If debug.go is compiled into the program, debugFunc prints a human-readable
 // representation of the gob data read from r by calling that file's Debug function.
 // Otherwise it is nil.
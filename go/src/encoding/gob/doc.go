@@ -62,6 +62,11 @@ Attempting to receive into these types will draw a decode error:
 	struct { }			// no field names in common
 	struct { C, D int }		// no field names in common
 
+A Decoder's ReportFieldMismatches method makes it report ignored fields
+rather than drop them silently. The types a stream defines can be read
+with ReadSchema, and Schema.Check reports in advance how they would be
+received into a given Go type.
+
 Integers are transmitted two ways: arbitrary precision signed integers or
 arbitrary precision unsigned integers. There is no int8, int16 etc.
 discrimination in the gob format; there are only signed and unsigned integers. As
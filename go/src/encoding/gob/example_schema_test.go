@@ -0,0 +1,52 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gob_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"reflect"
+)
+
+// This example shows how to inspect the types stored in a gob stream
+// and check whether a newer version of a type can still decode them.
+func Example_schema() {
+	type Record struct {
+		Name  string
+		Score int
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(Record{"gopher", 7}); err != nil {
+		log.Fatal(err)
+	}
+
+	schema, err := gob.ReadSchema(&buf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	record := schema.Lookup("Record")
+	for _, f := range record.Fields {
+		fmt.Println(f.Name, f.Type.Kind)
+	}
+
+	// A later version of Record renamed Score.
+	type RecordV2 struct {
+		Name   string
+		Points int
+	}
+	diffs, err := schema.Check(record, reflect.TypeOf(RecordV2{}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, d := range diffs {
+		fmt.Println("ignored:", d.Ignored, "missing:", d.Missing)
+	}
+	// Output:
+	// Name string
+	// Score int
+	// ignored: [Score] missing: [Points]
+}
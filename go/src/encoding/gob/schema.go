@@ -0,0 +1,315 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gob
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Kind represents the specific kind of type that a Type describes.
+type Kind uint8
+
+const (
+	KindInvalid Kind = iota
+	KindBool
+	KindInt
+	KindUint
+	KindFloat
+	KindComplex
+	KindString
+	KindBytes
+	KindInterface
+	KindArray
+	KindSlice
+	KindMap
+	KindStruct
+	KindGobEncoder      // a type sent using its GobEncode method
+	KindBinaryMarshaler // a type sent using its MarshalBinary method
+	KindTextMarshaler   // a type sent using its MarshalText method
+)
+
+var kindNames = []string{
+	KindInvalid:         "invalid",
+	KindBool:            "bool",
+	KindInt:             "int",
+	KindUint:            "uint",
+	KindFloat:           "float",
+	KindComplex:         "complex",
+	KindString:          "string",
+	KindBytes:           "bytes",
+	KindInterface:       "interface",
+	KindArray:           "array",
+	KindSlice:           "slice",
+	KindMap:             "map",
+	KindStruct:          "struct",
+	KindGobEncoder:      "GobEncoder",
+	KindBinaryMarshaler: "BinaryMarshaler",
+	KindTextMarshaler:   "TextMarshaler",
+}
+
+// String returns the name of k.
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return "kind" + strconv.Itoa(int(k))
+}
+
+// basicKinds maps the ids of the predefined types to their kinds.
+var basicKinds = map[typeId]Kind{
+	tBool:      KindBool,
+	tInt:       KindInt,
+	tUint:      KindUint,
+	tFloat:     KindFloat,
+	tBytes:     KindBytes,
+	tString:    KindString,
+	tComplex:   KindComplex,
+	tInterface: KindInterface,
+}
+
+// A Type describes a type as it is defined in a gob stream. Types
+// refer to each other by pointer, so the Types of a recursive
+// definition form a cycle.
+type Type struct {
+	Id     int      // id of the type in the stream; predefined types have ids below 64
+	Name   string   // name of the type, as chosen by the encoder
+	Kind   Kind     // kind of the type
+	Len    int      // length of an array
+	Key    *Type    // key type of a map
+	Elem   *Type    // element type of an array, slice or map
+	Fields []*Field // fields of a struct, in the order they are sent
+}
+
+// String returns the name of t.
+func (t *Type) String() string {
+	return t.Name
+}
+
+// A Field describes a field of a struct defined in a gob stream.
+type Field struct {
+	Name string
+	Type *Type
+}
+
+// A Schema holds the type definitions read from a gob stream.
+type Schema struct {
+	Types  []*Type // types defined by the stream, in order of id
+	Values []*Type // types of the top-level values, in order of first appearance
+
+	wire map[typeId]*wireType
+}
+
+// ReadSchema reads the gob stream from r to its end, discarding the
+// values it holds, and returns the types the stream defines. Values
+// stored in interfaces are read as well, so their types are included.
+// If an error occurs, ReadSchema returns the schema read so far
+// together with the error.
+func ReadSchema(r io.Reader) (*Schema, error) {
+	dec := NewDecoder(r)
+	for {
+		if err := dec.Decode(nil); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return dec.Schema(), err
+		}
+	}
+}
+
+// Schema returns the type definitions the decoder has received so far
+// and the types of the top-level values it has read.
+func (dec *Decoder) Schema() *Schema {
+	dec.mutex.Lock()
+	defer dec.mutex.Unlock()
+	wire := make(map[typeId]*wireType, len(dec.wireType))
+	for id, w := range dec.wireType {
+		wire[id] = w
+	}
+	s := &Schema{wire: wire}
+	b := &schemaBuilder{wire: wire, types: make(map[typeId]*Type)}
+	ids := make([]typeId, 0, len(wire))
+	for id := range wire {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		s.Types = append(s.Types, b.typ(id))
+	}
+	for _, id := range dec.valueTypes {
+		s.Values = append(s.Values, b.typ(id))
+	}
+	return s
+}
+
+// Lookup returns the type with the given name defined by the stream,
+// or nil if there is none.
+func (s *Schema) Lookup(name string) *Type {
+	for _, t := range s.Types {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Check reports whether values of type t, which must be one of the
+// types of s or a predefined type, can be decoded into a value of
+// the Go type rt. It returns the error a Decoder would return if
+// they cannot. Otherwise, it returns a FieldError for each struct,
+// reachable from t, whose fields differ from those of the Go struct it
+// would be decoded into. Fields of types held in interfaces are not
+// checked, as those types are only known once a value is decoded.
+func (s *Schema) Check(t *Type, rt reflect.Type) ([]*FieldError, error) {
+	if t == nil || rt == nil {
+		return nil, errors.New("gob: Check of nil type")
+	}
+	ut, err := validUserType(rt)
+	if err != nil {
+		return nil, err
+	}
+	id := typeId(t.Id)
+	if s.wire[id] == nil && builtinIdToType[id] == nil {
+		return nil, errBadType
+	}
+	dec := &Decoder{
+		wireType:     s.wire,
+		decoderCache: make(map[reflect.Type]map[typeId]**decEngine),
+		ignorerCache: make(map[typeId]**decEngine),
+	}
+	enginePtr, err := dec.getDecEnginePtr(id, ut)
+	if err != nil {
+		return nil, err
+	}
+	if st := ut.base; st.Kind() == reflect.Struct && ut.externalDec == 0 {
+		wt := s.wire[id]
+		if (*enginePtr).numInstr == 0 && st.NumField() > 0 &&
+			wt != nil && len(wt.StructT.Field) > 0 {
+			return nil, errors.New("gob: type mismatch: no fields matched compiling decoder for " + st.Name())
+		}
+	}
+	var fields []*FieldError
+	for _, engines := range dec.decoderCache {
+		for _, enginePtr := range engines {
+			if e := *enginePtr; e != nil && e.fields != nil {
+				fields = append(fields, e.fields)
+			}
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].WireType != fields[j].WireType {
+			return fields[i].WireType < fields[j].WireType
+		}
+		return fields[i].GoType.String() < fields[j].GoType.String()
+	})
+	return fields, nil
+}
+
+// A FieldError describes a struct in a gob stream whose fields differ
+// from those of the Go struct it is decoded into. Decoding such a
+// struct succeeds; see Decoder.ReportFieldMismatches.
+type FieldError struct {
+	WireType string       // name of the struct type in the stream
+	GoType   reflect.Type // Go type the struct is decoded into
+	Ignored  []string     // fields in the stream with no Go field; their values are discarded
+	Missing  []string     // Go fields that are not in the stream; they are left unchanged
+}
+
+func (e *FieldError) Error() string {
+	var parts []string
+	if len(e.Ignored) > 0 {
+		parts = append(parts, "ignored fields "+strings.Join(e.Ignored, ", "))
+	}
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing fields "+strings.Join(e.Missing, ", "))
+	}
+	return "gob: decoding " + e.WireType + " into " + e.GoType.String() + ": " + strings.Join(parts, "; ")
+}
+
+// fieldError returns a FieldError describing the differences between
+// the fields of the wire struct and those of the Go struct rt, or nil
+// if they have the same fields.
+func fieldError(wireStruct *structType, rt reflect.Type) *FieldError {
+	var ignored, missing []string
+	sent := make(map[string]bool, len(wireStruct.Field))
+	for _, f := range wireStruct.Field {
+		sent[f.Name] = true
+		if _, ok := rt.FieldByName(f.Name); !ok || !isExported(f.Name) {
+			ignored = append(ignored, f.Name)
+		}
+	}
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if isSent(&f) && !sent[f.Name] {
+			missing = append(missing, f.Name)
+		}
+	}
+	if ignored == nil && missing == nil {
+		return nil
+	}
+	return &FieldError{WireType: wireStruct.Name, GoType: rt, Ignored: ignored, Missing: missing}
+}
+
+// A schemaBuilder turns wire type definitions into Types.
+type schemaBuilder struct {
+	wire  map[typeId]*wireType
+	types map[typeId]*Type
+}
+
+// typ returns the Type with the given id, building it if necessary.
+func (b *schemaBuilder) typ(id typeId) *Type {
+	if t := b.types[id]; t != nil {
+		return t
+	}
+	t := &Type{Id: int(id)}
+	b.types[id] = t
+	var gt gobType
+	if w := b.wire[id]; w != nil {
+		switch {
+		case w.ArrayT != nil:
+			gt = w.ArrayT
+		case w.SliceT != nil:
+			gt = w.SliceT
+		case w.StructT != nil:
+			gt = w.StructT
+		case w.MapT != nil:
+			gt = w.MapT
+		case w.GobEncoderT != nil:
+			t.Name, t.Kind = w.GobEncoderT.Name, KindGobEncoder
+		case w.BinaryMarshalerT != nil:
+			t.Name, t.Kind = w.BinaryMarshalerT.Name, KindBinaryMarshaler
+		case w.TextMarshalerT != nil:
+			t.Name, t.Kind = w.TextMarshalerT.Name, KindTextMarshaler
+		}
+	} else {
+		gt = builtinIdToType[id]
+	}
+	switch gt := gt.(type) {
+	case *CommonType:
+		t.Name, t.Kind = gt.Name, basicKinds[id]
+	case *arrayType:
+		t.Name, t.Kind = gt.Name, KindArray
+		t.Len = gt.Len
+		t.Elem = b.typ(gt.Elem)
+	case *sliceType:
+		t.Name, t.Kind = gt.Name, KindSlice
+		t.Elem = b.typ(gt.Elem)
+	case *mapType:
+		t.Name, t.Kind = gt.Name, KindMap
+		t.Key = b.typ(gt.Key)
+		t.Elem = b.typ(gt.Elem)
+	case *structType:
+		t.Name, t.Kind = gt.Name, KindStruct
+		t.Fields = make([]*Field, len(gt.Field))
+		for i, f := range gt.Field {
+			t.Fields[i] = &Field{Name: f.Name, Type: b.typ(f.Id)}
+		}
+	}
+	return t
+}
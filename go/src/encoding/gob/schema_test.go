@@ -0,0 +1,296 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gob
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type schemaItem struct {
+	Name  string
+	Price float64
+}
+
+type schemaOrder struct {
+	ID      uint
+	Items   []schemaItem
+	Tags    map[string]int
+	Digest  [4]byte
+	Next    *schemaOrder
+	When    time.Time
+	Payload interface{}
+}
+
+type schemaPayload struct {
+	Note string
+}
+
+// Later versions of schemaItem and schemaOrder.
+type schemaItemV2 struct {
+	Name     string
+	Quantity int
+}
+
+type schemaOrderV2 struct {
+	ID    uint
+	Items []schemaItemV2
+	Notes string
+}
+
+func encodeSchemaOrders(t *testing.T) []byte {
+	Register(schemaPayload{})
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	orders := []interface{}{
+		schemaOrder{ID: 1, Items: []schemaItem{{"a", 1}}, Payload: schemaPayload{"x"}},
+		schemaOrder{ID: 2},
+		"trailer",
+	}
+	for _, o := range orders {
+		if err := enc.Encode(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestReadSchema(t *testing.T) {
+	s, err := ReadSchema(bytes.NewReader(encodeSchemaOrders(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := s.Lookup("schemaOrder")
+	if order == nil {
+		t.Fatalf("schemaOrder not found in %v", s.Types)
+	}
+	if order.Kind != KindStruct {
+		t.Fatalf("schemaOrder kind = %v, want struct", order.Kind)
+	}
+	want := []struct {
+		name string
+		kind Kind
+	}{
+		{"ID", KindUint},
+		{"Items", KindSlice},
+		{"Tags", KindMap},
+		{"Digest", KindArray},
+		{"Next", KindStruct},
+		{"When", KindGobEncoder},
+		{"Payload", KindInterface},
+	}
+	if len(order.Fields) != len(want) {
+		t.Fatalf("schemaOrder has %d fields, want %d", len(order.Fields), len(want))
+	}
+	for i, f := range order.Fields {
+		if f.Name != want[i].name || f.Type.Kind != want[i].kind {
+			t.Errorf("field %d = %s %v, want %s %v", i, f.Name, f.Type.Kind, want[i].name, want[i].kind)
+		}
+	}
+	if next := order.Fields[4].Type; next != order {
+		t.Errorf("Next has type %v, want schemaOrder itself", next)
+	}
+	items := order.Fields[1].Type
+	if items.Elem == nil || items.Elem.Name != "schemaItem" || len(items.Elem.Fields) != 2 {
+		t.Errorf("Items element = %+v", items.Elem)
+	}
+	tags := order.Fields[2].Type
+	if tags.Key.Kind != KindString || tags.Elem.Kind != KindInt {
+		t.Errorf("Tags = map[%v]%v, want map[string]int", tags.Key.Kind, tags.Elem.Kind)
+	}
+	if digest := order.Fields[3].Type; digest.Len != 4 || digest.Elem.Kind != KindUint {
+		t.Errorf("Digest = [%d]%v, want [4]uint", digest.Len, digest.Elem.Kind)
+	}
+	// The type of the value held in the interface is read too.
+	if s.Lookup("schemaPayload") == nil {
+		t.Errorf("schemaPayload not found in %v", s.Types)
+	}
+	if len(s.Values) != 2 || s.Values[0] != order || s.Values[1].Kind != KindString {
+		t.Errorf("Values = %v, want [schemaOrder string]", s.Values)
+	}
+}
+
+func TestReadSchemaError(t *testing.T) {
+	b := encodeSchemaOrders(t)
+	s, err := ReadSchema(bytes.NewReader(b[:len(b)-3]))
+	if err == nil {
+		t.Fatal("no error for truncated stream")
+	}
+	if s == nil || s.Lookup("schemaOrder") == nil {
+		t.Errorf("schema read before the error is missing schemaOrder")
+	}
+}
+
+func TestSchemaCheck(t *testing.T) {
+	s, err := ReadSchema(bytes.NewReader(encodeSchemaOrders(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := s.Lookup("schemaOrder")
+
+	fields, err := s.Check(order, reflect.TypeOf(schemaOrder{}))
+	if err != nil || len(fields) != 0 {
+		t.Errorf("Check(schemaOrder) = %v, %v, want no differences", fields, err)
+	}
+
+	fields, err = s.Check(order, reflect.TypeOf(&schemaOrderV2{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*FieldError{
+		{
+			WireType: "schemaItem",
+			GoType:   reflect.TypeOf(schemaItemV2{}),
+			Ignored:  []string{"Price"},
+			Missing:  []string{"Quantity"},
+		},
+		{
+			WireType: "schemaOrder",
+			GoType:   reflect.TypeOf(schemaOrderV2{}),
+			Ignored:  []string{"Tags", "Digest", "Next", "When", "Payload"},
+			Missing:  []string{"Notes"},
+		},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("Check(schemaOrderV2):")
+		for _, f := range fields {
+			t.Errorf("\thave %v", f)
+		}
+		for _, f := range want {
+			t.Errorf("\twant %v", f)
+		}
+	}
+
+	type badOrder struct {
+		ID string
+	}
+	if _, err := s.Check(order, reflect.TypeOf(badOrder{})); err == nil || !strings.Contains(err.Error(), "wrong type") {
+		t.Errorf("Check(badOrder) error = %v, want wrong type", err)
+	}
+	type unrelated struct {
+		X int
+	}
+	if _, err := s.Check(order, reflect.TypeOf(unrelated{})); err == nil || !strings.Contains(err.Error(), "no fields matched") {
+		t.Errorf("Check(unrelated) error = %v, want no fields matched", err)
+	}
+	str := s.Values[1]
+	if _, err := s.Check(str, reflect.TypeOf("")); err != nil {
+		t.Errorf("Check(string, string) = %v", err)
+	}
+	if _, err := s.Check(str, reflect.TypeOf(0)); err == nil {
+		t.Error("Check(string, int) succeeded")
+	}
+}
+
+func TestReportFieldMismatches(t *testing.T) {
+	dec := NewDecoder(bytes.NewReader(encodeSchemaOrders(t)))
+	dec.ReportFieldMismatches()
+
+	var o schemaOrderV2
+	err := dec.Decode(&o)
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Fatalf("Decode error = %v, want *FieldError", err)
+	}
+	if fe.WireType != "schemaOrder" || !reflect.DeepEqual(fe.Missing, []string{"Notes"}) {
+		t.Errorf("FieldError = %v", fe)
+	}
+	// The value is decoded in full regardless.
+	if o.ID != 1 || len(o.Items) != 1 || o.Items[0].Name != "a" {
+		t.Errorf("decoded %+v", o)
+	}
+
+	// A matching type is not reported.
+	var o2 schemaOrder
+	if err := dec.Decode(&o2); err != nil || o2.ID != 2 {
+		t.Errorf("second Decode = %+v, %v", o2, err)
+	}
+
+	var str string
+	if err := dec.Decode(&str); err != nil || str != "trailer" {
+		t.Errorf("Decode(string) = %q, %v", str, err)
+	}
+}
+
+func TestReportFieldMismatchesCopy(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for id := uint(1); id <= 2; id++ {
+		if err := enc.Encode(schemaOrder{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := NewDecoder(&buf)
+	dec.ReportFieldMismatches()
+	var o schemaOrderV2
+	err := dec.Decode(&o)
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Fatalf("Decode error = %v, want *FieldError", err)
+	}
+	// Changing the error must not change the one reported next.
+	fe.WireType = "changed"
+	fe.Missing[0] = "changed"
+	err = dec.Decode(&o)
+	if !errors.As(err, &fe) {
+		t.Fatalf("second Decode error = %v, want *FieldError", err)
+	}
+	if fe.WireType != "schemaOrder" || !reflect.DeepEqual(fe.Missing, []string{"Notes"}) {
+		t.Errorf("second FieldError = %v", fe)
+	}
+}
+
+func TestReportFieldMismatchesNested(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(schemaOrder{ID: 1, Items: []schemaItem{{"a", 1}, {"b", 2}}}); err != nil {
+		t.Fatal(err)
+	}
+	type outer struct {
+		ID      uint
+		Items   []schemaItemV2
+		Tags    map[string]int
+		Digest  [4]byte
+		Next    *schemaOrder
+		When    time.Time
+		Payload interface{}
+	}
+	var o outer
+	dec := NewDecoder(&buf)
+	dec.ReportFieldMismatches()
+	err := dec.Decode(&o)
+	want := `gob: decoding schemaItem into gob.schemaItemV2: ignored fields Price; missing fields Quantity`
+	if err == nil || err.Error() != want {
+		t.Errorf("Decode error = %v, want %s", err, want)
+	}
+	if len(o.Items) != 2 || o.Items[1].Name != "b" {
+		t.Errorf("decoded %+v", o)
+	}
+
+	// Without the mode, the mismatch is not reported.
+	buf.Reset()
+	if err := NewEncoder(&buf).Encode(schemaOrder{Items: []schemaItem{{"a", 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewDecoder(&buf).Decode(&o); err != nil {
+		t.Errorf("Decode without ReportFieldMismatches = %v", err)
+	}
+}
+
+func TestKindString(t *testing.T) {
+	for k, want := range map[Kind]string{
+		KindStruct:        "struct",
+		KindBytes:         "bytes",
+		KindTextMarshaler: "TextMarshaler",
+		Kind(200):         "kind200",
+	} {
+		if got := k.String(); got != want {
+			t.Errorf("Kind(%d).String() = %q, want %q", k, got, want)
+		}
+	}
+}
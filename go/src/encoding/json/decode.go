@@ -11,9 +11,11 @@ import (
 	"encoding"
 	"encoding/base64"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"unicode"
 	"unicode/utf16"
 	"unicode/utf8"
@@ -44,7 +46,9 @@ import (
 // keys to the keys used by Marshal (either the struct field name or its tag),
 // preferring an exact match but also accepting a case-insensitive match. By
 // default, object keys which don't have a corresponding struct field are
-// ignored (see Decoder.DisallowUnknownFields for an alternative).
+// ignored (see Decoder.DisallowUnknownFields for an alternative), unless
+// the struct has an inline map, which then stores them (see the "inline"
+// and "unknown" options described for Marshal).
 //
 // To unmarshal JSON into an interface value,
 // Unmarshal stores one of these in the interface value:
@@ -93,18 +97,12 @@ import (
 // Instead, they are replaced by the Unicode replacement
 // character U+FFFD.
 //
+// To match keys case-sensitively, reject duplicate keys or invalid
+// UTF-8, or change other aspects of decoding, use the Unmarshal method
+// of UnmarshalOptions.
+//
 func Unmarshal(data []byte, v interface{}) error {
-	// Check for well-formedness.
-	// Avoids filling out half a data structure
-	// before discovering a JSON syntax error.
-	var d decodeState
-	err := checkValid(data, &d.scan)
-	if err != nil {
-		return err
-	}
-
-	d.init(data)
-	return d.unmarshal(v)
+	return UnmarshalOptions{}.Unmarshal(data, v)
 }
 
 // Unmarshaler is the interface implemented by types
@@ -173,7 +171,18 @@ func (d *decodeState) unmarshal(v interface{}) error {
 		return &InvalidUnmarshalError{reflect.TypeOf(v)}
 	}
 
-	d.scan.reset()
+	if d.opts.RejectInvalidUTF8 {
+		if err := checkUTF8(d.data); err != nil {
+			return err
+		}
+	}
+	if d.opts.RejectDuplicateKeys {
+		if err := checkDuplicateKeys(d.data); err != nil {
+			return err
+		}
+	}
+
+	d.parseState = d.parseState[:0]
 	d.scanWhile(scanSkipSpace)
 	// We decode rv not rv.Elem because the Unmarshaler interface
 	// test must be applied at the top level of the value.
@@ -207,15 +216,21 @@ type errorContext struct {
 }
 
 // decodeState represents the state while decoding a JSON value.
+//
+// The data has been validated before decoding starts, by checkValid or
+// by Decoder.readValue, so decodeState does not run the scanner state
+// machine over it again: step classifies each structural byte knowing
+// only the innermost open array or object, and rescanLiteral and skip
+// jump over literals and ignored values without examining their bytes
+// further.
 type decodeState struct {
-	data                  []byte
-	off                   int // next read offset in data
-	opcode                int // last read result
-	scan                  scanner
-	errorContext          *errorContext
-	savedError            error
-	useNumber             bool
-	disallowUnknownFields bool
+	data         []byte
+	off          int   // next read offset in data
+	opcode       int   // last read result
+	parseState   []int // stack of what we're in the middle of, as for scanner
+	errorContext *errorContext
+	savedError   error
+	opts         UnmarshalOptions
 }
 
 // readIndex returns the position of the last byte read.
@@ -240,6 +255,15 @@ func (d *decodeState) init(data []byte) *decodeState {
 	return d
 }
 
+var decodeStatePool sync.Pool
+
+func newDecodeState(data []byte) *decodeState {
+	if v := decodeStatePool.Get(); v != nil {
+		return v.(*decodeState).init(data)
+	}
+	return new(decodeState).init(data)
+}
+
 // saveError saves the first err it is called with,
 // for reporting at the end of the unmarshal.
 func (d *decodeState) saveError(err error) {
@@ -262,26 +286,69 @@ func (d *decodeState) addErrorContext(err error) error {
 
 // skip scans to the end of what was started.
 func (d *decodeState) skip() {
-	s, data, i := &d.scan, d.data, d.off
-	depth := len(s.parseState)
-	for {
-		op := s.step(s, data[i])
-		i++
-		if len(s.parseState) < depth {
-			d.off = i
-			d.opcode = op
-			return
+	data, i := d.data, d.off
+	depth := 1
+	for ; ; i++ {
+		switch data[i] {
+		case '"':
+			for i++; data[i] != '"'; i++ {
+				if data[i] == '\\' {
+					i++ // escaped char
+				}
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				d.opcode = d.step(data[i])
+				d.off = i + 1
+				return
+			}
 		}
 	}
 }
 
+// step returns the scan code of c, the byte at d.data[d.off], which
+// is not inside a literal, and updates d.parseState as the scanner
+// would.
+func (d *decodeState) step(c byte) int {
+	switch c {
+	case ' ', '\t', '\r', '\n':
+		return scanSkipSpace
+	case '{':
+		d.parseState = append(d.parseState, parseObjectKey)
+		return scanBeginObject
+	case '[':
+		d.parseState = append(d.parseState, parseArrayValue)
+		return scanBeginArray
+	case ':':
+		d.parseState[len(d.parseState)-1] = parseObjectValue
+		return scanObjectKey
+	case ',':
+		ps := &d.parseState[len(d.parseState)-1]
+		if *ps == parseObjectValue {
+			*ps = parseObjectKey
+			return scanObjectValue
+		}
+		return scanArrayValue
+	case '}':
+		d.parseState = d.parseState[:len(d.parseState)-1]
+		return scanEndObject
+	case ']':
+		d.parseState = d.parseState[:len(d.parseState)-1]
+		return scanEndArray
+	}
+	return scanBeginLiteral
+}
+
 // scanNext processes the byte at d.data[d.off].
 func (d *decodeState) scanNext() {
 	if d.off < len(d.data) {
-		d.opcode = d.scan.step(&d.scan, d.data[d.off])
+		d.opcode = d.step(d.data[d.off])
 		d.off++
 	} else {
-		d.opcode = d.scan.eof()
+		d.opcode = scanEnd
 		d.off = len(d.data) + 1 // mark processed EOF with len+1
 	}
 }
@@ -289,9 +356,9 @@ func (d *decodeState) scanNext() {
 // scanWhile processes bytes in d.data[d.off:] until it
 // receives a scan code not equal to op.
 func (d *decodeState) scanWhile(op int) {
-	s, data, i := &d.scan, d.data, d.off
+	data, i := d.data, d.off
 	for i < len(data) {
-		newOp := s.step(s, data[i])
+		newOp := d.step(data[i])
 		i++
 		if newOp != op {
 			d.opcode = newOp
@@ -301,17 +368,13 @@ func (d *decodeState) scanWhile(op int) {
 	}
 
 	d.off = len(data) + 1 // mark processed EOF with len+1
-	d.opcode = d.scan.eof()
+	d.opcode = scanEnd
 }
 
-// rescanLiteral is similar to scanWhile(scanContinue), but it specialises the
-// common case where we're decoding a literal. The decoder scans the input
-// twice, once for syntax errors and to check the length of the value, and the
-// second to perform the decoding.
-//
-// Only in the second step do we use decodeState to tokenize literals, so we
-// know there aren't any syntax errors. We can take advantage of that knowledge,
-// and scan a literal's bytes much more quickly.
+// rescanLiteral skips over the literal whose first byte has been read.
+// The input has been validated already, so we know there aren't any
+// syntax errors. We can take advantage of that knowledge, and scan a
+// literal's bytes much more quickly than the scanner would.
 func (d *decodeState) rescanLiteral() {
 	data, i := d.data, d.off
 Switch:
@@ -343,7 +406,7 @@ Switch:
 		i += len("ull")
 	}
 	if i < len(data) {
-		d.opcode = stateEndValue(&d.scan, data[i])
+		d.opcode = d.step(data[i])
 	} else {
 		d.opcode = scanEnd
 	}
@@ -417,6 +480,63 @@ func (d *decodeState) valueQuoted() interface{} {
 	return unquotedValue{}
 }
 
+// A decoderPlan holds what the decoder needs to know about a type,
+// worked out once per type and cached like the encoderFuncs.
+type decoderPlan struct {
+	// direct reports that indirect has nothing to do for an addressable
+	// value of the type: it is neither a pointer nor an interface, and
+	// no method set reachable from it includes UnmarshalJSON or
+	// UnmarshalText.
+	direct bool
+
+	// mapKey reports whether a map type has keys that can be decoded
+	// from object keys.
+	mapKey bool
+
+	// fields holds the fields of a struct type.
+	fields structFields
+}
+
+var decoderCache sync.Map // map[reflect.Type]*decoderPlan
+
+// cachedDecoderPlan is like makeDecoderPlan but uses a cache to avoid
+// repeated work.
+func cachedDecoderPlan(t reflect.Type) *decoderPlan {
+	if p, ok := decoderCache.Load(t); ok {
+		return p.(*decoderPlan)
+	}
+	p, _ := decoderCache.LoadOrStore(t, makeDecoderPlan(t))
+	return p.(*decoderPlan)
+}
+
+func makeDecoderPlan(t reflect.Type) *decoderPlan {
+	p := new(decoderPlan)
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface:
+	default:
+		pt := reflect.PtrTo(t)
+		p.direct = !pt.Implements(unmarshalerType) && !pt.Implements(textUnmarshalerType)
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		// Map key must either have string kind, have an integer kind,
+		// or be an encoding.TextUnmarshaler.
+		switch t.Key().Kind() {
+		case reflect.String,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			p.mapKey = true
+		default:
+			p.mapKey = reflect.PtrTo(t.Key()).Implements(textUnmarshalerType)
+		}
+	case reflect.Struct:
+		p.fields = cachedTypeFields(t)
+	}
+	return p
+}
+
+var unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+
 // indirect walks down v allocating pointers as needed,
 // until it gets to a non-pointer.
 // If it encounters an Unmarshaler, indirect stops and returns that.
@@ -434,6 +554,10 @@ func indirect(v reflect.Value, decodingNull bool) (Unmarshaler, encoding.TextUnm
 	//
 	// After the first round-trip, we set v back to the original value to
 	// preserve the original RW flags contained in reflect.Value.
+	if v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface && cachedDecoderPlan(v.Type()).direct {
+		return nil, nil, v
+	}
+
 	v0 := v
 	haveAddr := false
 
@@ -634,24 +758,16 @@ func (d *decodeState) object(v reflect.Value) error {
 	//             or an encoding.TextUnmarshaler
 	switch v.Kind() {
 	case reflect.Map:
-		// Map key must either have string kind, have an integer kind,
-		// or be an encoding.TextUnmarshaler.
-		switch t.Key().Kind() {
-		case reflect.String,
-			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		default:
-			if !reflect.PtrTo(t.Key()).Implements(textUnmarshalerType) {
-				d.saveError(&UnmarshalTypeError{Value: "object", Type: t, Offset: int64(d.off)})
-				d.skip()
-				return nil
-			}
+		if !cachedDecoderPlan(t).mapKey {
+			d.saveError(&UnmarshalTypeError{Value: "object", Type: t, Offset: int64(d.off)})
+			d.skip()
+			return nil
 		}
 		if v.IsNil() {
 			v.Set(reflect.MakeMap(t))
 		}
 	case reflect.Struct:
-		fields = cachedTypeFields(t)
+		fields = cachedDecoderPlan(t).fields
 		// ok
 	default:
 		d.saveError(&UnmarshalTypeError{Value: "object", Type: t, Offset: int64(d.off)})
@@ -664,6 +780,13 @@ func (d *decodeState) object(v reflect.Value) error {
 	if d.errorContext != nil {
 		origErrorContext = *d.errorContext
 	}
+	// Fields set so far, for RejectDuplicateKeys. Keys that are
+	// equal are rejected by checkDuplicateKeys before decoding; this
+	// catches distinct keys that match the same field.
+	var seen map[*field]bool
+	if d.opts.RejectDuplicateKeys && v.Kind() == reflect.Struct {
+		seen = make(map[*field]bool)
+	}
 
 	for {
 		// Read opening " of string key or closing }.
@@ -687,7 +810,8 @@ func (d *decodeState) object(v reflect.Value) error {
 
 		// Figure out field corresponding to key.
 		var subv reflect.Value
-		destring := false // whether the value is wrapped in a string to be decoded first
+		var inline reflect.Value // inline map to store subv in
+		destring := false        // whether the value is wrapped in a string to be decoded first
 
 		if v.Kind() == reflect.Map {
 			elemType := t.Elem()
@@ -702,48 +826,35 @@ func (d *decodeState) object(v reflect.Value) error {
 			if i, ok := fields.nameIndex[string(key)]; ok {
 				// Found an exact name match.
 				f = &fields.list[i]
-			} else {
-				// Fall back to the expensive case-insensitive
-				// linear search.
-				for i := range fields.list {
-					ff := &fields.list[i]
-					if ff.equalFold(ff.nameBytes, key) {
-						f = ff
-						break
-					}
+			} else if !d.opts.CaseSensitive {
+				// Fall back to a case-insensitive match.
+				if i, ok := fields.byFoldedName[string(foldName(key))]; ok {
+					f = &fields.list[i]
 				}
 			}
 			if f != nil {
-				subv = v
-				destring = f.quoted
-				for _, i := range f.index {
-					if subv.Kind() == reflect.Ptr {
-						if subv.IsNil() {
-							// If a struct embeds a pointer to an unexported type,
-							// it is not possible to set a newly allocated value
-							// since the field is unexported.
-							//
-							// See https://golang.org/issue/21357
-							if !subv.CanSet() {
-								d.saveError(fmt.Errorf("json: cannot set embedded pointer to unexported struct: %v", subv.Type().Elem()))
-								// Invalidate subv to ensure d.value(subv) skips over
-								// the JSON value without assigning it to subv.
-								subv = reflect.Value{}
-								destring = false
-								break
-							}
-							subv.Set(reflect.New(subv.Type().Elem()))
-						}
-						subv = subv.Elem()
+				if seen != nil {
+					if seen[f] {
+						d.saveError(fmt.Errorf("json: duplicate key %q", key))
 					}
-					subv = subv.Field(i)
+					seen[f] = true
 				}
+				subv = d.fieldByIndex(v, f.index)
+				destring = f.quoted && subv.IsValid()
 				if d.errorContext == nil {
 					d.errorContext = new(errorContext)
 				}
 				d.errorContext.FieldStack = append(d.errorContext.FieldStack, f.name)
 				d.errorContext.Struct = t
-			} else if d.disallowUnknownFields {
+			} else if fields.inline != nil {
+				inline = d.fieldByIndex(v, fields.inline.index)
+				if inline.IsValid() {
+					if inline.IsNil() {
+						inline.Set(reflect.MakeMap(inline.Type()))
+					}
+					subv = reflect.New(inline.Type().Elem()).Elem()
+				}
+			} else if d.opts.DisallowUnknownFields {
 				d.saveError(fmt.Errorf("json: unknown field %q", key))
 			}
 		}
@@ -778,6 +889,9 @@ func (d *decodeState) object(v reflect.Value) error {
 
 		// Write value back to map;
 		// if using struct, subv points into struct already.
+		if inline.IsValid() {
+			inline.SetMapIndex(reflect.ValueOf(string(key)).Convert(inline.Type().Key()), subv)
+		}
 		if v.Kind() == reflect.Map {
 			kt := t.Key()
 			var kv reflect.Value
@@ -838,10 +952,37 @@ func (d *decodeState) object(v reflect.Value) error {
 	return nil
 }
 
+// fieldByIndex returns the field of the struct v with the given index
+// sequence, allocating embedded pointers as needed. It returns the zero
+// Value if an embedded pointer cannot be allocated.
+func (d *decodeState) fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				// If a struct embeds a pointer to an unexported type,
+				// it is not possible to set a newly allocated value
+				// since the field is unexported.
+				//
+				// See https://golang.org/issue/21357
+				if !v.CanSet() {
+					d.saveError(fmt.Errorf("json: cannot set embedded pointer to unexported struct: %v", v.Type().Elem()))
+					// Return an invalid value to ensure d.value skips over
+					// the JSON value without assigning it.
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
 // convertNumber converts the number literal s to a float64 or a Number
-// depending on the setting of d.useNumber.
+// depending on the setting of d.opts.UseNumber.
 func (d *decodeState) convertNumber(s string) (interface{}, error) {
-	if d.useNumber {
+	if d.opts.UseNumber {
 		return Number(s), nil
 	}
 	f, err := strconv.ParseFloat(s, 64)
@@ -979,13 +1120,12 @@ func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool
 			}
 			panic(phasePanicMsg)
 		}
-		s := string(item)
 		switch v.Kind() {
 		default:
 			if v.Kind() == reflect.String && v.Type() == numberType {
-				// s must be a valid number, because it's
+				// item must be a valid number, because it's
 				// already been tokenized.
-				v.SetString(s)
+				v.SetString(string(item))
 				break
 			}
 			if fromQuoted {
@@ -993,7 +1133,7 @@ func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool
 			}
 			d.saveError(&UnmarshalTypeError{Value: "number", Type: v.Type(), Offset: int64(d.readIndex())})
 		case reflect.Interface:
-			n, err := d.convertNumber(s)
+			n, err := d.convertNumber(string(item))
 			if err != nil {
 				d.saveError(err)
 				break
@@ -1005,6 +1145,11 @@ func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool
 			v.Set(reflect.ValueOf(n))
 
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n, ok := parseInt(item); ok && !v.OverflowInt(n) {
+				v.SetInt(n)
+				break
+			}
+			s := string(item)
 			n, err := strconv.ParseInt(s, 10, 64)
 			if err != nil || v.OverflowInt(n) {
 				d.saveError(&UnmarshalTypeError{Value: "number " + s, Type: v.Type(), Offset: int64(d.readIndex())})
@@ -1013,6 +1158,11 @@ func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool
 			v.SetInt(n)
 
 		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if n, ok := parseUint(item); ok && !v.OverflowUint(n) {
+				v.SetUint(n)
+				break
+			}
+			s := string(item)
 			n, err := strconv.ParseUint(s, 10, 64)
 			if err != nil || v.OverflowUint(n) {
 				d.saveError(&UnmarshalTypeError{Value: "number " + s, Type: v.Type(), Offset: int64(d.readIndex())})
@@ -1021,6 +1171,7 @@ func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool
 			v.SetUint(n)
 
 		case reflect.Float32, reflect.Float64:
+			s := string(item)
 			n, err := strconv.ParseFloat(s, v.Type().Bits())
 			if err != nil || v.OverflowFloat(n) {
 				d.saveError(&UnmarshalTypeError{Value: "number " + s, Type: v.Type(), Offset: int64(d.readIndex())})
@@ -1032,6 +1183,40 @@ func (d *decodeState) literalStore(item []byte, v reflect.Value, fromQuoted bool
 	return nil
 }
 
+// parseUint parses item, a JSON number, as a uint64 without
+// allocating. It reports false if item is not a non-negative integer
+// of at most 19 digits, which always fits; strconv.ParseUint handles
+// the rest.
+func parseUint(item []byte) (uint64, bool) {
+	if len(item) == 0 || len(item) > 19 {
+		return 0, false
+	}
+	var n uint64
+	for _, c := range item {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + uint64(c-'0')
+	}
+	return n, true
+}
+
+// parseInt is like parseUint but parses item as an int64.
+func parseInt(item []byte) (int64, bool) {
+	neg := len(item) > 0 && item[0] == '-'
+	if neg {
+		item = item[1:]
+	}
+	u, ok := parseUint(item)
+	if !ok || u > math.MaxInt64 {
+		return 0, false
+	}
+	if neg {
+		return -int64(u), true
+	}
+	return int64(u), true
+}
+
 // The xxxInterface routines build up a value to be stored
 // in an empty interface. They are not strictly necessary,
 // but they avoid the weight of reflection in this common case.
@@ -1101,7 +1286,6 @@ func (d *decodeState) objectInterface() map[string]interface{} {
 		if !ok {
 			panic(phasePanicMsg)
 		}
-
 		// Read : before value.
 		if d.opcode == scanSkipSpace {
 			d.scanWhile(scanSkipSpace)
@@ -2572,3 +2572,88 @@ func TestUnmarshalMaxDepth(t *testing.T) {
 		}
 	}
 }
+
+func TestUnmarshalSkipAndFold(t *testing.T) {
+	type T struct {
+		Kind   string
+		Stress string
+		A      []int
+	}
+	data := `{"ignored": [{"x": "]}\"[{"}, "\"", [[], {}]], "kInd": "a", "Kind": "b", "ſtress": "c", "a": [1, {"b": 2}]}`
+	var got T
+	err := Unmarshal([]byte(data), &got)
+	if _, ok := err.(*UnmarshalTypeError); !ok {
+		t.Fatalf("Unmarshal: got error %v, want UnmarshalTypeError", err)
+	}
+	want := T{Kind: "b", Stress: "c", A: []int{1, 0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal = %+v, want %+v", got, want)
+	}
+}
+
+func TestUnmarshalIntegers(t *testing.T) {
+	type T struct {
+		I   int64
+		I8  int8
+		U   uint64
+		U16 uint16
+	}
+	for _, tt := range []struct {
+		in   string
+		want T
+		err  bool
+	}{
+		{in: `{"I": -9223372036854775808, "U": 18446744073709551615}`, want: T{I: math.MinInt64, U: math.MaxUint64}},
+		{in: `{"I": 9223372036854775807, "U": 9999999999999999999}`, want: T{I: math.MaxInt64, U: 9999999999999999999}},
+		{in: `{"I": -0, "I8": -128, "U16": 65535}`, want: T{I8: -128, U16: 65535}},
+		{in: `{"I": 1e3}`, err: true},
+		{in: `{"I": 9223372036854775808}`, err: true},
+		{in: `{"I8": 128}`, err: true},
+		{in: `{"U": -1}`, err: true},
+		{in: `{"U": 18446744073709551616}`, err: true},
+		{in: `{"U16": 65536}`, err: true},
+	} {
+		var got T
+		err := Unmarshal([]byte(tt.in), &got)
+		if tt.err {
+			if _, ok := err.(*UnmarshalTypeError); !ok {
+				t.Errorf("Unmarshal(%s): got error %v, want UnmarshalTypeError", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Unmarshal(%s) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalSyntaxErrorRestoresValue(t *testing.T) {
+	type T struct {
+		A int
+		B *int
+		C []int
+		M map[string]int
+		U *countingUnmarshaler
+	}
+	b := 5
+	u := new(countingUnmarshaler)
+	got := T{A: 1, B: &b, C: []int{1, 2, 3}, M: map[string]int{"x": 1}, U: u}
+	err := Unmarshal([]byte(`{"A": 2, "B": 3, "C": [9, 9, 9], "M": {"a": 1}, "U": {}, "D": }`), &got)
+	if _, ok := err.(*SyntaxError); !ok {
+		t.Fatalf("Unmarshal: got error %v, want SyntaxError", err)
+	}
+	want := T{A: 1, B: &b, C: []int{1, 2, 3}, M: map[string]int{"x": 1}, U: u}
+	if !reflect.DeepEqual(got, want) || b != 5 {
+		t.Errorf("Unmarshal changed the value to %+v (B = %d), want %+v (B = 5)", got, b, want)
+	}
+	if u.calls != 0 {
+		t.Errorf("UnmarshalJSON called %d times on invalid input", u.calls)
+	}
+}
+
+type countingUnmarshaler struct{ calls int }
+
+func (u *countingUnmarshaler) UnmarshalJSON([]byte) error {
+	u.calls++
+	return nil
+}
@@ -72,6 +72,13 @@ import (
 // false, 0, a nil pointer, a nil interface value, and any empty array,
 // slice, map, or string.
 //
+// The "omitzero" option specifies that the field should be omitted
+// from the encoding if the field has a zero value. If the field type
+// has an IsZero() bool method, that method is used to determine
+// whether the value is zero; otherwise a value is zero if it is the
+// zero value for its type. A nil pointer whose type has an IsZero
+// method is zero as well.
+//
 // As a special case, if the field tag is "-", the field is always omitted.
 // Note that a field with name "-" can still be generated using the tag "-,".
 //
@@ -96,6 +103,10 @@ import (
 //   // Field appears in JSON as key "-".
 //   Field int `json:"-,"`
 //
+//   // Field is omitted if Field.IsZero() reports true, as for a
+//   // time.Time holding the zero time.
+//   Field time.Time `json:",omitzero"`
+//
 // The "string" option signals that a field is stored as JSON inside a
 // JSON-encoded string. It applies only to fields of string, floating point,
 // integer, or boolean types. This extra level of encoding is sometimes used
@@ -128,6 +139,18 @@ import (
 //
 // 3) Otherwise there are multiple fields, and all are ignored; no error occurs.
 //
+// The "inline" option applies to a field of struct type, or pointer to
+// struct type, and to a field of map type with string keys. The fields
+// of an inline struct are treated as if the struct were anonymous. The
+// entries of an inline map are encoded as members of the enclosing
+// object, following its fields; a key that is also the name of a field
+// causes an error. When decoding, members that match no field are
+// stored in the inline map. The "unknown" option is like "inline" but
+// applies only to a field of type map[string]RawMessage, which then
+// captures unrecognized members as they appear in the input. A struct
+// uses at most one inline map: the least nested one, and the first
+// of those in field order.
+//
 // Handling of anonymous struct fields is new in Go 1.1.
 // Prior to Go 1.1, anonymous struct fields were ignored. To force ignoring of
 // an anonymous struct field in both current and earlier versions, give the field
@@ -155,18 +178,11 @@ import (
 // handle them. Passing cyclic structures to Marshal will result in
 // an error.
 //
+// To change the HTML escaping, the ordering of map keys or the handling
+// of invalid UTF-8, use the Marshal method of MarshalOptions.
+//
 func Marshal(v interface{}) ([]byte, error) {
-	e := newEncodeState()
-
-	err := e.marshal(v, encOpts{escapeHTML: true})
-	if err != nil {
-		return nil, err
-	}
-	buf := append([]byte(nil), e.Bytes()...)
-
-	encodeStatePool.Put(e)
-
-	return buf, nil
+	return MarshalOptions{}.Marshal(v)
 }
 
 // MarshalIndent is like Marshal but applies Indent to format the output.
@@ -247,12 +263,12 @@ func (e *UnsupportedValueError) Error() string {
 	return "json: unsupported value: " + e.Str
 }
 
-// Before Go 1.2, an InvalidUTF8Error was returned by Marshal when
-// attempting to encode a string value with invalid UTF-8 sequences.
-// As of Go 1.2, Marshal instead coerces the string to valid UTF-8 by
-// replacing invalid bytes with the Unicode replacement rune U+FFFD.
-//
-// Deprecated: No longer used; kept for compatibility.
+// An InvalidUTF8Error is returned by MarshalOptions.Marshal with
+// RejectInvalidUTF8 set when attempting to encode a string value with
+// invalid UTF-8 sequences.
+// Before Go 1.2, it was also returned by Marshal. As of Go 1.2, Marshal
+// instead coerces the string to valid UTF-8 by replacing invalid bytes
+// with the Unicode replacement rune U+FFFD.
 type InvalidUTF8Error struct {
 	S string // the whole string value that caused the error
 }
@@ -295,6 +311,10 @@ type encodeState struct {
 	// reasonable amount of nested pointers deep.
 	ptrLevel uint
 	ptrSeen  map[interface{}]struct{}
+
+	// rejectInvalidUTF8 causes strings with invalid UTF-8 to be
+	// an error rather than coerced.
+	rejectInvalidUTF8 bool
 }
 
 const startDetectingCyclesAfter = 1000
@@ -309,6 +329,7 @@ func newEncodeState() *encodeState {
 			panic("ptrEncoder.encode should have emptied ptrSeen via defers")
 		}
 		e.ptrLevel = 0
+		e.rejectInvalidUTF8 = false
 		return e
 	}
 	return &encodeState{ptrSeen: make(map[interface{}]struct{})}
@@ -365,6 +386,8 @@ type encOpts struct {
 	quoted bool
 	// escapeHTML causes '<', '>', and '&' to be escaped in JSON strings.
 	escapeHTML bool
+	// sortKeys causes map entries to be encoded in sorted key order.
+	sortKeys bool
 }
 
 type encoderFunc func(e *encodeState, v reflect.Value, opts encOpts)
@@ -638,6 +661,7 @@ func stringEncoder(e *encodeState, v reflect.Value, opts encOpts) {
 	}
 	if opts.quoted {
 		e2 := newEncodeState()
+		e2.rejectInvalidUTF8 = e.rejectInvalidUTF8
 		// Since we encode the string twice, we only need to escape HTML
 		// the first time.
 		e2.string(v.String(), opts.escapeHTML)
@@ -726,7 +750,15 @@ type structEncoder struct {
 
 type structFields struct {
 	list      []field
-	nameIndex map[string]int
+	nameIndex map[string]int // field index by name
+
+	// byFoldedName is the index of the first field, in list order,
+	// with each name folded by foldName, for case-insensitive lookup.
+	byFoldedName map[string]int
+
+	// inline is the inline map that holds the members matching no
+	// field, if any. Its encoder is that of the map elements.
+	inline *field
 }
 
 func (se structEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
@@ -750,6 +782,9 @@ FieldLoop:
 		if f.omitEmpty && isEmptyValue(fv) {
 			continue
 		}
+		if f.omitZero && f.isZero(fv) {
+			continue
+		}
 		e.WriteByte(next)
 		next = ','
 		if opts.escapeHTML {
@@ -760,6 +795,9 @@ FieldLoop:
 		opts.quoted = f.quoted
 		f.encoder(e, fv, opts)
 	}
+	if f := se.fields.inline; f != nil {
+		next = se.encodeInline(e, v, f, next, opts)
+	}
 	if next == '{' {
 		e.WriteString("{}")
 	} else {
@@ -767,6 +805,42 @@ FieldLoop:
 	}
 }
 
+// encodeInline encodes the entries of the inline map f of the struct v
+// as members, given the byte to write before the first one. It returns
+// the byte to write before the next member.
+func (se structEncoder) encodeInline(e *encodeState, v reflect.Value, f *field, next byte, opts encOpts) byte {
+	mv := v
+	for _, i := range f.index {
+		if mv.Kind() == reflect.Ptr {
+			if mv.IsNil() {
+				return next
+			}
+			mv = mv.Elem()
+		}
+		mv = mv.Field(i)
+	}
+	if mv.Len() == 0 {
+		return next
+	}
+	keys := mv.MapKeys()
+	if opts.sortKeys {
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	}
+	opts.quoted = false
+	for _, k := range keys {
+		ks := k.String()
+		if _, ok := se.fields.nameIndex[ks]; ok {
+			e.error(fmt.Errorf("json: inline map key %q of type %v conflicts with a field", ks, v.Type()))
+		}
+		e.WriteByte(next)
+		next = ','
+		e.string(ks, opts.escapeHTML)
+		e.WriteByte(':')
+		f.encoder(e, mv.MapIndex(k), opts)
+	}
+	return next
+}
+
 func newStructEncoder(t reflect.Type) encoderFunc {
 	se := structEncoder{fields: cachedTypeFields(t)}
 	return se.encode
@@ -803,7 +877,9 @@ func (me mapEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
 			e.error(fmt.Errorf("json: encoding error for type %q: %q", v.Type().String(), err.Error()))
 		}
 	}
-	sort.Slice(sv, func(i, j int) bool { return sv[i].ks < sv[j].ks })
+	if opts.sortKeys {
+		sort.Slice(sv, func(i, j int) bool { return sv[i].ks < sv[j].ks })
+	}
 
 	for i, kv := range sv {
 		if i > 0 {
@@ -1066,6 +1142,9 @@ func (e *encodeState) string(s string, escapeHTML bool) {
 		}
 		c, size := utf8.DecodeRuneInString(s[i:])
 		if c == utf8.RuneError && size == 1 {
+			if e.rejectInvalidUTF8 {
+				e.error(&InvalidUTF8Error{s})
+			}
 			if start < i {
 				e.WriteString(s[start:i])
 			}
@@ -1138,6 +1217,9 @@ func (e *encodeState) stringBytes(s []byte, escapeHTML bool) {
 		}
 		c, size := utf8.DecodeRune(s[i:])
 		if c == utf8.RuneError && size == 1 {
+			if e.rejectInvalidUTF8 {
+				e.error(&InvalidUTF8Error{string(s)})
+			}
 			if start < i {
 				e.Write(s[start:i])
 			}
@@ -1174,8 +1256,7 @@ func (e *encodeState) stringBytes(s []byte, escapeHTML bool) {
 // A field represents a single field found in a struct.
 type field struct {
 	name      string
-	nameBytes []byte                 // []byte(name)
-	equalFold func(s, t []byte) bool // bytes.EqualFold or equivalent
+	nameBytes []byte // []byte(name)
 
 	nameNonEsc  string // `"` + name + `":`
 	nameEscHTML string // `"` + HTMLEscape(name) + `":`
@@ -1184,8 +1265,10 @@ type field struct {
 	index     []int
 	typ       reflect.Type
 	omitEmpty bool
+	omitZero  bool
 	quoted    bool
 
+	isZero  func(reflect.Value) bool // for omitZero
 	encoder encoderFunc
 }
 
@@ -1225,6 +1308,9 @@ func typeFields(t reflect.Type) structFields {
 	// Fields found.
 	var fields []field
 
+	// Inline map found, if any.
+	var inline *field
+
 	// Buffer to run HTMLEscape on field names.
 	var nameEscBuf bytes.Buffer
 
@@ -1274,6 +1360,15 @@ func typeFields(t reflect.Type) structFields {
 					ft = ft.Elem()
 				}
 
+				// Inline maps hold members matching no field.
+				if isInlineMap(sf.Type, opts) {
+					if inline == nil {
+						inline = &field{name: sf.Name, index: index, typ: ft}
+					}
+					continue
+				}
+				inlineStruct := opts.Contains("inline") && ft.Kind() == reflect.Struct
+
 				// Only strings, floats, integers, and booleans can be quoted.
 				quoted := false
 				if opts.Contains("string") {
@@ -1288,7 +1383,7 @@ func typeFields(t reflect.Type) structFields {
 				}
 
 				// Record found field and index sequence.
-				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+				if !inlineStruct && (name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct) {
 					tagged := name != ""
 					if name == "" {
 						name = sf.Name
@@ -1299,10 +1394,10 @@ func typeFields(t reflect.Type) structFields {
 						index:     index,
 						typ:       ft,
 						omitEmpty: opts.Contains("omitempty"),
+						omitZero:  opts.Contains("omitzero"),
 						quoted:    quoted,
 					}
 					field.nameBytes = []byte(field.name)
-					field.equalFold = foldFunc(field.nameBytes)
 
 					// Build nameEscHTML and nameNonEsc ahead of time.
 					nameEscBuf.Reset()
@@ -1382,13 +1477,70 @@ func typeFields(t reflect.Type) structFields {
 
 	for i := range fields {
 		f := &fields[i]
-		f.encoder = typeEncoder(typeByIndex(t, f.index))
+		ft := typeByIndex(t, f.index)
+		f.encoder = typeEncoder(ft)
+		if f.omitZero {
+			f.isZero = zeroFunc(ft)
+		}
+	}
+	if inline != nil {
+		inline.encoder = typeEncoder(inline.typ.Elem())
 	}
 	nameIndex := make(map[string]int, len(fields))
+	byFoldedName := make(map[string]int, len(fields))
 	for i, field := range fields {
 		nameIndex[field.name] = i
+		folded := string(foldName(field.nameBytes))
+		if _, ok := byFoldedName[folded]; !ok {
+			byFoldedName[folded] = i
+		}
+	}
+	return structFields{fields, nameIndex, byFoldedName, inline}
+}
+
+// isInlineMap reports whether a field of type t with tag options opts
+// is an inline map: a map with string keys under the "inline" option,
+// or a map[string]RawMessage under the "unknown" option.
+func isInlineMap(t reflect.Type, opts tagOptions) bool {
+	if t.Kind() != reflect.Map || t.Key().Kind() != reflect.String {
+		return false
+	}
+	return opts.Contains("inline") || opts.Contains("unknown") && t.Elem() == rawMessageType
+}
+
+var rawMessageType = reflect.TypeOf(RawMessage(nil))
+
+type isZeroer interface {
+	IsZero() bool
+}
+
+var isZeroerType = reflect.TypeOf((*isZeroer)(nil)).Elem()
+
+// zeroFunc returns a function that reports whether a value of type t
+// is zero, using its IsZero method if it has one.
+func zeroFunc(t reflect.Type) func(reflect.Value) bool {
+	switch {
+	case t.Implements(isZeroerType):
+		if t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface {
+			return func(v reflect.Value) bool {
+				return v.IsNil() || v.Interface().(isZeroer).IsZero()
+			}
+		}
+		return func(v reflect.Value) bool {
+			return v.Interface().(isZeroer).IsZero()
+		}
+	case t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			if !v.CanAddr() {
+				// Copy the value to call the pointer method.
+				v2 := reflect.New(t).Elem()
+				v2.Set(v)
+				v = v2
+			}
+			return v.Addr().Interface().(isZeroer).IsZero()
+		}
 	}
-	return structFields{fields, nameIndex}
+	return reflect.Value.IsZero
 }
 
 // dominantField looks through the fields, all of which are known to
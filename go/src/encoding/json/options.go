@@ -0,0 +1,170 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// MarshalOptions configures the encoding of Go values as JSON.
+// The zero MarshalOptions encodes like Marshal.
+//
+// MarshalOptions配置Go值到JSON的编码方式。
+type MarshalOptions struct {
+	// DisableHTMLEscape causes '<', '>', and '&' to be written as is
+	// in JSON strings, instead of being escaped as described for
+	// Marshal.
+	DisableHTMLEscape bool
+
+	// Nondeterministic causes the entries of maps to be encoded in
+	// map iteration order, which is faster, instead of in sorted key
+	// order. Equal values may then encode to different bytes.
+	Nondeterministic bool
+
+	// RejectInvalidUTF8 causes strings holding invalid UTF-8 to be
+	// reported with an InvalidUTF8Error instead of having the invalid
+	// bytes replaced with the Unicode replacement rune.
+	RejectInvalidUTF8 bool
+}
+
+// Marshal is like the package-level Marshal but uses the options in o.
+func (o MarshalOptions) Marshal(v interface{}) ([]byte, error) {
+	e := newEncodeState()
+	e.rejectInvalidUTF8 = o.RejectInvalidUTF8
+
+	err := e.marshal(v, o.encOpts())
+	if err != nil {
+		return nil, err
+	}
+	buf := append([]byte(nil), e.Bytes()...)
+
+	encodeStatePool.Put(e)
+
+	return buf, nil
+}
+
+func (o MarshalOptions) encOpts() encOpts {
+	return encOpts{escapeHTML: !o.DisableHTMLEscape, sortKeys: !o.Nondeterministic}
+}
+
+// UnmarshalOptions configures the decoding of JSON into Go values.
+// The zero UnmarshalOptions decodes like Unmarshal.
+//
+// UnmarshalOptions配置JSON到Go值的解码方式。
+type UnmarshalOptions struct {
+	// UseNumber causes a number to be unmarshaled into an interface{}
+	// as a Number instead of as a float64.
+	UseNumber bool
+
+	// DisallowUnknownFields causes an error to be returned when the
+	// destination is a struct and the input contains object keys which
+	// match no non-ignored, exported field of the destination and are
+	// not captured by an inline map.
+	DisallowUnknownFields bool
+
+	// CaseSensitive causes object keys to match struct field names
+	// only exactly, instead of also case-insensitively.
+	CaseSensitive bool
+
+	// RejectDuplicateKeys causes an error to be returned when an
+	// object holds the same key more than once, anywhere in the input,
+	// including in values that are skipped or handed to an Unmarshaler
+	// or RawMessage, or when two keys of an object match the same
+	// struct field. Otherwise the last value for the key wins.
+	RejectDuplicateKeys bool
+
+	// RejectInvalidUTF8 causes a SyntaxError to be returned when a
+	// string holds invalid UTF-8, instead of having the invalid bytes
+	// replaced with the Unicode replacement rune.
+	RejectInvalidUTF8 bool
+}
+
+// Unmarshal is like the package-level Unmarshal but uses the options in o.
+func (o UnmarshalOptions) Unmarshal(data []byte, v interface{}) error {
+	// Check for well-formedness.
+	// Avoids filling out half a data structure
+	// before discovering a JSON syntax error.
+	scan := newScanner()
+	err := checkValid(data, scan)
+	freeScanner(scan)
+	if err != nil {
+		return err
+	}
+
+	d := newDecodeState(data)
+	d.opts = o
+	err = d.unmarshal(v)
+	d.data = nil // don't hold on to the caller's data
+	decodeStatePool.Put(d)
+	return err
+}
+
+// checkDuplicateKeys returns an error if an object in data, a valid
+// JSON value, holds the same key more than once.
+func checkDuplicateKeys(data []byte) error {
+	type object struct {
+		keys    map[string]bool
+		wantKey bool // whether the next string is a key
+	}
+	var stack []*object // innermost last; nil entries are arrays
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '{':
+			stack = append(stack, &object{wantKey: true})
+		case '[':
+			stack = append(stack, nil)
+		case '}', ']':
+			stack = stack[:len(stack)-1]
+		case ',':
+			if o := stack[len(stack)-1]; o != nil {
+				o.wantKey = true
+			}
+		case '"':
+			start := i
+			for i++; data[i] != '"'; i++ {
+				if data[i] == '\\' {
+					i++ // escaped char
+				}
+			}
+			if len(stack) == 0 {
+				break
+			}
+			o := stack[len(stack)-1]
+			if o == nil || !o.wantKey {
+				break
+			}
+			o.wantKey = false
+			key, ok := unquote(data[start : i+1])
+			if !ok {
+				panic(phasePanicMsg)
+			}
+			if o.keys[key] {
+				return fmt.Errorf("json: duplicate key %q", key)
+			}
+			if o.keys == nil {
+				o.keys = make(map[string]bool)
+			}
+			o.keys[key] = true
+		}
+	}
+	return nil
+}
+
+// checkUTF8 returns a SyntaxError if data, a valid JSON value, holds
+// invalid UTF-8. Such bytes can only appear inside strings.
+func checkUTF8(data []byte) error {
+	if utf8.Valid(data) {
+		return nil
+	}
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			return &SyntaxError{"invalid UTF-8 in string", int64(i)}
+		}
+		i += size
+	}
+	return nil
+}
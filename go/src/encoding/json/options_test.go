@@ -0,0 +1,204 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type OmitZero struct {
+	Int    int       `json:",omitzero"`
+	Slice  []int     `json:",omitzero"`
+	Struct struct{}  `json:",omitzero"`
+	Time   time.Time `json:",omitzero"`
+	Ptr    *zeroer   `json:",omitzero"`
+	Val    zeroer    `json:",omitzero"`
+}
+
+// zeroer is zero when it says so, not when all its fields are.
+type zeroer struct {
+	N int
+}
+
+func (z zeroer) IsZero() bool { return z.N < 0 }
+
+func TestOmitZero(t *testing.T) {
+	for _, tt := range []struct {
+		in   OmitZero
+		want string
+	}{
+		{OmitZero{Val: zeroer{-1}}, `{}`},
+		{OmitZero{Val: zeroer{-1}, Slice: []int{}}, `{"Slice":[]}`},
+		{OmitZero{Val: zeroer{-1}, Time: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}, `{"Time":"2021-01-01T00:00:00Z"}`},
+		{OmitZero{Val: zeroer{-1}, Ptr: &zeroer{-1}}, `{}`},
+		{OmitZero{Val: zeroer{-1}, Ptr: &zeroer{0}}, `{"Ptr":{"N":0}}`},
+		{OmitZero{}, `{"Val":{"N":0}}`},
+	} {
+		b, err := Marshal(tt.in)
+		if err != nil {
+			t.Errorf("Marshal(%+v): %v", tt.in, err)
+			continue
+		}
+		if string(b) != tt.want {
+			t.Errorf("Marshal(%+v) = %s, want %s", tt.in, b, tt.want)
+		}
+	}
+}
+
+type Inner struct {
+	A, B int
+}
+
+type Inline struct {
+	X     int
+	Inner Inner             `json:",inline"`
+	Extra map[string]string `json:",inline"`
+}
+
+type Unknown struct {
+	X     int
+	Extra map[string]RawMessage `json:",unknown"`
+}
+
+func TestInline(t *testing.T) {
+	in := Inline{X: 1, Inner: Inner{2, 3}, Extra: map[string]string{"d": "4", "c": "5"}}
+	b, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"X":1,"A":2,"B":3,"c":"5","d":"4"}`
+	if string(b) != want {
+		t.Errorf("Marshal = %s, want %s", b, want)
+	}
+	var out Inline
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("Unmarshal = %+v, want %+v", out, in)
+	}
+
+	in.Extra["X"] = "6"
+	if _, err := Marshal(in); err == nil || !strings.Contains(err.Error(), "conflicts") {
+		t.Errorf("Marshal with conflicting key: got error %v", err)
+	}
+}
+
+func TestUnknown(t *testing.T) {
+	var u Unknown
+	err := UnmarshalOptions{DisallowUnknownFields: true}.Unmarshal([]byte(`{"a": [1, 2], "X": 1, "b": {"c": null}}`), &u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Unknown{X: 1, Extra: map[string]RawMessage{"a": RawMessage(`[1, 2]`), "b": RawMessage(`{"c": null}`)}}
+	if !reflect.DeepEqual(u, want) {
+		t.Errorf("Unmarshal = %+v, want %+v", u, want)
+	}
+	b, err := Marshal(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"X":1,"a":[1,2],"b":{"c":null}}`; string(b) != want {
+		t.Errorf("Marshal = %s, want %s", b, want)
+	}
+}
+
+func TestMarshalOptions(t *testing.T) {
+	b, err := MarshalOptions{}.Marshal("<\xff>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"\u003c\ufffd\u003e"`; string(b) != want {
+		t.Errorf("Marshal = %s, want %s", b, want)
+	}
+	b, err = MarshalOptions{DisableHTMLEscape: true}.Marshal("<\xff>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"<\ufffd>"`; string(b) != want {
+		t.Errorf("Marshal with DisableHTMLEscape = %s, want %s", b, want)
+	}
+
+	var e *InvalidUTF8Error
+	_, err = MarshalOptions{RejectInvalidUTF8: true}.Marshal(map[string][]string{"a": {"b\xff"}})
+	if !errors.As(err, &e) || e.S != "b\xff" {
+		t.Errorf("Marshal with RejectInvalidUTF8: got error %v", err)
+	}
+
+	// Maps of a single entry encode the same in any order.
+	b, err = MarshalOptions{Nondeterministic: true}.Marshal(map[string]int{"a": 1})
+	if err != nil || string(b) != `{"a":1}` {
+		t.Errorf("Marshal = %s, %v", b, err)
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetOptions(MarshalOptions{RejectInvalidUTF8: true})
+	if err := enc.Encode("\xff"); !errors.As(err, &e) {
+		t.Errorf("Encode with RejectInvalidUTF8: got error %v", err)
+	}
+}
+
+func TestUnmarshalOptions(t *testing.T) {
+	type T struct {
+		Name string
+		M    map[string]int
+	}
+	for _, tt := range []struct {
+		opts UnmarshalOptions
+		in   string
+		want T
+		err  string
+	}{
+		{UnmarshalOptions{}, `{"name": "a"}`, T{Name: "a"}, ""},
+		{UnmarshalOptions{CaseSensitive: true}, `{"name": "a"}`, T{}, ""},
+		{UnmarshalOptions{CaseSensitive: true}, `{"Name": "a"}`, T{Name: "a"}, ""},
+		{UnmarshalOptions{}, `{"Name": "a", "Name": "b"}`, T{Name: "b"}, ""},
+		{UnmarshalOptions{RejectDuplicateKeys: true}, `{"Name": "a", "Name": "b"}`, T{}, `json: duplicate key "Name"`},
+		{UnmarshalOptions{RejectDuplicateKeys: true}, `{"M": {"x": 1, "x": 2}}`, T{}, `json: duplicate key "x"`},
+		{UnmarshalOptions{RejectDuplicateKeys: true}, `{"Name": "a", "name": "b"}`, T{}, `json: duplicate key "name"`},
+		{UnmarshalOptions{RejectDuplicateKeys: true, CaseSensitive: true}, `{"Name": "a", "name": "b"}`, T{Name: "a"}, ""},
+		{UnmarshalOptions{RejectDuplicateKeys: true}, `{"Skipped": {"x": 1, "x": 2}}`, T{}, `json: duplicate key "x"`},
+		{UnmarshalOptions{RejectDuplicateKeys: true}, `{"Name": "a", "N\u0061me": "b"}`, T{}, `json: duplicate key "Name"`},
+		{UnmarshalOptions{RejectDuplicateKeys: true}, `{"M": {"x": 1}, "Raw": {"x": 1}}`, T{M: map[string]int{"x": 1}}, ""},
+		{UnmarshalOptions{}, "{\"Name\": \"\xff\"}", T{Name: "�"}, ""},
+		{UnmarshalOptions{RejectInvalidUTF8: true}, "{\"Name\": \"\xff\"}", T{}, "invalid UTF-8 in string"},
+	} {
+		var got T
+		err := tt.opts.Unmarshal([]byte(tt.in), &got)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%+v.Unmarshal(%#q): got error %v, want %s", tt.opts, tt.in, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%+v.Unmarshal(%#q): %v", tt.opts, tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v.Unmarshal(%#q) = %+v, want %+v", tt.opts, tt.in, got, tt.want)
+		}
+	}
+
+	var v interface{}
+	if err := (UnmarshalOptions{RejectDuplicateKeys: true}).Unmarshal([]byte(`[{"a": 1, "a": 2}]`), &v); err == nil {
+		t.Error("Unmarshal into interface{} with duplicate keys: no error")
+	}
+	var raw struct{ R RawMessage }
+	if err := (UnmarshalOptions{RejectDuplicateKeys: true}).Unmarshal([]byte(`{"R": {"a": [{"b": 1, "b": 2}]}}`), &raw); err == nil {
+		t.Error("Unmarshal into RawMessage with duplicate keys: no error")
+	}
+	dec := NewDecoder(strings.NewReader(`1`))
+	dec.SetOptions(UnmarshalOptions{UseNumber: true})
+	if err := dec.Decode(&v); err != nil || v != Number("1") {
+		t.Errorf("Decode with UseNumber = %#v, %v", v, err)
+	}
+}
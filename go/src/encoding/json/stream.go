@@ -8,6 +8,9 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"reflect"
+	"strings"
+	"unicode/utf8"
 )
 
 // A Decoder reads and decodes JSON values from an input stream.
@@ -34,12 +37,17 @@ func NewDecoder(r io.Reader) *Decoder {
 
 // UseNumber causes the Decoder to unmarshal a number into an interface{} as a
 // Number instead of as a float64.
-func (dec *Decoder) UseNumber() { dec.d.useNumber = true }
+func (dec *Decoder) UseNumber() { dec.d.opts.UseNumber = true }
 
 // DisallowUnknownFields causes the Decoder to return an error when the destination
 // is a struct and the input contains object keys which do not match any
 // non-ignored, exported fields in the destination.
-func (dec *Decoder) DisallowUnknownFields() { dec.d.disallowUnknownFields = true }
+func (dec *Decoder) DisallowUnknownFields() { dec.d.opts.DisallowUnknownFields = true }
+
+// SetOptions causes the Decoder to decode subsequent values using the
+// options in o, replacing those set so far, including by UseNumber and
+// DisallowUnknownFields.
+func (dec *Decoder) SetOptions(o UnmarshalOptions) { dec.d.opts = o }
 
 // Decode reads the next JSON-encoded value from its
 // input and stores it in the value pointed to by v.
@@ -179,22 +187,28 @@ func nonSpace(b []byte) bool {
 
 // An Encoder writes JSON values to an output stream.
 type Encoder struct {
-	w          io.Writer
-	err        error
-	escapeHTML bool
+	w    io.Writer
+	err  error
+	opts MarshalOptions
 
-	indentBuf    *bytes.Buffer
+	buf          *bytes.Buffer // output of the current call
 	indentPrefix string
 	indentValue  string
+
+	tokenState int
+	tokenStack []int
 }
 
 // NewEncoder returns a new encoder that writes to w.
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w: w, escapeHTML: true}
+	return &Encoder{w: w}
 }
 
-// Encode writes the JSON encoding of v to the stream,
-// followed by a newline character.
+// Encode writes the JSON encoding of v to the stream.
+// At the top level the value is followed by a newline character.
+// Between calls to WriteToken that begin and end an array or object,
+// Encode writes v as the next array element, object key or object
+// member value, adding separators and indentation as needed.
 //
 // See the documentation for Marshal for details about the
 // conversion of Go values to JSON.
@@ -203,35 +217,214 @@ func (enc *Encoder) Encode(v interface{}) error {
 		return enc.err
 	}
 	e := newEncodeState()
-	err := e.marshal(v, encOpts{escapeHTML: enc.escapeHTML})
+	e.rejectInvalidUTF8 = enc.opts.RejectInvalidUTF8
+	err := e.marshal(v, enc.opts.encOpts())
 	if err != nil {
 		return err
 	}
+	if enc.tokenState == tokenTopValue && !enc.indented() {
+		// A top-level value needs no separator or indentation,
+		// so it is written directly, followed by a newline.
+		e.WriteByte('\n')
+		if _, err = enc.w.Write(e.Bytes()); err != nil {
+			enc.err = err
+		}
+	} else {
+		err = enc.writeValue(e.Bytes())
+	}
+	encodeStatePool.Put(e)
+	return err
+}
 
-	// Terminate each value with a newline.
-	// This makes the output look a little nicer
-	// when debugging, and some kind of space
-	// is required if the encoded value was a number,
-	// so that the reader knows there aren't more
-	// digits coming.
-	e.WriteByte('\n')
+// WriteToken writes the JSON token t to the stream. The token must be
+// of one of the types returned by Decoder.Token: Delim, bool, float64,
+// Number, string or nil. To write values of other types inside an
+// array or object, use Encode.
+//
+// WriteToken checks that the tokens written so far form a prefix of
+// valid JSON: delimiters must be properly nested and matched, and an
+// object key must be a string. It inserts the commas and colons
+// elided by Decoder.Token, the indentation requested by SetIndent,
+// and the newline that follows each top-level value.
+// A token that would not be valid in the current position is not
+// written and causes an error; the Encoder remains usable.
+func (enc *Encoder) WriteToken(t Token) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	switch t := t.(type) {
+	case Delim:
+		return enc.writeDelim(t)
+	case bool, float64, Number, string, nil:
+		return enc.Encode(t)
+	}
+	return &UnsupportedTypeError{reflect.TypeOf(t)}
+}
 
-	b := e.Bytes()
-	if enc.indentPrefix != "" || enc.indentValue != "" {
-		if enc.indentBuf == nil {
-			enc.indentBuf = new(bytes.Buffer)
+// WriteValue writes the JSON value v to the stream as if it had been
+// written token by token with WriteToken. The insignificant space
+// characters in v are replaced by the indentation requested by
+// SetIndent, if any. If v is not valid JSON, WriteValue writes nothing
+// and returns a SyntaxError.
+func (enc *Encoder) WriteValue(v RawMessage) error {
+	if enc.err != nil {
+		return enc.err
+	}
+	e := newEncodeState()
+	defer encodeStatePool.Put(e)
+	err := compact(&e.Buffer, v, !enc.opts.DisableHTMLEscape)
+	if err != nil {
+		return err
+	}
+	if enc.opts.RejectInvalidUTF8 && !utf8.Valid(e.Bytes()) {
+		return &InvalidUTF8Error{string(v)}
+	}
+	return enc.writeValue(e.Bytes())
+}
+
+// writeValue writes the compact JSON value b to the stream in the
+// position given by the token state.
+func (enc *Encoder) writeValue(b []byte) error {
+	key := false
+	switch enc.tokenState {
+	case tokenObjectStart, tokenObjectComma:
+		if b[0] != '"' {
+			return enc.tokenError("non-string object key")
 		}
-		enc.indentBuf.Reset()
-		err = Indent(enc.indentBuf, b, enc.indentPrefix, enc.indentValue)
-		if err != nil {
+		key = true
+	}
+	buf := enc.tokenPrefix()
+	if enc.indented() {
+		depth := len(enc.tokenStack)
+		if err := Indent(buf, b, enc.indentPrefix+strings.Repeat(enc.indentValue, depth), enc.indentValue); err != nil {
 			return err
 		}
-		b = enc.indentBuf.Bytes()
+	} else {
+		buf.Write(b)
 	}
-	if _, err = enc.w.Write(b); err != nil {
+	if key {
+		buf.WriteByte(':')
+		if enc.indented() {
+			buf.WriteByte(' ')
+		}
+		enc.tokenState = tokenObjectValue
+	} else {
+		enc.tokenValueEnd()
+	}
+	return enc.flush()
+}
+
+// writeDelim writes one of the delimiters [ ] { } to the stream.
+func (enc *Encoder) writeDelim(d Delim) error {
+	switch d {
+	case '[', '{':
+		if !enc.tokenValueAllowed() {
+			return enc.tokenError(quoteChar(byte(d)))
+		}
+		buf := enc.tokenPrefix()
+		buf.WriteByte(byte(d))
+		enc.tokenStack = append(enc.tokenStack, enc.tokenState)
+		if d == '[' {
+			enc.tokenState = tokenArrayStart
+		} else {
+			enc.tokenState = tokenObjectStart
+		}
+		return enc.flush()
+
+	case ']', '}':
+		start, comma := tokenArrayStart, tokenArrayComma
+		if d == '}' {
+			start, comma = tokenObjectStart, tokenObjectComma
+		}
+		if enc.tokenState != start && enc.tokenState != comma {
+			return enc.tokenError(quoteChar(byte(d)))
+		}
+		buf := enc.resetBuf()
+		if enc.tokenState == comma && enc.indented() {
+			newline(buf, enc.indentPrefix, enc.indentValue, len(enc.tokenStack)-1)
+		}
+		buf.WriteByte(byte(d))
+		enc.tokenState = enc.tokenStack[len(enc.tokenStack)-1]
+		enc.tokenStack = enc.tokenStack[:len(enc.tokenStack)-1]
+		enc.tokenValueEnd()
+		return enc.flush()
+	}
+	return enc.tokenError("delimiter " + quoteChar(byte(d)))
+}
+
+func (enc *Encoder) indented() bool {
+	return enc.indentPrefix != "" || enc.indentValue != ""
+}
+
+func (enc *Encoder) resetBuf() *bytes.Buffer {
+	if enc.buf == nil {
+		enc.buf = new(bytes.Buffer)
+	}
+	enc.buf.Reset()
+	return enc.buf
+}
+
+// tokenPrefix resets the output buffer and writes to it the separator
+// and indentation that precede a value or key in the current state.
+func (enc *Encoder) tokenPrefix() *bytes.Buffer {
+	buf := enc.resetBuf()
+	switch enc.tokenState {
+	case tokenArrayComma, tokenObjectComma:
+		buf.WriteByte(',')
+		fallthrough
+	case tokenArrayStart, tokenObjectStart:
+		if enc.indented() {
+			newline(buf, enc.indentPrefix, enc.indentValue, len(enc.tokenStack))
+		}
+	}
+	return buf
+}
+
+func (enc *Encoder) tokenValueAllowed() bool {
+	switch enc.tokenState {
+	case tokenTopValue, tokenArrayStart, tokenArrayComma, tokenObjectValue:
+		return true
+	}
+	return false
+}
+
+// tokenValueEnd advances the token state past a value. A value at the
+// top level is terminated with a newline. This makes the output look
+// a little nicer when debugging, and some kind of space is required if
+// the encoded value was a number, so that the reader knows there
+// aren't more digits coming.
+func (enc *Encoder) tokenValueEnd() {
+	switch enc.tokenState {
+	case tokenTopValue:
+		enc.buf.WriteByte('\n')
+	case tokenArrayStart, tokenArrayComma:
+		enc.tokenState = tokenArrayComma
+	case tokenObjectValue:
+		enc.tokenState = tokenObjectComma
+	}
+}
+
+func (enc *Encoder) tokenError(what string) error {
+	var context string
+	switch enc.tokenState {
+	case tokenTopValue:
+		context = " at top level"
+	case tokenArrayStart, tokenArrayComma:
+		context = " in array"
+	case tokenObjectStart, tokenObjectComma:
+		context = " where object key is expected"
+	case tokenObjectValue:
+		context = " where object value is expected"
+	}
+	return errors.New("json: cannot write " + what + context)
+}
+
+// flush writes the output buffer to the underlying writer.
+func (enc *Encoder) flush() error {
+	_, err := enc.w.Write(enc.buf.Bytes())
+	if err != nil {
 		enc.err = err
 	}
-	encodeStatePool.Put(e)
 	return err
 }
 
@@ -251,7 +444,14 @@ func (enc *Encoder) SetIndent(prefix, indent string) {
 // In non-HTML settings where the escaping interferes with the readability
 // of the output, SetEscapeHTML(false) disables this behavior.
 func (enc *Encoder) SetEscapeHTML(on bool) {
-	enc.escapeHTML = on
+	enc.opts.DisableHTMLEscape = !on
+}
+
+// SetOptions causes the Encoder to encode subsequent values using the
+// options in o, replacing those set so far, including by SetEscapeHTML.
+// A new Encoder uses the zero MarshalOptions.
+func (enc *Encoder) SetOptions(o MarshalOptions) {
+	enc.opts = o
 }
 
 // RawMessage is a raw encoded JSON value.
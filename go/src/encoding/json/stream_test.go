@@ -89,6 +89,86 @@ func TestEncoderIndent(t *testing.T) {
 	}
 }
 
+func TestEncoderWriteToken(t *testing.T) {
+	v := map[string]interface{}{
+		"a": []interface{}{1.0, "x", nil, []interface{}{}, map[string]interface{}{}},
+		"b": map[string]interface{}{"c": true, "d": []interface{}{map[string]interface{}{"e": 3.0}}},
+	}
+	for _, indent := range []string{"", "\t"} {
+		var want bytes.Buffer
+		enc := NewEncoder(&want)
+		enc.SetIndent(">", indent)
+		enc.Encode(v)
+		enc.Encode(1.0)
+
+		var have bytes.Buffer
+		enc = NewEncoder(&have)
+		enc.SetIndent(">", indent)
+		for _, tk := range []Token{
+			Delim('{'), "a", Delim('['), 1.0, "x", nil, Delim('['), Delim(']'), Delim('{'), Delim('}'), Delim(']'),
+			"b", Delim('{'), "c",
+		} {
+			if err := enc.WriteToken(tk); err != nil {
+				t.Fatalf("WriteToken(%v): %v", tk, err)
+			}
+		}
+		if err := enc.Encode(true); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.WriteToken("d"); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.WriteValue(RawMessage(` [ {"e" : 3} ] `)); err != nil {
+			t.Fatal(err)
+		}
+		for _, tk := range []Token{Delim('}'), Delim('}'), Number("1")} {
+			if err := enc.WriteToken(tk); err != nil {
+				t.Fatalf("WriteToken(%v): %v", tk, err)
+			}
+		}
+		if have.String() != want.String() {
+			t.Errorf("indent %q: token encoding mismatch", indent)
+			diff(t, have.Bytes(), want.Bytes())
+		}
+	}
+}
+
+func TestEncoderWriteTokenErrors(t *testing.T) {
+	for _, tt := range []struct {
+		tokens []Token
+		err    string
+	}{
+		{[]Token{Delim(']')}, "json: cannot write ']' at top level"},
+		{[]Token{Delim('['), Delim('}')}, "json: cannot write '}' in array"},
+		{[]Token{Delim('{'), 1.0}, "json: cannot write non-string object key where object key is expected"},
+		{[]Token{Delim('{'), Delim('[')}, "json: cannot write '[' where object key is expected"},
+		{[]Token{Delim('{'), "a", Delim('}')}, "json: cannot write '}' where object value is expected"},
+		{[]Token{Delim('x')}, "json: cannot write delimiter 'x' at top level"},
+		{[]Token{1}, "json: unsupported type: int"},
+	} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		var err error
+		for _, tk := range tt.tokens {
+			if err = enc.WriteToken(tk); err != nil {
+				break
+			}
+		}
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("WriteToken(%v): got error %v, want %s", tt.tokens, err, tt.err)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.WriteValue(RawMessage(`[1,`)); err == nil {
+		t.Error("WriteValue of invalid JSON: no error")
+	}
+	if err := enc.WriteValue(RawMessage(`[1]`)); err != nil || buf.String() != "[1]\n" {
+		t.Errorf("WriteValue after error = %q, %v", buf.String(), err)
+	}
+}
+
 type strMarshaler string
 
 func (s strMarshaler) MarshalJSON() ([]byte, error) {
@@ -0,0 +1,433 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Canonical sets the encoder to write the Exclusive XML
+// Canonicalization 1.0 form (https://www.w3.org/TR/xml-exc-c14n/) of
+// the XML it encodes, as used for XML signatures. If withComments is
+// false, comments are omitted, as by the method identified by
+// http://www.w3.org/2001/10/xml-exc-c14n#; otherwise they are kept,
+// as by http://www.w3.org/2001/10/xml-exc-c14n#WithComments.
+//
+// The prefixList is the InclusiveNamespaces PrefixList parameter of
+// the method: a whitespace-separated list of prefixes whose name
+// spaces are handled as by inclusive canonicalization, being declared
+// on every element where they are in scope and not already declared
+// the same way by an output ancestor, whether visibly used or not.
+// The default name space is named #default. Only name spaces declared
+// in the encoded tokens are in scope.
+//
+// The names of tokens passed to EncodeToken hold name space URLs, so
+// the encoder writes each name with a prefix declared for its name
+// space in scope, preferring the default name space for elements, or
+// declares one if there is none. To keep the prefixes of an existing
+// document, as the canonical form requires, use Canonicalize.
+//
+// In canonical form, each element declares exactly the name spaces
+// visibly used by its name and attributes that are not already
+// declared the same way by an output ancestor, name space
+// declarations and attributes are sorted, empty elements are written
+// as a start-end tag pair and character references are replaced by
+// the characters they stand for. The XML declaration, directives and
+// character data outside of elements are omitted, and Indent has no
+// effect.
+//
+// Values written by Encode and EncodeElement, including any innerxml
+// fields, are marshaled as usual and then canonicalized, so they must
+// be well-formed XML.
+func (enc *Encoder) Canonical(withComments bool, prefixList string) {
+	c := &c14nState{withComments: withComments}
+	for _, prefix := range strings.Fields(prefixList) {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		c.inclusive = append(c.inclusive, prefix)
+	}
+	enc.p.c14n = c
+}
+
+// Canonicalize reads tokens from d until io.EOF and writes their
+// Exclusive XML Canonicalization 1.0 form to w, as described for
+// Encoder.Canonical. It is used to canonicalize an existing document
+// before signing or verifying it.
+//
+// Canonicalize reads d with RawToken and resolves the name space
+// prefixes itself, so every element and attribute keeps the prefix
+// it has in the document, even where several prefixes are bound to
+// the same name space. It reports an error for an undeclared prefix
+// or for mismatched start and end elements.
+func Canonicalize(w io.Writer, d *Decoder, withComments bool, prefixList string) error {
+	enc := NewEncoder(w)
+	enc.Canonical(withComments, prefixList)
+	if err := enc.p.writeCanonicalRaw(d); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// c14nState is the state of an Encoder writing canonical XML.
+type c14nState struct {
+	withComments bool
+	inclusive    []string    // prefixes of the InclusiveNamespaces PrefixList
+	seenRoot     bool        // a top-level element has been started
+	names        []Name      // prefixed names of the open elements
+	rendered     []nsBinding // name space declarations written on open elements
+	marks        []int       // len(rendered) at each open element
+}
+
+// renderedURL returns the name space that an open output element
+// declared for prefix, if any.
+func (c *c14nState) renderedURL(prefix string) (string, bool) {
+	for i := len(c.rendered) - 1; i >= 0; i-- {
+		if c.rendered[i].prefix == prefix {
+			return c.rendered[i].url, true
+		}
+	}
+	return "", false
+}
+
+// encodeCanonical marshals v as Encode or EncodeElement would and
+// writes the result in canonical form.
+func (enc *Encoder) encodeCanonical(v interface{}, start *StartElement) error {
+	var b bytes.Buffer
+	plain := NewEncoder(&b)
+	plain.UseDeclaredPrefixes(true)
+	if err := plain.p.marshalValue(reflect.ValueOf(v), nil, start); err != nil {
+		return err
+	}
+	if err := plain.Flush(); err != nil {
+		return err
+	}
+	if err := enc.p.writeCanonicalRaw(NewDecoder(&b)); err != nil {
+		return err
+	}
+	return enc.p.Flush()
+}
+
+// writeCanonicalRaw writes the raw tokens read from d in canonical
+// form, keeping their prefixes.
+func (p *printer) writeCanonicalRaw(d *Decoder) error {
+	for {
+		t, err := d.RawToken()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := p.writeCanonical(t, true); err != nil {
+			return err
+		}
+	}
+}
+
+// writeCanonical writes the token t in canonical form. If raw is set,
+// the names of t carry prefixes, as those returned by Decoder.RawToken
+// do, rather than name space URLs.
+func (p *printer) writeCanonical(t Token, raw bool) error {
+	c := p.c14n
+	switch t := t.(type) {
+	case StartElement:
+		if !raw {
+			return p.writeCanonicalStart(&t)
+		}
+		if t.Name.Local == "" {
+			return fmt.Errorf("xml: start tag with no name")
+		}
+		p.tags = append(p.tags, t.Name)
+		p.bindDecls(t.Attr)
+		return p.writeCanonicalRawStart(&t)
+	case EndElement:
+		if err := p.popTag(t.Name); err != nil {
+			return err
+		}
+		p.WriteString("</")
+		p.writeCanonicalName(c.names[len(c.names)-1])
+		p.WriteByte('>')
+		p.unbindDecls()
+		c.names = c.names[:len(c.names)-1]
+		c.rendered = c.rendered[:c.marks[len(c.marks)-1]]
+		c.marks = c.marks[:len(c.marks)-1]
+	case CharData:
+		if len(p.tags) > 0 {
+			p.escapeCanonical(t, false)
+		}
+	case Comment:
+		if bytes.Contains(t, endComment) {
+			return fmt.Errorf("xml: EncodeToken of Comment containing --> marker")
+		}
+		if c.withComments {
+			p.writeCanonicalTopLevel(func() {
+				p.WriteString("<!--")
+				p.Write(t)
+				p.WriteString("-->")
+			})
+		}
+	case ProcInst:
+		if t.Target == "xml" {
+			// The XML declaration is not part of the canonical form.
+			break
+		}
+		if !isNameString(t.Target) {
+			return fmt.Errorf("xml: EncodeToken of ProcInst with invalid Target")
+		}
+		if bytes.Contains(t.Inst, endProcInst) {
+			return fmt.Errorf("xml: EncodeToken of ProcInst containing ?> marker")
+		}
+		p.writeCanonicalTopLevel(func() {
+			p.WriteString("<?")
+			p.WriteString(t.Target)
+			if len(t.Inst) > 0 {
+				p.WriteByte(' ')
+				p.Write(t.Inst)
+			}
+			p.WriteString("?>")
+		})
+	case Directive:
+		if !isValidDirective(t) {
+			return fmt.Errorf("xml: EncodeToken of Directive containing wrong < or > markers")
+		}
+		// The document type declaration is not part of the canonical form.
+	default:
+		return fmt.Errorf("xml: EncodeToken of invalid token type")
+	}
+	return p.cachedWriteError()
+}
+
+// writeCanonicalTopLevel calls write to write a comment or processing
+// instruction, separating it with a newline from the document element
+// if it is outside of it.
+func (p *printer) writeCanonicalTopLevel(write func()) {
+	if len(p.tags) > 0 {
+		write()
+		return
+	}
+	if p.c14n.seenRoot {
+		p.WriteByte('\n')
+		write()
+	} else {
+		write()
+		p.WriteByte('\n')
+	}
+}
+
+// writeCanonicalStart writes the start element start, whose names
+// carry name space URLs as those returned by Decoder.Token do, in
+// canonical form.
+func (p *printer) writeCanonicalStart(start *StartElement) error {
+	if start.Name.Local == "" {
+		return fmt.Errorf("xml: start tag with no name")
+	}
+	p.tags = append(p.tags, start.Name)
+	p.bindDecls(start.Attr)
+
+	// Bind the name spaces of the element and its attributes that are
+	// not declared yet, and replace the name spaces by prefixes.
+	if _, ok := p.elementPrefix(start.Name); !ok {
+		p.bound = append(p.bound, nsBinding{"", start.Name.Space})
+	} else if u, _ := p.boundURL(""); start.Name.Space == "" && u != "" {
+		p.bound = append(p.bound, nsBinding{"", ""})
+	}
+	prefix, _ := p.elementPrefix(start.Name)
+	raw := StartElement{Name: Name{prefix, start.Name.Local}}
+	for _, attr := range start.Attr {
+		if _, ok := declaredPrefix(attr.Name); ok || attr.Name.Local == "" {
+			continue
+		}
+		switch attr.Name.Space {
+		case "":
+		case xmlURL:
+			attr.Name.Space = xmlPrefix
+		default:
+			prefix, ok := p.boundPrefix(attr.Name.Space, false)
+			if !ok {
+				prefix = p.canonicalAttrPrefix(attr.Name.Space)
+				p.bound = append(p.bound, nsBinding{prefix, attr.Name.Space})
+			}
+			attr.Name.Space = prefix
+		}
+		raw.Attr = append(raw.Attr, attr)
+	}
+	return p.writeCanonicalRawStart(&raw)
+}
+
+// writeCanonicalRawStart writes the start element start, whose names
+// carry the prefixes written in the document as those returned by
+// Decoder.RawToken do, in canonical form. The name space declarations
+// of start must already be bound.
+func (p *printer) writeCanonicalRawStart(start *StartElement) error {
+	c := p.c14n
+	c.seenRoot = true
+	c.marks = append(c.marks, len(c.rendered))
+	c.names = append(c.names, start.Name)
+
+	// Note the prefixes visibly used by the element and its attributes,
+	// and the name spaces of the attributes, by which they are sorted.
+	if start.Name.Space == xmlnsPrefix {
+		return fmt.Errorf("xml: element name with reserved prefix %s", xmlnsPrefix)
+	}
+	used := []string{start.Name.Space}
+	type canonicalAttr struct {
+		Attr
+		url string
+	}
+	var attrs []canonicalAttr
+	for _, attr := range start.Attr {
+		if _, ok := declaredPrefix(attr.Name); ok || attr.Name.Local == "" {
+			continue
+		}
+		a := canonicalAttr{Attr: attr}
+		switch attr.Name.Space {
+		case "":
+		case xmlPrefix:
+			a.url = xmlURL
+		default:
+			a.url, _ = p.boundURL(attr.Name.Space)
+			used = append(used, attr.Name.Space)
+		}
+		attrs = append(attrs, a)
+	}
+	for _, prefix := range c.inclusive {
+		if _, ok := p.boundURL(prefix); ok {
+			used = append(used, prefix)
+		}
+	}
+
+	// Declare the visibly used and inclusive prefixes not declared
+	// the same way by an output ancestor.
+	var decls []nsBinding
+	for _, prefix := range used {
+		if prefix == xmlPrefix {
+			continue
+		}
+		url, bound := p.boundURL(prefix)
+		if !bound && prefix != "" {
+			return fmt.Errorf("xml: name space prefix %s is not declared", prefix)
+		}
+		rendered, ok := c.renderedURL(prefix)
+		if rendered == url && (ok || url == "") {
+			continue
+		}
+		decl := nsBinding{prefix, url}
+		decls = append(decls, decl)
+		c.rendered = append(c.rendered, decl)
+	}
+	sort.Slice(decls, func(i, j int) bool { return decls[i].prefix < decls[j].prefix })
+	sort.Slice(attrs, func(i, j int) bool {
+		a, b := attrs[i], attrs[j]
+		if a.url != b.url {
+			return a.url < b.url
+		}
+		return a.Name.Local < b.Name.Local
+	})
+
+	p.WriteByte('<')
+	p.writeCanonicalName(start.Name)
+	for _, decl := range decls {
+		p.WriteString(" xmlns")
+		if decl.prefix != "" {
+			p.WriteByte(':')
+			p.WriteString(decl.prefix)
+		}
+		p.WriteString(`="`)
+		p.escapeCanonical([]byte(decl.url), true)
+		p.WriteByte('"')
+	}
+	for _, attr := range attrs {
+		p.WriteByte(' ')
+		p.writeCanonicalName(attr.Name)
+		p.WriteString(`="`)
+		p.escapeCanonical([]byte(attr.Value), true)
+		p.WriteByte('"')
+	}
+	p.WriteByte('>')
+	return nil
+}
+
+// writeCanonicalName writes the qualified name n, which carries a
+// prefix rather than a name space URL.
+func (p *printer) writeCanonicalName(n Name) {
+	if n.Space != "" {
+		p.WriteString(n.Space)
+		p.WriteByte(':')
+	}
+	p.WriteString(n.Local)
+}
+
+// canonicalAttrPrefix picks a prefix for the attribute name space url,
+// which has none in scope, the way createAttrPrefix does.
+func (p *printer) canonicalAttrPrefix(url string) string {
+	prefix := attrPrefixFor(url)
+	if _, taken := p.boundURL(prefix); taken {
+		for p.seq++; ; p.seq++ {
+			id := prefix + "_" + strconv.Itoa(p.seq)
+			if _, taken := p.boundURL(id); !taken {
+				return id
+			}
+		}
+	}
+	return prefix
+}
+
+// escapeCanonical writes s escaped as canonical XML requires for
+// character data, or for an attribute value if attr is set.
+func (p *printer) escapeCanonical(s []byte, attr bool) {
+	var esc []byte
+	last := 0
+	for i := 0; i < len(s); {
+		r, width := utf8.DecodeRune(s[i:])
+		i += width
+		switch r {
+		case '&':
+			esc = escAmp
+		case '<':
+			esc = escLT
+		case '>':
+			if attr {
+				continue
+			}
+			esc = escGT
+		case '"':
+			if !attr {
+				continue
+			}
+			esc = escQuotC14N
+		case '\t', '\n':
+			if !attr {
+				continue
+			}
+			if r == '\t' {
+				esc = escTab
+			} else {
+				esc = escNL
+			}
+		case '\r':
+			esc = escCR
+		default:
+			if !isInCharacterRange(r) || (r == 0xFFFD && width == 1) {
+				esc = escFFFD
+				break
+			}
+			continue
+		}
+		p.Write(s[last : i-width])
+		p.Write(esc)
+		last = i
+	}
+	p.Write(s[last:])
+}
+
+var escQuotC14N = []byte("&quot;")
@@ -0,0 +1,200 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const c14nInput = `<?xml version="1.0"?>
+<!DOCTYPE doc>
+<!-- before -->
+<doc xmlns:a="http://a" xmlns:unused="http://u">
+   <a:e1   attr2="2" attr1='1' a:x="&lt;&quot;&#9;>" />
+   <e2 xmlns="http://d"><e3 xmlns="">t&amp;&#13;&gt;<![CDATA[<c>]]></e3></e2>
+</doc>
+<!-- after -->
+<?pi data?>
+`
+
+const c14nBody = `<doc>
+   <a:e1 xmlns:a="http://a" attr1="1" attr2="2" a:x="&lt;&quot;&#x9;>"></a:e1>
+   <e2 xmlns="http://d"><e3 xmlns="">t&amp;&#xD;&gt;&lt;c&gt;</e3></e2>
+</doc>`
+
+func TestCanonicalize(t *testing.T) {
+	for _, tt := range []struct {
+		withComments bool
+		want         string
+	}{
+		{false, c14nBody + "\n<?pi data?>"},
+		{true, "<!-- before -->\n" + c14nBody + "\n<!-- after -->\n<?pi data?>"},
+	} {
+		var buf bytes.Buffer
+		if err := Canonicalize(&buf, NewDecoder(strings.NewReader(c14nInput)), tt.withComments, ""); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("Canonicalize(withComments=%v):\ngot  %s\nwant %s", tt.withComments, got, tt.want)
+		}
+	}
+}
+
+// c14nSpecInput is the example of section 2.2 of the Exclusive XML
+// Canonicalization specification.
+const c14nSpecInput = `<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org">
+  <n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"/>
+  </n1:elem2>
+</n0:local>`
+
+func TestCanonicalizePrefixList(t *testing.T) {
+	// The spec example with a default name space declared on the root.
+	withDefault := strings.Replace(c14nSpecInput, `<n0:local `, `<n0:local xmlns="urn:d" `, 1)
+	for _, tt := range []struct {
+		in, prefixList string
+		want           string
+	}{
+		{c14nSpecInput, "", `<n0:local xmlns:n0="foo:bar">
+  <n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>
+</n0:local>`},
+		{c14nSpecInput, "n3", `<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org">
+  <n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff></n3:stuff>
+  </n1:elem2>
+</n0:local>`},
+		// Prefixes not in scope are not declared, nor are those
+		// already declared the same way by an output ancestor.
+		{c14nSpecInput, " #default\tn9  n1 ", `<n0:local xmlns:n0="foo:bar">
+  <n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>
+</n0:local>`},
+		{withDefault, "", `<n0:local xmlns:n0="foo:bar">
+  <n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>
+</n0:local>`},
+		{withDefault, "#default", `<n0:local xmlns="urn:d" xmlns:n0="foo:bar">
+  <n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+    <n3:stuff xmlns:n3="ftp://example.org"></n3:stuff>
+  </n1:elem2>
+</n0:local>`},
+	} {
+		var buf bytes.Buffer
+		if err := Canonicalize(&buf, NewDecoder(strings.NewReader(tt.in)), false, tt.prefixList); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("Canonicalize(prefixList=%q):\ngot  %s\nwant %s", tt.prefixList, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalizeKeepsPrefixes(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		// Two prefixes bound to the same name space.
+		{
+			`<p:a xmlns:p="urn:x" xmlns:q="urn:x"><q:b q:at="1"/></p:a>`,
+			`<p:a xmlns:p="urn:x"><q:b xmlns:q="urn:x" q:at="1"></q:b></p:a>`,
+		},
+		// A prefix bound to the default name space.
+		{
+			`<a xmlns="urn:x" xmlns:p="urn:x"><p:b/></a>`,
+			`<a xmlns="urn:x"><p:b xmlns:p="urn:x"></p:b></a>`,
+		},
+	} {
+		var buf bytes.Buffer
+		if err := Canonicalize(&buf, NewDecoder(strings.NewReader(tt.in)), false, ""); err != nil {
+			t.Fatalf("Canonicalize(%s): %v", tt.in, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("Canonicalize(%s):\ngot  %s\nwant %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalizeErrors(t *testing.T) {
+	for _, in := range []string{
+		`<p:a/>`,
+		`<a q:at="1"/>`,
+		`<a xmlns:p="urn:x"><p:b></b></a>`,
+	} {
+		var buf bytes.Buffer
+		if err := Canonicalize(&buf, NewDecoder(strings.NewReader(in)), false, ""); err == nil {
+			t.Errorf("Canonicalize(%s) = nil, want error", in)
+		}
+	}
+}
+
+func TestCanonicalizeIdempotent(t *testing.T) {
+	var b1, b2 bytes.Buffer
+	if err := Canonicalize(&b1, NewDecoder(strings.NewReader(c14nInput)), true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := Canonicalize(&b2, NewDecoder(bytes.NewReader(b1.Bytes())), true, ""); err != nil {
+		t.Fatal(err)
+	}
+	if b1.String() != b2.String() {
+		t.Errorf("canonical form changed when canonicalized again:\n%s\n%s", &b1, &b2)
+	}
+}
+
+const soapNS = "http://schemas.xmlsoap.org/soap/envelope/"
+
+type c14nEnvelope struct {
+	XMLName Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+	Body    struct {
+		Value string `xml:"urn:x value"`
+		ID    string `xml:"urn:x id,attr"`
+	} `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
+}
+
+func TestEncoderCanonical(t *testing.T) {
+	var v c14nEnvelope
+	v.Body.Value = "<v>"
+	v.Body.ID = "1"
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.Indent("", "  ")
+	enc.Canonical(false, "")
+	if err := enc.Encode(&v); err != nil {
+		t.Fatal(err)
+	}
+	want := `<Envelope xmlns="` + soapNS + `"><Body xmlns:_="urn:x" _:id="1"><value xmlns="urn:x">&lt;v&gt;</value></Body></Envelope>`
+	if got := buf.String(); got != want {
+		t.Errorf("Encode:\ngot  %s\nwant %s", got, want)
+	}
+
+	buf.Reset()
+	enc = NewEncoder(&buf)
+	enc.Canonical(false, "")
+	toks := []Token{
+		StartElement{Name{soapNS, "Envelope"}, []Attr{{Name{"xmlns", "soap"}, soapNS}, {Name{"xmlns", "unused"}, "urn:u"}}},
+		StartElement{Name{soapNS, "Body"}, []Attr{{Name{"urn:x", "b"}, "2"}, {Name{"", "a"}, "1"}}},
+		CharData("\r\n"),
+		EndElement{Name{soapNS, "Body"}},
+		EndElement{Name{soapNS, "Envelope"}},
+	}
+	for _, tok := range toks {
+		if err := enc.EncodeToken(tok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want = `<soap:Envelope xmlns:soap="` + soapNS + `"><soap:Body xmlns:_="urn:x" a="1" _:b="2">&#xD;` + "\n" + `</soap:Body></soap:Envelope>`
+	if got := buf.String(); got != want {
+		t.Errorf("EncodeToken:\ngot  %s\nwant %s", got, want)
+	}
+}
@@ -152,6 +152,28 @@ func (enc *Encoder) Indent(prefix, indent string) {
 	enc.p.indent = indent
 }
 
+// UseDeclaredPrefixes sets whether the encoder writes names using the
+// name space prefixes declared in the encoded XML. By default, an
+// element in a name space declares it as its default name space and an
+// attribute in a name space is given an invented prefix, even if an
+// ancestor already declares the name space, and declarations are
+// written like any other attribute.
+//
+// When enabled, an attribute named "xmlns:prefix", for example from a
+// field with tag "xmlns:prefix,attr" or a StartElement attribute with
+// Name.Space "xmlns" and Name.Local "prefix", declares prefix for the
+// name space given by its value, and an attribute named "xmlns"
+// declares the default name space, as does an element in a name space
+// that is not declared yet. The declarations apply to the element and
+// its descendants: their element and attribute names in a declared
+// name space are written with the declared prefix, or with no prefix
+// for the default name space, instead of redeclaring the name space or
+// inventing a prefix for it. Attributes never use the default name
+// space.
+func (enc *Encoder) UseDeclaredPrefixes(on bool) {
+	enc.p.declPrefixes = on
+}
+
 // Encode writes the XML encoding of v to the stream.
 //
 // See the documentation for Marshal for details about the conversion
@@ -159,6 +181,9 @@ func (enc *Encoder) Indent(prefix, indent string) {
 //
 // Encode calls Flush before returning.
 func (enc *Encoder) Encode(v interface{}) error {
+	if enc.p.c14n != nil {
+		return enc.encodeCanonical(v, nil)
+	}
 	err := enc.p.marshalValue(reflect.ValueOf(v), nil, nil)
 	if err != nil {
 		return err
@@ -174,6 +199,9 @@ func (enc *Encoder) Encode(v interface{}) error {
 //
 // EncodeElement calls Flush before returning.
 func (enc *Encoder) EncodeElement(v interface{}, start StartElement) error {
+	if enc.p.c14n != nil {
+		return enc.encodeCanonical(v, &start)
+	}
 	err := enc.p.marshalValue(reflect.ValueOf(v), nil, &start)
 	if err != nil {
 		return err
@@ -202,6 +230,9 @@ var (
 func (enc *Encoder) EncodeToken(t Token) error {
 
 	p := &enc.p
+	if p.c14n != nil {
+		return p.writeCanonical(t, false)
+	}
 	switch t := t.(type) {
 	case StartElement:
 		if err := p.writeStart(&t); err != nil {
@@ -313,6 +344,101 @@ type printer struct {
 	attrPrefix map[string]string // map name space -> prefix
 	prefixes   []string
 	tags       []Name
+	bound      []nsBinding // name space declarations in scope
+	boundMarks []int       // len(bound) at each open element
+	c14n       *c14nState  // canonical output state, if any
+
+	declPrefixes bool // honor name space declarations; see UseDeclaredPrefixes
+}
+
+// A nsBinding records a name space declaration made by an xmlns
+// attribute of an open element, binding prefix to url. The empty
+// prefix is the default name space.
+type nsBinding struct {
+	prefix, url string
+}
+
+// bindDecls records the name space declarations among attrs as in
+// scope until the matching call to unbindDecls.
+func (p *printer) bindDecls(attrs []Attr) {
+	p.boundMarks = append(p.boundMarks, len(p.bound))
+	for _, attr := range attrs {
+		if prefix, ok := declaredPrefix(attr.Name); ok && (prefix == "" || attr.Value != "") {
+			p.bound = append(p.bound, nsBinding{prefix, attr.Value})
+		}
+	}
+}
+
+func (p *printer) unbindDecls() {
+	p.bound = p.bound[:p.boundMarks[len(p.boundMarks)-1]]
+	p.boundMarks = p.boundMarks[:len(p.boundMarks)-1]
+}
+
+// declaredPrefix reports whether name is the name of an attribute
+// declaring a name space, and which prefix it declares.
+func declaredPrefix(name Name) (string, bool) {
+	switch {
+	case name.Space == xmlnsPrefix:
+		return name.Local, true
+	case name.Space == "" && name.Local == xmlnsPrefix:
+		return "", true
+	case name.Space == "" && strings.HasPrefix(name.Local, xmlnsPrefix+":"):
+		return name.Local[len(xmlnsPrefix)+1:], true
+	}
+	return "", false
+}
+
+// declaresDefault reports whether attrs declare the default name space.
+func declaresDefault(attrs []Attr) bool {
+	for _, attr := range attrs {
+		if prefix, ok := declaredPrefix(attr.Name); ok && prefix == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// boundURL returns the name space that prefix is bound to in the
+// current scope.
+func (p *printer) boundURL(prefix string) (string, bool) {
+	for i := len(p.bound) - 1; i >= 0; i-- {
+		if p.bound[i].prefix == prefix {
+			return p.bound[i].url, true
+		}
+	}
+	return "", false
+}
+
+// boundPrefix returns a prefix bound to the name space url in the
+// current scope, the innermost one if there are several. Elements
+// prefer the default name space; attributes never use it.
+func (p *printer) boundPrefix(url string, elem bool) (string, bool) {
+	if elem {
+		if u, ok := p.boundURL(""); ok && u == url {
+			return "", true
+		}
+	}
+	for i := len(p.bound) - 1; i >= 0; i-- {
+		b := p.bound[i]
+		if b.prefix == "" || b.url != url {
+			continue
+		}
+		if u, _ := p.boundURL(b.prefix); u == url {
+			return b.prefix, true
+		}
+	}
+	return "", false
+}
+
+// elementPrefix returns the prefix to write before the local part of
+// the element name n, and whether n.Space is bound by a declaration
+// in scope. If it is not, the element declares n.Space as its default
+// name space.
+func (p *printer) elementPrefix(n Name) (string, bool) {
+	if n.Space == "" {
+		return "", true
+	}
+	return p.boundPrefix(n.Space, true)
 }
 
 // createAttrPrefix finds the name space prefix attribute to use for the given name space,
@@ -336,26 +462,11 @@ func (p *printer) createAttrPrefix(url string) string {
 		p.attrNS = make(map[string]string)
 	}
 
-	// Pick a name. We try to use the final element of the path
-	// but fall back to _.
-	prefix := strings.TrimRight(url, "/")
-	if i := strings.LastIndex(prefix, "/"); i >= 0 {
-		prefix = prefix[i+1:]
-	}
-	if prefix == "" || !isName([]byte(prefix)) || strings.Contains(prefix, ":") {
-		prefix = "_"
-	}
-	// xmlanything is reserved and any variant of it regardless of
-	// case should be matched, so:
-	//    (('X'|'x') ('M'|'m') ('L'|'l'))
-	// See Section 2.3 of https://www.w3.org/TR/REC-xml/
-	if len(prefix) >= 3 && strings.EqualFold(prefix[:3], "xml") {
-		prefix = "_" + prefix
-	}
-	if p.attrNS[prefix] != "" {
+	prefix := attrPrefixFor(url)
+	if p.prefixTaken(prefix) {
 		// Name is taken. Find a better one.
 		for p.seq++; ; p.seq++ {
-			if id := prefix + "_" + strconv.Itoa(p.seq); p.attrNS[id] == "" {
+			if id := prefix + "_" + strconv.Itoa(p.seq); !p.prefixTaken(id) {
 				prefix = id
 				break
 			}
@@ -376,6 +487,36 @@ func (p *printer) createAttrPrefix(url string) string {
 	return prefix
 }
 
+// attrPrefixFor picks a name for a prefix of the name space url.
+// We try to use the final element of the path but fall back to _.
+func attrPrefixFor(url string) string {
+	prefix := strings.TrimRight(url, "/")
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		prefix = prefix[i+1:]
+	}
+	if prefix == "" || !isName([]byte(prefix)) || strings.Contains(prefix, ":") {
+		prefix = "_"
+	}
+	// xmlanything is reserved and any variant of it regardless of
+	// case should be matched, so:
+	//    (('X'|'x') ('M'|'m') ('L'|'l'))
+	// See Section 2.3 of https://www.w3.org/TR/REC-xml/
+	if len(prefix) >= 3 && strings.EqualFold(prefix[:3], "xml") {
+		prefix = "_" + prefix
+	}
+	return prefix
+}
+
+// prefixTaken reports whether prefix is already in use for another
+// name space.
+func (p *printer) prefixTaken(prefix string) bool {
+	if p.attrNS[prefix] != "" {
+		return true
+	}
+	_, ok := p.boundURL(prefix)
+	return ok
+}
+
 // deleteAttrPrefix removes an attribute name space prefix.
 func (p *printer) deleteAttrPrefix(prefix string) {
 	delete(p.attrPrefix, p.attrNS[prefix])
@@ -696,15 +837,33 @@ func (p *printer) writeStart(start *StartElement) error {
 
 	p.tags = append(p.tags, start.Name)
 	p.markPrefix()
+	if p.declPrefixes {
+		p.bindDecls(start.Attr)
+	} else {
+		p.bindDecls(nil)
+	}
 
 	p.writeIndent(1)
 	p.WriteByte('<')
+	prefix, bound := p.elementPrefix(start.Name)
+	if prefix != "" {
+		p.WriteString(prefix)
+		p.WriteByte(':')
+	}
 	p.WriteString(start.Name.Local)
 
-	if start.Name.Space != "" {
+	if !bound {
 		p.WriteString(` xmlns="`)
 		p.EscapeString(start.Name.Space)
 		p.WriteByte('"')
+		if p.declPrefixes {
+			p.bound = append(p.bound, nsBinding{"", start.Name.Space})
+		}
+	} else if u, _ := p.boundURL(""); start.Name.Space == "" && u != "" && !declaresDefault(start.Attr) {
+		// Only possible with declared prefixes: leave the default
+		// name space declared by an ancestor.
+		p.WriteString(` xmlns=""`)
+		p.bound = append(p.bound, nsBinding{"", ""})
 	}
 
 	// Attributes
@@ -713,9 +872,21 @@ func (p *printer) writeStart(start *StartElement) error {
 		if name.Local == "" {
 			continue
 		}
+		if p.declPrefixes && name.Space == xmlnsPrefix && attr.Value == "" {
+			// Prefixes cannot be undeclared.
+			continue
+		}
 		p.WriteByte(' ')
-		if name.Space != "" {
-			p.WriteString(p.createAttrPrefix(name.Space))
+		switch {
+		case p.declPrefixes && name.Space == xmlnsPrefix:
+			p.WriteString(xmlnsPrefix)
+			p.WriteByte(':')
+		case name.Space != "":
+			prefix, ok := p.boundPrefix(name.Space, false)
+			if !ok {
+				prefix = p.createAttrPrefix(name.Space)
+			}
+			p.WriteString(prefix)
 			p.WriteByte(':')
 		}
 		p.WriteString(name.Local)
@@ -728,6 +899,27 @@ func (p *printer) writeStart(start *StartElement) error {
 }
 
 func (p *printer) writeEnd(name Name) error {
+	if err := p.popTag(name); err != nil {
+		return err
+	}
+
+	p.writeIndent(-1)
+	p.WriteByte('<')
+	p.WriteByte('/')
+	if prefix, _ := p.elementPrefix(name); prefix != "" {
+		p.WriteString(prefix)
+		p.WriteByte(':')
+	}
+	p.WriteString(name.Local)
+	p.WriteByte('>')
+	p.unbindDecls()
+	p.popPrefix()
+	return nil
+}
+
+// popTag checks that name matches the innermost open element and
+// removes it from p.tags.
+func (p *printer) popTag(name Name) error {
 	if name.Local == "" {
 		return fmt.Errorf("xml: end tag with no name")
 	}
@@ -741,13 +933,6 @@ func (p *printer) writeEnd(name Name) error {
 		return fmt.Errorf("xml: end tag </%s> in namespace %s does not match start tag <%s> in namespace %s", name.Local, name.Space, top.Local, top.Space)
 	}
 	p.tags = p.tags[:len(p.tags)-1]
-
-	p.writeIndent(-1)
-	p.WriteByte('<')
-	p.WriteByte('/')
-	p.WriteString(name.Local)
-	p.WriteByte('>')
-	p.popPrefix()
 	return nil
 }
 
@@ -2347,6 +2347,219 @@ loop:
 	}
 }
 
+// declaredPrefixTokenTests are run with UseDeclaredPrefixes(true).
+var declaredPrefixTokenTests = []struct {
+	desc string
+	toks []Token
+	want string
+}{{
+	desc: "start element with explicit namespace",
+	toks: []Token{
+		StartElement{Name{"space", "local"}, []Attr{
+			{Name{"xmlns", "x"}, "space"},
+			{Name{"space", "foo"}, "value"},
+		}},
+	},
+	want: `<x:local xmlns:x="space" x:foo="value">`,
+}, {
+	desc: "start element with explicit namespace and colliding prefix",
+	toks: []Token{
+		StartElement{Name{"space", "local"}, []Attr{
+			{Name{"xmlns", "x"}, "space"},
+			{Name{"space", "foo"}, "value"},
+			{Name{"x", "bar"}, "other"},
+		}},
+	},
+	want: `<x:local xmlns:x="space" x:foo="value" xmlns:x_1="x" x_1:bar="other">`,
+}, {
+	desc: "start element using previously defined namespace",
+	toks: []Token{
+		StartElement{Name{"", "local"}, []Attr{
+			{Name{"xmlns", "x"}, "space"},
+		}},
+		StartElement{Name{"space", "foo"}, []Attr{
+			{Name{"space", "x"}, "y"},
+		}},
+	},
+	want: `<local xmlns:x="space"><x:foo x:x="y">`,
+}, {
+	desc: "nested name space with same prefix",
+	toks: []Token{
+		StartElement{Name{"", "foo"}, []Attr{
+			{Name{"xmlns", "x"}, "space1"},
+		}},
+		StartElement{Name{"", "foo"}, []Attr{
+			{Name{"xmlns", "x"}, "space2"},
+		}},
+		StartElement{Name{"", "foo"}, []Attr{
+			{Name{"space1", "a"}, "space1 value"},
+			{Name{"space2", "b"}, "space2 value"},
+		}},
+		EndElement{Name{"", "foo"}},
+		EndElement{Name{"", "foo"}},
+		StartElement{Name{"", "foo"}, []Attr{
+			{Name{"space1", "a"}, "space1 value"},
+			{Name{"space2", "b"}, "space2 value"},
+		}},
+	},
+	want: `<foo xmlns:x="space1"><foo xmlns:x="space2"><foo xmlns:space1="space1" space1:a="space1 value" x:b="space2 value"></foo></foo><foo x:a="space1 value" xmlns:space2="space2" space2:b="space2 value">`,
+}, {
+	desc: "start element defining several prefixes for the same name space",
+	toks: []Token{
+		StartElement{Name{"space", "foo"}, []Attr{
+			{Name{"xmlns", "a"}, "space"},
+			{Name{"xmlns", "b"}, "space"},
+			{Name{"space", "x"}, "value"},
+		}},
+	},
+	want: `<b:foo xmlns:a="space" xmlns:b="space" b:x="value">`,
+}, {
+	desc: "nested element redefines name space",
+	toks: []Token{
+		StartElement{Name{"", "foo"}, []Attr{
+			{Name{"xmlns", "x"}, "space"},
+		}},
+		StartElement{Name{"space", "foo"}, []Attr{
+			{Name{"xmlns", "y"}, "space"},
+			{Name{"space", "a"}, "value"},
+		}},
+	},
+	want: `<foo xmlns:x="space"><y:foo xmlns:y="space" y:a="value">`,
+}, {
+	desc: "nested element creates alias for default name space",
+	toks: []Token{
+		StartElement{Name{"space", "foo"}, []Attr{
+			{Name{"", "xmlns"}, "space"},
+		}},
+		StartElement{Name{"space", "foo"}, []Attr{
+			{Name{"xmlns", "y"}, "space"},
+			{Name{"space", "a"}, "value"},
+		}},
+	},
+	want: `<foo xmlns="space"><foo xmlns:y="space" y:a="value">`,
+}, {
+	desc: "nested element defines default name space with existing prefix",
+	toks: []Token{
+		StartElement{Name{"", "foo"}, []Attr{
+			{Name{"xmlns", "x"}, "space"},
+		}},
+		StartElement{Name{"space", "foo"}, []Attr{
+			{Name{"", "xmlns"}, "space"},
+			{Name{"space", "a"}, "value"},
+		}},
+	},
+	want: `<foo xmlns:x="space"><foo xmlns="space" x:a="value">`,
+}, {
+	desc: "nested element uses empty attribute name space when default ns defined",
+	toks: []Token{
+		StartElement{Name{"space", "foo"}, []Attr{
+			{Name{"", "xmlns"}, "space"},
+		}},
+		StartElement{Name{"space", "foo"}, []Attr{
+			{Name{"", "attr"}, "value"},
+		}},
+	},
+	want: `<foo xmlns="space"><foo attr="value">`,
+}, {
+	desc: "empty name space declaration is ignored",
+	toks: []Token{
+		StartElement{Name{"", "foo"}, []Attr{
+			{Name{"xmlns", "foo"}, ""},
+		}},
+	},
+	want: `<foo>`,
+}, {
+	desc: "nested element resets default namespace to empty",
+	toks: []Token{
+		StartElement{Name{"space", "foo"}, []Attr{
+			{Name{"", "xmlns"}, "space"},
+		}},
+		StartElement{Name{"", "foo"}, []Attr{
+			{Name{"", "xmlns"}, ""},
+			{Name{"", "x"}, "value"},
+			{Name{"space", "x"}, "value"},
+		}},
+	},
+	want: `<foo xmlns="space"><foo xmlns="" x="value" xmlns:space="space" space:x="value">`,
+}, {
+	desc: "nested element requires empty default name space",
+	toks: []Token{
+		StartElement{Name{"space", "foo"}, []Attr{
+			{Name{"", "xmlns"}, "space"},
+		}},
+		StartElement{Name{"", "foo"}, nil},
+	},
+	want: `<foo xmlns="space"><foo xmlns="">`,
+}, {
+	desc: "default name space should not be used by attributes",
+	toks: []Token{
+		StartElement{Name{"space", "foo"}, []Attr{
+			{Name{"", "xmlns"}, "space"},
+			{Name{"xmlns", "bar"}, "space"},
+			{Name{"space", "baz"}, "foo"},
+		}},
+		StartElement{Name{"space", "baz"}, nil},
+		EndElement{Name{"space", "baz"}},
+		EndElement{Name{"space", "foo"}},
+	},
+	want: `<foo xmlns="space" xmlns:bar="space" bar:baz="foo"><baz></baz></foo>`,
+}, {
+	desc: "default name space not used by attributes, not explicitly defined",
+	toks: []Token{
+		StartElement{Name{"space", "foo"}, []Attr{
+			{Name{"", "xmlns"}, "space"},
+			{Name{"space", "baz"}, "foo"},
+		}},
+		StartElement{Name{"space", "baz"}, nil},
+		EndElement{Name{"space", "baz"}},
+		EndElement{Name{"space", "foo"}},
+	},
+	want: `<foo xmlns="space" xmlns:space="space" space:baz="foo"><baz></baz></foo>`,
+}, {
+	desc: "impossible xmlns declaration",
+	toks: []Token{
+		StartElement{Name{"", "foo"}, []Attr{
+			{Name{"", "xmlns"}, "space"},
+		}},
+		StartElement{Name{"space", "bar"}, []Attr{
+			{Name{"space", "attr"}, "value"},
+		}},
+	},
+	want: `<foo xmlns="space"><bar xmlns:space="space" space:attr="value">`,
+}, {
+	desc: "nested element in another name space is followed by one in the outer",
+	toks: []Token{
+		StartElement{Name{"B", "root"}, nil},
+		StartElement{Name{"A", "mid"}, nil},
+		StartElement{Name{"B", "leaf"}, nil},
+		EndElement{Name{"B", "leaf"}},
+		EndElement{Name{"A", "mid"}},
+		StartElement{Name{"B", "next"}, nil},
+		EndElement{Name{"B", "next"}},
+		EndElement{Name{"B", "root"}},
+	},
+	want: `<root xmlns="B"><mid xmlns="A"><leaf xmlns="B"></leaf></mid><next></next></root>`,
+}}
+
+func TestEncodeTokenDeclaredPrefixes(t *testing.T) {
+	for i, tt := range declaredPrefixTokenTests {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.UseDeclaredPrefixes(true)
+		for _, tok := range tt.toks {
+			if err := enc.EncodeToken(tok); err != nil {
+				t.Fatalf("#%d %s: %v", i, tt.desc, err)
+			}
+		}
+		if err := enc.Flush(); err != nil {
+			t.Fatalf("#%d %s: %v", i, tt.desc, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("#%d %s:\ngot  %v\nwant %v", i, tt.desc, got, tt.want)
+		}
+	}
+}
+
 func TestProcInstEncodeToken(t *testing.T) {
 	var buf bytes.Buffer
 	enc := NewEncoder(&buf)
@@ -2490,3 +2703,71 @@ func TestInvalidXMLName(t *testing.T) {
 		t.Errorf("error %q does not contain %q", err, want)
 	}
 }
+
+func TestMarshalNamespacePrefix(t *testing.T) {
+	type body struct {
+		Value string `xml:"http://schemas.xmlsoap.org/soap/envelope/ value"`
+	}
+	type envelope struct {
+		XMLName Name   `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+		Soap    string `xml:"xmlns:soap,attr"`
+		Body    body   `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
+	}
+	v := envelope{Soap: "http://schemas.xmlsoap.org/soap/envelope/", Body: body{"v"}}
+	var b bytes.Buffer
+	enc := NewEncoder(&b)
+	enc.UseDeclaredPrefixes(true)
+	if err := enc.Encode(&v); err != nil {
+		t.Fatal(err)
+	}
+	const want = `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><soap:value>v</soap:value></soap:Body></soap:Envelope>`
+	if got := b.String(); got != want {
+		t.Errorf("Encode:\ngot  %s\nwant %s", got, want)
+	}
+
+	var w envelope
+	if err := Unmarshal(b.Bytes(), &w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Body.Value != "v" {
+		t.Errorf("Unmarshal = %+v", w)
+	}
+}
+
+func TestMarshalDeclaredPrefixesDefaultSpace(t *testing.T) {
+	type leaf struct {
+		XMLName Name `xml:"B leaf"`
+	}
+	type mid struct {
+		XMLName Name `xml:"A mid"`
+		Leaf    leaf
+	}
+	type root struct {
+		XMLName Name `xml:"B root"`
+		Mid     mid
+	}
+	var b bytes.Buffer
+	enc := NewEncoder(&b)
+	enc.UseDeclaredPrefixes(true)
+	if err := enc.Encode(root{}); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDecoder(&b)
+	var got []Name
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if se, ok := tok.(StartElement); ok {
+			got = append(got, se.Name)
+		}
+	}
+	want := []Name{{"B", "root"}, {"A", "mid"}, {"B", "leaf"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded element names = %v, want %v", got, want)
+	}
+}
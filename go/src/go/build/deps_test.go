@@ -101,6 +101,9 @@ var depsRules = `
 	< sort
 	< container/heap;
 
+	RUNTIME
+	< internal/intern;
+
 	RUNTIME
 	< io;
 
@@ -241,6 +244,9 @@ var depsRules = `
 	FMT, encoding/binary, math/rand
 	< math/big;
 
+	encoding, math/big
+	< encoding/cbor;
+
 	# compression
 	FMT, encoding/binary, hash/adler32, hash/crc32
 	< compress/bzip2, compress/flate, compress/lzw
@@ -344,6 +350,9 @@ var depsRules = `
 	sync
 	< internal/singleflight;
 
+	internal/intern, math, strconv
+	< net/netip;
+
 	os
 	< golang.org/x/net/dns/dnsmessage,
 	  golang.org/x/net/lif,
@@ -361,6 +370,7 @@ var depsRules = `
 	internal/poll,
 	internal/singleflight,
 	internal/race,
+	net/netip,
 	os
 	< net;
 
@@ -383,8 +393,8 @@ var depsRules = `
 	FMT, log, net
 	< log/syslog;
 
-	NET, log
-	< net/mail;
+	net, os/exec
+	< net/activation;
 
 	# CRYPTO is core crypto algorithms - no cgo, fmt, net.
 	# Unfortunately, stuck with reflect via encoding/binary.
@@ -434,6 +444,9 @@ var depsRules = `
 	NET, crypto/rand, mime/quotedprintable
 	< mime/multipart;
 
+	log, mime/multipart
+	< net/mail;
+
 	crypto/tls
 	< net/smtp;
 
@@ -479,6 +492,12 @@ var depsRules = `
 	< net/http/cgi
 	< net/http/fcgi;
 
+	net/http
+	< net/http/websocket;
+
+	net/http
+	< net/securedns;
+
 	# Profiling
 	FMT, compress/gzip, encoding/binary, text/tabwriter
 	< runtime/pprof;
@@ -492,7 +511,7 @@ var depsRules = `
 	# RPC
 	encoding/gob, encoding/json, go/token, html/template, net/http
 	< net/rpc
-	< net/rpc/jsonrpc;
+	< net/rpc/jsonrpc, net/rpc/jsonrpc2;
 
 	# System Information
 	internal/cpu, sync
@@ -59,6 +59,16 @@ type Flags struct {
 	PreemptibleLoops  bool
 	StaticLockRanking bool
 
+	// IOUring makes internal/poll perform regular file reads and
+	// writes, and socket reads, writes, accepts and connects, through
+	// io_uring on Linux. File I/O no longer blocks an OS thread, and
+	// operations started concurrently are submitted together with a
+	// single system call. Completions are delivered through the
+	// runtime network poller, which still waits for socket readiness
+	// and implements deadlines. Kernels without io_uring fall back
+	// to plain system calls.
+	IOUring bool
+
 	// Regabi is split into several sub-experiments that can be
 	// enabled individually. Not all combinations work.
 	// The "regabi" GOEXPERIMENT is an alias for all "working"
@@ -20,3 +20,11 @@ func GetPipeFds(p *SplicePipe) (int, int) {
 }
 
 type SplicePipe = splicePipe
+
+func (fd *FD) RingFile() bool {
+	return fd.ringFile
+}
+
+func (fd *FD) RingSocket() bool {
+	return fd.ringSocket
+}
@@ -0,0 +1,631 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build goexperiment.iouring
+// +build goexperiment.iouring
+
+package poll
+
+import (
+	"internal/syscall/unix"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+// ringEntries is the number of submission queue entries requested
+// for the ring.
+const ringEntries = 256
+
+// linkedTimeout is set in the user data of the timeout linked to an
+// operation that must not wait, whose own user data is the rest.
+const linkedTimeout = 1 << 63
+
+// notSubmitted is the result delivered for an operation that the
+// kernel did not accept, so that the caller falls back to a system
+// call. It is not a valid completion result, which is either a count
+// or a negated errno.
+const notSubmitted = -1 << 31
+
+// A uring is the io_uring instance shared by all regular files and
+// sockets.
+//
+// Goroutines queue their operations under mu, which only guards the
+// submission queue in memory, and wake the submitter goroutine. The
+// submitter hands everything queued so far to the kernel with a single
+// io_uring_enter call, so operations started concurrently by many
+// goroutines cost one system call between them. Completions are
+// reaped by the submitter right after io_uring_enter, which catches
+// the socket operations the kernel completes inline, and otherwise by
+// a goroutine that waits on an eventfd registered both with the ring
+// and with the runtime poller, so that no thread blocks while an
+// operation is in flight.
+// uring是所有普通文件和套接字共享的io_uring实例。
+type uring struct {
+	fd int
+
+	mu      sync.Mutex
+	sqTail  *uint32
+	sqMask  uint32
+	sqArray []uint32
+	sqes    []unix.IoUringSqe
+	seq     uint64
+	ops     map[uint64]*ringOp
+	queued  []uint64 // operations not yet consumed by the kernel, in order
+
+	// kick wakes the submitter when operations are queued.
+	kick chan struct{}
+
+	cqMu   sync.Mutex
+	cqHead *uint32
+	cqTail *uint32
+	cqMask uint32
+	cqes   []unix.IoUringCqe
+
+	// slots bounds the number of operations queued or in flight so
+	// that their entries, at most two each, fit in the submission
+	// queue, which is at most as large as the completion queue, and
+	// neither ever overflows.
+	slots chan struct{}
+
+	// efd is the eventfd signaled when completions are posted.
+	efd *FD
+
+	sqRing, cqRing, sqeMem []byte
+}
+
+// A ringOp is an operation submitted to the ring.
+type ringOp struct {
+	// buf is the memory the kernel reads or writes. Holding it here
+	// keeps it alive, and on the heap, until the operation completes.
+	buf []byte
+
+	// sa and salen hold the socket address of an accept or connect.
+	sa    syscall.RawSockaddrAny
+	salen uint32
+
+	// ts is the zero struct __kernel_timespec of a linked timeout.
+	ts [2]int64
+
+	res  int32 // result of the operation
+	cqes int   // completions still to be reaped
+	done chan int32
+}
+
+var ringOpPool = sync.Pool{
+	New: func() interface{} {
+		return &ringOp{done: make(chan int32, 1)}
+	},
+}
+
+var (
+	ringOnce sync.Once
+	theRing  *uring
+)
+
+// getRing returns the shared ring, setting it up on first use. It
+// returns nil if the kernel does not provide a suitable io_uring.
+func getRing() *uring {
+	ringOnce.Do(func() {
+		if r, err := newRing(ringEntries); err == nil {
+			theRing = r
+			go r.submitLoop()
+			go r.reapLoop()
+		}
+	})
+	return theRing
+}
+
+func newRing(entries uint32) (*uring, error) {
+	var p unix.IoUringParams
+	fd, err := unix.IoUringSetup(entries, &p)
+	if err != nil {
+		return nil, err
+	}
+	r := &uring{fd: fd}
+	if err := r.init(&p); err != nil {
+		r.destroy()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *uring) init(p *unix.IoUringParams) error {
+	// Reads and writes at the file position need IORING_FEAT_RW_CUR_POS
+	// (Linux 5.6), which also implies IORING_OP_READ, IORING_OP_WRITE,
+	// IORING_OP_SEND, IORING_OP_RECV, IORING_OP_ACCEPT and
+	// IORING_OP_CONNECT.
+	const features = unix.IORING_FEAT_NODROP | unix.IORING_FEAT_RW_CUR_POS
+	if p.Features&features != features {
+		return syscall.ENOSYS
+	}
+
+	const prot, flags = syscall.PROT_READ | syscall.PROT_WRITE, syscall.MAP_SHARED
+	var err error
+	r.sqRing, err = syscall.Mmap(r.fd, unix.IORING_OFF_SQ_RING, int(p.SqOff.Array+p.SqEntries*4), prot, flags)
+	if err != nil {
+		return err
+	}
+	r.cqRing, err = syscall.Mmap(r.fd, unix.IORING_OFF_CQ_RING, int(p.CqOff.Cqes)+int(p.CqEntries)*int(unsafe.Sizeof(unix.IoUringCqe{})), prot, flags)
+	if err != nil {
+		return err
+	}
+	r.sqeMem, err = syscall.Mmap(r.fd, unix.IORING_OFF_SQES, int(p.SqEntries)*int(unsafe.Sizeof(unix.IoUringSqe{})), prot, flags)
+	if err != nil {
+		return err
+	}
+
+	r.sqTail = (*uint32)(unsafe.Pointer(&r.sqRing[p.SqOff.Tail]))
+	r.sqMask = *(*uint32)(unsafe.Pointer(&r.sqRing[p.SqOff.RingMask]))
+	r.sqArray = (*[1 << 20]uint32)(unsafe.Pointer(&r.sqRing[p.SqOff.Array]))[:p.SqEntries:p.SqEntries]
+	r.sqes = (*[1 << 20]unix.IoUringSqe)(unsafe.Pointer(&r.sqeMem[0]))[:p.SqEntries:p.SqEntries]
+	r.cqHead = (*uint32)(unsafe.Pointer(&r.cqRing[p.CqOff.Head]))
+	r.cqTail = (*uint32)(unsafe.Pointer(&r.cqRing[p.CqOff.Tail]))
+	r.cqMask = *(*uint32)(unsafe.Pointer(&r.cqRing[p.CqOff.RingMask]))
+	r.cqes = (*[1 << 20]unix.IoUringCqe)(unsafe.Pointer(&r.cqRing[p.CqOff.Cqes]))[:p.CqEntries:p.CqEntries]
+
+	efd, err := unix.Eventfd(0, syscall.O_CLOEXEC|syscall.O_NONBLOCK)
+	if err != nil {
+		return err
+	}
+	// Initialized as a file, the eventfd does not itself use the
+	// ring, which is not set up yet.
+	r.efd = &FD{Sysfd: efd, IsStream: true, ZeroReadIsEOF: true}
+	if err := r.efd.Init("file", true); err != nil {
+		return err
+	}
+	efd32 := int32(efd)
+	if err := unix.IoUringRegister(r.fd, unix.IORING_REGISTER_EVENTFD, unsafe.Pointer(&efd32), 1); err != nil {
+		return err
+	}
+
+	r.ops = make(map[uint64]*ringOp)
+	r.kick = make(chan struct{}, 1)
+	r.slots = make(chan struct{}, p.SqEntries/2)
+	return nil
+}
+
+// destroy releases the resources of a ring whose setup failed.
+func (r *uring) destroy() {
+	for _, b := range [][]byte{r.sqRing, r.cqRing, r.sqeMem} {
+		if b != nil {
+			syscall.Munmap(b)
+		}
+	}
+	if r.efd != nil {
+		r.efd.Close()
+	}
+	syscall.Close(r.fd)
+}
+
+// do queues the operation described by sqe, whose memory is held by
+// op, and waits for it to complete. It returns the result of the
+// operation, which is notSubmitted if the kernel did not accept it.
+//
+// If nowait is set, the operation is linked to a timeout that expires
+// at once, so that if it cannot complete immediately it is canceled
+// and fails with ECANCELED instead of waiting. This keeps the runtime
+// poller, which implements deadlines and Close, in charge of waiting
+// for sockets.
+func (r *uring) do(op *ringOp, sqe unix.IoUringSqe, nowait bool) int32 {
+	r.slots <- struct{}{}
+
+	r.mu.Lock()
+	r.seq++
+	id := r.seq
+	sqe.UserData = id
+	op.cqes = 1
+	if nowait {
+		sqe.Flags |= unix.IOSQE_IO_LINK
+		op.cqes = 2
+	}
+	r.queue(sqe)
+	if nowait {
+		r.queue(unix.IoUringSqe{
+			Opcode:   unix.IORING_OP_LINK_TIMEOUT,
+			Fd:       -1,
+			Addr:     uint64(uintptr(unsafe.Pointer(&op.ts))),
+			Len:      1,
+			UserData: id | linkedTimeout,
+		})
+	}
+	r.ops[id] = op
+	r.mu.Unlock()
+
+	select {
+	case r.kick <- struct{}{}:
+	default:
+		// The submitter is already due to run and will see
+		// this operation.
+	}
+	return <-op.done
+}
+
+// queue adds sqe to the submission queue. r.mu must be held.
+func (r *uring) queue(sqe unix.IoUringSqe) {
+	tail := atomic.LoadUint32(r.sqTail)
+	i := tail & r.sqMask
+	r.sqes[i] = sqe
+	r.sqArray[i] = i
+	r.queued = append(r.queued, sqe.UserData)
+	atomic.StoreUint32(r.sqTail, tail+1)
+}
+
+// submitLoop submits queued operations, in batches, until the program
+// exits.
+func (r *uring) submitLoop() {
+	for range r.kick {
+		r.submit()
+		r.reap()
+	}
+}
+
+// submit hands the queued operations to the kernel. Operations it does
+// not accept are withdrawn and completed with notSubmitted.
+func (r *uring) submit() {
+	for {
+		r.mu.Lock()
+		n := len(r.queued)
+		r.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		consumed, err := unix.IoUringEnter(r.fd, uint32(n), 0, 0)
+		if err == syscall.EINTR {
+			continue
+		}
+		r.mu.Lock()
+		if err != nil || consumed <= 0 {
+			// The kernel consumed entries in order, so the ones
+			// it left are the last queued; withdraw them all,
+			// including any queued since, and let their callers
+			// fall back to system calls.
+			atomic.StoreUint32(r.sqTail, atomic.LoadUint32(r.sqTail)-uint32(len(r.queued)))
+			for _, id := range r.queued {
+				if id&linkedTimeout != 0 {
+					// Withdrawn with its operation.
+					continue
+				}
+				op := r.ops[id]
+				delete(r.ops, id)
+				op.done <- notSubmitted
+				<-r.slots
+			}
+			r.queued = r.queued[:0]
+			r.mu.Unlock()
+			return
+		}
+		r.queued = append(r.queued[:0], r.queued[consumed:]...)
+		r.mu.Unlock()
+	}
+}
+
+// reapLoop reaps completions signaled through the eventfd until the
+// program exits.
+func (r *uring) reapLoop() {
+	var buf [8]byte
+	for {
+		// Reading resets the eventfd counter, so completions
+		// posted while the queue is drained signal it again.
+		r.efd.Read(buf[:])
+		r.reap()
+	}
+}
+
+// reap delivers the posted completions to the goroutines waiting for
+// them.
+func (r *uring) reap() {
+	r.cqMu.Lock()
+	defer r.cqMu.Unlock()
+	head := atomic.LoadUint32(r.cqHead)
+	for head != atomic.LoadUint32(r.cqTail) {
+		cqe := &r.cqes[head&r.cqMask]
+		id, res := cqe.UserData, cqe.Res
+		head++
+		atomic.StoreUint32(r.cqHead, head)
+
+		r.mu.Lock()
+		op := r.ops[id&^linkedTimeout]
+		if op == nil {
+			r.mu.Unlock()
+			continue
+		}
+		if id&linkedTimeout == 0 {
+			op.res = res
+		}
+		op.cqes--
+		if op.cqes > 0 {
+			r.mu.Unlock()
+			continue
+		}
+		delete(r.ops, id&^linkedTimeout)
+		r.mu.Unlock()
+		op.done <- op.res
+		<-r.slots
+	}
+}
+
+// getOp returns an operation holding buf.
+func getOp(buf []byte) *ringOp {
+	op := ringOpPool.Get().(*ringOp)
+	op.buf = buf
+	return op
+}
+
+func putOp(op *ringOp) {
+	op.buf = nil
+	ringOpPool.Put(op)
+}
+
+// bufSqe returns a submission queue entry for opcode on fd with the
+// data buffer p.
+func bufSqe(opcode uint8, fd int, p []byte) unix.IoUringSqe {
+	sqe := unix.IoUringSqe{
+		Opcode: opcode,
+		Fd:     int32(fd),
+		Len:    uint32(len(p)),
+	}
+	if len(p) > 0 {
+		sqe.Addr = uint64(uintptr(unsafe.Pointer(&p[0])))
+	}
+	return sqe
+}
+
+// result converts the result of an operation to the results of a
+// system call. The ok result is false if the operation was not
+// submitted.
+func result(res int32) (n int, err error, ok bool) {
+	switch {
+	case res == notSubmitted:
+		return 0, nil, false
+	case res < 0:
+		return 0, syscall.Errno(-res), true
+	}
+	return int(res), nil, true
+}
+
+// rw performs a read or write, as given by opcode, of p at offset off
+// of fd, or at the file position if off is negative. The ok result is
+// false if the operation could not be submitted, in which case the
+// caller should fall back to a system call.
+func (r *uring) rw(opcode uint8, fd int, p []byte, off int64) (n int, err error, ok bool) {
+	op := getOp(p)
+	defer putOp(op)
+	sqe := bufSqe(opcode, fd, p)
+	sqe.Off = uint64(off)
+	return result(r.do(op, sqe, false))
+}
+
+// sendRecv performs a send or receive, as given by opcode, of p on the
+// socket fd without waiting, so that the runtime poller, which
+// implements deadlines, remains in charge of waiting for readiness.
+func (r *uring) sendRecv(opcode uint8, fd int, p []byte) (n int, err error, ok bool) {
+	op := getOp(p)
+	defer putOp(op)
+	sqe := bufSqe(opcode, fd, p)
+	// MSG_DONTWAIT makes the kernel fail with EAGAIN rather than
+	// wait, as it does for O_NONBLOCK sockets, so no linked timeout
+	// is needed.
+	sqe.OpFlags = syscall.MSG_DONTWAIT
+	return result(r.do(op, sqe, false))
+}
+
+// accept accepts a connection on the nonblocking listening socket fd.
+func (r *uring) accept(fd int) (ns int, sa syscall.Sockaddr, err error, ok bool) {
+	op := getOp(nil)
+	defer putOp(op)
+	op.sa = syscall.RawSockaddrAny{}
+	op.salen = syscall.SizeofSockaddrAny
+	sqe := unix.IoUringSqe{
+		Opcode:  unix.IORING_OP_ACCEPT,
+		Fd:      int32(fd),
+		Addr:    uint64(uintptr(unsafe.Pointer(&op.sa))),
+		Off:     uint64(uintptr(unsafe.Pointer(&op.salen))),
+		OpFlags: syscall.SOCK_NONBLOCK | syscall.SOCK_CLOEXEC,
+	}
+	ns, err, ok = result(r.do(op, sqe, true))
+	if err == syscall.ECANCELED {
+		// No connection was pending.
+		err = syscall.EAGAIN
+	}
+	if !ok || err != nil {
+		return -1, nil, err, ok
+	}
+	if sa = sockaddrFromRaw(&op.sa); sa == nil {
+		sa, err = syscall.Getpeername(ns)
+		if err != nil {
+			CloseFunc(ns)
+			return -1, nil, err, true
+		}
+	}
+	return ns, sa, nil, true
+}
+
+// connect starts connecting the nonblocking socket fd to sa. Like
+// connect(2), it fails with EINPROGRESS if the connection cannot be
+// completed immediately.
+func (r *uring) connect(fd int, sa syscall.Sockaddr) (err error, ok bool) {
+	op := getOp(nil)
+	defer putOp(op)
+	if !sockaddrToRaw(sa, &op.sa, &op.salen) {
+		return nil, false
+	}
+	sqe := unix.IoUringSqe{
+		Opcode: unix.IORING_OP_CONNECT,
+		Fd:     int32(fd),
+		Addr:   uint64(uintptr(unsafe.Pointer(&op.sa))),
+		Off:    uint64(op.salen),
+	}
+	_, err, ok = result(r.do(op, sqe, true))
+	if err == syscall.ECANCELED {
+		// The connection was started but has not completed.
+		err = syscall.EINPROGRESS
+	}
+	return err, ok
+}
+
+// sockaddrToRaw stores sa in raw, and its length in n, in the form
+// the kernel uses. It reports whether the address family is handled.
+func sockaddrToRaw(sa syscall.Sockaddr, raw *syscall.RawSockaddrAny, n *uint32) bool {
+	switch sa := sa.(type) {
+	case *syscall.SockaddrInet4:
+		if sa.Port < 0 || sa.Port > 0xFFFF {
+			return false
+		}
+		r := (*syscall.RawSockaddrInet4)(unsafe.Pointer(raw))
+		*r = syscall.RawSockaddrInet4{Family: syscall.AF_INET, Addr: sa.Addr}
+		p := (*[2]byte)(unsafe.Pointer(&r.Port))
+		p[0], p[1] = byte(sa.Port>>8), byte(sa.Port)
+		*n = syscall.SizeofSockaddrInet4
+	case *syscall.SockaddrInet6:
+		if sa.Port < 0 || sa.Port > 0xFFFF {
+			return false
+		}
+		r := (*syscall.RawSockaddrInet6)(unsafe.Pointer(raw))
+		*r = syscall.RawSockaddrInet6{Family: syscall.AF_INET6, Addr: sa.Addr, Scope_id: sa.ZoneId}
+		p := (*[2]byte)(unsafe.Pointer(&r.Port))
+		p[0], p[1] = byte(sa.Port>>8), byte(sa.Port)
+		*n = syscall.SizeofSockaddrInet6
+	case *syscall.SockaddrUnix:
+		r := (*syscall.RawSockaddrUnix)(unsafe.Pointer(raw))
+		name := sa.Name
+		if name == "" || len(name) >= len(r.Path) {
+			return false
+		}
+		*r = syscall.RawSockaddrUnix{Family: syscall.AF_UNIX}
+		for i := 0; i < len(name); i++ {
+			r.Path[i] = int8(name[i])
+		}
+		// A name starting with @ is in the abstract name space,
+		// where it starts with a NUL byte and is not terminated.
+		*n = 2 + uint32(len(name)) + 1
+		if r.Path[0] == '@' {
+			r.Path[0] = 0
+			*n--
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// sockaddrFromRaw returns the socket address stored in raw, as
+// syscall.Accept4 would, or nil if its family is not handled.
+func sockaddrFromRaw(raw *syscall.RawSockaddrAny) syscall.Sockaddr {
+	switch raw.Addr.Family {
+	case syscall.AF_INET:
+		r := (*syscall.RawSockaddrInet4)(unsafe.Pointer(raw))
+		p := (*[2]byte)(unsafe.Pointer(&r.Port))
+		return &syscall.SockaddrInet4{Port: int(p[0])<<8 + int(p[1]), Addr: r.Addr}
+	case syscall.AF_INET6:
+		r := (*syscall.RawSockaddrInet6)(unsafe.Pointer(raw))
+		p := (*[2]byte)(unsafe.Pointer(&r.Port))
+		return &syscall.SockaddrInet6{Port: int(p[0])<<8 + int(p[1]), ZoneId: r.Scope_id, Addr: r.Addr}
+	case syscall.AF_UNIX:
+		r := (*syscall.RawSockaddrUnix)(unsafe.Pointer(raw))
+		if r.Path[0] == 0 {
+			// Abstract or unnamed; see sockaddrToRaw.
+			r.Path[0] = '@'
+		}
+		n := 0
+		for n < len(r.Path) && r.Path[n] != 0 {
+			n++
+		}
+		b := (*[len(r.Path)]byte)(unsafe.Pointer(&r.Path[0]))[:n]
+		return &syscall.SockaddrUnix{Name: string(b)}
+	}
+	return nil
+}
+
+// initRing arranges for I/O on fd to go through the ring if the kernel
+// supports io_uring and fd is either a regular file, which cannot be
+// managed by the runtime poller and whose reads and writes would
+// otherwise block a thread, or a socket managed by the poller.
+//
+// Other files, such as pipes and the eventfd of the ring itself, do not
+// use the ring.
+func (fd *FD) initRing() {
+	if !fd.isFile {
+		fd.ringSocket = fd.pd.pollable() && getRing() != nil
+		return
+	}
+	if fd.pd.pollable() {
+		return
+	}
+	var st syscall.Stat_t
+	if err := syscall.Fstat(fd.Sysfd, &st); err != nil || st.Mode&syscall.S_IFMT != syscall.S_IFREG {
+		return
+	}
+	fd.ringFile = getRing() != nil
+}
+
+// ringRead reads from fd at offset off, or at the file position if
+// off is negative, through the ring.
+func ringRead(fd int, p []byte, off int64) (int, error) {
+	if n, err, ok := theRing.rw(unix.IORING_OP_READ, fd, p, off); ok {
+		return n, err
+	}
+	return sysRead(fd, p, off)
+}
+
+// ringWrite writes to fd at offset off, or at the file position if
+// off is negative, through the ring.
+func ringWrite(fd int, p []byte, off int64) (int, error) {
+	if n, err, ok := theRing.rw(unix.IORING_OP_WRITE, fd, p, off); ok {
+		return n, err
+	}
+	return sysWrite(fd, p, off)
+}
+
+// ringRecv reads from the socket fd through the ring without waiting.
+func ringRecv(fd int, p []byte) (int, error) {
+	if n, err, ok := theRing.sendRecv(unix.IORING_OP_RECV, fd, p); ok {
+		return n, err
+	}
+	return ignoringEINTRIO(syscall.Read, fd, p)
+}
+
+// ringSend writes to the socket fd through the ring without waiting.
+func ringSend(fd int, p []byte) (int, error) {
+	if n, err, ok := theRing.sendRecv(unix.IORING_OP_SEND, fd, p); ok {
+		return n, err
+	}
+	return ignoringEINTRIO(syscall.Write, fd, p)
+}
+
+// ringAccept accepts a connection on the socket s through the ring,
+// as accept does. The hooks used by tests to observe accept calls are
+// honored by bypassing the ring when they are set.
+func ringAccept(s int) (int, syscall.Sockaddr, string, error) {
+	if !sameFunc(Accept4Func, defaultAccept4) {
+		return accept(s)
+	}
+	if ns, sa, err, ok := theRing.accept(s); ok {
+		if err != nil {
+			return -1, nil, "accept4", err
+		}
+		return ns, sa, "", nil
+	}
+	return accept(s)
+}
+
+var defaultAccept4 = Accept4Func
+
+// Connect starts connecting the nonblocking socket fd to sa, as
+// connect(2) does. With GOEXPERIMENT=iouring, the connection is
+// started through the ring shared with file and socket I/O.
+func Connect(fd int, sa syscall.Sockaddr) error {
+	if r := getRing(); r != nil {
+		if err, ok := r.connect(fd, sa); ok {
+			return err
+		}
+	}
+	return syscall.Connect(fd, sa)
+}
+
+// sameFunc reports whether f and g are the same function value.
+func sameFunc(f, g func(int, int) (int, syscall.Sockaddr, error)) bool {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&f)) == *(*unsafe.Pointer)(unsafe.Pointer(&g))
+}
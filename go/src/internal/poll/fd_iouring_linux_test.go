@@ -0,0 +1,140 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package poll_test
+
+import (
+	"bytes"
+	"internal/goexperiment"
+	"internal/poll"
+	"io"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRegularFileIO(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	sysfd, err := syscall.Open(path, syscall.O_CREAT|syscall.O_RDWR|syscall.O_CLOEXEC, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := &poll.FD{Sysfd: sysfd, IsStream: true, ZeroReadIsEOF: true}
+	fd.Init("file", true)
+	defer fd.Close()
+	if goexperiment.IOUring && !fd.RingFile() {
+		t.Log("io_uring is not available; using system calls")
+	}
+
+	data := []byte("hello, world\n")
+	if n, err := fd.Write(data); n != len(data) || err != nil {
+		t.Fatalf("Write = %d, %v; want %d, nil", n, err, len(data))
+	}
+	if n, err := fd.Pwrite([]byte("HELLO"), 0); n != 5 || err != nil {
+		t.Fatalf("Pwrite = %d, %v; want 5, nil", n, err)
+	}
+	want := []byte("HELLO, world\n")
+
+	// Concurrent positioned reads.
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(off int) {
+			defer wg.Done()
+			b := make([]byte, len(want)-off)
+			n, err := fd.Pread(b, int64(off))
+			if err != nil || !bytes.Equal(b[:n], want[off:]) {
+				t.Errorf("Pread at %d = %q, %v; want %q, nil", off, b[:n], err, want[off:])
+			}
+		}(i % len(want))
+	}
+	wg.Wait()
+
+	if _, err := fd.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 64)
+	n, err := fd.Read(b)
+	if err != nil || !bytes.Equal(b[:n], want) {
+		t.Fatalf("Read = %q, %v; want %q, nil", b[:n], err, want)
+	}
+	if n, err := fd.Read(b); n != 0 || err != io.EOF {
+		t.Fatalf("Read at end of file = %d, %v; want 0, EOF", n, err)
+	}
+}
+
+func TestSocketIO(t *testing.T) {
+	newSocket := func() *poll.FD {
+		s, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM|syscall.SOCK_NONBLOCK|syscall.SOCK_CLOEXEC, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &poll.FD{Sysfd: s, IsStream: true, ZeroReadIsEOF: true}
+	}
+
+	ln := newSocket()
+	if err := syscall.Bind(ln.Sysfd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Listen(ln.Sysfd, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ln.Init("tcp", true); err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if goexperiment.IOUring && !ln.RingSocket() {
+		t.Log("io_uring is not available; using system calls")
+	}
+	addr, err := syscall.Getsockname(ln.Sysfd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newSocket()
+	switch err := poll.Connect(c.Sysfd, addr); err {
+	case nil, syscall.EINPROGRESS:
+	default:
+		t.Fatalf("Connect: %v", err)
+	}
+	if err := c.Init("tcp", true); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.WaitWrite(); err != nil {
+		t.Fatal(err)
+	}
+
+	ns, rsa, _, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	s := &poll.FD{Sysfd: ns, IsStream: true, ZeroReadIsEOF: true}
+	if err := s.Init("tcp", true); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if la, err := syscall.Getsockname(c.Sysfd); err != nil || la.(*syscall.SockaddrInet4).Port != rsa.(*syscall.SockaddrInet4).Port {
+		t.Errorf("Accept returned address %v; want %v", rsa, la)
+	}
+
+	// A read with no data waits in the poller, where deadlines apply.
+	s.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	b := make([]byte, 64)
+	if _, err := s.Read(b); err != poll.ErrDeadlineExceeded {
+		t.Fatalf("Read with no data = %v; want %v", err, poll.ErrDeadlineExceeded)
+	}
+	s.SetReadDeadline(time.Time{})
+
+	data := []byte("hello, world\n")
+	if n, err := c.Write(data); n != len(data) || err != nil {
+		t.Fatalf("Write = %d, %v; want %d, nil", n, err, len(data))
+	}
+	n, err := io.ReadFull(s, b[:len(data)])
+	if err != nil || !bytes.Equal(b[:n], data) {
+		t.Fatalf("Read = %q, %v; want %q, nil", b[:n], err, data)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build (aix || darwin || dragonfly || freebsd || (js && wasm) || linux || netbsd || openbsd || solaris) && !(linux && goexperiment.iouring)
+// +build aix darwin dragonfly freebsd js,wasm linux netbsd openbsd solaris
+// +build !linux !goexperiment.iouring
+
+package poll
+
+import "syscall"
+
+// Without the iouring experiment, files and sockets never use a ring.
+
+func (fd *FD) initRing() {}
+
+func ringRead(fd int, p []byte, off int64) (int, error) {
+	return sysRead(fd, p, off)
+}
+
+func ringWrite(fd int, p []byte, off int64) (int, error) {
+	return sysWrite(fd, p, off)
+}
+
+func ringRecv(fd int, p []byte) (int, error) {
+	return ignoringEINTRIO(syscall.Read, fd, p)
+}
+
+func ringSend(fd int, p []byte) (int, error) {
+	return ignoringEINTRIO(syscall.Write, fd, p)
+}
+
+func ringAccept(s int) (int, syscall.Sockaddr, string, error) {
+	return accept(s)
+}
+
+// Connect starts connecting the nonblocking socket fd to sa, as
+// connect(2) does. With GOEXPERIMENT=iouring on Linux, the connection
+// is started through the ring shared with file and socket I/O.
+func Connect(fd int, sa syscall.Sockaddr) error {
+	return syscall.Connect(fd, sa)
+}
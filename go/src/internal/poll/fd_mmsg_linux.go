@@ -0,0 +1,66 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package poll
+
+import (
+	"internal/syscall/unix"
+	"syscall"
+)
+
+// RecvMmsg wraps the recvmmsg network call. It blocks until at least
+// one message is available and returns the number of messages read.
+func (fd *FD) RecvMmsg(msgs []unix.Mmsghdr, flags int) (int, error) {
+	if err := fd.readLock(); err != nil {
+		return 0, err
+	}
+	defer fd.readUnlock()
+	if err := fd.pd.prepareRead(fd.isFile); err != nil {
+		return 0, err
+	}
+	for {
+		n, err := unix.Recvmmsg(fd.Sysfd, msgs, flags)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			if err == syscall.EAGAIN && fd.pd.pollable() {
+				if err = fd.pd.waitRead(fd.isFile); err == nil {
+					continue
+				}
+			}
+		}
+		return n, err
+	}
+}
+
+// SendMmsg wraps the sendmmsg network call. It sends all of msgs,
+// waiting for the socket to become writable as needed, and returns
+// the number of messages sent.
+func (fd *FD) SendMmsg(msgs []unix.Mmsghdr, flags int) (int, error) {
+	if err := fd.writeLock(); err != nil {
+		return 0, err
+	}
+	defer fd.writeUnlock()
+	if err := fd.pd.prepareWrite(fd.isFile); err != nil {
+		return 0, err
+	}
+	var sent int
+	for sent < len(msgs) {
+		n, err := unix.Sendmmsg(fd.Sysfd, msgs[sent:], flags)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.EAGAIN && fd.pd.pollable() {
+			if err = fd.pd.waitWrite(fd.isFile); err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			return sent, err
+		}
+		sent += n
+	}
+	return sent, nil
+}
@@ -57,6 +57,15 @@ type FD struct {
 	// Whether this is a file rather than a network socket.
 	// 是否系统中真实文件还是socket连接
 	isFile bool
+
+	// Whether this is a regular file whose reads and writes go
+	// through io_uring (GOEXPERIMENT=iouring). Immutable.
+	ringFile bool
+
+	// Whether this is a socket whose reads, writes and accepts go
+	// through io_uring (GOEXPERIMENT=iouring), the runtime poller
+	// still being used to wait for readiness. Immutable.
+	ringSocket bool
 }
 
 // Init initializes the FD. The Sysfd field should already be set.
@@ -75,6 +84,7 @@ func (fd *FD) Init(net string, pollable bool) error {
 	}
 	if !pollable {
 		fd.isBlocking = 1
+		fd.initRing()
 		return nil
 	}
 	err := fd.pd.init(fd)
@@ -84,6 +94,7 @@ func (fd *FD) Init(net string, pollable bool) error {
 		// 如果我们不能初始化运行时轮询器，假设我们使用的是阻塞模式
 		fd.isBlocking = 1
 	}
+	fd.initRing()
 	return err
 }
 
@@ -185,8 +196,21 @@ func (fd *FD) Read(p []byte) (int, error) {
 	if fd.IsStream && len(p) > maxRW {
 		p = p[:maxRW]
 	}
+	if fd.ringFile {
+		n, err := ringRead(fd.Sysfd, p, -1)
+		if err != nil {
+			n = 0
+		}
+		return n, fd.eofError(n, err)
+	}
 	for {
-		n, err := ignoringEINTRIO(syscall.Read, fd.Sysfd, p)
+		var n int
+		var err error
+		if fd.ringSocket {
+			n, err = ringRecv(fd.Sysfd, p)
+		} else {
+			n, err = ignoringEINTRIO(syscall.Read, fd.Sysfd, p)
+		}
 		if err != nil {
 			n = 0
 			if err == syscall.EAGAIN && fd.pd.pollable() {
@@ -216,7 +240,11 @@ func (fd *FD) Pread(p []byte, off int64) (int, error) {
 		err error
 	)
 	for {
-		n, err = syscall.Pread(fd.Sysfd, p, off)
+		if fd.ringFile {
+			n, err = ringRead(fd.Sysfd, p, off)
+		} else {
+			n, err = syscall.Pread(fd.Sysfd, p, off)
+		}
 		if err != syscall.EINTR {
 			break
 		}
@@ -256,6 +284,60 @@ func (fd *FD) ReadFrom(p []byte) (int, syscall.Sockaddr, error) {
 	}
 }
 
+// ReadFromInet4 wraps the recvfrom network call for IPv4.
+func (fd *FD) ReadFromInet4(p []byte, from *syscall.SockaddrInet4) (int, error) {
+	if err := fd.readLock(); err != nil {
+		return 0, err
+	}
+	defer fd.readUnlock()
+	if err := fd.pd.prepareRead(fd.isFile); err != nil {
+		return 0, err
+	}
+	for {
+		n, err := syscall.RecvfromInet4(fd.Sysfd, p, 0, from)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			n = 0
+			if err == syscall.EAGAIN && fd.pd.pollable() {
+				if err = fd.pd.waitRead(fd.isFile); err == nil {
+					continue
+				}
+			}
+		}
+		err = fd.eofError(n, err)
+		return n, err
+	}
+}
+
+// ReadFromInet6 wraps the recvfrom network call for IPv6.
+func (fd *FD) ReadFromInet6(p []byte, from *syscall.SockaddrInet6) (int, error) {
+	if err := fd.readLock(); err != nil {
+		return 0, err
+	}
+	defer fd.readUnlock()
+	if err := fd.pd.prepareRead(fd.isFile); err != nil {
+		return 0, err
+	}
+	for {
+		n, err := syscall.RecvfromInet6(fd.Sysfd, p, 0, from)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			n = 0
+			if err == syscall.EAGAIN && fd.pd.pollable() {
+				if err = fd.pd.waitRead(fd.isFile); err == nil {
+					continue
+				}
+			}
+		}
+		err = fd.eofError(n, err)
+		return n, err
+	}
+}
+
 // ReadMsg wraps the recvmsg network call.
 func (fd *FD) ReadMsg(p []byte, oob []byte, flags int) (int, int, int, syscall.Sockaddr, error) {
 	if err := fd.readLock(); err != nil {
@@ -299,7 +381,16 @@ func (fd *FD) Write(p []byte) (int, error) {
 		if fd.IsStream && max-nn > maxRW {
 			max = nn + maxRW
 		}
-		n, err := ignoringEINTRIO(syscall.Write, fd.Sysfd, p[nn:max])
+		var n int
+		var err error
+		switch {
+		case fd.ringFile:
+			n, err = ringWrite(fd.Sysfd, p[nn:max], -1)
+		case fd.ringSocket:
+			n, err = ringSend(fd.Sysfd, p[nn:max])
+		default:
+			n, err = ignoringEINTRIO(syscall.Write, fd.Sysfd, p[nn:max])
+		}
 		if n > 0 {
 			nn += n
 		}
@@ -338,7 +429,13 @@ func (fd *FD) Pwrite(p []byte, off int64) (int, error) {
 		if fd.IsStream && max-nn > maxRW {
 			max = nn + maxRW
 		}
-		n, err := syscall.Pwrite(fd.Sysfd, p[nn:max], off+int64(nn))
+		var n int
+		var err error
+		if fd.ringFile {
+			n, err = ringWrite(fd.Sysfd, p[nn:max], off+int64(nn))
+		} else {
+			n, err = syscall.Pwrite(fd.Sysfd, p[nn:max], off+int64(nn))
+		}
 		if err == syscall.EINTR {
 			continue
 		}
@@ -383,6 +480,58 @@ func (fd *FD) WriteTo(p []byte, sa syscall.Sockaddr) (int, error) {
 	}
 }
 
+// WriteToInet4 wraps the sendto network call for IPv4 addresses.
+func (fd *FD) WriteToInet4(p []byte, sa *syscall.SockaddrInet4) (int, error) {
+	if err := fd.writeLock(); err != nil {
+		return 0, err
+	}
+	defer fd.writeUnlock()
+	if err := fd.pd.prepareWrite(fd.isFile); err != nil {
+		return 0, err
+	}
+	for {
+		err := syscall.SendtoInet4(fd.Sysfd, p, 0, sa)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.EAGAIN && fd.pd.pollable() {
+			if err = fd.pd.waitWrite(fd.isFile); err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+}
+
+// WriteToInet6 wraps the sendto network call for IPv6 addresses.
+func (fd *FD) WriteToInet6(p []byte, sa *syscall.SockaddrInet6) (int, error) {
+	if err := fd.writeLock(); err != nil {
+		return 0, err
+	}
+	defer fd.writeUnlock()
+	if err := fd.pd.prepareWrite(fd.isFile); err != nil {
+		return 0, err
+	}
+	for {
+		err := syscall.SendtoInet6(fd.Sysfd, p, 0, sa)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.EAGAIN && fd.pd.pollable() {
+			if err = fd.pd.waitWrite(fd.isFile); err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+}
+
 // WriteMsg wraps the sendmsg network call.
 func (fd *FD) WriteMsg(p []byte, oob []byte, sa syscall.Sockaddr) (int, int, error) {
 	if err := fd.writeLock(); err != nil {
@@ -427,7 +576,17 @@ func (fd *FD) Accept() (int, syscall.Sockaddr, string, error) {
 		return -1, nil, "", err
 	}
 	for {
-		s, rsa, errcall, err := accept(fd.Sysfd)
+		var (
+			s       int
+			rsa     syscall.Sockaddr
+			errcall string
+			err     error
+		)
+		if fd.ringSocket {
+			s, rsa, errcall, err = ringAccept(fd.Sysfd)
+		} else {
+			s, rsa, errcall, err = accept(fd.Sysfd)
+		}
 		if err == nil {
 			return s, rsa, "", err
 		}
@@ -623,3 +782,21 @@ func ignoringEINTRIO(fn func(fd int, p []byte) (int, error), fd int, p []byte) (
 		}
 	}
 }
+
+// sysRead reads from fd at offset off, or at the file position if
+// off is negative.
+func sysRead(fd int, p []byte, off int64) (int, error) {
+	if off < 0 {
+		return ignoringEINTRIO(syscall.Read, fd, p)
+	}
+	return syscall.Pread(fd, p, off)
+}
+
+// sysWrite writes to fd at offset off, or at the file position if
+// off is negative.
+func sysWrite(fd int, p []byte, off int64) (int, error) {
+	if off < 0 {
+		return ignoringEINTRIO(syscall.Write, fd, p)
+	}
+	return syscall.Pwrite(fd, p, off)
+}
@@ -584,6 +584,64 @@ func (fd *FD) ReadFrom(buf []byte) (int, syscall.Sockaddr, error) {
 		return 0, nil, err
 	}
 	defer fd.readUnlock()
+	n, err := fd.recvFrom(buf)
+	if err != nil {
+		return n, nil, err
+	}
+	sa, _ := fd.rop.rsa.Sockaddr()
+	return n, sa, nil
+}
+
+// ReadFromInet4 wraps the recvfrom network call for IPv4.
+func (fd *FD) ReadFromInet4(buf []byte, sa4 *syscall.SockaddrInet4) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	if len(buf) > maxRW {
+		buf = buf[:maxRW]
+	}
+	if err := fd.readLock(); err != nil {
+		return 0, err
+	}
+	defer fd.readUnlock()
+	n, err := fd.recvFrom(buf)
+	if err != nil {
+		return n, err
+	}
+	raw := (*syscall.RawSockaddrInet4)(unsafe.Pointer(fd.rop.rsa))
+	p := (*[2]byte)(unsafe.Pointer(&raw.Port))
+	sa4.Port = int(p[0])<<8 + int(p[1])
+	sa4.Addr = raw.Addr
+	return n, nil
+}
+
+// ReadFromInet6 wraps the recvfrom network call for IPv6.
+func (fd *FD) ReadFromInet6(buf []byte, sa6 *syscall.SockaddrInet6) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	if len(buf) > maxRW {
+		buf = buf[:maxRW]
+	}
+	if err := fd.readLock(); err != nil {
+		return 0, err
+	}
+	defer fd.readUnlock()
+	n, err := fd.recvFrom(buf)
+	if err != nil {
+		return n, err
+	}
+	raw := (*syscall.RawSockaddrInet6)(unsafe.Pointer(fd.rop.rsa))
+	p := (*[2]byte)(unsafe.Pointer(&raw.Port))
+	sa6.Port = int(p[0])<<8 + int(p[1])
+	sa6.ZoneId = raw.Scope_id
+	sa6.Addr = raw.Addr
+	return n, nil
+}
+
+// recvFrom reads a datagram into buf, leaving its source address in
+// fd.rop.rsa. The caller must hold the read lock.
+func (fd *FD) recvFrom(buf []byte) (int, error) {
 	o := &fd.rop
 	o.InitBuf(buf)
 	n, err := execIO(o, func(o *operation) error {
@@ -593,12 +651,7 @@ func (fd *FD) ReadFrom(buf []byte) (int, syscall.Sockaddr, error) {
 		o.rsan = int32(unsafe.Sizeof(*o.rsa))
 		return syscall.WSARecvFrom(o.fd.Sysfd, &o.buf, 1, &o.qty, &o.flags, o.rsa, &o.rsan, &o.o, nil)
 	})
-	err = fd.eofError(n, err)
-	if err != nil {
-		return n, nil, err
-	}
-	sa, _ := o.rsa.Sockaddr()
-	return n, sa, nil
+	return n, fd.eofError(n, err)
 }
 
 // Write implements io.Writer.
@@ -1020,6 +1073,16 @@ func sockaddrToRaw(sa syscall.Sockaddr) (unsafe.Pointer, int32, error) {
 	}
 }
 
+// WriteToInet4 wraps the sendto network call for IPv4 addresses.
+func (fd *FD) WriteToInet4(buf []byte, sa4 *syscall.SockaddrInet4) (int, error) {
+	return fd.WriteTo(buf, sa4)
+}
+
+// WriteToInet6 wraps the sendto network call for IPv6 addresses.
+func (fd *FD) WriteToInet6(buf []byte, sa6 *syscall.SockaddrInet6) (int, error) {
+	return fd.WriteTo(buf, sa6)
+}
+
 // ReadMsg wraps the WSARecvMsg network call.
 func (fd *FD) ReadMsg(p []byte, oob []byte, flags int) (int, int, int, syscall.Sockaddr, error) {
 	if err := fd.readLock(); err != nil {
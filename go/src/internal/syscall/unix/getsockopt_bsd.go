@@ -0,0 +1,14 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || freebsd || netbsd || openbsd
+// +build darwin freebsd netbsd openbsd
+
+package unix
+
+import "unsafe"
+
+// Implemented in the syscall package.
+//go:linkname getsockopt syscall.getsockopt
+func getsockopt(s int, level int, name int, val unsafe.Pointer, vallen *uint32) error
@@ -0,0 +1,135 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unix
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// IoUringParams is struct io_uring_params, used to set up an io_uring
+// instance and to learn the layout of its rings.
+type IoUringParams struct {
+	SqEntries    uint32
+	CqEntries    uint32
+	Flags        uint32
+	SqThreadCPU  uint32
+	SqThreadIdle uint32
+	Features     uint32
+	WqFd         uint32
+	Resv         [3]uint32
+	SqOff        IoSqringOffsets
+	CqOff        IoCqringOffsets
+}
+
+// IoSqringOffsets is struct io_sqring_offsets.
+type IoSqringOffsets struct {
+	Head        uint32
+	Tail        uint32
+	RingMask    uint32
+	RingEntries uint32
+	Flags       uint32
+	Dropped     uint32
+	Array       uint32
+	Resv1       uint32
+	Resv2       uint64
+}
+
+// IoCqringOffsets is struct io_cqring_offsets.
+type IoCqringOffsets struct {
+	Head        uint32
+	Tail        uint32
+	RingMask    uint32
+	RingEntries uint32
+	Overflow    uint32
+	Cqes        uint32
+	Flags       uint32
+	Resv1       uint32
+	Resv2       uint64
+}
+
+// IoUringSqe is struct io_uring_sqe, a submission queue entry.
+type IoUringSqe struct {
+	Opcode      uint8
+	Flags       uint8
+	Ioprio      uint16
+	Fd          int32
+	Off         uint64
+	Addr        uint64
+	Len         uint32
+	OpFlags     uint32
+	UserData    uint64
+	BufIndex    uint16
+	Personality uint16
+	SpliceFdIn  int32
+	Pad         [2]uint64
+}
+
+// IoUringCqe is struct io_uring_cqe, a completion queue entry.
+type IoUringCqe struct {
+	UserData uint64
+	Res      int32
+	Flags    uint32
+}
+
+const (
+	// Offsets passed to mmap to map the parts of an io_uring instance.
+	IORING_OFF_SQ_RING = 0x0
+	IORING_OFF_CQ_RING = 0x8000000
+	IORING_OFF_SQES    = 0x10000000
+
+	IORING_OP_ACCEPT       = 13
+	IORING_OP_LINK_TIMEOUT = 15
+	IORING_OP_CONNECT      = 16
+	IORING_OP_READ         = 22
+	IORING_OP_WRITE        = 23
+	IORING_OP_SEND         = 26
+	IORING_OP_RECV         = 27
+
+	IOSQE_IO_LINK = 1 << 2
+
+	IORING_FEAT_NODROP     = 1 << 1
+	IORING_FEAT_RW_CUR_POS = 1 << 3
+
+	IORING_REGISTER_EVENTFD = 4
+)
+
+// IoUringSetup creates an io_uring instance with room for at least
+// entries submissions and returns its file descriptor.
+func IoUringSetup(entries uint32, p *IoUringParams) (int, error) {
+	fd, _, errno := syscall.Syscall(ioUringSetupTrap, uintptr(entries), uintptr(unsafe.Pointer(p)), 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// IoUringEnter submits toSubmit entries of the submission queue of the
+// io_uring instance fd and returns the number consumed.
+func IoUringEnter(fd int, toSubmit, minComplete, flags uint32) (int, error) {
+	n, _, errno := syscall.Syscall6(ioUringEnterTrap, uintptr(fd), uintptr(toSubmit), uintptr(minComplete), uintptr(flags), 0, 0)
+	if errno != 0 {
+		return int(n), errno
+	}
+	return int(n), nil
+}
+
+// IoUringRegister registers resources with the io_uring instance fd.
+func IoUringRegister(fd int, opcode uint32, arg unsafe.Pointer, nrArgs uint32) error {
+	_, _, errno := syscall.Syscall6(ioUringRegisterTrap, uintptr(fd), uintptr(opcode), uintptr(arg), uintptr(nrArgs), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Eventfd creates an event notification file descriptor.
+func Eventfd(initval uint, flags int) (int, error) {
+	fd, _, errno := syscall.RawSyscall(syscall.SYS_EVENTFD2, uintptr(initval), uintptr(flags), 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
@@ -0,0 +1,38 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unix
+
+import "unsafe"
+
+// Options of level SOL_LOCAL missing from package syscall.
+const (
+	solLocal      = 0
+	localPeerCred = 0x1
+	localPeerPid  = 0x2
+)
+
+// xucred is struct xucred of <sys/ucred.h>.
+type xucred struct {
+	version uint32
+	uid     uint32
+	ngroups int16
+	groups  [16]uint32
+}
+
+// PeerCredentials returns the process, user and effective group IDs
+// of the peer of the connected Unix domain socket fd.
+func PeerCredentials(fd int) (pid, uid, gid int, err error) {
+	var cred xucred
+	n := uint32(unsafe.Sizeof(cred))
+	if err := getsockopt(fd, solLocal, localPeerCred, unsafe.Pointer(&cred), &n); err != nil {
+		return 0, 0, 0, err
+	}
+	var p int32
+	n = uint32(unsafe.Sizeof(p))
+	if err := getsockopt(fd, solLocal, localPeerPid, unsafe.Pointer(&p), &n); err != nil {
+		return 0, 0, 0, err
+	}
+	return int(p), int(cred.uid), int(cred.groups[0]), nil
+}
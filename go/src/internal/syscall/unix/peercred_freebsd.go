@@ -0,0 +1,34 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unix
+
+import "unsafe"
+
+// localPeerCred is LOCAL_PEERCRED, an option of level 0, missing from
+// package syscall.
+const localPeerCred = 0x1
+
+// xucred is struct xucred of <sys/ucred.h>. Since FreeBSD 13, the
+// union that ends it holds the process ID.
+type xucred struct {
+	version uint32
+	uid     uint32
+	ngroups int16
+	groups  [16]uint32
+	_       [0]uintptr
+	pid     int32
+}
+
+// PeerCredentials returns the process, user and effective group IDs
+// of the peer of the connected Unix domain socket fd. The process ID
+// is zero before FreeBSD 13.
+func PeerCredentials(fd int) (pid, uid, gid int, err error) {
+	var cred xucred
+	n := uint32(unsafe.Sizeof(cred))
+	if err := getsockopt(fd, 0, localPeerCred, unsafe.Pointer(&cred), &n); err != nil {
+		return 0, 0, 0, err
+	}
+	return int(cred.pid), int(cred.uid), int(cred.groups[0]), nil
+}
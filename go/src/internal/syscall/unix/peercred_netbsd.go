@@ -0,0 +1,29 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unix
+
+import "unsafe"
+
+// localPeerEid is LOCAL_PEEREID, an option of level 0, missing from
+// package syscall.
+const localPeerEid = 0x3
+
+// unpcbid is struct unpcbid of <sys/un.h>.
+type unpcbid struct {
+	pid int32
+	uid uint32
+	gid uint32
+}
+
+// PeerCredentials returns the process, user and effective group IDs
+// of the peer of the connected Unix domain socket fd.
+func PeerCredentials(fd int) (pid, uid, gid int, err error) {
+	var id unpcbid
+	n := uint32(unsafe.Sizeof(id))
+	if err := getsockopt(fd, 0, localPeerEid, unsafe.Pointer(&id), &n); err != nil {
+		return 0, 0, 0, err
+	}
+	return int(id.pid), int(id.uid), int(id.gid), nil
+}
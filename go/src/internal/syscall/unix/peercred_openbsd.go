@@ -0,0 +1,28 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unix
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// sockpeercred is struct sockpeercred of <sys/socket.h>.
+type sockpeercred struct {
+	uid uint32
+	gid uint32
+	pid int32
+}
+
+// PeerCredentials returns the process, user and effective group IDs
+// of the peer of the connected Unix domain socket fd.
+func PeerCredentials(fd int) (pid, uid, gid int, err error) {
+	var cred sockpeercred
+	n := uint32(unsafe.Sizeof(cred))
+	if err := getsockopt(fd, syscall.SOL_SOCKET, syscall.SO_PEERCRED, unsafe.Pointer(&cred), &n); err != nil {
+		return 0, 0, 0, err
+	}
+	return int(cred.pid), int(cred.uid), int(cred.gid), nil
+}
@@ -0,0 +1,28 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unix
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// SO_ATTACH_REUSEPORT_CBPF attaches a classic BPF program that selects
+// the member of a SO_REUSEPORT group receiving each connection or
+// datagram.
+const SO_ATTACH_REUSEPORT_CBPF = 0x33
+
+// AttachReuseportCBPF attaches the classic BPF program prog to the
+// SO_REUSEPORT group of the socket fd.
+func AttachReuseportCBPF(fd int, prog []syscall.SockFilter) error {
+	if len(prog) == 0 {
+		return syscall.EINVAL
+	}
+	p := syscall.SockFprog{
+		Len:    uint16(len(prog)),
+		Filter: &prog[0],
+	}
+	return setsockopt(fd, syscall.SOL_SOCKET, SO_ATTACH_REUSEPORT_CBPF, unsafe.Pointer(&p), unsafe.Sizeof(p))
+}
@@ -0,0 +1,21 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux && !386 && !s390x
+// +build linux,!386,!s390x
+
+package unix
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func setsockopt(fd, level, opt int, val unsafe.Pointer, vallen uintptr) error {
+	_, _, errno := syscall.Syscall6(syscall.SYS_SETSOCKOPT, uintptr(fd), uintptr(level), uintptr(opt), uintptr(val), vallen, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
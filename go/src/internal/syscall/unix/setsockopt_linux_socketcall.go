@@ -0,0 +1,27 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux && (386 || s390x)
+// +build linux
+// +build 386 s390x
+
+package unix
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// On these architectures socket system calls are multiplexed
+// through socketcall(2).
+const socketcallSetsockopt = 14
+
+func setsockopt(fd, level, opt int, val unsafe.Pointer, vallen uintptr) error {
+	args := [5]uintptr{uintptr(fd), uintptr(level), uintptr(opt), uintptr(val), vallen}
+	_, _, errno := syscall.Syscall(syscall.SYS_SOCKETCALL, socketcallSetsockopt, uintptr(unsafe.Pointer(&args[0])), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unix
+
+// SO_REUSEPORT_LB is missing from package syscall. Unlike
+// SO_REUSEPORT, it spreads incoming connections across the sockets
+// bound to an address.
+const SO_REUSEPORT_LB = 0x10000
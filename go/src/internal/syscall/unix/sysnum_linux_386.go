@@ -5,6 +5,11 @@
 package unix
 
 const (
-	getrandomTrap     uintptr = 355
-	copyFileRangeTrap uintptr = 377
+	getrandomTrap       uintptr = 355
+	copyFileRangeTrap   uintptr = 377
+	recvmmsgTrap        uintptr = 337
+	sendmmsgTrap        uintptr = 345
+	ioUringSetupTrap    uintptr = 425
+	ioUringEnterTrap    uintptr = 426
+	ioUringRegisterTrap uintptr = 427
 )
@@ -5,6 +5,11 @@
 package unix
 
 const (
-	getrandomTrap     uintptr = 318
-	copyFileRangeTrap uintptr = 326
+	getrandomTrap       uintptr = 318
+	copyFileRangeTrap   uintptr = 326
+	recvmmsgTrap        uintptr = 299
+	sendmmsgTrap        uintptr = 307
+	ioUringSetupTrap    uintptr = 425
+	ioUringEnterTrap    uintptr = 426
+	ioUringRegisterTrap uintptr = 427
 )
@@ -8,6 +8,11 @@
 package unix
 
 const (
-	getrandomTrap     uintptr = 5313
-	copyFileRangeTrap uintptr = 5320
+	getrandomTrap       uintptr = 5313
+	copyFileRangeTrap   uintptr = 5320
+	recvmmsgTrap        uintptr = 5294
+	sendmmsgTrap        uintptr = 5302
+	ioUringSetupTrap    uintptr = 5425
+	ioUringEnterTrap    uintptr = 5426
+	ioUringRegisterTrap uintptr = 5427
 )
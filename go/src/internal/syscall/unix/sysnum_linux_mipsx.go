@@ -8,6 +8,11 @@
 package unix
 
 const (
-	getrandomTrap     uintptr = 4353
-	copyFileRangeTrap uintptr = 4360
+	getrandomTrap       uintptr = 4353
+	copyFileRangeTrap   uintptr = 4360
+	recvmmsgTrap        uintptr = 4335
+	sendmmsgTrap        uintptr = 4343
+	ioUringSetupTrap    uintptr = 4425
+	ioUringEnterTrap    uintptr = 4426
+	ioUringRegisterTrap uintptr = 4427
 )
@@ -159,12 +159,15 @@ const (
 	WSA_FLAG_OVERLAPPED        = 0x01
 	WSA_FLAG_NO_HANDLE_INHERIT = 0x80
 
-	WSAEMSGSIZE syscall.Errno = 10040
+	WSAEMSGSIZE    syscall.Errno = 10040
+	WSAENOPROTOOPT syscall.Errno = 10042
 
 	MSG_PEEK   = 0x2
 	MSG_TRUNC  = 0x0100
 	MSG_CTRUNC = 0x0200
 
+	FIONREAD = 0x4004667f
+
 	socket_error = uintptr(^uint32(0))
 )
 
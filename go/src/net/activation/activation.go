@@ -0,0 +1,249 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+// Package activation implements socket activation: obtaining
+// listening sockets from the service manager, such as systemd, or
+// from a parent process, instead of creating them.
+//
+// Sockets are passed as open file descriptors starting at 3,
+// described by the LISTEN_FDS, LISTEN_PID and LISTEN_FDNAMES
+// environment variables, as documented in sd_listen_fds(3). A
+// process retrieves them with Listeners and PacketConns.
+//
+// The same protocol can be used to hand live sockets to a child
+// process for a graceful upgrade: the parent calls Inherit on the
+// child's exec.Cmd, and the child retrieves the sockets with
+// Listeners and PacketConns as if it had been started by systemd.
+//
+// The package is only available on Unix systems.
+//
+// 包activation实现了套接字激活：从服务管理器（如systemd）或父进程
+// 获取监听套接字，而不是自己创建它们。
+package activation
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// listenFDsStart is the first file descriptor passed by the service
+// manager (SD_LISTEN_FDS_START).
+const listenFDsStart = 3
+
+// defaultName is the name of sockets for which LISTEN_FDNAMES gives
+// none, as in sd_listen_fds_with_names(3).
+const defaultName = "unknown"
+
+var (
+	filesOnce sync.Once
+	files     []*os.File
+	filesErr  error
+)
+
+// Files returns the files passed to the process. The name of each
+// file, as returned by its Name method, is the one given in
+// LISTEN_FDNAMES, or "unknown".
+//
+// The environment is read on the first call only, after which the
+// LISTEN_FDS, LISTEN_PID and LISTEN_FDNAMES variables are removed so
+// that they are not inherited by child processes; later calls return
+// the same files. The files are marked close-on-exec. Files returns
+// no files if LISTEN_PID is set and is not the ID of the process; an
+// unset LISTEN_PID is accepted, as for processes started by Inherit.
+func Files() ([]*os.File, error) {
+	filesOnce.Do(func() {
+		files, filesErr = inheritedFiles(os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES"))
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	})
+	return files, filesErr
+}
+
+// inheritedFiles returns the files described by the values of the
+// LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES variables.
+func inheritedFiles(pid, fds, fdNames string) ([]*os.File, error) {
+	if fds == "" {
+		return nil, nil
+	}
+	if pid != "" {
+		// A LISTEN_PID that is not ours means the variables were
+		// meant for another process, such as our parent.
+		p, err := strconv.Atoi(pid)
+		if err != nil {
+			return nil, errors.New("activation: invalid LISTEN_PID " + strconv.Quote(pid))
+		}
+		if p != os.Getpid() {
+			return nil, nil
+		}
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		return nil, errors.New("activation: invalid LISTEN_FDS " + strconv.Quote(fds))
+	}
+	var names []string
+	if fdNames != "" {
+		names = strings.Split(fdNames, ":")
+	}
+	files := make([]*os.File, n)
+	for i := range files {
+		fd := listenFDsStart + i
+		syscall.CloseOnExec(fd)
+		name := defaultName
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		files[i] = os.NewFile(uintptr(fd), name)
+	}
+	return files, nil
+}
+
+// Listeners returns the stream sockets passed to the process, such
+// as TCP and Unix listeners, keyed by name. See Files.
+//
+// Each call returns new Listeners for the same sockets; the caller
+// is responsible for closing them.
+func Listeners() (map[string][]net.Listener, error) {
+	files, err := Files()
+	if err != nil {
+		return nil, err
+	}
+	var ls map[string][]net.Listener
+	for _, f := range files {
+		l, err := net.FileListener(f)
+		if err != nil {
+			// Not a stream socket; see PacketConns.
+			continue
+		}
+		if network(l.Addr()) == "unixgram" {
+			l.Close()
+			continue
+		}
+		if ls == nil {
+			ls = make(map[string][]net.Listener)
+		}
+		ls[f.Name()] = append(ls[f.Name()], l)
+	}
+	return ls, nil
+}
+
+// PacketConns returns the datagram sockets passed to the process,
+// such as UDP sockets, keyed by name. See Files.
+//
+// Each call returns new PacketConns for the same sockets; the caller
+// is responsible for closing them.
+func PacketConns() (map[string][]net.PacketConn, error) {
+	files, err := Files()
+	if err != nil {
+		return nil, err
+	}
+	var pcs map[string][]net.PacketConn
+	for _, f := range files {
+		pc, err := net.FilePacketConn(f)
+		if err != nil {
+			continue
+		}
+		if n := network(pc.LocalAddr()); n == "unix" || n == "unixpacket" {
+			// A stream socket; see Listeners.
+			pc.Close()
+			continue
+		}
+		if pcs == nil {
+			pcs = make(map[string][]net.PacketConn)
+		}
+		pcs[f.Name()] = append(pcs[f.Name()], pc)
+	}
+	return pcs, nil
+}
+
+// network returns the network name of a, which may be nil.
+func network(a net.Addr) string {
+	if ua, ok := a.(*net.UnixAddr); ok {
+		if ua == nil {
+			return ""
+		}
+		return ua.Net
+	}
+	if a == nil {
+		return ""
+	}
+	return a.Network()
+}
+
+// A Socket is a listener or connection whose underlying socket can be
+// passed to another process, such as *net.TCPListener,
+// *net.UnixListener and *net.UDPConn.
+type Socket interface {
+	File() (*os.File, error)
+}
+
+// Inherit arranges for the child process started by cmd to inherit
+// socks under the given name, using the protocol of sd_listen_fds(3).
+// Inherit may be called several times on the same cmd, with different
+// names, but must be called before cmd is started; cmd.ExtraFiles
+// must only hold files added by Inherit.
+//
+// The child process retrieves the sockets with Listeners and
+// PacketConns. Since the ID of the child is not known in advance,
+// LISTEN_PID is not set, which this package accepts; a child using
+// sd_listen_fds(3) instead must first set LISTEN_PID to its own ID,
+// as that function requires it.
+//
+// Inherit adds duplicates of the sockets to cmd.ExtraFiles; the
+// caller should close them once the child has started. If it returns
+// an error, Inherit leaves cmd unchanged.
+func Inherit(cmd *exec.Cmd, name string, socks ...Socket) error {
+	if name == "" || strings.Contains(name, ":") {
+		return errors.New("activation: invalid socket name " + strconv.Quote(name))
+	}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	var names []string
+	newEnv := make([]string, 0, len(env)+2)
+	for _, kv := range env {
+		switch {
+		case strings.HasPrefix(kv, "LISTEN_FDNAMES="):
+			names = strings.Split(strings.TrimPrefix(kv, "LISTEN_FDNAMES="), ":")
+		case strings.HasPrefix(kv, "LISTEN_FDS="), strings.HasPrefix(kv, "LISTEN_PID="):
+		default:
+			newEnv = append(newEnv, kv)
+		}
+	}
+	if cmd.Env == nil {
+		// Variables inherited from our own environment do not
+		// describe cmd.ExtraFiles.
+		names = nil
+	}
+	if len(names) != len(cmd.ExtraFiles) {
+		return errors.New("activation: cmd.ExtraFiles holds files not added by Inherit")
+	}
+	files := make([]*os.File, 0, len(socks))
+	for _, s := range socks {
+		f, err := s.File()
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return err
+		}
+		files = append(files, f)
+		names = append(names, name)
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, files...)
+	cmd.Env = append(newEnv,
+		"LISTEN_FDS="+strconv.Itoa(len(cmd.ExtraFiles)),
+		"LISTEN_FDNAMES="+strings.Join(names, ":"))
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package activation
+
+import (
+	"errors"
+	"fmt"
+	"internal/testenv"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestInheritedFiles(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+	tests := []struct {
+		pid, fds, names string
+		want            []string
+		err             bool
+	}{
+		{"", "", "", nil, false},
+		{pid, "0", "", []string{}, false},
+		{pid, "2", "web:dns", []string{"web", "dns"}, false},
+		{pid, "3", "web::", []string{"web", "unknown", "unknown"}, false},
+		{"", "1", "", []string{"unknown"}, false},
+		{strconv.Itoa(os.Getpid() + 1), "1", "web", nil, false},
+		{pid, "x", "", nil, true},
+		{pid, "-1", "", nil, true},
+		{"x", "1", "", nil, true},
+	}
+	for _, tt := range tests {
+		files, err := inheritedFiles(tt.pid, tt.fds, tt.names)
+		if (err != nil) != tt.err {
+			t.Errorf("inheritedFiles(%q, %q, %q) error = %v; want error %v", tt.pid, tt.fds, tt.names, err, tt.err)
+			continue
+		}
+		var names []string
+		if files != nil {
+			names = []string{}
+		}
+		for i, f := range files {
+			names = append(names, f.Name())
+			if f.Fd() != uintptr(listenFDsStart+i) {
+				t.Errorf("file %d has descriptor %d", i, f.Fd())
+			}
+		}
+		if fmt.Sprint(names) != fmt.Sprint(tt.want) || (names == nil) != (tt.want == nil) {
+			t.Errorf("inheritedFiles(%q, %q, %q) = %v; want %v", tt.pid, tt.fds, tt.names, names, tt.want)
+		}
+	}
+}
+
+// TestHelperProcess is run as the child process by TestInherit.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+	ls, err := Listeners()
+	if err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+	pcs, err := PacketConns()
+	if err != nil {
+		fmt.Println("error:", err)
+		return
+	}
+	var lines []string
+	for name, ls := range ls {
+		for _, l := range ls {
+			lines = append(lines, name+" "+l.Addr().Network()+" "+l.Addr().String())
+		}
+	}
+	for name, pcs := range pcs {
+		for _, pc := range pcs {
+			lines = append(lines, name+" "+pc.LocalAddr().Network()+" "+pc.LocalAddr().String())
+		}
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		lines = append(lines, "LISTEN_FDS still set")
+	}
+	sort.Strings(lines)
+	fmt.Print(strings.Join(lines, "\n"))
+}
+
+func TestInherit(t *testing.T) {
+	testenv.MustHaveExec(t)
+
+	ln1, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln1.Close()
+	ln2, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln2.Close()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess")
+	cmd.Env = append(os.Environ(), "GO_WANT_HELPER_PROCESS=1")
+	if err := Inherit(cmd, "web", ln1.(*net.TCPListener), ln2.(*net.TCPListener)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Inherit(cmd, "dns", pc.(*net.UDPConn)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Inherit(cmd, "a:b", pc.(*net.UDPConn)); err == nil {
+		t.Error("Inherit accepted a name containing a colon")
+	}
+	out, err := cmd.Output()
+	for _, f := range cmd.ExtraFiles {
+		f.Close()
+	}
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	want := []string{
+		"dns udp " + pc.LocalAddr().String(),
+		"web tcp " + ln1.Addr().String(),
+		"web tcp " + ln2.Addr().String(),
+	}
+	sort.Strings(want)
+	if got := string(out); got != strings.Join(want, "\n") {
+		t.Errorf("child got sockets:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+	if cmd.Args[0] != os.Args[0] || len(cmd.Args) != 2 {
+		t.Errorf("cmd.Args = %q; want it unchanged", cmd.Args)
+	}
+
+	cmd = exec.Command(os.Args[0])
+	cmd.ExtraFiles = []*os.File{os.Stdin}
+	if err := Inherit(cmd, "web", ln1.(*net.TCPListener)); err == nil {
+		t.Error("Inherit accepted a command with unrelated ExtraFiles")
+	}
+}
+
+// fileSocket is a Socket that records the file it returns.
+type fileSocket struct {
+	Socket
+	f *os.File
+}
+
+func (s *fileSocket) File() (f *os.File, err error) {
+	s.f, err = s.Socket.File()
+	return s.f, err
+}
+
+type badSocket struct{}
+
+func (badSocket) File() (*os.File, error) {
+	return nil, errors.New("no file")
+}
+
+func TestInheritError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	cmd := exec.Command(os.Args[0])
+	s := &fileSocket{Socket: ln.(*net.TCPListener)}
+	if err := Inherit(cmd, "web", s, badSocket{}); err == nil {
+		t.Fatal("Inherit succeeded with a failing socket")
+	}
+	if s.f == nil {
+		t.Fatal("Inherit did not duplicate the first socket")
+	}
+	if err := s.f.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("closing the duplicate of the first socket: got %v; want %v", err, os.ErrClosed)
+	}
+	if len(cmd.ExtraFiles) != 0 || cmd.Env != nil {
+		t.Errorf("Inherit changed cmd: ExtraFiles = %v, Env = %q", cmd.ExtraFiles, cmd.Env)
+	}
+}
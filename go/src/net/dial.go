@@ -17,6 +17,10 @@ import (
 const (
 	// 默认保活15秒
 	defaultTCPKeepAlive = 15 * time.Second
+
+	// Defaults for the probe interval and count of a KeepAliveConfig.
+	defaultTCPKeepAliveInterval = 15 * time.Second
+	defaultTCPKeepAliveCount    = 9
 )
 
 // A Dialer contains options for connecting to an address.
@@ -100,6 +104,14 @@ type Dialer struct {
 	// 不支持keep-alive的网络连接会忽略本字段。
 	KeepAlive time.Duration
 
+	// KeepAliveConfig specifies the full keep-alive configuration
+	// (idle time, probe interval and probe count) for an active
+	// network connection. If KeepAliveConfig.Enable is true, it
+	// takes precedence over KeepAlive, even a negative one, and
+	// keep-alives are enabled. Otherwise it is ignored; to disable
+	// keep-alives, set KeepAlive to a negative value.
+	KeepAliveConfig KeepAliveConfig
+
 	// Resolver optionally specifies an alternate resolver to use.
 	Resolver *Resolver
 
@@ -491,16 +503,23 @@ func (d *Dialer) DialContext(ctx context.Context, network, address string) (Conn
 		return nil, err
 	}
 
-	if tc, ok := c.(*TCPConn); ok && d.KeepAlive >= 0 {
-		// 设置保活时间
-		setKeepAlive(tc.fd, true)
-		ka := d.KeepAlive
-		// 如果d,KeepAlive设置的保活时间为0，则使用默认的15秒
-		if d.KeepAlive == 0 {
-			ka = defaultTCPKeepAlive
+	if tc, ok := c.(*TCPConn); ok {
+		if d.KeepAliveConfig.Enable {
+			setKeepAlive(tc.fd, true)
+			cfg := d.KeepAliveConfig.withDefaults()
+			setKeepAliveConfig(tc.fd, cfg)
+			testHookSetKeepAlive(cfg.Idle)
+		} else if d.KeepAlive >= 0 {
+			// 设置保活时间
+			setKeepAlive(tc.fd, true)
+			ka := d.KeepAlive
+			// 如果d,KeepAlive设置的保活时间为0，则使用默认的15秒
+			if d.KeepAlive == 0 {
+				ka = defaultTCPKeepAlive
+			}
+			setKeepAlivePeriod(tc.fd, ka)
+			testHookSetKeepAlive(ka)
 		}
-		setKeepAlivePeriod(tc.fd, ka)
-		testHookSetKeepAlive(ka)
 	}
 	return c, nil
 }
@@ -689,6 +708,23 @@ type ListenConfig struct {
 	// 如果为零，则如果得到协议和操作系统的支持，则启用保持保活。不支持保活的网络协议或操作系统忽略此字段。
 	// 如果为负数，则禁用保活机制。
 	KeepAlive time.Duration
+
+	// KeepAliveConfig specifies the full keep-alive configuration
+	// (idle time, probe interval and probe count) for network
+	// connections accepted by this listener. If
+	// KeepAliveConfig.Enable is true, it takes precedence over
+	// KeepAlive, even a negative one, and keep-alives are enabled.
+	// Otherwise it is ignored; to disable keep-alives, set KeepAlive
+	// to a negative value.
+	KeepAliveConfig KeepAliveConfig
+
+	// SteerByCPU, if true, makes ListenGroup and ListenPacketGroup
+	// distribute incoming connections and datagrams by the CPU that
+	// received them: the i'th member of a group of n serves the
+	// CPUs whose number is i modulo n. This keeps each connection
+	// on the CPU that handled its packets when the goroutine serving
+	// a member is pinned there. It is only supported on Linux.
+	SteerByCPU bool
 }
 
 // Listen announces on the local network address.
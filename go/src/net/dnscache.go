@@ -0,0 +1,326 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// defaultDNSCacheEntries is the size bound used when
+// DNSCache.MaxEntries is zero.
+const defaultDNSCacheEntries = 1024
+
+// defaultDNSCacheSystemTTL is the lifetime used when
+// DNSCache.SystemTTL is zero.
+const defaultDNSCacheSystemTTL = 30 * time.Second
+
+// A DNSCache is an in-process cache of DNS answers for a Resolver.
+//
+// Answers obtained by Go's built-in resolver are kept for the
+// smallest TTL among their records. Negative answers (the name
+// does not exist, or has no records of the requested type) are
+// kept for the negative caching TTL of RFC 2308 taken from the SOA
+// record of the response, and are not cached when the response has
+// no SOA record. Answers obtained from the system resolver carry no
+// TTL and are kept for SystemTTL. Temporary failures, such as
+// timeouts and SERVFAIL responses, are never cached.
+//
+// A DNSCache may be shared by several Resolvers and is safe for
+// concurrent use by multiple goroutines. A DNSCache must not be
+// copied after first use.
+//
+// DNSCache缓存Resolver的DNS应答，并遵循记录的TTL。
+type DNSCache struct {
+	// MaxEntries is the maximum number of answers held by the cache.
+	// When the cache is full, the least recently used answer is
+	// discarded. If zero, a default of 1024 is used.
+	MaxEntries int
+
+	// MaxTTL, if positive, limits how long any answer is kept,
+	// regardless of the TTL given by the name server.
+	MaxTTL time.Duration
+
+	// SystemTTL is how long answers from the system resolver
+	// (such as the cgo-based resolver on Unix systems) are kept,
+	// subject to MaxTTL. If zero, a default of 30 seconds is used.
+	// If negative, such answers are not cached.
+	SystemTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[dnsCacheKey]*dnsCacheEntry
+	lru     dnsCacheEntry // sentinel of the recently used list; lru.next is the most recent
+	stats   DNSCacheStats
+}
+
+// DNSCacheStats holds statistics about the use of a DNSCache.
+type DNSCacheStats struct {
+	Hits      uint64 // lookups answered from the cache
+	Misses    uint64 // lookups that had to query a resolver
+	Evictions uint64 // answers discarded to respect MaxEntries
+	Entries   int    // answers currently held
+}
+
+// dnsCacheKey identifies a cached answer. Answers from Go's resolver
+// are keyed by the fully qualified name and query type; answers from
+// the system resolver by the lookup operation and the name as given.
+type dnsCacheKey struct {
+	name  string
+	qtype dnsmessage.Type
+	op    string
+}
+
+type dnsCacheEntry struct {
+	key        dnsCacheKey
+	expires    time.Time
+	prev, next *dnsCacheEntry
+
+	// An answer from Go's resolver.
+	p      dnsmessage.Parser
+	server string
+
+	// An answer from the system resolver.
+	val interface{}
+
+	err *DNSError // negative answer, or nil
+}
+
+// Flush discards all cached answers.
+func (c *DNSCache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+	c.lru.next, c.lru.prev = &c.lru, &c.lru
+}
+
+// Stats returns statistics about the use of the cache.
+func (c *DNSCache) Stats() DNSCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = len(c.entries)
+	return s
+}
+
+// get returns the unexpired entry for k and counts the lookup.
+// The caller must copy what it needs out of the entry before
+// releasing it to others; entries are never modified once stored.
+func (c *DNSCache) get(k dnsCacheKey) *dnsCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entries[k]
+	if e != nil && !time.Now().Before(e.expires) {
+		c.remove(e)
+		e = nil
+	}
+	if e == nil {
+		c.stats.Misses++
+		return nil
+	}
+	c.stats.Hits++
+	c.unlink(e)
+	c.pushFront(e)
+	return e
+}
+
+// put stores e, to be kept for ttl. Entries with a non-positive
+// lifetime are not stored.
+func (c *DNSCache) put(e *dnsCacheEntry, ttl time.Duration) {
+	if c.MaxTTL > 0 && ttl > c.MaxTTL {
+		ttl = c.MaxTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+	e.expires = time.Now().Add(ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[dnsCacheKey]*dnsCacheEntry)
+		c.lru.next, c.lru.prev = &c.lru, &c.lru
+	}
+	if old := c.entries[e.key]; old != nil {
+		c.remove(old)
+	}
+	max := c.MaxEntries
+	if max <= 0 {
+		max = defaultDNSCacheEntries
+	}
+	for len(c.entries) >= max {
+		c.remove(c.lru.prev)
+		c.stats.Evictions++
+	}
+	c.entries[e.key] = e
+	c.pushFront(e)
+}
+
+func (c *DNSCache) remove(e *dnsCacheEntry) {
+	c.unlink(e)
+	delete(c.entries, e.key)
+}
+
+func (c *DNSCache) unlink(e *dnsCacheEntry) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.prev, e.next = nil, nil
+}
+
+func (c *DNSCache) pushFront(e *dnsCacheEntry) {
+	e.prev = &c.lru
+	e.next = c.lru.next
+	c.lru.next.prev = e
+	c.lru.next = e
+}
+
+// systemTTL returns how long answers from the system resolver are kept.
+func (c *DNSCache) systemTTL() time.Duration {
+	if c.SystemTTL == 0 {
+		return defaultDNSCacheSystemTTL
+	}
+	return c.SystemTTL
+}
+
+// lookupSystem returns the answer of the system resolver operation
+// op for name, from the cache if possible. Otherwise it calls lookup
+// and caches its answer when the lookup completed with either a
+// result or a not-found error. If copyVal is not nil, results are
+// copied by it on their way into and out of the cache.
+func (c *DNSCache) lookupSystem(op, name string, lookup func() (interface{}, error, bool), copyVal func(interface{}) interface{}) (interface{}, error, bool) {
+	if c == nil {
+		return lookup()
+	}
+	k := dnsCacheKey{name: name, op: op}
+	if e := c.get(k); e != nil {
+		if e.err != nil {
+			err := *e.err
+			return nil, &err, true
+		}
+		if copyVal != nil {
+			return copyVal(e.val), nil, true
+		}
+		return e.val, nil, true
+	}
+	v, err, ok := lookup()
+	if !ok {
+		return v, err, ok
+	}
+	e := &dnsCacheEntry{key: k}
+	if err != nil {
+		dnsErr, isDNSErr := err.(*DNSError)
+		if !isDNSErr || !dnsErr.IsNotFound {
+			return v, err, ok
+		}
+		errCopy := *dnsErr
+		e.err = &errCopy
+	} else if copyVal != nil {
+		e.val = copyVal(v)
+	} else {
+		e.val = v
+	}
+	c.put(e, c.systemTTL())
+	return v, err, ok
+}
+
+// dnsResponseTTL reports how long the response in p, whose header is
+// h, may be cached. A positive answer is cacheable for the smallest
+// TTL among its answer records; a negative answer for the SOA
+// minimum of RFC 2308 section 5. The ok result is false if the
+// response may not be cached. p is taken by value so that the
+// caller's parser is not advanced.
+func dnsResponseTTL(p dnsmessage.Parser, h dnsmessage.Header, qtype dnsmessage.Type) (ttl uint32, ok bool) {
+	negative := h.RCode == dnsmessage.RCodeNameError
+	if h.RCode != dnsmessage.RCodeSuccess && !negative {
+		return 0, false
+	}
+	found := false
+	ttl = ^uint32(0)
+	for {
+		rh, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return 0, false
+		}
+		if rh.Type == qtype {
+			found = true
+		}
+		if rh.TTL < ttl {
+			ttl = rh.TTL
+		}
+		if err := p.SkipAnswer(); err != nil {
+			return 0, false
+		}
+	}
+	if found && !negative {
+		return ttl, true
+	}
+
+	// A negative answer: the name does not exist, or it exists
+	// but has no records of the requested type.
+	for {
+		rh, err := p.AuthorityHeader()
+		if err != nil {
+			// No SOA record; negative answers without one
+			// must not be cached (RFC 2308 section 5).
+			return 0, false
+		}
+		if rh.Type != dnsmessage.TypeSOA {
+			if err := p.SkipAuthority(); err != nil {
+				return 0, false
+			}
+			continue
+		}
+		soa, err := p.SOAResource()
+		if err != nil {
+			return 0, false
+		}
+		ttl = rh.TTL
+		if soa.MinTTL < ttl {
+			ttl = soa.MinTTL
+		}
+		return ttl, true
+	}
+}
+
+// putAnswer caches the answer of server to the query for k. resp is
+// the response positioned at its answer section and h its header; p,
+// server and err are the results to be replayed on later lookups.
+// It does nothing if c is nil or the response may not be cached.
+func (c *DNSCache) putAnswer(k dnsCacheKey, resp dnsmessage.Parser, h dnsmessage.Header, p dnsmessage.Parser, server string, err *DNSError) {
+	if c == nil {
+		return
+	}
+	ttl, ok := dnsResponseTTL(resp, h, k.qtype)
+	if !ok {
+		return
+	}
+	e := &dnsCacheEntry{key: k, p: p, server: server}
+	if err != nil {
+		errCopy := *err
+		e.err = &errCopy
+	}
+	c.put(e, time.Duration(ttl)*time.Second)
+}
+
+// getAnswer returns the results of a lookup of Go's resolver cached
+// for k, if any.
+func (c *DNSCache) getAnswer(k dnsCacheKey) (p dnsmessage.Parser, server string, err error, ok bool) {
+	if c == nil {
+		return dnsmessage.Parser{}, "", nil, false
+	}
+	e := c.get(k)
+	if e == nil {
+		return dnsmessage.Parser{}, "", nil, false
+	}
+	if e.err != nil {
+		errCopy := *e.err
+		return e.p, e.server, &errCopy, true
+	}
+	return e.p, e.server, nil, true
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDNSCacheLookupSystem(t *testing.T) {
+	c := &DNSCache{MaxEntries: 2}
+	calls := 0
+	lookup := func(name string, err error) ([]string, error) {
+		v, err, ok := c.lookupSystem("host", name, func() (interface{}, error, bool) {
+			calls++
+			if err != nil {
+				return nil, err, true
+			}
+			return []string{"192.0.2.1"}, nil, true
+		}, copyStringsForTest)
+		if !ok {
+			t.Fatal("lookup not completed")
+		}
+		addrs, _ := v.([]string)
+		return addrs, err
+	}
+
+	for i := 0; i < 2; i++ {
+		addrs, err := lookup("a", nil)
+		if err != nil || !reflect.DeepEqual(addrs, []string{"192.0.2.1"}) {
+			t.Fatalf("lookup = %v, %v", addrs, err)
+		}
+		addrs[0] = "modified"
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls; want 1", calls)
+	}
+
+	notFound := &DNSError{Err: errNoSuchHost.Error(), Name: "b", IsNotFound: true}
+	for i := 0; i < 2; i++ {
+		_, err := lookup("b", notFound)
+		de, ok := err.(*DNSError)
+		if !ok || !de.IsNotFound {
+			t.Fatalf("lookup error = %#v; want not-found error", err)
+		}
+		if i > 0 && de == notFound {
+			t.Fatal("cached lookup returned the original error, not a copy")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls; want 2", calls)
+	}
+
+	// Temporary failures are not cached.
+	for i := 0; i < 2; i++ {
+		if _, err := lookup("c", errors.New("temporary")); err == nil {
+			t.Fatal("lookup succeeded")
+		}
+	}
+	if calls != 4 {
+		t.Errorf("got %d calls; want 4", calls)
+	}
+
+	// "a" is the least recently used entry and is evicted.
+	if _, err := lookup("d", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lookup("a", nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 6 {
+		t.Errorf("got %d calls; want 6", calls)
+	}
+	if st := c.Stats(); st.Evictions != 2 || st.Entries != 2 {
+		t.Errorf("Stats = %+v; want 2 evictions and 2 entries", st)
+	}
+
+	// Expired entries are not used.
+	c.mu.Lock()
+	for _, e := range c.entries {
+		e.expires = time.Now().Add(-time.Second)
+	}
+	c.mu.Unlock()
+	if _, err := lookup("a", nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 7 {
+		t.Errorf("got %d calls; want 7", calls)
+	}
+
+	c.SystemTTL = -1
+	c.Flush()
+	lookup("a", nil)
+	lookup("a", nil)
+	if calls != 9 {
+		t.Errorf("got %d calls with caching disabled; want 9", calls)
+	}
+}
+
+func copyStringsForTest(v interface{}) interface{} {
+	return append([]string(nil), v.([]string)...)
+}
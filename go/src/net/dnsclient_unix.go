@@ -144,6 +144,9 @@ func (r *Resolver) exchange(ctx context.Context, server string, q dnsmessage.Que
 	if err != nil {
 		return dnsmessage.Parser{}, dnsmessage.Header{}, errCannotMarshalDNSMessage
 	}
+	if r != nil && r.Transport != nil {
+		return r.transportExchange(ctx, server, id, q, udpReq, timeout)
+	}
 	var networks []string
 	if useTCP {
 		networks = []string{"tcp"}
@@ -183,6 +186,38 @@ func (r *Resolver) exchange(ctx context.Context, server string, q dnsmessage.Que
 	return dnsmessage.Parser{}, dnsmessage.Header{}, errNoAnswerFromDNSServer
 }
 
+// transportExchange sends a query to server using r.Transport.
+func (r *Resolver) transportExchange(ctx context.Context, server string, id uint16, q dnsmessage.Question, req []byte, timeout time.Duration) (dnsmessage.Parser, dnsmessage.Header, error) {
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(timeout))
+	defer cancel()
+
+	b, err := r.Transport.RoundTrip(ctx, server, req)
+	if err != nil {
+		return dnsmessage.Parser{}, dnsmessage.Header{}, mapErr(err)
+	}
+	var p dnsmessage.Parser
+	h, err := p.Start(b)
+	if err != nil {
+		return dnsmessage.Parser{}, dnsmessage.Header{}, errCannotUnmarshalDNSMessage
+	}
+	rq, err := p.Question()
+	if err != nil {
+		return dnsmessage.Parser{}, dnsmessage.Header{}, errCannotUnmarshalDNSMessage
+	}
+	if !checkResponse(id, q, h, rq) {
+		return dnsmessage.Parser{}, dnsmessage.Header{}, errInvalidDNSResponse
+	}
+	if err := p.SkipQuestion(); err != dnsmessage.ErrSectionDone {
+		return dnsmessage.Parser{}, dnsmessage.Header{}, errInvalidDNSResponse
+	}
+	if h.Truncated {
+		// Encrypted transports are stream based; there is nothing
+		// to fall back to.
+		return dnsmessage.Parser{}, dnsmessage.Header{}, errNoAnswerFromDNSServer
+	}
+	return p, h, nil
+}
+
 // checkHeader performs basic sanity checks on the header.
 func checkHeader(p *dnsmessage.Parser, h dnsmessage.Header) error {
 	if h.RCode == dnsmessage.RCodeNameError {
@@ -236,6 +271,12 @@ func skipToAnswer(p *dnsmessage.Parser, qtype dnsmessage.Type) error {
 // Do a lookup for a single name, which must be rooted
 // (otherwise answer will not find the answers).
 func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string, qtype dnsmessage.Type) (dnsmessage.Parser, string, error) {
+	cache := r.cache()
+	key := dnsCacheKey{name: name, qtype: qtype}
+	if p, server, err, ok := cache.getAnswer(key); ok {
+		return p, server, err
+	}
+
 	var lastErr error
 	serverOffset := cfg.serverOffset()
 	sLen := uint32(len(cfg.servers))
@@ -272,6 +313,7 @@ func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string,
 				lastErr = dnsErr
 				continue
 			}
+			resp := p
 
 			if err := checkHeader(&p, h); err != nil {
 				dnsErr := &DNSError{
@@ -287,6 +329,7 @@ func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string,
 					// another server won't help.
 
 					dnsErr.IsNotFound = true
+					cache.putAnswer(key, resp, h, p, server, dnsErr)
 					return p, server, dnsErr
 				}
 				lastErr = dnsErr
@@ -295,6 +338,7 @@ func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string,
 
 			err = skipToAnswer(&p, qtype)
 			if err == nil {
+				cache.putAnswer(key, resp, h, p, server, nil)
 				return p, server, nil
 			}
 			lastErr = &DNSError{
@@ -307,6 +351,7 @@ func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string,
 				// server won't help.
 
 				lastErr.(*DNSError).IsNotFound = true
+				cache.putAnswer(key, resp, h, p, server, lastErr.(*DNSError))
 				return p, server, lastErr
 			}
 		}
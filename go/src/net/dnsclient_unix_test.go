@@ -1957,3 +1957,223 @@ func TestCVE202133195(t *testing.T) {
 		t.Errorf("LookupAddr returned unexpected error, got %q, want %q", err, expected)
 	}
 }
+
+// fakeDNSTransport adapts a fakeDNSServer to the DNSTransport interface.
+type fakeDNSTransport struct {
+	server *fakeDNSServer
+
+	mu        sync.Mutex
+	addresses []string
+}
+
+func (f *fakeDNSTransport) RoundTrip(ctx context.Context, address string, query []byte) ([]byte, error) {
+	f.mu.Lock()
+	f.addresses = append(f.addresses, address)
+	f.mu.Unlock()
+
+	var q dnsmessage.Message
+	if err := q.Unpack(query); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal DNS message: %v", err)
+	}
+	d, _ := ctx.Deadline()
+	resp, err := f.server.rh("transport", address, q, d)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Pack()
+}
+
+func TestResolverTransport(t *testing.T) {
+	conf, err := newResolvConfTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conf.teardown()
+	if err := conf.writeAndUpdate([]string{
+		"nameserver 192.0.2.1",
+		"nameserver 192.0.2.2",
+		"search example.com",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tr := &fakeDNSTransport{server: &fakeDNSServer{
+		rh: func(_, s string, q dnsmessage.Message, _ time.Time) (dnsmessage.Message, error) {
+			if s == "192.0.2.1:53" {
+				return dnsmessage.Message{}, errors.New("connection refused")
+			}
+			r := dnsmessage.Message{
+				Header: dnsmessage.Header{
+					ID:       q.Header.ID,
+					Response: true,
+					RCode:    dnsmessage.RCodeSuccess,
+				},
+				Questions: q.Questions,
+			}
+			switch {
+			case q.Questions[0].Name.String() != "www.example.com.":
+				r.Header.RCode = dnsmessage.RCodeNameError
+			case q.Questions[0].Type == dnsmessage.TypeA:
+				r.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{
+						Name:   q.Questions[0].Name,
+						Type:   dnsmessage.TypeA,
+						Class:  dnsmessage.ClassINET,
+						Length: 4,
+					},
+					Body: &dnsmessage.AResource{A: [4]byte{192, 0, 2, 10}},
+				}}
+			}
+			return r, nil
+		},
+	}}
+	r := &Resolver{
+		Transport: tr,
+		Dial: func(context.Context, string, string) (Conn, error) {
+			t.Error("Dial called with Transport set")
+			return nil, errors.New("unexpected dial")
+		},
+	}
+	addrs, err := r.LookupHost(context.Background(), "www")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"192.0.2.10"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("LookupHost = %v; want %v", addrs, want)
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	var sawFirst, sawSecond bool
+	for _, a := range tr.addresses {
+		switch a {
+		case "192.0.2.1:53":
+			sawFirst = true
+		case "192.0.2.2:53":
+			sawSecond = true
+		}
+	}
+	if !sawFirst || !sawSecond {
+		t.Errorf("transport saw servers %v; want both name servers tried", tr.addresses)
+	}
+}
+
+func TestResolverCache(t *testing.T) {
+	conf, err := newResolvConfTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conf.teardown()
+	if err := conf.writeAndUpdate([]string{"nameserver 192.0.2.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	soa := dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{
+			Name:  dnsmessage.MustNewName("example.com."),
+			Type:  dnsmessage.TypeSOA,
+			Class: dnsmessage.ClassINET,
+			TTL:   3600,
+		},
+		Body: &dnsmessage.SOAResource{
+			NS:     dnsmessage.MustNewName("ns.example.com."),
+			MBox:   dnsmessage.MustNewName("hostmaster.example.com."),
+			MinTTL: 60,
+		},
+	}
+	var mu sync.Mutex
+	queries := make(map[string]int)
+	fake := fakeDNSServer{
+		rh: func(_, _ string, q dnsmessage.Message, _ time.Time) (dnsmessage.Message, error) {
+			name, qtype := q.Questions[0].Name.String(), q.Questions[0].Type
+			mu.Lock()
+			queries[name]++
+			mu.Unlock()
+			r := dnsmessage.Message{
+				Header: dnsmessage.Header{
+					ID:            q.Header.ID,
+					Response:      true,
+					Authoritative: true,
+				},
+				Questions: q.Questions,
+			}
+			switch name {
+			case "www.example.com.":
+				if qtype == dnsmessage.TypeA {
+					r.Answers = []dnsmessage.Resource{{
+						Header: dnsmessage.ResourceHeader{
+							Name:  q.Questions[0].Name,
+							Type:  dnsmessage.TypeA,
+							Class: dnsmessage.ClassINET,
+							TTL:   300,
+						},
+						Body: &dnsmessage.AResource{A: [4]byte{192, 0, 2, 10}},
+					}}
+				} else {
+					r.Authorities = []dnsmessage.Resource{soa}
+				}
+			case "nx.example.com.":
+				r.Header.RCode = dnsmessage.RCodeNameError
+				r.Authorities = []dnsmessage.Resource{soa}
+			case "nosoa.example.com.":
+				r.Header.RCode = dnsmessage.RCodeNameError
+			default:
+				r.Header.RCode = dnsmessage.RCodeServerFailure
+			}
+			return r, nil
+		},
+	}
+	cache := &DNSCache{MaxTTL: time.Minute}
+	r := &Resolver{PreferGo: true, Dial: fake.DialContext, Cache: cache}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		addrs, err := r.LookupHost(ctx, "www.example.com.")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"192.0.2.10"}; !reflect.DeepEqual(addrs, want) {
+			t.Errorf("LookupHost = %v; want %v", addrs, want)
+		}
+		for _, name := range []string{"nx.example.com.", "nosoa.example.com.", "fail.example.com."} {
+			_, err := r.LookupHost(ctx, name)
+			if err == nil {
+				t.Fatalf("LookupHost(%q) succeeded", name)
+			}
+			if de, ok := err.(*DNSError); !ok || de.Name != name {
+				t.Errorf("LookupHost(%q) error = %#v; want *DNSError for the name", name, err)
+			}
+		}
+	}
+	want := map[string]int{
+		"www.example.com.":   2, // A and AAAA, each cached
+		"nx.example.com.":    2, // NXDOMAIN with SOA, cached
+		"nosoa.example.com.": 4, // negative answer without SOA, not cached
+		"fail.example.com.":  8, // SERVFAIL, retried and not cached
+	}
+	if !reflect.DeepEqual(queries, want) {
+		t.Errorf("queries = %v; want %v", queries, want)
+	}
+	st := cache.Stats()
+	if st.Hits != 4 || st.Entries != 4 {
+		t.Errorf("Stats = %+v; want 4 hits and 4 entries", st)
+	}
+
+	// The negative TTL is the SOA minimum and all lifetimes are
+	// clamped to MaxTTL.
+	cache.mu.Lock()
+	for k, e := range cache.entries {
+		ttl := time.Until(e.expires)
+		if ttl > time.Minute || ttl < 50*time.Second {
+			t.Errorf("%v cached for %v; want about a minute", k, ttl)
+		}
+	}
+	cache.mu.Unlock()
+
+	cache.Flush()
+	if _, err := r.LookupHost(ctx, "www.example.com."); err != nil {
+		t.Fatal(err)
+	}
+	if queries["www.example.com."] != 4 {
+		t.Errorf("got %d queries after Flush; want 4", queries["www.example.com."])
+	}
+}
@@ -7,7 +7,10 @@
 
 package net
 
-import "syscall"
+import (
+	"internal/poll"
+	"syscall"
+)
 
 var (
 	testHookDialChannel  = func() {} // for golang.org/issue/5349
@@ -15,7 +18,7 @@ var (
 
 	// Placeholders for socket system calls.
 	socketFunc        func(int, int, int) (int, error)  = syscall.Socket
-	connectFunc       func(int, syscall.Sockaddr) error = syscall.Connect
+	connectFunc       func(int, syscall.Sockaddr) error = poll.Connect
 	listenFunc        func(int, int) error              = syscall.Listen
 	getsockoptIntFunc func(int, int, int) (int, error)  = syscall.GetsockoptInt
 )
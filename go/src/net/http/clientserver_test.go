@@ -109,7 +109,7 @@ func newClientServerTest(t *testing.T, h2 bool, h Handler, opts ...interface{})
 		cst.ts.Start()
 		return cst
 	}
-	ExportHttp2ConfigureServer(cst.ts.Config, nil)
+	ExportHttp2ConfigureServerFromConfig(cst.ts.Config)
 	cst.ts.TLS = cst.ts.Config.TLSConfig
 	cst.ts.StartTLS()
 
@@ -0,0 +1,104 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// AcceptConnect accepts a CONNECT request and returns the tunnel to the
+// client. It replies to the request with a 200 status code and the
+// headers already set in w.Header(), after which the returned stream
+// carries the bytes sent by the client and sends bytes back to it.
+//
+// On HTTP/1 connections the connection is hijacked, so the handler may
+// return while the tunnel is in use; closing the stream closes the
+// connection. On HTTP/2 connections the tunnel is the request's stream,
+// which ends when the handler returns: the handler must not return
+// before it is done with the tunnel. Both classic CONNECT requests and
+// extended CONNECT requests (RFC 8441), which name the tunneled
+// protocol in r.Proto, are supported over HTTP/2.
+//
+// AcceptConnect returns an error, without replying to the request, if
+// r is not a CONNECT request or w does not support tunneling.
+// AcceptConnect接受一个CONNECT请求并返回到客户端的隧道。
+func AcceptConnect(w ResponseWriter, r *Request) (io.ReadWriteCloser, error) {
+	if r.Method != MethodConnect {
+		return nil, errors.New("http: AcceptConnect called for " + r.Method + " request")
+	}
+	if r.ProtoMajor == 2 {
+		f, ok := w.(Flusher)
+		if !ok {
+			return nil, errors.New("http: ResponseWriter does not implement Flusher")
+		}
+		w.WriteHeader(StatusOK)
+		f.Flush()
+		return &connectStream{body: r.Body, w: w, f: f}, nil
+	}
+
+	hj, ok := w.(Hijacker)
+	if !ok {
+		return nil, errors.New("http: ResponseWriter does not implement Hijacker")
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// The tunnel outlives the request, so the server's deadlines
+	// for it no longer apply.
+	conn.SetDeadline(time.Time{})
+
+	brw.WriteString("HTTP/1.1 200 Connection established\r\n")
+	w.Header().Write(brw)
+	brw.WriteString("\r\n")
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &hijackedTunnel{Conn: conn, br: brw.Reader}, nil
+}
+
+// isExtendedConnect reports whether r is an extended CONNECT request
+// (RFC 8441), whose Proto names the protocol being bootstrapped.
+func isExtendedConnect(r *Request) bool {
+	return r.Method == MethodConnect && r.ProtoMajor == 2 &&
+		r.Proto != "" && !strings.HasPrefix(r.Proto, "HTTP/")
+}
+
+// connectStream is the tunnel of an HTTP/2 CONNECT request.
+type connectStream struct {
+	body io.ReadCloser
+	w    io.Writer
+	f    Flusher
+}
+
+func (s *connectStream) Read(p []byte) (int, error) { return s.body.Read(p) }
+
+func (s *connectStream) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	s.f.Flush()
+	return n, nil
+}
+
+// Close stops reading from the client. The server's side of the
+// stream is closed when the handler returns.
+func (s *connectStream) Close() error { return s.body.Close() }
+
+// hijackedTunnel is the tunnel of an HTTP/1 CONNECT request. Reads
+// drain any data the server had already buffered from the client.
+type hijackedTunnel struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+func (t *hijackedTunnel) Read(p []byte) (int, error) { return t.br.Read(p) }
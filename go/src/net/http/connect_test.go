@@ -0,0 +1,225 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	. "net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// echoTunnel accepts CONNECT requests and echoes the tunneled bytes.
+func echoTunnel(t *testing.T) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		rwc, err := AcceptConnect(w, r)
+		if err != nil {
+			t.Errorf("AcceptConnect: %v", err)
+			return
+		}
+		defer rwc.Close()
+		io.Copy(rwc, rwc)
+	})
+}
+
+func TestAcceptConnectHTTP1(t *testing.T) {
+	setParallel(t)
+	defer afterTest(t)
+	cst := newClientServerTest(t, h1Mode, echoTunnel(t))
+	defer cst.close()
+
+	c, err := net.Dial("tcp", cst.ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	// The tunneled bytes follow the request immediately, so the
+	// server has them buffered when it hijacks the connection.
+	io.WriteString(c, "CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\nhello")
+	br := bufio.NewReader(c)
+	res, err := ReadResponse(br, &Request{Method: "CONNECT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != StatusOK {
+		t.Fatalf("status = %v; want 200", res.Status)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(br, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hello" {
+		t.Errorf("read %q; want %q", buf, "hello")
+	}
+	io.WriteString(c, "again")
+	if _, err := io.ReadFull(br, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "again" {
+		t.Errorf("read %q; want %q", buf, "again")
+	}
+}
+
+func TestAcceptConnectNotConnect(t *testing.T) {
+	setParallel(t)
+	defer afterTest(t)
+	cst := newClientServerTest(t, h1Mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		if _, err := AcceptConnect(w, r); err == nil {
+			t.Error("AcceptConnect succeeded for GET request")
+		}
+	}))
+	defer cst.close()
+	res, err := cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+}
+
+// dialTunnel sends a CONNECT request with the given Proto and returns
+// the tunnel's response, along with the writer for the client side.
+func dialTunnel(t *testing.T, cst *clientServerTest, url, proto string) (*Response, *io.PipeWriter) {
+	pr, pw := io.Pipe()
+	req, err := NewRequest("CONNECT", url, pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proto != "" {
+		req.Proto = proto
+	}
+	res, err := cst.tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != StatusOK {
+		t.Fatalf("status = %v; want 200", res.Status)
+	}
+	return res, pw
+}
+
+func checkEcho(t *testing.T, res *Response, pw *io.PipeWriter) {
+	for _, msg := range []string{"hello", "world"} {
+		if _, err := io.WriteString(pw, msg); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, len(msg))
+		if _, err := io.ReadFull(res.Body, buf); err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != msg {
+			t.Errorf("read %q; want %q", buf, msg)
+		}
+	}
+	pw.Close()
+	if b, err := io.ReadAll(res.Body); err != nil || len(b) != 0 {
+		t.Errorf("after close, read %q, %v; want EOF", b, err)
+	}
+	res.Body.Close()
+}
+
+func TestExtendedConnectHTTP2(t *testing.T) {
+	setParallel(t)
+	defer afterTest(t)
+	mux := NewServeMux()
+	mux.HandleFunc("/chat", func(w ResponseWriter, r *Request) {
+		if r.Proto != "echo" || r.ProtoMajor != 2 {
+			t.Errorf("Proto = %q, ProtoMajor = %v; want echo, 2", r.Proto, r.ProtoMajor)
+		}
+		echoTunnel(t).ServeHTTP(w, r)
+	})
+	cst := newClientServerTest(t, h2Mode, mux, enableExtendedConnect)
+	defer cst.close()
+
+	res, pw := dialTunnel(t, cst, cst.ts.URL+"/chat", "echo")
+	if res.ProtoMajor != 2 {
+		t.Errorf("response ProtoMajor = %v; want 2", res.ProtoMajor)
+	}
+	checkEcho(t, res, pw)
+}
+
+// enableExtendedConnect is a newClientServerTest option that makes the
+// server accept extended CONNECT requests.
+func enableExtendedConnect(ts *httptest.Server) {
+	ts.Config.HTTP2 = &HTTP2Config{EnableExtendedConnectProtocol: true}
+}
+
+func TestExtendedConnectDisabledByDefault(t *testing.T) {
+	setParallel(t)
+	defer afterTest(t)
+	cst := newClientServerTest(t, h2Mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		t.Error("unexpected request")
+	}))
+	defer cst.close()
+
+	req, err := NewRequest("CONNECT", cst.ts.URL+"/chat", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Proto = "echo"
+	_, err = cst.tr.RoundTrip(req)
+	if err == nil || !strings.Contains(err.Error(), "extended CONNECT not supported") {
+		t.Errorf("RoundTrip error = %v; want extended CONNECT not supported", err)
+	}
+}
+
+func TestConnectHTTP2(t *testing.T) {
+	setParallel(t)
+	defer afterTest(t)
+	cst := newClientServerTest(t, h2Mode, echoTunnel(t))
+	defer cst.close()
+
+	res, pw := dialTunnel(t, cst, cst.ts.URL, "")
+	checkEcho(t, res, pw)
+}
+
+func TestExtendedConnectRequiresHTTP2(t *testing.T) {
+	setParallel(t)
+	defer afterTest(t)
+	cst := newClientServerTest(t, h1Mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		t.Error("unexpected request")
+	}))
+	defer cst.close()
+
+	req, err := NewRequest("CONNECT", cst.ts.URL+"/chat", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Proto = "echo"
+	_, err = cst.tr.RoundTrip(req)
+	if err == nil || !strings.Contains(err.Error(), "HTTP/2") {
+		t.Errorf("RoundTrip error = %v; want extended CONNECT error", err)
+	}
+}
+
+func TestServeMuxConnectEmptyPath(t *testing.T) {
+	mux := NewServeMux()
+	mux.Handle("/", echoTunnel(t))
+	tests := []struct {
+		name        string
+		proto       string
+		protoMajor  int
+		wantPattern string
+	}{
+		{"HTTP/1 CONNECT", "HTTP/1.1", 1, ""},
+		{"HTTP/2 CONNECT", "HTTP/2.0", 2, ""},
+		{"extended CONNECT", "echo", 2, "/"},
+	}
+	for _, tt := range tests {
+		r := &Request{
+			Method:     "CONNECT",
+			Host:       "example.com:443",
+			URL:        &url.URL{Host: "example.com:443"},
+			Proto:      tt.proto,
+			ProtoMajor: tt.protoMajor,
+		}
+		if _, pattern := mux.Handler(r); pattern != tt.wantPattern {
+			t.Errorf("%s: pattern = %q; want %q", tt.name, pattern, tt.wantPattern)
+		}
+	}
+}
@@ -103,5 +103,18 @@ functions. Manually configuring HTTP/2 via the golang.org/x/net/http2
 package takes precedence over the net/http package's built-in HTTP/2
 support.
 
+The built-in HTTP/2 support is tuned with the HTTP2 field of Server and
+Transport, which sets limits such as the number of concurrent streams
+and flow control window sizes, and enables PING-based health checks
+that detect dead connections:
+
+	tr := &http.Transport{
+		ForceAttemptHTTP2: true,
+		HTTP2: &http.HTTP2Config{
+			ReadIdleTimeout: 30 * time.Second,
+			PingTimeout:     10 * time.Second,
+		},
+	}
+
 */
 package http
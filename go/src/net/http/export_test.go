@@ -38,6 +38,16 @@ var (
 
 const MaxWriteWaitBeforeConnReuse = maxWriteWaitBeforeConnReuse
 
+// ExportHttp2ConfigureServerFromConfig configures HTTP/2 on s the way
+// the server would by default, applying s.HTTP2 if set.
+func ExportHttp2ConfigureServerFromConfig(s *Server) error {
+	conf := new(http2Server)
+	if s.HTTP2 != nil {
+		s.HTTP2.configureServer(conf)
+	}
+	return http2ConfigureServer(s, conf)
+}
+
 func init() {
 	// We only want to pay for this cost during testing.
 	// When not under test, these values are always nil
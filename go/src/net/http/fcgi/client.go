@@ -0,0 +1,824 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fcgi
+
+// This file implements FastCGI from the perspective of the web server,
+// talking to an application such as PHP-FPM.
+// 这个文件从web服务器的角度实现了FastCGI，用于和PHP-FPM这类应用程序通信。
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// DefaultMaxIdleConns is the default value of Client's MaxIdleConns.
+const DefaultMaxIdleConns = 2
+
+// Client is a FastCGI client. It implements http.RoundTripper by
+// forwarding each request to a FastCGI responder, such as a PHP-FPM pool,
+// and converting the CGI response written by the responder back into an
+// *http.Response.
+//
+// Connections to the responder are kept open and reused for future
+// requests. If Multiplex is set, concurrent requests share a single
+// connection, distinguished by their FastCGI request IDs.
+//
+// A Client is safe for concurrent use by multiple goroutines.
+type Client struct {
+	// Network and Address name the responder, as accepted by
+	// net.Dial. For example "tcp" and "127.0.0.1:9000", or
+	// "unix" and "/run/php/php-fpm.sock".
+	Network string
+	Address string
+
+	// Dial optionally specifies the dial function for creating
+	// connections to the responder.
+	// If Dial is nil, a net.Dialer is used.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// Path is the script to execute, sent to the responder as
+	// SCRIPT_FILENAME. Requests are relative to Root, which is
+	// sent as SCRIPT_NAME; the remainder of the URL path is sent
+	// as PATH_INFO. This matches the environment built by
+	// cgi.Handler.
+	//
+	// If Path is empty, the script is instead located by
+	// joining DocumentRoot with the request's URL path, and
+	// PATH_INFO is left empty.
+	Path string
+	Root string // root URI prefix of the script or empty for "/"
+
+	// DocumentRoot is sent as DOCUMENT_ROOT and, if Path is
+	// empty, used to build SCRIPT_FILENAME.
+	DocumentRoot string
+
+	Env    []string  // extra parameters to set, if any, as "key=value"
+	Stderr io.Writer // optional writer for the responder's stderr stream; nil means os.Stderr
+
+	// Multiplex specifies whether concurrent requests may share a
+	// connection. Only enable it if the responder reports
+	// FCGI_MPXS_CONNS; many implementations, including PHP-FPM,
+	// do not support multiplexing. As FastCGI has no flow control,
+	// a response body with 1MB of unread data stops the responder
+	// from sending any of the responses sharing its connection
+	// until the body is read or closed.
+	Multiplex bool
+
+	// DisableKeepAlives, if true, asks the responder to close the
+	// connection after each request.
+	DisableKeepAlives bool
+
+	// MaxIdleConns controls the maximum number of idle connections
+	// to keep. If zero, DefaultMaxIdleConns is used.
+	MaxIdleConns int
+
+	mu     sync.Mutex
+	conns  []*clientConn // all open connections
+	closed bool
+}
+
+// clientConn is a connection to a FastCGI responder.
+// clientConn是一个到FastCGI响应者的连接。
+type clientConn struct {
+	client *Client
+	conn   *conn
+
+	// The following fields are guarded by client.mu.
+	reqs   map[uint16]*clientRequest // keyed by request ID		// 请求Id作为key
+	nextID uint16
+	broken bool // no new requests may be started on the connection
+}
+
+// clientRequest holds the state of a request in flight on a clientConn.
+// clientRequest保存一个在clientConn上进行中的请求的状态。
+type clientRequest struct {
+	cc     *clientConn
+	id     uint16
+	stdout *stdoutBuffer // responder's stdout stream
+	stderr io.Writer
+
+	done     chan struct{} // closed when the end request record was read or the conn failed
+	doneOnce sync.Once
+}
+
+// errCantMultiplex is returned by reads from a response body when the
+// responder rejected a request because another one was in flight on the
+// same connection.
+var errCantMultiplex = errors.New("fcgi: responder cannot multiplex connections")
+
+var errClientClosed = errors.New("fcgi: client closed")
+
+// maxBufferedBody is the largest request body of unknown length that
+// RoundTrip reads into memory to learn its length.
+const maxBufferedBody = 10 << 20
+
+var errBodyTooLarge = errors.New("fcgi: request body of unknown length exceeds 10MB")
+
+func (c *Client) stderr() io.Writer {
+	if c.Stderr != nil {
+		return c.Stderr
+	}
+	return os.Stderr
+}
+
+func (c *Client) maxIdleConns() int {
+	if c.MaxIdleConns > 0 {
+		return c.MaxIdleConns
+	}
+	return DefaultMaxIdleConns
+}
+
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	if c.Dial != nil {
+		return c.Dial(ctx, c.Network, c.Address)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, c.Network, c.Address)
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+//
+// The request's body, if any, is streamed to the responder as FCGI_STDIN
+// and the response body streams the responder's FCGI_STDOUT. Responders
+// ignore a body whose length is not sent, so a body of unknown length
+// is read into memory first; RoundTrip fails if it exceeds 10MB. The
+// caller must close the response body; closing it before reading it to
+// EOF aborts the request.
+func (c *Client) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL == nil {
+		closeBody(req)
+		return nil, errors.New("fcgi: nil Request.URL")
+	}
+	if req.Header == nil {
+		closeBody(req)
+		return nil, errors.New("fcgi: nil Request.Header")
+	}
+	reqBody, contentLength, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	ctx := req.Context()
+	cr, err := c.startRequest(ctx)
+	if err != nil {
+		closeBody(req)
+		return nil, err
+	}
+	cc := cr.cc
+
+	flags := uint8(flagKeepConn)
+	if c.DisableKeepAlives {
+		flags = 0
+	}
+	if err := cc.writeBeginRequest(cr.id, roleResponder, flags); err != nil {
+		closeBody(req)
+		cc.fail(err)
+		return nil, err
+	}
+	if err := cc.conn.writePairs(typeParams, cr.id, c.params(req, contentLength)); err != nil {
+		closeBody(req)
+		cc.fail(err)
+		return nil, err
+	}
+	go cr.writeStdin(reqBody)
+	go cr.watchContext(ctx)
+
+	br := bufio.NewReader(cr.stdout)
+	res, err := readResponse(br, req)
+	if err != nil {
+		if cerr := ctx.Err(); cerr != nil {
+			err = cerr
+		}
+		cr.abort(err)
+		return nil, err
+	}
+	res.Body = &body{br: br, cr: cr}
+	return res, nil
+}
+
+// CloseIdleConnections closes any connections which were previously
+// connected from previous requests but are now sitting idle.
+// It does not interrupt any connections currently in use.
+func (c *Client) CloseIdleConnections() {
+	c.mu.Lock()
+	var idle []*clientConn
+	for _, cc := range c.conns {
+		if len(cc.reqs) == 0 {
+			cc.broken = true
+			idle = append(idle, cc)
+		}
+	}
+	c.mu.Unlock()
+	for _, cc := range idle {
+		cc.conn.Close()
+	}
+}
+
+// Close closes all connections to the responder, interrupting any
+// requests in flight. The Client must not be used after Close.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	conns := append([]*clientConn(nil), c.conns...)
+	c.mu.Unlock()
+	for _, cc := range conns {
+		cc.fail(errClientClosed)
+	}
+	return nil
+}
+
+// startRequest reserves a request ID on an existing connection or
+// dials a new one.
+func (c *Client) startRequest(ctx context.Context) (*clientRequest, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, errClientClosed
+	}
+	for _, cc := range c.conns {
+		if cc.broken {
+			continue
+		}
+		if len(cc.reqs) == 0 || c.Multiplex && len(cc.reqs) < 1<<16-1 {
+			cr := cc.newRequestLocked()
+			c.mu.Unlock()
+			return cr, nil
+		}
+	}
+	c.mu.Unlock()
+
+	nc, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cc := &clientConn{
+		client: c,
+		conn:   newConn(nc),
+		reqs:   make(map[uint16]*clientRequest),
+	}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		nc.Close()
+		return nil, errClientClosed
+	}
+	c.conns = append(c.conns, cc)
+	cr := cc.newRequestLocked()
+	c.mu.Unlock()
+	go cc.readLoop()
+	return cr, nil
+}
+
+// newRequestLocked allocates an unused request ID on cc.
+// The caller must hold cc.client.mu.
+func (cc *clientConn) newRequestLocked() *clientRequest {
+	for {
+		cc.nextID++
+		if cc.nextID == 0 {
+			// ID 0 is reserved for management records.
+			cc.nextID = 1
+		}
+		if _, ok := cc.reqs[cc.nextID]; !ok {
+			break
+		}
+	}
+	cr := &clientRequest{
+		cc:     cc,
+		id:     cc.nextID,
+		stderr: cc.client.stderr(),
+		stdout: newStdoutBuffer(),
+		done:   make(chan struct{}),
+	}
+	cc.reqs[cr.id] = cr
+	return cr
+}
+
+func (cc *clientConn) writeBeginRequest(reqId uint16, role uint16, flags uint8) error {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint16(b, role)
+	b[2] = flags
+	return cc.conn.writeRecord(typeBeginRequest, reqId, b)
+}
+
+// readLoop reads records from the responder and dispatches them to the
+// requests in flight until the connection fails.
+func (cc *clientConn) readLoop() {
+	var rec record
+	for {
+		if err := rec.read(cc.conn.rwc); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			cc.fail(err)
+			return
+		}
+		if err := cc.handleRecord(&rec); err != nil {
+			cc.fail(err)
+			return
+		}
+	}
+}
+
+func (cc *clientConn) handleRecord(rec *record) error {
+	cc.client.mu.Lock()
+	cr := cc.reqs[rec.h.Id]
+	cc.client.mu.Unlock()
+	if cr == nil {
+		// Records for aborted or unknown requests are ignored,
+		// as are management records we did not ask for.
+		return nil
+	}
+
+	switch rec.h.Type {
+	case typeStdout:
+		if content := rec.content(); len(content) > 0 {
+			// Errors are ignored: the body was closed early and
+			// the remaining output is discarded.
+			cr.stdout.Write(content)
+		}
+		return nil
+	case typeStderr:
+		if content := rec.content(); len(content) > 0 {
+			cr.stderr.Write(content)
+		}
+		return nil
+	case typeEndRequest:
+		content := rec.content()
+		if len(content) != 8 {
+			return errors.New("fcgi: invalid end request record")
+		}
+		appStatus := binary.BigEndian.Uint32(content)
+		var err error
+		switch content[4] {
+		case statusRequestComplete:
+			// Exit status of the application is informational;
+			// the CGI Status header carries the HTTP status.
+		case statusCantMultiplex:
+			err = errCantMultiplex
+		case statusOverloaded:
+			err = errors.New("fcgi: responder overloaded")
+		case statusUnknownRole:
+			err = errors.New("fcgi: responder does not implement the responder role")
+		default:
+			err = fmt.Errorf("fcgi: unknown protocol status %d (app status %d)", content[4], appStatus)
+		}
+		cc.finish(cr, err)
+		return nil
+	default:
+		return nil
+	}
+}
+
+// finish removes cr from the connection after its end request record
+// has been read, and releases the connection if it is now idle.
+func (cc *clientConn) finish(cr *clientRequest, err error) {
+	c := cc.client
+	c.mu.Lock()
+	delete(cc.reqs, cr.id)
+	closeConn := false
+	if c.DisableKeepAlives {
+		cc.broken = true
+		closeConn = len(cc.reqs) == 0
+	} else if len(cc.reqs) == 0 && c.idleConnsLocked() > c.maxIdleConns() {
+		cc.broken = true
+		closeConn = true
+	}
+	if closeConn {
+		c.removeConnLocked(cc)
+	}
+	c.mu.Unlock()
+
+	cr.end(err)
+	if closeConn {
+		cc.conn.Close()
+	}
+}
+
+// fail closes the connection and ends every request in flight on it
+// with err.
+func (cc *clientConn) fail(err error) {
+	c := cc.client
+	c.mu.Lock()
+	cc.broken = true
+	c.removeConnLocked(cc)
+	reqs := cc.reqs
+	cc.reqs = make(map[uint16]*clientRequest)
+	c.mu.Unlock()
+
+	cc.conn.Close()
+	for _, cr := range reqs {
+		cr.end(err)
+	}
+}
+
+// idleConnsLocked returns the number of connections with no requests
+// in flight. The caller must hold c.mu.
+func (c *Client) idleConnsLocked() int {
+	n := 0
+	for _, cc := range c.conns {
+		if !cc.broken && len(cc.reqs) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *Client) removeConnLocked(cc *clientConn) {
+	for i, v := range c.conns {
+		if v == cc {
+			copy(c.conns[i:], c.conns[i+1:])
+			c.conns[len(c.conns)-1] = nil
+			c.conns = c.conns[:len(c.conns)-1]
+			return
+		}
+	}
+}
+
+// end closes the responder's stdout stream with err, or io.EOF if err
+// is nil.
+func (cr *clientRequest) end(err error) {
+	cr.doneOnce.Do(func() {
+		// Close done first so that a reader seeing EOF never
+		// aborts a request that has already ended.
+		close(cr.done)
+		cr.stdout.closeWithError(err)
+	})
+}
+
+// abort asks the responder to stop processing the request, removes it
+// from its connection and makes reads from the response body fail
+// with err.
+func (cr *clientRequest) abort(err error) {
+	cr.stdout.abort(err)
+	select {
+	case <-cr.done:
+		return
+	default:
+	}
+	cc := cr.cc
+	switch werr := cc.conn.writeRecord(typeAbortRequest, cr.id, nil); {
+	case werr != nil:
+		cc.fail(werr)
+	case cc.client.Multiplex:
+		// Records the responder still sends for the request are
+		// ignored once it is removed. Its ID is not reused until
+		// all others have been.
+		cc.finish(cr, err)
+	default:
+		// The responder may still be busy with the request, so
+		// the connection cannot carry the next one.
+		cc.fail(err)
+	}
+}
+
+// writeStdin streams the request body to the responder.
+func (cr *clientRequest) writeStdin(body io.ReadCloser) {
+	w := newWriter(cr.cc.conn, typeStdin, cr.id)
+	if body != nil {
+		_, err := io.Copy(w, body)
+		body.Close()
+		if err != nil {
+			cr.cc.conn.writeRecord(typeAbortRequest, cr.id, nil)
+			return
+		}
+	}
+	// Closing w sends the empty record that terminates the stream.
+	if err := w.Close(); err != nil {
+		cr.cc.fail(err)
+	}
+}
+
+// watchContext aborts the request if ctx is canceled before the
+// request ends.
+func (cr *clientRequest) watchContext(ctx context.Context) {
+	select {
+	case <-cr.done:
+	case <-ctx.Done():
+		cr.abort(ctx.Err())
+	}
+}
+
+// maxBufferedStdout is the amount of a response body that is read from
+// the connection ahead of the reader of the body.
+const maxBufferedStdout = 1 << 20
+
+// stdoutBuffer holds the responder's stdout stream for one request.
+// Unlike an io.Pipe, it lets the connection's readLoop run ahead of a
+// body that is read slowly, but only by maxBufferedStdout bytes. Once
+// that much is buffered, writes block, which stops the readLoop from
+// reading the connection until the body is read or closed, and so
+// stops the responder. FastCGI has no flow control of its own, so the
+// other requests sharing the connection wait as well.
+type stdoutBuffer struct {
+	mu   sync.Mutex
+	cond sync.Cond // signaled when data arrives, is read, or the stream ends
+	buf  bytes.Buffer
+	err  error // set when the stream ends
+}
+
+func newStdoutBuffer() *stdoutBuffer {
+	b := new(stdoutBuffer)
+	b.cond.L = &b.mu
+	return b
+}
+
+// Write appends p to the stream, waiting while the buffer is full. It
+// discards p if the stream has ended.
+func (b *stdoutBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.buf.Len() >= maxBufferedStdout && b.err == nil {
+		b.cond.Wait()
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	b.buf.Write(p)
+	b.cond.Broadcast()
+	return len(p), nil
+}
+
+// Read reads buffered data, waiting for some to arrive if necessary.
+// Once the stream has ended and the buffer is drained, it returns the
+// error the stream ended with.
+func (b *stdoutBuffer) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.buf.Len() == 0 && b.err == nil {
+		b.cond.Wait()
+	}
+	if b.buf.Len() > 0 {
+		b.cond.Broadcast()
+		return b.buf.Read(p)
+	}
+	return 0, b.err
+}
+
+// closeWithError ends the stream with err, or io.EOF if err is nil.
+// Data already buffered can still be read. Only the first error is kept.
+func (b *stdoutBuffer) closeWithError(err error) {
+	if err == nil {
+		err = io.EOF
+	}
+	b.mu.Lock()
+	if b.err == nil {
+		b.err = err
+	}
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// abort ends the stream with err, discarding any buffered data.
+func (b *stdoutBuffer) abort(err error) {
+	b.mu.Lock()
+	b.buf = bytes.Buffer{}
+	b.mu.Unlock()
+	b.closeWithError(err)
+}
+
+// body is the response body. It reads the responder's FCGI_STDOUT
+// stream following the CGI headers.
+type body struct {
+	br *bufio.Reader
+	cr *clientRequest
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	return b.br.Read(p)
+}
+
+func (b *body) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.cr.abort(errBodyClosed)
+	return nil
+}
+
+var errBodyClosed = errors.New("fcgi: read on closed response body")
+
+// requestBody returns the body of req to send as FCGI_STDIN and its
+// length. A body of unknown length is read into memory, as responders
+// such as PHP-FPM ignore a body that comes without CONTENT_LENGTH.
+// The returned body is nil if there is nothing to send.
+func requestBody(req *http.Request) (io.ReadCloser, int64, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, 0, nil
+	}
+	if req.ContentLength > 0 {
+		return req.Body, req.ContentLength, nil
+	}
+	// A ContentLength of -1, or of 0 with a non-nil Body, means
+	// the length is unknown.
+	b, err := io.ReadAll(io.LimitReader(req.Body, maxBufferedBody+1))
+	req.Body.Close()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(b) > maxBufferedBody {
+		return nil, 0, errBodyTooLarge
+	}
+	if len(b) == 0 {
+		return nil, 0, nil
+	}
+	return io.NopCloser(bytes.NewReader(b)), int64(len(b)), nil
+}
+
+// params returns the FastCGI parameters for req. They are the CGI
+// environment described in RFC 3875, as built by cgi.Handler.
+func (c *Client) params(req *http.Request, contentLength int64) map[string]string {
+	root := c.Root
+	if root == "" {
+		root = "/"
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	serverName, port := host, ""
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		serverName, port = h, p
+	}
+	if port == "" {
+		if req.TLS != nil || req.URL.Scheme == "https" {
+			port = "443"
+		} else {
+			port = "80"
+		}
+	}
+	requestURI := req.RequestURI
+	if requestURI == "" {
+		requestURI = req.URL.RequestURI()
+	}
+	proto := req.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+
+	params := map[string]string{
+		"SERVER_SOFTWARE":   "go",
+		"SERVER_NAME":       serverName,
+		"SERVER_PROTOCOL":   proto,
+		"SERVER_PORT":       port,
+		"HTTP_HOST":         host,
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"REQUEST_METHOD":    req.Method,
+		"QUERY_STRING":      req.URL.RawQuery,
+		"REQUEST_URI":       requestURI,
+		"DOCUMENT_ROOT":     c.DocumentRoot,
+	}
+	if params["REQUEST_METHOD"] == "" {
+		params["REQUEST_METHOD"] = "GET"
+	}
+
+	if c.Path != "" {
+		pathInfo := req.URL.Path
+		if root != "/" && strings.HasPrefix(pathInfo, root) {
+			pathInfo = pathInfo[len(root):]
+		}
+		params["SCRIPT_FILENAME"] = c.Path
+		params["SCRIPT_NAME"] = root
+		params["PATH_INFO"] = pathInfo
+	} else {
+		scriptName := path.Clean("/" + req.URL.Path)
+		params["SCRIPT_FILENAME"] = strings.TrimSuffix(c.DocumentRoot, "/") + scriptName
+		params["SCRIPT_NAME"] = scriptName
+		params["PATH_INFO"] = ""
+	}
+
+	if req.RemoteAddr != "" {
+		if remoteIP, remotePort, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+			params["REMOTE_ADDR"] = remoteIP
+			params["REMOTE_HOST"] = remoteIP
+			params["REMOTE_PORT"] = remotePort
+		} else {
+			params["REMOTE_ADDR"] = req.RemoteAddr
+			params["REMOTE_HOST"] = req.RemoteAddr
+		}
+	}
+
+	if req.TLS != nil || req.URL.Scheme == "https" {
+		params["HTTPS"] = "on"
+	}
+
+	for k, v := range req.Header {
+		k = strings.Map(upperCaseAndUnderscore, k)
+		if k == "PROXY" {
+			// See Issue 16405
+			continue
+		}
+		joinStr := ", "
+		if k == "COOKIE" {
+			joinStr = "; "
+		}
+		params["HTTP_"+k] = strings.Join(v, joinStr)
+	}
+
+	params["CONTENT_LENGTH"] = strconv.FormatInt(contentLength, 10)
+	if ctype := req.Header.Get("Content-Type"); ctype != "" {
+		params["CONTENT_TYPE"] = ctype
+	}
+
+	for _, e := range c.Env {
+		if eq := strings.IndexByte(e, '='); eq != -1 {
+			params[e[:eq]] = e[eq+1:]
+		}
+	}
+	return params
+}
+
+// readResponse reads the CGI response headers written by the responder
+// from br. The response body is the remainder of br.
+func readResponse(br *bufio.Reader, req *http.Request) (*http.Response, error) {
+	tp := textproto.NewReader(br)
+	mh, err := tp.ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF {
+			return nil, errors.New("fcgi: no headers")
+		}
+		return nil, fmt.Errorf("fcgi: error reading headers: %w", err)
+	}
+	header := http.Header(mh)
+	for k := range header {
+		if !httpguts.ValidHeaderFieldName(k) {
+			return nil, fmt.Errorf("fcgi: invalid header name: %q", k)
+		}
+	}
+
+	res := &http.Response{
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		ContentLength: -1,
+		Request:       req,
+	}
+	if status := header.Get("Status"); status != "" {
+		header.Del("Status")
+		if len(status) < 3 {
+			return nil, fmt.Errorf("fcgi: bogus status (short): %q", status)
+		}
+		code, err := strconv.Atoi(status[:3])
+		if err != nil || code < 100 {
+			return nil, fmt.Errorf("fcgi: bogus status: %q", status)
+		}
+		res.StatusCode = code
+		res.Status = textproto.TrimString(status)
+		if len(res.Status) == 3 {
+			res.Status += " " + http.StatusText(code)
+		}
+	} else if header.Get("Location") != "" {
+		res.StatusCode = http.StatusFound
+	} else {
+		res.StatusCode = http.StatusOK
+	}
+	if res.Status == "" {
+		res.Status = strconv.Itoa(res.StatusCode) + " " + http.StatusText(res.StatusCode)
+	}
+	if cl := header.Get("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil && n >= 0 {
+			res.ContentLength = n
+		}
+	}
+	return res, nil
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+func upperCaseAndUnderscore(r rune) rune {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return r - ('a' - 'A')
+	case r == '-':
+		return '_'
+	case r == '=':
+		return '_'
+	}
+	return r
+}
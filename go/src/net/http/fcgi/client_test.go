@@ -0,0 +1,411 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fcgi
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestResponder starts a FastCGI responder on a local TCP listener
+// and returns a Client connected to it.
+func newTestResponder(t *testing.T, h http.Handler) *Client {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go Serve(ln, h)
+	c := &Client{Network: "tcp", Address: ln.Addr().String()}
+	t.Cleanup(func() {
+		c.Close()
+		ln.Close()
+	})
+	return c
+}
+
+func TestClientRoundTrip(t *testing.T) {
+	c := newTestResponder(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		env := ProcessEnv(r)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Script", env["SCRIPT_FILENAME"])
+		w.Header().Set("X-Remote-User", env["REMOTE_USER"])
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s %s %s %s", r.Method, r.URL.Path, r.Header.Get("X-Foo"), body)
+	}))
+	c.Path = "/srv/app/index.php"
+	c.Root = "/app"
+	c.Env = []string{"REMOTE_USER=gopher"}
+
+	req, err := http.NewRequest("POST", "http://example.com/app/foo?x=1", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Foo", "bar")
+	res, err := c.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		t.Errorf("StatusCode = %d; want %d", res.StatusCode, http.StatusCreated)
+	}
+	if got, want := res.Header.Get("X-Script"), "/srv/app/index.php"; got != want {
+		t.Errorf("SCRIPT_FILENAME = %q; want %q", got, want)
+	}
+	if got, want := res.Header.Get("X-Remote-User"), "gopher"; got != want {
+		t.Errorf("REMOTE_USER = %q; want %q", got, want)
+	}
+	if res.Header.Get("Status") != "" {
+		t.Errorf("Status header was not removed from the response")
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), "POST /app/foo bar hello"; got != want {
+		t.Errorf("body = %q; want %q", got, want)
+	}
+}
+
+func TestClientParams(t *testing.T) {
+	c := &Client{DocumentRoot: "/var/www/"}
+	req, _ := http.NewRequest("GET", "https://example.com:8443/dir/index.php?a=b", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("Cookie", "a=1")
+	req.Header.Add("Cookie", "b=2")
+	params := c.params(req, 0)
+	want := map[string]string{
+		"SCRIPT_FILENAME": "/var/www/dir/index.php",
+		"SCRIPT_NAME":     "/dir/index.php",
+		"PATH_INFO":       "",
+		"DOCUMENT_ROOT":   "/var/www/",
+		"QUERY_STRING":    "a=b",
+		"REQUEST_URI":     "/dir/index.php?a=b",
+		"REQUEST_METHOD":  "GET",
+		"SERVER_NAME":     "example.com",
+		"SERVER_PORT":     "8443",
+		"HTTPS":           "on",
+		"REMOTE_ADDR":     "192.0.2.1",
+		"REMOTE_PORT":     "1234",
+		"HTTP_COOKIE":     "a=1; b=2",
+		"CONTENT_LENGTH":  "0",
+	}
+	for k, v := range want {
+		if got, ok := params[k]; !ok || got != v {
+			t.Errorf("params[%q] = %q, %v; want %q", k, got, ok, v)
+		}
+	}
+}
+
+func TestClientKeepAlive(t *testing.T) {
+	c := newTestResponder(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	var conns []*clientConn
+	for i := 0; i < 3; i++ {
+		res, err := c.RoundTrip(mustNewRequest(t, "GET", "http://example.com/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(res.Body); err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		waitIdle(t, c)
+		c.mu.Lock()
+		conns = append(conns, c.conns...)
+		c.mu.Unlock()
+	}
+	for _, cc := range conns[1:] {
+		if cc != conns[0] {
+			t.Fatalf("connection was not reused")
+		}
+	}
+}
+
+func TestClientMultiplex(t *testing.T) {
+	c := newTestResponder(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Query().Get("n"))
+	}))
+	c.Multiplex = true
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := c.RoundTrip(mustNewRequest(t, "GET", fmt.Sprintf("http://example.com/?n=%d", i)))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got, want := string(body), fmt.Sprint(i); got != want {
+				t.Errorf("body = %q; want %q", got, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestClientMultiplexSlowReader(t *testing.T) {
+	big := strings.Repeat("x", maxBufferedStdout/2)
+	c := newTestResponder(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/big" {
+			io.WriteString(w, big)
+			return
+		}
+		io.WriteString(w, "small")
+	}))
+	c.Multiplex = true
+
+	// The body of the first response is never read.
+	res, err := c.RoundTrip(mustNewRequest(t, "GET", "http://example.com/big"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		res, err := c.RoundTrip(mustNewRequest(t, "GET", "http://example.com/small"))
+		if err == nil {
+			_, err = io.ReadAll(res.Body)
+			res.Body.Close()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("request stalled behind an unread response body")
+	}
+	c.mu.Lock()
+	n := len(c.conns)
+	c.mu.Unlock()
+	if n != 1 {
+		t.Errorf("requests used %d connections; want 1", n)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil || string(body) != big {
+		t.Errorf("big body: read %d bytes, %v", len(body), err)
+	}
+}
+
+func TestClientStdoutBackpressure(t *testing.T) {
+	big := strings.Repeat("x", 4*maxBufferedStdout)
+	c := newTestResponder(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, big)
+	}))
+
+	res, err := c.RoundTrip(mustNewRequest(t, "GET", "http://example.com/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	stdout := res.Body.(*body).cr.stdout
+	buffered := func() int {
+		stdout.mu.Lock()
+		defer stdout.mu.Unlock()
+		return stdout.buf.Len()
+	}
+	// Wait for the buffer to fill up, then check that the readLoop
+	// stopped instead of reading the rest of the response.
+	for i := 0; buffered() < maxBufferedStdout; i++ {
+		if i == 1000 {
+			t.Fatalf("buffered %d bytes; want %d", buffered(), maxBufferedStdout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := buffered(); n > maxBufferedStdout+maxWrite {
+		t.Errorf("buffered %d bytes of unread body; want at most %d", n, maxBufferedStdout+maxWrite)
+	}
+	b, err := io.ReadAll(res.Body)
+	if err != nil || string(b) != big {
+		t.Errorf("read %d bytes, %v; want %d bytes", len(b), err, len(big))
+	}
+}
+
+func TestClientAbortOnBodyClose(t *testing.T) {
+	release := make(chan struct{})
+	c := newTestResponder(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "partial")
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer close(release)
+
+	res, err := c.RoundTrip(mustNewRequest(t, "GET", "http://example.com/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(res.Body)
+	if _, err := br.Peek(len("partial")); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if _, err := io.ReadAll(res.Body); err != errBodyClosed {
+		t.Errorf("read after Close = %v; want %v", err, errBodyClosed)
+	}
+}
+
+func TestClientAbortRemovesRequest(t *testing.T) {
+	for _, multiplex := range []bool{false, true} {
+		release := make(chan struct{})
+		c := newTestResponder(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "partial")
+			w.(http.Flusher).Flush()
+			<-release
+		}))
+		c.Multiplex = multiplex
+
+		res, err := c.RoundTrip(mustNewRequest(t, "GET", "http://example.com/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		c.mu.Lock()
+		n := 0
+		for _, cc := range c.conns {
+			n += len(cc.reqs)
+		}
+		conns := len(c.conns)
+		c.mu.Unlock()
+		if n != 0 {
+			t.Errorf("Multiplex=%v: %d requests in flight after abort; want 0", multiplex, n)
+		}
+		if !multiplex && conns != 0 {
+			t.Errorf("Multiplex=%v: connection kept after abort", multiplex)
+		}
+		close(release)
+	}
+}
+
+func TestClientUnknownContentLength(t *testing.T) {
+	c := newTestResponder(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%d %s", r.ContentLength, body)
+	}))
+
+	req, err := http.NewRequest("POST", "http://example.com/", io.NopCloser(strings.NewReader("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.ContentLength != 0 {
+		t.Fatalf("ContentLength = %d; want 0 (unknown)", req.ContentLength)
+	}
+	res, err := c.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), "5 hello"; got != want {
+		t.Errorf("body = %q; want %q", got, want)
+	}
+
+	req, err = http.NewRequest("POST", "http://example.com/", io.NopCloser(io.LimitReader(zeroReader{}, maxBufferedBody+1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.ContentLength = -1
+	if _, err := c.RoundTrip(req); err != errBodyTooLarge {
+		t.Errorf("RoundTrip with oversized body = %v; want %v", err, errBodyTooLarge)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestClientContextCancel(t *testing.T) {
+	release := make(chan struct{})
+	c := newTestResponder(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := mustNewRequest(t, "GET", "http://example.com/").WithContext(ctx)
+	if _, err := c.RoundTrip(req); err != context.DeadlineExceeded {
+		t.Errorf("RoundTrip error = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestReadResponseHeaders(t *testing.T) {
+	tests := []struct {
+		in     string
+		status int
+	}{
+		{"Content-Type: text/plain\r\n\r\n", 200},
+		{"Status: 404 Not Found\r\n\r\n", 404},
+		{"Location: http://example.com/\r\n\r\n", 302},
+		{"Status: 301\r\nLocation: /x\r\n\r\n", 301},
+	}
+	for _, tt := range tests {
+		res, err := readResponse(bufio.NewReader(strings.NewReader(tt.in)), nil)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if res.StatusCode != tt.status {
+			t.Errorf("%q: StatusCode = %d; want %d", tt.in, res.StatusCode, tt.status)
+		}
+	}
+	if _, err := readResponse(bufio.NewReader(strings.NewReader("Status: xx\r\n\r\n")), nil); err == nil {
+		t.Errorf("bogus status: got nil error")
+	}
+}
+
+func mustNewRequest(t *testing.T, method, url string) *http.Request {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+// waitIdle waits for every connection of c to have no requests in flight.
+func waitIdle(t *testing.T, c *Client) {
+	for i := 0; i < 100; i++ {
+		c.mu.Lock()
+		n := 0
+		for _, cc := range c.conns {
+			n += len(cc.reqs)
+		}
+		c.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("requests still in flight")
+}
@@ -7,7 +7,8 @@
 // See https://fast-cgi.github.io/ for an unofficial mirror of the
 // original documentation.
 //
-// Currently only the responder role is supported.
+// Currently only the responder role is supported. Serve runs an
+// application as a responder; Client forwards requests to one.
 package fcgi
 
 // This file defines the raw protocol and some utilities used by the child and
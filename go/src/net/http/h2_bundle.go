@@ -2763,7 +2763,7 @@ func (mh *http2MetaHeadersFrame) checkPseudos() error {
 	pf := mh.PseudoFields()
 	for i, hf := range pf {
 		switch hf.Name {
-		case ":method", ":path", ":scheme", ":authority":
+		case ":method", ":path", ":scheme", ":authority", ":protocol":
 			isRequest = true
 		case ":status":
 			isResponse = true
@@ -2771,7 +2771,7 @@ func (mh *http2MetaHeadersFrame) checkPseudos() error {
 			return http2pseudoHeaderError(hf.Name)
 		}
 		// Check for duplicates.
-		// This would be a bad algorithm, but N is 4.
+		// This would be a bad algorithm, but N is 5.
 		// And this doesn't allocate.
 		for _, hf2 := range pf[:i] {
 			if hf.Name == hf2.Name {
@@ -3197,6 +3197,14 @@ var (
 	http2logFrameWrites bool
 	http2logFrameReads  bool
 	http2inTests        bool
+
+	// Enabling extended CONNECT causes browsers to attempt to use
+	// WebSockets-over-HTTP/2. This results in problems when the server's
+	// WebSocket package doesn't support extended CONNECT, so servers
+	// only advertise it when asked to.
+	//
+	// Enable extended CONNECT for all servers with GODEBUG=http2xconnect=1.
+	http2enableExtendedConnectProtocol bool
 )
 
 func init() {
@@ -3209,6 +3217,9 @@ func init() {
 		http2logFrameWrites = true
 		http2logFrameReads = true
 	}
+	if strings.Contains(e, "http2xconnect=1") {
+		http2enableExtendedConnectProtocol = true
+	}
 }
 
 const (
@@ -3300,6 +3311,10 @@ func (s http2Setting) Valid() error {
 		if s.Val < 16384 || s.Val > 1<<24-1 {
 			return http2ConnectionError(http2ErrCodeProtocol)
 		}
+	case http2SettingEnableConnectProtocol:
+		if s.Val != 1 && s.Val != 0 {
+			return http2ConnectionError(http2ErrCodeProtocol)
+		}
 	}
 	return nil
 }
@@ -3315,6 +3330,10 @@ const (
 	http2SettingInitialWindowSize    http2SettingID = 0x4
 	http2SettingMaxFrameSize         http2SettingID = 0x5
 	http2SettingMaxHeaderListSize    http2SettingID = 0x6
+
+	// SettingEnableConnectProtocol is defined by RFC 8441, "Bootstrapping
+	// WebSockets with HTTP/2".
+	http2SettingEnableConnectProtocol http2SettingID = 0x8
 )
 
 var http2settingName = map[http2SettingID]string{
@@ -3324,6 +3343,8 @@ var http2settingName = map[http2SettingID]string{
 	http2SettingInitialWindowSize:    "INITIAL_WINDOW_SIZE",
 	http2SettingMaxFrameSize:         "MAX_FRAME_SIZE",
 	http2SettingMaxHeaderListSize:    "MAX_HEADER_LIST_SIZE",
+
+	http2SettingEnableConnectProtocol: "ENABLE_CONNECT_PROTOCOL",
 }
 
 func (s http2SettingID) String() string {
@@ -3782,16 +3803,43 @@ type http2Server struct {
 	// maximum, a default value will be used instead.
 	MaxUploadBufferPerStream int32
 
+	// MaxHeaderListSize is the http2 SETTINGS_MAX_HEADER_LIST_SIZE
+	// to advertise. If zero, it is derived from the net/http
+	// Server's MaxHeaderBytes.
+	MaxHeaderListSize uint32
+
+	// ReadIdleTimeout is the timeout after which a health check using
+	// a ping frame will be carried out if no frame is received on the
+	// connection. If zero, no health check is performed.
+	ReadIdleTimeout time.Duration
+
+	// PingTimeout is the timeout after which the connection will be
+	// closed if a response to a health check ping is not received.
+	// Defaults to 15s.
+	PingTimeout time.Duration
+
 	// NewWriteScheduler constructs a write scheduler for a connection.
 	// If nil, a default scheduler is chosen.
 	NewWriteScheduler func() http2WriteScheduler
 
+	// EnableExtendedConnectProtocol, if true, advertises
+	// SETTINGS_ENABLE_CONNECT_PROTOCOL and accepts extended CONNECT
+	// requests (RFC 8441), such as WebSockets over HTTP/2. Only
+	// enable it if the server's handlers support such requests.
+	EnableExtendedConnectProtocol bool
+
 	// Internal state. This is a pointer (rather than embedded directly)
 	// so that we don't embed a Mutex in this struct, which will make the
 	// struct non-copyable, which might break some callers.
 	state *http2serverInternalState
 }
 
+// extendedConnectEnabled reports whether s accepts extended CONNECT
+// requests.
+func (s *http2Server) extendedConnectEnabled() bool {
+	return s.EnableExtendedConnectProtocol || http2enableExtendedConnectProtocol
+}
+
 func (s *http2Server) initialConnRecvWindowSize() int32 {
 	if s.MaxUploadBufferPerConnection > http2initialWindowSize {
 		return s.MaxUploadBufferPerConnection
@@ -3813,6 +3861,13 @@ func (s *http2Server) maxReadFrameSize() uint32 {
 	return http2defaultMaxReadFrameSize
 }
 
+func (s *http2Server) pingTimeout() time.Duration {
+	if s.PingTimeout == 0 {
+		return 15 * time.Second
+	}
+	return s.PingTimeout
+}
+
 func (s *http2Server) maxConcurrentStreams() uint32 {
 	if v := s.MaxConcurrentStreams; v > 0 {
 		return v
@@ -4188,6 +4243,9 @@ type http2serverConn struct {
 	goAwayCode                  http2ErrCode
 	shutdownTimer               *time.Timer // nil until used
 	idleTimer                   *time.Timer // nil if unused
+	readIdleTimer               *time.Timer // nil if unused
+	pingSent                    bool        // health check ping awaiting its ack
+	sentPingData                [8]byte
 
 	// Owned by the writeFrameAsync goroutine:
 	headerWriteBuf bytes.Buffer
@@ -4198,6 +4256,9 @@ type http2serverConn struct {
 }
 
 func (sc *http2serverConn) maxHeaderListSize() uint32 {
+	if n := sc.srv.MaxHeaderListSize; n != 0 {
+		return n
+	}
 	n := sc.hs.MaxHeaderBytes
 	if n <= 0 {
 		n = DefaultMaxHeaderBytes
@@ -4469,13 +4530,17 @@ func (sc *http2serverConn) serve() {
 		sc.vlogf("http2: server connection from %v on %p", sc.conn.RemoteAddr(), sc.hs)
 	}
 
+	settings := http2writeSettings{
+		{http2SettingMaxFrameSize, sc.srv.maxReadFrameSize()},
+		{http2SettingMaxConcurrentStreams, sc.advMaxStreams},
+		{http2SettingMaxHeaderListSize, sc.maxHeaderListSize()},
+		{http2SettingInitialWindowSize, uint32(sc.srv.initialStreamRecvWindowSize())},
+	}
+	if sc.srv.extendedConnectEnabled() {
+		settings = append(settings, http2Setting{http2SettingEnableConnectProtocol, 1})
+	}
 	sc.writeFrame(http2FrameWriteRequest{
-		write: http2writeSettings{
-			{http2SettingMaxFrameSize, sc.srv.maxReadFrameSize()},
-			{http2SettingMaxConcurrentStreams, sc.advMaxStreams},
-			{http2SettingMaxHeaderListSize, sc.maxHeaderListSize()},
-			{http2SettingInitialWindowSize, uint32(sc.srv.initialStreamRecvWindowSize())},
-		},
+		write: settings,
 	})
 	sc.unackedSettings++
 
@@ -4501,6 +4566,11 @@ func (sc *http2serverConn) serve() {
 		defer sc.idleTimer.Stop()
 	}
 
+	if sc.srv.ReadIdleTimeout != 0 {
+		sc.readIdleTimer = time.AfterFunc(sc.srv.ReadIdleTimeout, sc.onReadIdleTimer)
+		defer sc.readIdleTimer.Stop()
+	}
+
 	go sc.readFrames() // closed by defer sc.conn.Close above
 
 	settingsTimer := time.AfterFunc(http2firstSettingsTimeout, sc.onSettingsTimer)
@@ -4519,6 +4589,9 @@ func (sc *http2serverConn) serve() {
 		case res := <-sc.wroteFrameCh:
 			sc.wroteFrame(res)
 		case res := <-sc.readFrameCh:
+			if sc.readIdleTimer != nil && !sc.pingSent {
+				sc.readIdleTimer.Reset(sc.srv.ReadIdleTimeout)
+			}
 			if !sc.processFrameFromReader(res) {
 				return
 			}
@@ -4541,6 +4614,12 @@ func (sc *http2serverConn) serve() {
 				case http2idleTimerMsg:
 					sc.vlogf("connection is idle")
 					sc.goAway(http2ErrCodeNo)
+				case http2readIdleTimerMsg:
+					if sc.pingSent {
+						sc.vlogf("timeout waiting for PING response from %v", sc.conn.RemoteAddr())
+						return
+					}
+					sc.sendHealthCheckPing()
 				case http2shutdownTimerMsg:
 					sc.vlogf("GOAWAY close timer fired; closing conn from %v", sc.conn.RemoteAddr())
 					return
@@ -4589,6 +4668,7 @@ type http2serverMessage int
 var (
 	http2settingsTimerMsg    = new(http2serverMessage)
 	http2idleTimerMsg        = new(http2serverMessage)
+	http2readIdleTimerMsg    = new(http2serverMessage)
 	http2shutdownTimerMsg    = new(http2serverMessage)
 	http2gracefulShutdownMsg = new(http2serverMessage)
 )
@@ -4597,6 +4677,20 @@ func (sc *http2serverConn) onSettingsTimer() { sc.sendServeMsg(http2settingsTime
 
 func (sc *http2serverConn) onIdleTimer() { sc.sendServeMsg(http2idleTimerMsg) }
 
+func (sc *http2serverConn) onReadIdleTimer() { sc.sendServeMsg(http2readIdleTimerMsg) }
+
+// sendHealthCheckPing sends a PING after nothing was read from the
+// client for ReadIdleTimeout. The connection is closed if the ack
+// does not arrive within PingTimeout.
+func (sc *http2serverConn) sendHealthCheckPing() {
+	sc.serveG.check()
+	sc.pingSent = true
+	// A failed read leaves zeros, which is still a usable payload.
+	rand.Read(sc.sentPingData[:])
+	sc.writeFrame(http2FrameWriteRequest{write: http2writePing{sc.sentPingData}})
+	sc.readIdleTimer.Reset(sc.srv.pingTimeout())
+}
+
 func (sc *http2serverConn) onShutdownTimer() { sc.sendServeMsg(http2shutdownTimerMsg) }
 
 func (sc *http2serverConn) sendServeMsg(msg interface{}) {
@@ -5090,6 +5184,10 @@ func (sc *http2serverConn) processFrame(f http2Frame) error {
 func (sc *http2serverConn) processPing(f *http2PingFrame) error {
 	sc.serveG.check()
 	if f.IsAck() {
+		if sc.pingSent && f.Data == sc.sentPingData {
+			sc.pingSent = false
+			sc.readIdleTimer.Reset(sc.srv.ReadIdleTimeout)
+		}
 		// 6.7 PING: " An endpoint MUST NOT respond to PING frames
 		// containing this flag."
 		return nil
@@ -5245,6 +5343,9 @@ func (sc *http2serverConn) processSetting(s http2Setting) error {
 		sc.maxFrameSize = int32(s.Val) // the maximum valid s.Val is < 2^31
 	case http2SettingMaxHeaderListSize:
 		sc.peerMaxHeaderListSize = s.Val
+	case http2SettingEnableConnectProtocol:
+		// Receipt of this parameter by a server does not
+		// have any impact.
 	default:
 		// Unknown setting: "An endpoint that receives a SETTINGS
 		// frame with any unknown or unsupported identifier MUST
@@ -5651,13 +5752,27 @@ func (sc *http2serverConn) newWriterAndRequest(st *http2stream, f *http2MetaHead
 		scheme:    f.PseudoValue("scheme"),
 		authority: f.PseudoValue("authority"),
 		path:      f.PseudoValue("path"),
+		protocol:  f.PseudoValue("protocol"),
+	}
+
+	// extended connect is disabled, so we should not see :protocol
+	if !sc.srv.extendedConnectEnabled() && rp.protocol != "" {
+		return nil, nil, http2streamError(f.StreamID, http2ErrCodeProtocol)
 	}
 
 	isConnect := rp.method == "CONNECT"
 	if isConnect {
-		if rp.path != "" || rp.scheme != "" || rp.authority == "" {
+		if rp.protocol == "" && (rp.path != "" || rp.scheme != "" || rp.authority == "") {
+			return nil, nil, http2streamError(f.StreamID, http2ErrCodeProtocol)
+		}
+		// RFC 8441, section 4: an extended CONNECT request carries
+		// :scheme and :path like any other request.
+		if rp.protocol != "" && (rp.path == "" || rp.scheme == "" || rp.authority == "") {
 			return nil, nil, http2streamError(f.StreamID, http2ErrCodeProtocol)
 		}
+	} else if rp.protocol != "" {
+		// :protocol is only valid on CONNECT requests.
+		return nil, nil, http2streamError(f.StreamID, http2ErrCodeProtocol)
 	} else if rp.method == "" || rp.path == "" || (rp.scheme != "https" && rp.scheme != "http") {
 		// See 8.1.2.6 Malformed Requests and Responses:
 		//
@@ -5710,6 +5825,7 @@ func (sc *http2serverConn) newWriterAndRequest(st *http2stream, f *http2MetaHead
 type http2requestParam struct {
 	method                  string
 	scheme, authority, path string
+	protocol                string
 	header                  Header
 }
 
@@ -5751,7 +5867,7 @@ func (sc *http2serverConn) newWriterAndRequestNoBody(st *http2stream, rp http2re
 
 	var url_ *url.URL
 	var requestURI string
-	if rp.method == "CONNECT" {
+	if rp.method == "CONNECT" && rp.protocol == "" {
 		url_ = &url.URL{Host: rp.authority}
 		requestURI = rp.authority // mimic HTTP/1 server behavior
 	} else {
@@ -5763,6 +5879,13 @@ func (sc *http2serverConn) newWriterAndRequestNoBody(st *http2stream, rp http2re
 		requestURI = rp.path
 	}
 
+	// For extended CONNECT requests, Proto names the protocol
+	// being bootstrapped, such as "websocket".
+	proto := "HTTP/2.0"
+	if rp.protocol != "" {
+		proto = rp.protocol
+	}
+
 	body := &http2requestBody{
 		conn:          sc,
 		stream:        st,
@@ -5774,7 +5897,7 @@ func (sc *http2serverConn) newWriterAndRequestNoBody(st *http2stream, rp http2re
 		RemoteAddr: sc.remoteAddrStr,
 		Header:     rp.header,
 		RequestURI: requestURI,
-		Proto:      "HTTP/2.0",
+		Proto:      proto,
 		ProtoMajor: 2,
 		ProtoMinor: 0,
 		TLS:        tlsState,
@@ -6727,6 +6850,20 @@ type http2Transport struct {
 	// Defaults to 15s.
 	PingTimeout time.Duration
 
+	// MaxReadFrameSize is the http2 SETTINGS_MAX_FRAME_SIZE to send in the
+	// initial settings frame. It is the size in bytes of the largest frame
+	// payload that the sender is willing to receive. If 0 or outside the
+	// valid range, no setting is sent and the peer uses the spec's
+	// default of 16384.
+	MaxReadFrameSize uint32
+
+	// MaxReceiveBufferPerConnection and MaxReceiveBufferPerStream are
+	// the sizes of the flow control windows for data received on each
+	// connection and stream. Values below the spec's initial window
+	// size of 65535 select the defaults.
+	MaxReceiveBufferPerConnection int32
+	MaxReceiveBufferPerStream     int32
+
 	// t1, if non-nil, is the standard library Transport using
 	// this transport. Its settings are used (but not its
 	// RoundTrip method, etc).
@@ -6750,6 +6887,27 @@ func (t *http2Transport) disableCompression() bool {
 	return t.DisableCompression || (t.t1 != nil && t.t1.DisableCompression)
 }
 
+func (t *http2Transport) maxReadFrameSize() uint32 {
+	if v := t.MaxReadFrameSize; v >= http2minMaxFrameSize && v <= http2maxFrameSize {
+		return v
+	}
+	return 0
+}
+
+func (t *http2Transport) connRecvWindowSize() int32 {
+	if v := t.MaxReceiveBufferPerConnection; v >= http2initialWindowSize {
+		return v
+	}
+	return http2transportDefaultConnFlow
+}
+
+func (t *http2Transport) streamRecvWindowSize() int32 {
+	if v := t.MaxReceiveBufferPerStream; v >= http2initialWindowSize {
+		return v
+	}
+	return http2transportDefaultStreamFlow
+}
+
 func (t *http2Transport) pingTimeout() time.Duration {
 	if t.PingTimeout == 0 {
 		return 15 * time.Second
@@ -6870,6 +7028,12 @@ type http2ClientConn struct {
 	peerMaxHeaderListSize uint64
 	initialWindowSize     uint32
 
+	// seenSettings is closed when the peer's first SETTINGS frame
+	// has been processed. extendedConnectAllowed is whether that
+	// frame enabled extended CONNECT (RFC 8441).
+	seenSettings           chan struct{}
+	extendedConnectAllowed bool
+
 	hbuf    bytes.Buffer // HPACK encoder writes into this
 	henc    *hpack.Encoder
 	freeBuf [][]byte
@@ -7248,6 +7412,7 @@ func (t *http2Transport) newClientConn(c net.Conn, singleUse bool) (*http2Client
 		singleUse:             singleUse,
 		wantSettingsAck:       true,
 		pings:                 make(map[[8]byte]chan struct{}),
+		seenSettings:          make(chan struct{}),
 	}
 	if d := t.idleConnTimeout(); d != 0 {
 		cc.idleTimeout = d
@@ -7283,16 +7448,23 @@ func (t *http2Transport) newClientConn(c net.Conn, singleUse bool) (*http2Client
 
 	initialSettings := []http2Setting{
 		{ID: http2SettingEnablePush, Val: 0},
-		{ID: http2SettingInitialWindowSize, Val: http2transportDefaultStreamFlow},
+		{ID: http2SettingInitialWindowSize, Val: uint32(t.streamRecvWindowSize())},
+	}
+	if max := t.maxReadFrameSize(); max != 0 {
+		initialSettings = append(initialSettings, http2Setting{ID: http2SettingMaxFrameSize, Val: max})
+		cc.fr.SetMaxReadFrameSize(max)
 	}
 	if max := t.maxHeaderListSize(); max != 0 {
 		initialSettings = append(initialSettings, http2Setting{ID: http2SettingMaxHeaderListSize, Val: max})
 	}
 
+	connFlow := t.connRecvWindowSize()
 	cc.bw.Write(http2clientPreface)
 	cc.fr.WriteSettings(initialSettings...)
-	cc.fr.WriteWindowUpdate(0, http2transportDefaultConnFlow)
-	cc.inflow.add(http2transportDefaultConnFlow + http2initialWindowSize)
+	if connFlow > http2initialWindowSize {
+		cc.fr.WriteWindowUpdate(0, uint32(connFlow-http2initialWindowSize))
+	}
+	cc.inflow.add(connFlow)
 	cc.bw.Flush()
 	if cc.werr != nil {
 		cc.Close()
@@ -7630,6 +7802,11 @@ func (cc *http2ClientConn) roundTrip(req *Request) (res *Response, gotErrAfterRe
 	if err := http2checkConnHeaders(req); err != nil {
 		return nil, false, err
 	}
+	if http2extendedConnectProtocol(req) != "" {
+		if err := cc.awaitExtendedConnect(req); err != nil {
+			return nil, false, err
+		}
+	}
 	if cc.idleTimer != nil {
 		cc.idleTimer.Stop()
 	}
@@ -7818,6 +7995,39 @@ func (cc *http2ClientConn) roundTrip(req *Request) (res *Response, gotErrAfterRe
 	}
 }
 
+// extendedConnectProtocol returns the protocol of an extended CONNECT
+// request (RFC 8441), or "" if req is not one. A CONNECT request is an
+// extended CONNECT when its Proto field names a protocol, such as
+// "websocket", rather than an HTTP version.
+func http2extendedConnectProtocol(req *Request) string {
+	if req.Method != "CONNECT" || req.Proto == "" || strings.HasPrefix(req.Proto, "HTTP/") {
+		return ""
+	}
+	return req.Proto
+}
+
+// awaitExtendedConnect waits for the server's SETTINGS and reports
+// whether they allow extended CONNECT requests.
+func (cc *http2ClientConn) awaitExtendedConnect(req *Request) error {
+	select {
+	case <-cc.seenSettings:
+	case <-cc.readerDone:
+		return http2errClientConnClosed
+	case <-req.Context().Done():
+		return req.Context().Err()
+	case <-req.Cancel:
+		return http2errRequestCanceled
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if !cc.extendedConnectAllowed {
+		return http2errExtendedConnectNotSupported
+	}
+	return nil
+}
+
+var http2errExtendedConnectNotSupported = errors.New("http2: extended CONNECT not supported by peer")
+
 // awaitOpenSlotForRequest waits until len(streams) < maxConcurrentStreams.
 // Must hold cc.mu.
 func (cc *http2ClientConn) awaitOpenSlotForRequest(req *Request) error {
@@ -8076,8 +8286,9 @@ func (cc *http2ClientConn) encodeHeaders(req *Request, addGzipHeader bool, trail
 		return nil, err
 	}
 
+	protocol := http2extendedConnectProtocol(req)
 	var path string
-	if req.Method != "CONNECT" {
+	if req.Method != "CONNECT" || protocol != "" {
 		path = req.URL.RequestURI()
 		if !http2validPseudoPath(path) {
 			orig := path
@@ -8118,10 +8329,13 @@ func (cc *http2ClientConn) encodeHeaders(req *Request, addGzipHeader bool, trail
 			m = MethodGet
 		}
 		f(":method", m)
-		if req.Method != "CONNECT" {
+		if req.Method != "CONNECT" || protocol != "" {
 			f(":path", path)
 			f(":scheme", req.URL.Scheme)
 		}
+		if protocol != "" {
+			f(":protocol", protocol)
+		}
 		if trailers != "" {
 			f("trailer", trailers)
 		}
@@ -8306,7 +8520,7 @@ func (cc *http2ClientConn) newStream() *http2clientStream {
 	}
 	cs.flow.add(int32(cc.initialWindowSize))
 	cs.flow.setConnFlow(&cc.flow)
-	cs.inflow.add(http2transportDefaultStreamFlow)
+	cs.inflow.add(cc.t.streamRecvWindowSize())
 	cs.inflow.setConnFlow(&cc.inflow)
 	cc.nextStreamID += 2
 	cc.streams[cs.ID] = cs
@@ -8742,17 +8956,19 @@ func (b http2transportResponseBody) Read(p []byte) (n int, err error) {
 
 	var connAdd, streamAdd int32
 	// Check the conn-level first, before the stream-level.
-	if v := cc.inflow.available(); v < http2transportDefaultConnFlow/2 {
-		connAdd = http2transportDefaultConnFlow - v
+	connFlow := cc.t.connRecvWindowSize()
+	if v := cc.inflow.available(); v < connFlow/2 {
+		connAdd = connFlow - v
 		cc.inflow.add(connAdd)
 	}
 	if err == nil { // No need to refresh if the stream is over or failed.
 		// Consider any buffered body data (read from the conn but not
 		// consumed by the client) when computing flow control for this
 		// stream.
+		streamFlow := int(cc.t.streamRecvWindowSize())
 		v := int(cs.inflow.available()) + cs.bufPipe.Len()
-		if v < http2transportDefaultStreamFlow-http2transportDefaultStreamMinRefresh {
-			streamAdd = int32(http2transportDefaultStreamFlow - v)
+		if v < streamFlow-http2transportDefaultStreamMinRefresh {
+			streamAdd = int32(streamFlow - v)
 			cs.inflow.add(streamAdd)
 		}
 	}
@@ -8961,6 +9177,17 @@ func (rl *http2clientConnReadLoop) processSettings(f *http2SettingsFrame) error
 			cc.maxConcurrentStreams = s.Val
 		case http2SettingMaxHeaderListSize:
 			cc.peerMaxHeaderListSize = uint64(s.Val)
+		case http2SettingEnableConnectProtocol:
+			if err := s.Valid(); err != nil {
+				return err
+			}
+			// A server may not withdraw extended CONNECT
+			// support once it has been advertised.
+			// RFC 8441, section 3.
+			if s.Val == 0 && cc.extendedConnectAllowed {
+				return http2ConnectionError(http2ErrCodeProtocol)
+			}
+			cc.extendedConnectAllowed = s.Val == 1
 		case http2SettingInitialWindowSize:
 			// Values above the maximum flow-control
 			// window size of 2^31-1 MUST be treated as a
@@ -8989,6 +9216,11 @@ func (rl *http2clientConnReadLoop) processSettings(f *http2SettingsFrame) error
 	if err != nil {
 		return err
 	}
+	select {
+	case <-cc.seenSettings:
+	default:
+		close(cc.seenSettings)
+	}
 
 	cc.wmu.Lock()
 	defer cc.wmu.Unlock()
@@ -9485,6 +9717,16 @@ func (se http2StreamError) writeFrame(ctx http2writeContext) error {
 
 func (se http2StreamError) staysWithinBuffer(max int) bool { return http2frameHeaderLen+4 <= max }
 
+type http2writePing struct{ data [8]byte }
+
+func (w http2writePing) writeFrame(ctx http2writeContext) error {
+	return ctx.Framer().WritePing(false, w.data)
+}
+
+func (w http2writePing) staysWithinBuffer(max int) bool {
+	return http2frameHeaderLen+len(w.data) <= max
+}
+
 type http2writePingAck struct{ pf *http2PingFrame }
 
 func (w http2writePingAck) writeFrame(ctx http2writeContext) error {
@@ -160,3 +160,96 @@ type Pusher interface {
 	// is not supported on the underlying connection.
 	Push(target string, opts *PushOptions) error
 }
+
+// HTTP2Config defines HTTP/2 configuration parameters common to
+// both Transport and Server. A zero field means to use the default.
+// HTTP2Config定义了Transport和Server共用的HTTP/2配置参数。
+type HTTP2Config struct {
+	// MaxConcurrentStreams optionally specifies the number of
+	// concurrent streams that a client may have open on a connection
+	// to the Server. If zero, it defaults to at least 100.
+	// It is ignored by the Transport.
+	MaxConcurrentStreams int
+
+	// MaxReadFrameSize optionally specifies the largest frame
+	// payload this endpoint is willing to read. A valid value is
+	// between 16KiB and 16MiB, inclusive. If zero or otherwise
+	// invalid, a default value is used.
+	MaxReadFrameSize int
+
+	// MaxHeaderListSize optionally specifies the largest header
+	// block, in the units of the HTTP/2 SETTINGS_MAX_HEADER_LIST_SIZE
+	// setting, accepted from the peer. If zero, the limit is derived
+	// from Server.MaxHeaderBytes or Transport.MaxResponseHeaderBytes.
+	MaxHeaderListSize int
+
+	// MaxReceiveBufferPerConnection is the size of the flow control
+	// window for data received on a connection. It must be at least
+	// 64KiB and at most 2^31-1; other values are replaced by a default.
+	MaxReceiveBufferPerConnection int
+
+	// MaxReceiveBufferPerStream is the size of the flow control
+	// window for data received on a stream. It must be at least
+	// 64KiB and at most 2^31-1; other values are replaced by a default.
+	MaxReceiveBufferPerStream int
+
+	// ReadIdleTimeout is the time after which a health check using
+	// a PING frame is carried out if no frame has been received on
+	// a connection. If zero, no health check is performed.
+	ReadIdleTimeout time.Duration
+
+	// PingTimeout is how long to wait for the response to a health
+	// check PING before closing the connection. If zero, it
+	// defaults to 15 seconds.
+	PingTimeout time.Duration
+
+	// PermitProhibitedCipherSuites, if true, permits the Server
+	// to use cipher suites prohibited by the HTTP/2 spec.
+	// It is ignored by the Transport.
+	PermitProhibitedCipherSuites bool
+
+	// EnableExtendedConnectProtocol, if true, makes the Server
+	// accept extended CONNECT requests (RFC 8441), which carry
+	// protocols such as WebSockets over HTTP/2. It is off by default
+	// because browsers use it whenever a server advertises it, and
+	// handlers that only support WebSockets over HTTP/1.1 then fail.
+	// Setting GODEBUG=http2xconnect=1 enables it for all servers.
+	// It is ignored by the Transport.
+	EnableExtendedConnectProtocol bool
+}
+
+// configureServer applies c to the bundled HTTP/2 server.
+func (c *HTTP2Config) configureServer(conf *http2Server) {
+	conf.MaxConcurrentStreams = uint32(inRangeOrZero(c.MaxConcurrentStreams, 0, 1<<32-1))
+	conf.MaxReadFrameSize = uint32(inRangeOrZero(c.MaxReadFrameSize, 0, 1<<32-1))
+	conf.MaxHeaderListSize = uint32(inRangeOrZero(c.MaxHeaderListSize, 0, 1<<32-1))
+	conf.MaxUploadBufferPerConnection = int32(inRangeOrZero(c.MaxReceiveBufferPerConnection, 1<<16, 1<<31-1))
+	conf.MaxUploadBufferPerStream = int32(inRangeOrZero(c.MaxReceiveBufferPerStream, 1<<16, 1<<31-1))
+	conf.ReadIdleTimeout = c.ReadIdleTimeout
+	conf.PingTimeout = c.PingTimeout
+	conf.PermitProhibitedCipherSuites = c.PermitProhibitedCipherSuites
+	conf.EnableExtendedConnectProtocol = c.EnableExtendedConnectProtocol
+}
+
+// configureTransport applies c to the bundled HTTP/2 transport. A
+// MaxHeaderListSize in c overrides the one derived from the
+// Transport's MaxResponseHeaderBytes.
+func (c *HTTP2Config) configureTransport(t2 *http2Transport) {
+	if v := inRangeOrZero(c.MaxHeaderListSize, 0, 1<<32-1); v != 0 {
+		t2.MaxHeaderListSize = uint32(v)
+	}
+	t2.MaxReadFrameSize = uint32(inRangeOrZero(c.MaxReadFrameSize, 0, 1<<32-1))
+	t2.MaxReceiveBufferPerConnection = int32(inRangeOrZero(c.MaxReceiveBufferPerConnection, 1<<16, 1<<31-1))
+	t2.MaxReceiveBufferPerStream = int32(inRangeOrZero(c.MaxReceiveBufferPerStream, 1<<16, 1<<31-1))
+	t2.ReadIdleTimeout = c.ReadIdleTimeout
+	t2.PingTimeout = c.PingTimeout
+}
+
+// inRangeOrZero returns v if it is in [min, max], and 0, which
+// selects the default for HTTP2Config fields, otherwise.
+func inRangeOrZero(v int, min, max int64) int64 {
+	if int64(v) < min || int64(v) > max {
+		return 0
+	}
+	return int64(v)
+}
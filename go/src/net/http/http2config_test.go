@@ -0,0 +1,139 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !nethttpomithttp2
+// +build !nethttpomithttp2
+
+// White-box tests for HTTP2Config, which is applied to the bundled
+// HTTP/2 server and transport.
+
+package http
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// readSettings reads frames from fr until it finds a non-ack SETTINGS frame.
+func readSettings(t *testing.T, fr *http2Framer) *http2SettingsFrame {
+	for {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			t.Fatalf("reading SETTINGS: %v", err)
+		}
+		if sf, ok := f.(*http2SettingsFrame); ok && !sf.IsAck() {
+			return sf
+		}
+	}
+}
+
+func checkSetting(t *testing.T, sf *http2SettingsFrame, id http2SettingID, want uint32) {
+	if v, ok := sf.Value(id); !ok || v != want {
+		t.Errorf("setting %v = %v, %v; want %v", id, v, ok, want)
+	}
+}
+
+// awaitPingThenClose reads frames from fr until a PING that is not an
+// ack arrives, and then until the peer closes the connection.
+func awaitPingThenClose(t *testing.T, fr *http2Framer) {
+	sawPing := false
+	for {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			if !sawPing {
+				t.Fatalf("connection closed without a health check PING: %v", err)
+			}
+			return
+		}
+		if pf, ok := f.(*http2PingFrame); ok && !pf.IsAck() {
+			sawPing = true
+		}
+	}
+}
+
+func TestHTTP2ConfigServer(t *testing.T) {
+	c := &HTTP2Config{
+		MaxConcurrentStreams:      7,
+		MaxReadFrameSize:          1 << 20,
+		MaxHeaderListSize:         1 << 12,
+		MaxReceiveBufferPerStream: 1 << 17,
+		ReadIdleTimeout:           50 * time.Millisecond,
+		PingTimeout:               50 * time.Millisecond,
+	}
+	conf := new(http2Server)
+	c.configureServer(conf)
+
+	cc, sc := net.Pipe()
+	defer cc.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conf.ServeConn(sc, &http2ServeConnOpts{BaseConfig: &Server{}, Handler: HandlerFunc(func(ResponseWriter, *Request) {})})
+	}()
+
+	fr := http2NewFramer(cc, cc)
+	go func() {
+		io.WriteString(cc, http2ClientPreface)
+		fr.WriteSettings()
+	}()
+	sf := readSettings(t, fr)
+	checkSetting(t, sf, http2SettingMaxConcurrentStreams, 7)
+	checkSetting(t, sf, http2SettingMaxFrameSize, 1<<20)
+	checkSetting(t, sf, http2SettingMaxHeaderListSize, 1<<12)
+	checkSetting(t, sf, http2SettingInitialWindowSize, 1<<17)
+
+	// The client never answers the server's PING.
+	awaitPingThenClose(t, fr)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("server did not close the connection")
+	}
+}
+
+func TestHTTP2ConfigTransport(t *testing.T) {
+	tr := &Transport{
+		ForceAttemptHTTP2: true,
+		HTTP2: &HTTP2Config{
+			MaxReadFrameSize:              1 << 20,
+			MaxHeaderListSize:             1 << 12,
+			MaxReceiveBufferPerConnection: 1 << 18,
+			MaxReceiveBufferPerStream:     1 << 17,
+			ReadIdleTimeout:               50 * time.Millisecond,
+			PingTimeout:                   50 * time.Millisecond,
+		},
+	}
+	tr.onceSetNextProtoDefaults()
+	t2, ok := tr.h2transport.(*http2Transport)
+	if !ok {
+		t.Fatal("HTTP/2 not configured")
+	}
+
+	cc, sc := net.Pipe()
+	defer sc.Close()
+	go t2.NewClientConn(cc)
+
+	preface := make([]byte, len(http2ClientPreface))
+	if _, err := io.ReadFull(sc, preface); err != nil {
+		t.Fatal(err)
+	}
+	fr := http2NewFramer(sc, sc)
+	sf := readSettings(t, fr)
+	checkSetting(t, sf, http2SettingMaxFrameSize, 1<<20)
+	checkSetting(t, sf, http2SettingMaxHeaderListSize, 1<<12)
+	checkSetting(t, sf, http2SettingInitialWindowSize, 1<<17)
+	f, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wu, ok := f.(*http2WindowUpdateFrame); !ok || wu.Increment != 1<<18-http2initialWindowSize {
+		t.Errorf("got %v; want connection WINDOW_UPDATE of %v", http2summarizeFrame(f), 1<<18-http2initialWindowSize)
+	}
+
+	// The server sends its SETTINGS and then never answers a PING.
+	go fr.WriteSettings()
+	awaitPingThenClose(t, fr)
+}
@@ -9,6 +9,7 @@ package http
 
 import (
 	"errors"
+	"strings"
 	"sync"
 	"time"
 )
@@ -26,8 +27,13 @@ var http2goAwayTimeout = 1 * time.Second
 const http2NextProtoTLS = "h2"
 
 type http2Transport struct {
-	MaxHeaderListSize uint32
-	ConnPool          interface{}
+	MaxHeaderListSize             uint32
+	MaxReadFrameSize              uint32
+	MaxReceiveBufferPerConnection int32
+	MaxReceiveBufferPerStream     int32
+	ReadIdleTimeout               time.Duration
+	PingTimeout                   time.Duration
+	ConnPool                      interface{}
 }
 
 func (*http2Transport) RoundTrip(*Request) (*Response, error) { panic(noHTTP2) }
@@ -53,8 +59,24 @@ func http2isNoCachedConnError(err error) bool {
 	return ok
 }
 
+func http2extendedConnectProtocol(req *Request) string {
+	if req.Method != "CONNECT" || req.Proto == "" || strings.HasPrefix(req.Proto, "HTTP/") {
+		return ""
+	}
+	return req.Proto
+}
+
 type http2Server struct {
-	NewWriteScheduler func() http2WriteScheduler
+	MaxConcurrentStreams          uint32
+	MaxReadFrameSize              uint32
+	MaxHeaderListSize             uint32
+	MaxUploadBufferPerConnection  int32
+	MaxUploadBufferPerStream      int32
+	ReadIdleTimeout               time.Duration
+	PingTimeout                   time.Duration
+	PermitProhibitedCipherSuites  bool
+	NewWriteScheduler             func() http2WriteScheduler
+	EnableExtendedConnectProtocol bool
 }
 
 type http2WriteScheduler interface{}
@@ -140,9 +140,16 @@ type Request struct {
 
 	// The protocol version for incoming server requests.
 	//
+	// For HTTP/2 extended CONNECT requests (RFC 8441), Proto is
+	// the protocol named by the :protocol pseudo-header, such as
+	// "websocket", and ProtoMajor is 2.
+	//
 	// For client requests, these fields are ignored. The HTTP
 	// client code always uses either HTTP/1.1 or HTTP/2.
-	// See the docs on Transport for details.
+	// See the docs on Transport for details. The one exception
+	// is a CONNECT request whose Proto names a protocol other
+	// than HTTP, which the Transport sends as an HTTP/2 extended
+	// CONNECT request for that protocol.
 	// 传入服务器请求的协议版本。对于客户端请求，这些字段将被忽略。
 	// HTTP客户端代码始终使用HTTP/1.1或HTTP/2。有关详细信息，
 	// 请参阅有关传输的文档。
@@ -2384,7 +2384,9 @@ func (mux *ServeMux) shouldRedirectRLocked(host, path string) bool {
 // to the canonical path. If the host contains a port, it is ignored
 // when matching handlers.
 //
-// The path and host are used unchanged for CONNECT requests.
+// The path and host are used unchanged for CONNECT requests. Extended
+// CONNECT requests, whose Proto names a protocol rather than an HTTP
+// version, are matched as if their path were "/" if it is empty.
 //
 // Handler also returns the registered pattern that matches the
 // request or, in the case of internally-generated redirects,
@@ -2403,7 +2405,11 @@ func (mux *ServeMux) Handler(r *Request) (h Handler, pattern string) {
 			return RedirectHandler(u.String(), StatusMovedPermanently), u.Path
 		}
 
-		return mux.handler(r.Host, r.URL.Path)
+		path := r.URL.Path
+		if path == "" && isExtendedConnect(r) {
+			path = "/"
+		}
+		return mux.handler(r.Host, path)
 	}
 
 	// All other requests have any port stripped and path cleaned
@@ -2630,6 +2636,11 @@ type Server struct {
 	// automatically.
 	TLSNextProto map[string]func(*Server, *tls.Conn, Handler)
 
+	// HTTP2 configures HTTP/2 connections.
+	// It has no effect if HTTP/2 is not enabled automatically,
+	// such as when TLSNextProto is non-nil.
+	HTTP2 *HTTP2Config
+
 	// ConnState specifies an optional callback function that is
 	// called when a client connection changes state. See the
 	// ConnState type and associated constants for details.
@@ -3278,6 +3289,9 @@ func (srv *Server) onceSetNextProtoDefaults() {
 		conf := &http2Server{
 			NewWriteScheduler: func() http2WriteScheduler { return http2NewPriorityWriteScheduler(nil) },
 		}
+		if srv.HTTP2 != nil {
+			srv.HTTP2.configureServer(conf)
+		}
 		srv.nextProtoErr = http2ConfigureServer(srv, conf)
 	}
 }
@@ -87,6 +87,12 @@ const DefaultMaxIdleConnsPerHost = 2
 // ignored 1xx responses, use the httptrace trace package's
 // ClientTrace.Got1xxResponse.
 //
+// A CONNECT request whose Proto field names a protocol, such as
+// "websocket", is sent as an HTTP/2 extended CONNECT request (RFC 8441)
+// once the server has advertised support for it; it fails on HTTP/1
+// connections. The request Body is sent as the client's side of the
+// tunnel and the response Body carries the server's side.
+//
 // Transport only retries a request upon encountering a network error
 // if the request is idempotent and either has no body or has its
 // Request.GetBody defined. HTTP requests are considered idempotent if
@@ -310,6 +316,10 @@ type Transport struct {
 	// To use a custom dialer or TLS config and still attempt HTTP/2
 	// upgrades, set this to true.
 	ForceAttemptHTTP2 bool
+
+	// HTTP2 configures HTTP/2 connections.
+	// It has no effect if HTTP/2 is not enabled.
+	HTTP2 *HTTP2Config
 }
 
 // A cancelKey is the key of the reqCanceler map.
@@ -358,6 +368,10 @@ func (t *Transport) Clone() *Transport {
 		WriteBufferSize:        t.WriteBufferSize,
 		ReadBufferSize:         t.ReadBufferSize,
 	}
+	if t.HTTP2 != nil {
+		c := *t.HTTP2
+		t2.HTTP2 = &c
+	}
 	if t.TLSClientConfig != nil {
 		t2.TLSClientConfig = t.TLSClientConfig.Clone()
 	}
@@ -446,6 +460,10 @@ func (t *Transport) onceSetNextProtoDefaults() {
 			t2.MaxHeaderListSize = uint32(limit1)
 		}
 	}
+
+	if t.HTTP2 != nil {
+		t.HTTP2.configureTransport(t2)
+	}
 }
 
 // ProxyFromEnvironment returns the URL of the proxy to use for a
@@ -621,6 +639,12 @@ func (t *Transport) roundTrip(req *Request) (*Response, error) {
 			// HTTP/2路径。
 			t.setReqCanceler(cancelKey, nil) // not cancelable with CancelRequest
 			resp, err = pconn.alt.RoundTrip(req)
+		} else if http2extendedConnectProtocol(req) != "" {
+			// Extended CONNECT only exists in HTTP/2 (RFC 8441).
+			t.setReqCanceler(cancelKey, nil)
+			t.putOrCloseIdleConn(pconn)
+			req.closeBody()
+			return nil, errExtendedConnectHTTP1
 		} else {
 			resp, err = pconn.roundTrip(treq)
 		}
@@ -2541,6 +2565,7 @@ var errTimeout error = &httpError{err: "net/http: timeout awaiting response head
 // testing.
 var errRequestCanceled = http2errRequestCanceled
 var errRequestCanceledConn = errors.New("net/http: request canceled while waiting for connection") // TODO: unify?
+var errExtendedConnectHTTP1 = errors.New("net/http: extended CONNECT requires an HTTP/2 connection")
 
 func nop() {}
 
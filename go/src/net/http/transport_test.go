@@ -5902,6 +5902,7 @@ func TestTransportClone(t *testing.T) {
 		},
 		ReadBufferSize:  1,
 		WriteBufferSize: 1,
+		HTTP2:           &HTTP2Config{MaxConcurrentStreams: 1},
 	}
 	tr2 := tr.Clone()
 	rv := reflect.ValueOf(tr2).Elem()
@@ -0,0 +1,182 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package websocket
+
+// This file implements the client side of the opening handshake.
+// 这个文件实现了客户端的握手。
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// A Dialer contains options for connecting to a WebSocket server.
+// The zero value is a valid Dialer.
+type Dialer struct {
+	// Transport sends the opening handshake. The WebSocket uses
+	// the connection on which the handshake was sent, so the
+	// Transport's proxy, TLS and dial settings apply to it.
+	// If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// Header specifies additional headers to send with the opening
+	// handshake, such as Origin, Cookie or Authorization.
+	Header http.Header
+
+	// Subprotocols lists the subprotocols to request, in order of
+	// preference.
+	Subprotocols []string
+
+	// EnableCompression specifies whether the client offers the
+	// permessage-deflate extension.
+	EnableCompression bool
+}
+
+// Dial connects to the WebSocket server at urlStr using the zero
+// Dialer, sending header with the opening handshake.
+func Dial(ctx context.Context, urlStr string, header http.Header) (*Conn, *http.Response, error) {
+	d := &Dialer{Header: header}
+	return d.Dial(ctx, urlStr)
+}
+
+// Dial connects to the WebSocket server at urlStr, which must have the
+// scheme "ws" or "wss". The context bounds the opening handshake; once
+// Dial returns, canceling it has no effect on the connection.
+//
+// If the server does not accept the connection, Dial returns an error
+// wrapping ErrBadHandshake along with the server's response, whose body
+// holds up to the first kilobyte of the response body and need not be
+// closed.
+func (d *Dialer) Dial(ctx context.Context, urlStr string) (*Conn, *http.Response, error) {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	default:
+		return nil, nil, fmt.Errorf("websocket: unsupported URL scheme %q", u.Scheme)
+	}
+	if u.User != nil {
+		// User name and password are not allowed in WebSocket URIs.
+		return nil, nil, errors.New("websocket: URL must not contain user information")
+	}
+	u.Fragment = ""
+
+	var keyBytes [16]byte
+	if _, err := io.ReadFull(rand.Reader, keyBytes[:]); err != nil {
+		return nil, nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(keyBytes[:])
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       u.Host,
+	}
+	for k, vv := range d.Header {
+		switch k {
+		case "Host":
+			if len(vv) > 0 {
+				req.Host = vv[0]
+			}
+		case "Upgrade", "Connection", "Sec-Websocket-Key", "Sec-Websocket-Version",
+			"Sec-Websocket-Extensions", "Sec-Websocket-Protocol":
+			return nil, nil, errors.New("websocket: duplicate header not allowed: " + k)
+		default:
+			req.Header[k] = vv
+		}
+	}
+	// Transport sends requests with these headers on HTTP/1.1
+	// connections, even if the server supports HTTP/2.
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if len(d.Subprotocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(d.Subprotocols, ", "))
+	}
+	if d.EnableCompression {
+		req.Header.Set("Sec-WebSocket-Extensions", deflateOffer)
+	}
+	req = req.WithContext(ctx)
+
+	transport := d.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fail := func(reason string) (*Conn, *http.Response, error) {
+		// Keep the start of the body for the caller and release
+		// the connection. The body of a 101 response is the
+		// connection itself, which is not read.
+		var n int
+		buf := make([]byte, 1024)
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			n, _ = io.ReadFull(resp.Body, buf)
+		}
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(buf[:n]))
+		return nil, resp, fmt.Errorf("%w: %s", ErrBadHandshake, reason)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return fail("unexpected status " + resp.Status)
+	}
+	if !httpguts.HeaderValuesContainsToken(resp.Header["Upgrade"], "websocket") ||
+		!httpguts.HeaderValuesContainsToken(resp.Header["Connection"], "upgrade") {
+		return fail("missing upgrade headers")
+	}
+	if resp.Header.Get("Sec-Websocket-Accept") != computeAcceptKey(key) {
+		return fail("invalid 'Sec-WebSocket-Accept' header")
+	}
+	subprotocol := resp.Header.Get("Sec-Websocket-Protocol")
+	if subprotocol != "" {
+		ok := false
+		for _, p := range d.Subprotocols {
+			if p == subprotocol {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fail("server selected a subprotocol that was not requested")
+		}
+	}
+	compress := false
+	for _, ext := range parseExtensions(resp.Header["Sec-Websocket-Extensions"]) {
+		if !d.EnableCompression || compress || !acceptDeflateResponse(ext) {
+			return fail("unsupported extension " + ext.name)
+		}
+		compress = true
+	}
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		return fail("response body is not writable")
+	}
+	// The body is now the WebSocket connection.
+	resp.Body = io.NopCloser(bytes.NewReader(nil))
+	return newConn(rwc, nil, nil, false, subprotocol, compress), resp, nil
+}
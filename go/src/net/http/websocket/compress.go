@@ -0,0 +1,185 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package websocket
+
+// This file implements the permessage-deflate extension of RFC 7692.
+//
+// Both endpoints always negotiate no context takeover, so each message
+// is compressed independently. This keeps the memory held by idle
+// connections small and lets compressors be shared between connections.
+// 这个文件实现了RFC 7692中的permessage-deflate扩展。
+
+import (
+	"compress/flate"
+	"io"
+	"net/http/internal/ascii"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+const extensionName = "permessage-deflate"
+
+// deflateTail is appended to a compressed message before decompressing
+// it: the 0x00 0x00 0xff 0xff removed by the sender, followed by an
+// empty final stored block so that the flate reader sees the end of the
+// stream.
+const deflateTail = "\x00\x00\xff\xff\x01\x00\x00\xff\xff"
+
+var flateWriterPool sync.Pool // of *flate.Writer
+
+func (c *Conn) compressor(w io.Writer) *flate.Writer {
+	if fw, ok := flateWriterPool.Get().(*flate.Writer); ok {
+		fw.Reset(w)
+		return fw
+	}
+	fw, _ := flate.NewWriter(w, flate.BestSpeed)
+	return fw
+}
+
+func (c *Conn) releaseCompressor(fw *flate.Writer) {
+	flateWriterPool.Put(fw)
+}
+
+// decompressor returns a reader decompressing the message read from r.
+// The caller holds readMu.
+func (c *Conn) decompressor(r io.Reader) io.Reader {
+	src := io.MultiReader(r, strings.NewReader(deflateTail))
+	if c.flateR == nil {
+		c.flateR = flate.NewReader(src)
+	} else {
+		c.flateR.(flate.Resetter).Reset(src, nil)
+	}
+	return c.flateR
+}
+
+// trimWriter forwards writes to a messageWriter, holding back the last
+// four bytes written.
+type trimWriter struct {
+	w    *messageWriter
+	tail [4]byte
+	n    int // valid bytes in tail
+}
+
+func (t *trimWriter) Write(p []byte) (int, error) {
+	nn := len(p)
+	if t.n+len(p) <= len(t.tail) {
+		t.n += copy(t.tail[t.n:], p)
+		return nn, nil
+	}
+	// Send what is held back and all of p but its last four bytes,
+	// keeping up to four bytes of the old tail if p is shorter.
+	keep := len(t.tail) - len(p)
+	if keep < 0 {
+		keep = 0
+	}
+	if send := t.n - keep; send > 0 {
+		if _, err := t.w.writeRaw(t.tail[:send]); err != nil {
+			return 0, err
+		}
+		copy(t.tail[:], t.tail[send:t.n])
+		t.n -= send
+	}
+	if len(p) > len(t.tail) {
+		if _, err := t.w.writeRaw(p[:len(p)-len(t.tail)]); err != nil {
+			return 0, err
+		}
+		p = p[len(p)-len(t.tail):]
+	}
+	t.n += copy(t.tail[t.n:], p)
+	return nn, nil
+}
+
+// valid reports whether the held back bytes are the end of a sync flush.
+func (t *trimWriter) valid() bool {
+	return t.n == 4 && string(t.tail[:]) == "\x00\x00\xff\xff"
+}
+
+// extension is a single offer or response in a Sec-WebSocket-Extensions
+// header: a name followed by parameters.
+type extension struct {
+	name   string
+	params map[string]string
+}
+
+// parseExtensions parses the values of Sec-WebSocket-Extensions headers.
+// Malformed elements are skipped.
+func parseExtensions(values []string) []extension {
+	var exts []extension
+	for _, v := range values {
+		for _, e := range strings.Split(v, ",") {
+			parts := strings.Split(e, ";")
+			name, _ := ascii.ToLower(textproto.TrimString(parts[0]))
+			if name == "" {
+				continue
+			}
+			ext := extension{name: name, params: make(map[string]string)}
+			for _, p := range parts[1:] {
+				k, v := p, ""
+				if i := strings.IndexByte(p, '='); i >= 0 {
+					k, v = p[:i], p[i+1:]
+				}
+				k, _ = ascii.ToLower(textproto.TrimString(k))
+				v = strings.Trim(textproto.TrimString(v), `"`)
+				ext.params[k] = v
+			}
+			exts = append(exts, ext)
+		}
+	}
+	return exts
+}
+
+// acceptDeflateOffer reports whether the server can accept the
+// permessage-deflate offer ext.
+func acceptDeflateOffer(ext extension) bool {
+	if ext.name != extensionName {
+		return false
+	}
+	for k, v := range ext.params {
+		switch k {
+		case "server_no_context_takeover", "client_no_context_takeover":
+		case "client_max_window_bits":
+			// The client supports limiting its window. Not
+			// responding with the parameter leaves it at 15.
+		case "server_max_window_bits":
+			// compress/flate always uses a 32KB window.
+			if v != "15" {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// deflateResponse is the server's response to an accepted offer. It
+// asks both sides not to take over the compression context between
+// messages (RFC 7692, sections 7.1.1.1 and 7.1.1.2).
+const deflateResponse = extensionName + "; server_no_context_takeover; client_no_context_takeover"
+
+// deflateOffer is the client's offer.
+const deflateOffer = extensionName + "; server_no_context_takeover; client_no_context_takeover"
+
+// acceptDeflateResponse reports whether the client can use the
+// server's response ext to its offer.
+func acceptDeflateResponse(ext extension) bool {
+	if ext.name != extensionName {
+		return false
+	}
+	if _, ok := ext.params["server_no_context_takeover"]; !ok {
+		return false
+	}
+	for k := range ext.params {
+		switch k {
+		case "server_no_context_takeover", "client_no_context_takeover":
+		case "server_max_window_bits":
+			// A smaller window does not matter to the decompressor.
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package websocket
+
+// This file implements the base framing protocol of RFC 6455, section 5.2.
+// 这个文件实现了RFC 6455第5.2节中定义的基本帧协议。
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Frame opcodes, as defined in RFC 6455, section 11.8.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+const (
+	finalBit = 1 << 7
+	rsv1Bit  = 1 << 6
+	rsv2Bit  = 1 << 5
+	rsv3Bit  = 1 << 4
+	maskBit  = 1 << 7
+
+	// maxControlPayload is the largest payload of a control frame.
+	maxControlPayload = 125
+
+	// maxFrameHeaderSize is the size of the longest frame header:
+	// two bytes, an eight byte extended length and a masking key.
+	maxFrameHeaderSize = 2 + 8 + 4
+)
+
+// frameHeader is the header of a single frame.
+// frameHeader是单个帧的帧头。
+type frameHeader struct {
+	fin    bool
+	rsv1   bool
+	rsv2   bool
+	rsv3   bool
+	opcode byte
+	masked bool
+	key    [4]byte
+	length int64
+}
+
+func isControl(opcode byte) bool {
+	return opcode&0x8 != 0
+}
+
+func isData(opcode byte) bool {
+	return opcode == opText || opcode == opBinary || opcode == opContinuation
+}
+
+var errInvalidLength = errors.New("websocket: invalid frame payload length")
+
+// readFrameHeader reads a frame header from br.
+func readFrameHeader(br *bufio.Reader) (frameHeader, error) {
+	var h frameHeader
+	var b [8]byte
+	if _, err := io.ReadFull(br, b[:2]); err != nil {
+		return h, err
+	}
+	h.fin = b[0]&finalBit != 0
+	h.rsv1 = b[0]&rsv1Bit != 0
+	h.rsv2 = b[0]&rsv2Bit != 0
+	h.rsv3 = b[0]&rsv3Bit != 0
+	h.opcode = b[0] & 0xf
+	h.masked = b[1]&maskBit != 0
+
+	switch n := b[1] &^ maskBit; n {
+	case 126:
+		if _, err := io.ReadFull(br, b[:2]); err != nil {
+			return h, unexpectedEOF(err)
+		}
+		h.length = int64(binary.BigEndian.Uint16(b[:2]))
+		if h.length < 126 {
+			// The minimal number of bytes must be used.
+			return h, errInvalidLength
+		}
+	case 127:
+		if _, err := io.ReadFull(br, b[:8]); err != nil {
+			return h, unexpectedEOF(err)
+		}
+		v := binary.BigEndian.Uint64(b[:8])
+		if v>>63 != 0 || v <= 0xffff {
+			return h, errInvalidLength
+		}
+		h.length = int64(v)
+	default:
+		h.length = int64(n)
+	}
+
+	if h.masked {
+		if _, err := io.ReadFull(br, h.key[:]); err != nil {
+			return h, unexpectedEOF(err)
+		}
+	}
+	return h, nil
+}
+
+// appendFrameHeader appends the encoding of h to b.
+func appendFrameHeader(b []byte, h frameHeader) []byte {
+	b0 := h.opcode
+	if h.fin {
+		b0 |= finalBit
+	}
+	if h.rsv1 {
+		b0 |= rsv1Bit
+	}
+	if h.rsv2 {
+		b0 |= rsv2Bit
+	}
+	if h.rsv3 {
+		b0 |= rsv3Bit
+	}
+	var b1 byte
+	if h.masked {
+		b1 = maskBit
+	}
+	switch {
+	case h.length <= 125:
+		b = append(b, b0, b1|byte(h.length))
+	case h.length <= 0xffff:
+		b = append(b, b0, b1|126, byte(h.length>>8), byte(h.length))
+	default:
+		b = append(b, b0, b1|127)
+		var l [8]byte
+		binary.BigEndian.PutUint64(l[:], uint64(h.length))
+		b = append(b, l[:]...)
+	}
+	if h.masked {
+		b = append(b, h.key[:]...)
+	}
+	return b
+}
+
+// maskBytes applies the masking key to b in place, starting pos bytes
+// into the payload, and returns the position following b.
+// RFC 6455, section 5.3.
+func maskBytes(key [4]byte, pos int, b []byte) int {
+	for i := range b {
+		b[i] ^= key[pos&3]
+		pos++
+	}
+	return pos & 3
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,224 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package websocket
+
+// This file implements the server side of the opening handshake.
+// 这个文件实现了服务器端的握手。
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/internal/ascii"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// acceptGUID is the GUID appended to the key to compute
+// Sec-WebSocket-Accept, from RFC 6455, section 1.3.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+func computeAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key))
+	h.Write([]byte(acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// An Upgrader upgrades HTTP requests to WebSocket connections.
+// It is safe to call Upgrade concurrently.
+type Upgrader struct {
+	// Subprotocols lists the subprotocols supported by the server,
+	// in order of preference. The first protocol in the list that
+	// the client also requested is selected.
+	Subprotocols []string
+
+	// CheckOrigin reports whether the request's Origin header is
+	// acceptable. If CheckOrigin is nil, requests with an Origin
+	// whose host does not match the request's Host are rejected,
+	// which stops cross-site pages from connecting using the
+	// user's cookies.
+	CheckOrigin func(r *http.Request) bool
+
+	// EnableCompression specifies whether the server accepts the
+	// permessage-deflate extension when the client offers it.
+	EnableCompression bool
+}
+
+// IsWebSocketUpgrade reports whether r asks to open a WebSocket, either
+// with an HTTP/1.1 Upgrade request or with an HTTP/2 extended CONNECT
+// request.
+func IsWebSocketUpgrade(r *http.Request) bool {
+	if r.ProtoMajor == 2 {
+		return r.Method == http.MethodConnect && ascii.EqualFold(r.Proto, "websocket")
+	}
+	return httpguts.HeaderValuesContainsToken(r.Header["Connection"], "upgrade") &&
+		httpguts.HeaderValuesContainsToken(r.Header["Upgrade"], "websocket")
+}
+
+// Upgrade upgrades the request to a WebSocket connection. The
+// responseHeader, if non-nil, is included in the response to the
+// client; it must not set Sec-WebSocket-Protocol or
+// Sec-WebSocket-Extensions.
+//
+// If the request is not a valid opening handshake, Upgrade replies to
+// the client with an HTTP error and returns an error.
+//
+// On an HTTP/1.1 connection, the connection is hijacked and the handler
+// may return while the WebSocket is in use. On an HTTP/2 connection the
+// WebSocket uses the request's stream, which ends when the handler
+// returns: the handler must not return before it is done with the Conn.
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (*Conn, error) {
+	var key string
+	switch {
+	case r.ProtoMajor == 2:
+		if r.Method != http.MethodConnect || !ascii.EqualFold(r.Proto, "websocket") {
+			return u.fail(w, http.StatusBadRequest, "websocket: not an extended CONNECT request for websocket")
+		}
+	case r.Method != http.MethodGet:
+		return u.fail(w, http.StatusMethodNotAllowed, "websocket: request method is not GET")
+	case !httpguts.HeaderValuesContainsToken(r.Header["Connection"], "upgrade"):
+		return u.fail(w, http.StatusBadRequest, "websocket: 'upgrade' token not found in 'Connection' header")
+	case !httpguts.HeaderValuesContainsToken(r.Header["Upgrade"], "websocket"):
+		return u.fail(w, http.StatusBadRequest, "websocket: 'websocket' token not found in 'Upgrade' header")
+	default:
+		key = r.Header.Get("Sec-Websocket-Key")
+		if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+			return u.fail(w, http.StatusBadRequest, "websocket: invalid 'Sec-WebSocket-Key' header")
+		}
+	}
+	if r.Header.Get("Sec-Websocket-Version") != "13" {
+		w.Header().Set("Sec-Websocket-Version", "13")
+		return u.fail(w, http.StatusUpgradeRequired, "websocket: unsupported version: 13 not found in 'Sec-Websocket-Version' header")
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = checkSameOrigin
+	}
+	if !checkOrigin(r) {
+		return u.fail(w, http.StatusForbidden, "websocket: request origin not allowed by Upgrader.CheckOrigin")
+	}
+	if _, ok := responseHeader["Sec-Websocket-Protocol"]; ok {
+		return u.fail(w, http.StatusInternalServerError, "websocket: application specific 'Sec-WebSocket-Protocol' headers are unsupported")
+	}
+	if _, ok := responseHeader["Sec-Websocket-Extensions"]; ok {
+		return u.fail(w, http.StatusInternalServerError, "websocket: application specific 'Sec-WebSocket-Extensions' headers are unsupported")
+	}
+
+	subprotocol := u.selectSubprotocol(r)
+	compress := false
+	if u.EnableCompression {
+		for _, ext := range parseExtensions(r.Header["Sec-Websocket-Extensions"]) {
+			if acceptDeflateOffer(ext) {
+				compress = true
+				break
+			}
+		}
+	}
+
+	if r.ProtoMajor == 2 {
+		return u.upgradeHTTP2(w, r, responseHeader, subprotocol, compress)
+	}
+
+	h, ok := w.(http.Hijacker)
+	if !ok {
+		return u.fail(w, http.StatusInternalServerError, "websocket: response does not implement http.Hijacker")
+	}
+	netConn, brw, err := h.Hijack()
+	if err != nil {
+		return u.fail(w, http.StatusInternalServerError, err.Error())
+	}
+	// Clear any deadlines set by the server for the HTTP request.
+	netConn.SetDeadline(time.Time{})
+
+	bw := bufio.NewWriterSize(netConn, defaultWriteBufferSize+maxFrameHeaderSize)
+	bw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	bw.WriteString(computeAcceptKey(key))
+	bw.WriteString("\r\n")
+	if subprotocol != "" {
+		bw.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compress {
+		bw.WriteString("Sec-WebSocket-Extensions: " + deflateResponse + "\r\n")
+	}
+	responseHeader.Write(bw)
+	bw.WriteString("\r\n")
+	if err := bw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return newConn(netConn, brw.Reader, bw, true, subprotocol, compress), nil
+}
+
+// upgradeHTTP2 accepts an extended CONNECT request (RFC 8441).
+func (u *Upgrader) upgradeHTTP2(w http.ResponseWriter, r *http.Request, responseHeader http.Header, subprotocol string, compress bool) (*Conn, error) {
+	h := w.Header()
+	for k, vv := range responseHeader {
+		h[k] = append(h[k], vv...)
+	}
+	if subprotocol != "" {
+		h.Set("Sec-Websocket-Protocol", subprotocol)
+	}
+	if compress {
+		h.Set("Sec-Websocket-Extensions", deflateResponse)
+	}
+	rwc, err := http.AcceptConnect(w, r)
+	if err != nil {
+		return u.fail(w, http.StatusInternalServerError, err.Error())
+	}
+	return newConn(rwc, nil, nil, true, subprotocol, compress), nil
+}
+
+func (u *Upgrader) selectSubprotocol(r *http.Request) string {
+	requested := subprotocols(r.Header)
+	for _, s := range u.Subprotocols {
+		for _, t := range requested {
+			if s == t {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// subprotocols returns the subprotocols listed in the
+// Sec-WebSocket-Protocol headers of h.
+func subprotocols(h http.Header) []string {
+	var protocols []string
+	for _, v := range h["Sec-Websocket-Protocol"] {
+		for _, p := range strings.Split(v, ",") {
+			if p = textproto.TrimString(p); p != "" {
+				protocols = append(protocols, p)
+			}
+		}
+	}
+	return protocols
+}
+
+func (u *Upgrader) fail(w http.ResponseWriter, status int, reason string) (*Conn, error) {
+	err := errors.New(reason)
+	http.Error(w, http.StatusText(status), status)
+	return nil, err
+}
+
+// checkSameOrigin reports whether the Origin header, if present, has the
+// same host as the request.
+func checkSameOrigin(r *http.Request) bool {
+	origin := r.Header["Origin"]
+	if len(origin) == 0 {
+		return true
+	}
+	u, err := url.Parse(origin[0])
+	if err != nil {
+		return false
+	}
+	return ascii.EqualFold(u.Host, r.Host)
+}
@@ -0,0 +1,914 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package websocket implements the WebSocket protocol defined in RFC 6455,
+// including the permessage-deflate extension defined in RFC 7692.
+//
+// Servers accept WebSocket connections from an http.Handler using an
+// Upgrader. On HTTP/1.1 connections the handshake upgrades the connection
+// by hijacking it. On HTTP/2 connections the client opens the WebSocket
+// with an extended CONNECT request (RFC 8441) and the WebSocket runs on
+// that request's stream. Servers only accept such requests when
+// extended CONNECT is enabled with the EnableExtendedConnectProtocol
+// field of http.HTTP2Config.
+//
+// Clients connect using Dial or a Dialer. The opening handshake is sent
+// through an http.RoundTripper, so the proxy, TLS and dialing configuration
+// of the Transport apply to WebSocket connections too.
+//
+// A Conn supports one concurrent reader and one concurrent writer.
+// Applications must read from the connection for control frames, such as
+// pings and close messages, to be processed.
+package websocket
+
+import (
+	"bufio"
+	"compress/flate"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType is the type of a data message.
+type MessageType int
+
+const (
+	TextMessage   MessageType = opText   // UTF-8 encoded text
+	BinaryMessage MessageType = opBinary // binary data
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case TextMessage:
+		return "TextMessage"
+	case BinaryMessage:
+		return "BinaryMessage"
+	}
+	return "MessageType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// StatusCode is a close status code, as defined in RFC 6455, section 7.4.
+type StatusCode int
+
+const (
+	StatusNormalClosure           StatusCode = 1000
+	StatusGoingAway               StatusCode = 1001
+	StatusProtocolError           StatusCode = 1002
+	StatusUnsupportedData         StatusCode = 1003
+	StatusNoStatusReceived        StatusCode = 1005 // never sent in a close frame
+	StatusAbnormalClosure         StatusCode = 1006 // never sent in a close frame
+	StatusInvalidFramePayloadData StatusCode = 1007
+	StatusPolicyViolation         StatusCode = 1008
+	StatusMessageTooBig           StatusCode = 1009
+	StatusMandatoryExtension      StatusCode = 1010
+	StatusInternalError           StatusCode = 1011
+	StatusServiceRestart          StatusCode = 1012
+	StatusTryAgainLater           StatusCode = 1013
+	StatusBadGateway              StatusCode = 1014
+	StatusTLSHandshake            StatusCode = 1015 // never sent in a close frame
+)
+
+// validSentCode reports whether code may be sent in a close frame.
+func validSentCode(code StatusCode) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		// Registered with IANA or reserved for private use.
+		return true
+	}
+	return false
+}
+
+// CloseError is the error returned by reads after the peer sent a close
+// frame.
+type CloseError struct {
+	Code   StatusCode
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket: close %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: close %d: %s", e.Code, e.Reason)
+}
+
+var (
+	// ErrClosed is returned when writing to or reading from a
+	// connection after it was closed.
+	ErrClosed = errors.New("websocket: use of closed connection")
+
+	// ErrReadLimit is returned when reading a message larger than
+	// the connection's read limit.
+	ErrReadLimit = errors.New("websocket: read limit exceeded")
+
+	// ErrBadHandshake is returned by Dial when the server's response
+	// to the opening handshake is not valid.
+	ErrBadHandshake = errors.New("websocket: bad handshake")
+)
+
+// protocolError is a violation of the protocol by the peer. The
+// connection is failed with the status code.
+type protocolError struct {
+	code StatusCode
+	msg  string
+}
+
+func (e *protocolError) Error() string { return "websocket: " + e.msg }
+
+func newProtocolError(msg string) error {
+	return &protocolError{StatusProtocolError, msg}
+}
+
+const (
+	// DefaultReadLimit is the default maximum size of a message read
+	// from a connection. See Conn.SetReadLimit.
+	DefaultReadLimit = 32 << 20
+
+	// closeTimeout bounds how long Close waits for the peer's close frame.
+	closeTimeout = 5 * time.Second
+
+	defaultReadBufferSize  = 4096
+	defaultWriteBufferSize = 4096
+)
+
+// A Conn is a WebSocket connection.
+//
+// Applications may call the read methods (NextReader and ReadMessage)
+// from one goroutine and the write methods (NextWriter and
+// WriteMessage) from another goroutine. Ping and Close may be called
+// concurrently with all other methods.
+type Conn struct {
+	rwc         io.ReadWriteCloser
+	isServer    bool
+	subprotocol string
+	compress    bool // permessage-deflate was negotiated
+
+	// readMu is held by the goroutine reading frames. It is a
+	// channel so that Close can tell whether a read is in progress.
+	readMu     chan struct{}
+	br         *bufio.Reader
+	readLimit  int64
+	readRemain int64          // bytes left in the payload of the current frame
+	readFinal  bool           // current frame is the last of its message
+	readKey    [4]byte        // masking key of the current frame
+	readPos    int            // masking key position
+	reader     *messageReader // current message, or nil
+	readErr    error          // sticky read error
+	flateR     io.ReadCloser
+
+	// msgMu is held while a message is being written.
+	msgMu           sync.Mutex
+	writeMu         sync.Mutex // guards the fields below and the write of a frame
+	bw              *bufio.Writer
+	hdrBuf          []byte
+	maskBuf         []byte
+	writeErr        error
+	closeSent       bool
+	writeBufferSize int
+
+	pingMu sync.Mutex
+	pings  map[string]chan struct{}
+
+	closeRecvOnce sync.Once
+	closeRecv     chan struct{} // closed once a close frame was read
+	closeOnce     sync.Once
+	closed        chan struct{} // closed once rwc is closed
+}
+
+func newConn(rwc io.ReadWriteCloser, br *bufio.Reader, bw *bufio.Writer, isServer bool, subprotocol string, compress bool) *Conn {
+	if br == nil {
+		br = bufio.NewReaderSize(rwc, defaultReadBufferSize)
+	}
+	if bw == nil {
+		bw = bufio.NewWriterSize(rwc, defaultWriteBufferSize+maxFrameHeaderSize)
+	}
+	return &Conn{
+		rwc:             rwc,
+		isServer:        isServer,
+		subprotocol:     subprotocol,
+		compress:        compress,
+		readMu:          make(chan struct{}, 1),
+		br:              br,
+		readLimit:       DefaultReadLimit,
+		readFinal:       true,
+		bw:              bw,
+		writeBufferSize: defaultWriteBufferSize,
+		pings:           make(map[string]chan struct{}),
+		closeRecv:       make(chan struct{}),
+		closed:          make(chan struct{}),
+	}
+}
+
+// Subprotocol returns the subprotocol negotiated during the opening
+// handshake, or the empty string if none was.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Compressed reports whether the permessage-deflate extension was
+// negotiated during the opening handshake.
+func (c *Conn) Compressed() bool {
+	return c.compress
+}
+
+// SetReadLimit sets the maximum size in bytes of a message read from the
+// peer, after decompression. If a message exceeds the limit, the
+// connection is closed with StatusMessageTooBig and reads return
+// ErrReadLimit. The default limit is DefaultReadLimit.
+func (c *Conn) SetReadLimit(n int64) {
+	c.lockRead()
+	c.readLimit = n
+	c.unlockRead()
+}
+
+func (c *Conn) lockRead()   { c.readMu <- struct{}{} }
+func (c *Conn) unlockRead() { <-c.readMu }
+
+// NextReader returns the next data message received from the peer.
+// Any unread part of the previous message is discarded. Control frames
+// received before the message are processed: pings are answered and
+// a close frame ends the connection, in which case the returned error
+// is a *CloseError.
+//
+// The returned reader is valid until the next call to NextReader or
+// ReadMessage.
+func (c *Conn) NextReader() (MessageType, io.Reader, error) {
+	c.lockRead()
+	defer c.unlockRead()
+	return c.nextReader()
+}
+
+// ReadMessage reads the next data message using NextReader and returns
+// its contents.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	typ, r, err := c.NextReader()
+	if err != nil {
+		return typ, nil, err
+	}
+	p, err := io.ReadAll(r)
+	return typ, p, err
+}
+
+// nextReader implements NextReader. The caller holds readMu.
+func (c *Conn) nextReader() (MessageType, io.Reader, error) {
+	if c.reader != nil {
+		// Discard the rest of the previous message.
+		c.reader.stale = true
+		c.reader = nil
+		for c.readErr == nil && (c.readRemain > 0 || !c.readFinal) {
+			if err := c.discardFrame(); err != nil {
+				return 0, nil, err
+			}
+		}
+	}
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+
+	h, err := c.nextFrame()
+	if err != nil {
+		return 0, nil, err
+	}
+	if h.opcode == opContinuation {
+		return 0, nil, c.fail(newProtocolError("continuation frame without message"))
+	}
+	if h.rsv1 && !c.compress {
+		return 0, nil, c.fail(newProtocolError("unexpected RSV1 bit"))
+	}
+
+	mr := &messageReader{c: c, typ: MessageType(h.opcode), eof: h.fin && h.length == 0}
+	var src io.Reader = rawReader{mr}
+	if h.rsv1 {
+		src = c.decompressor(src)
+	}
+	mr.src = src
+	c.reader = mr
+	return mr.typ, mr, nil
+}
+
+// nextFrame reads frame headers until it reads the header of a data
+// frame, processing any control frames. The payload of the data frame
+// is read by readPayload. The caller holds readMu.
+func (c *Conn) nextFrame() (frameHeader, error) {
+	for {
+		h, err := readFrameHeader(c.br)
+		if err != nil {
+			return h, c.fail(err)
+		}
+		if err := c.checkFrameHeader(h); err != nil {
+			return h, c.fail(err)
+		}
+		if isControl(h.opcode) {
+			if err := c.handleControl(h); err != nil {
+				return h, err
+			}
+			continue
+		}
+		c.readRemain = h.length
+		c.readFinal = h.fin
+		c.readKey = h.key
+		c.readPos = 0
+		return h, nil
+	}
+}
+
+func (c *Conn) checkFrameHeader(h frameHeader) error {
+	if h.rsv2 || h.rsv3 {
+		return newProtocolError("unexpected reserved bits")
+	}
+	if h.masked != c.isServer {
+		if c.isServer {
+			return newProtocolError("client frame is not masked")
+		}
+		return newProtocolError("server frame is masked")
+	}
+	switch {
+	case isControl(h.opcode):
+		if h.opcode != opClose && h.opcode != opPing && h.opcode != opPong {
+			return newProtocolError(fmt.Sprintf("unknown opcode %d", h.opcode))
+		}
+		if !h.fin {
+			return newProtocolError("fragmented control frame")
+		}
+		if h.length > maxControlPayload {
+			return newProtocolError("control frame too long")
+		}
+		if h.rsv1 {
+			return newProtocolError("unexpected RSV1 bit")
+		}
+	case isData(h.opcode):
+		if h.rsv1 && h.opcode == opContinuation {
+			return newProtocolError("RSV1 bit set on continuation frame")
+		}
+	default:
+		return newProtocolError(fmt.Sprintf("unknown opcode %d", h.opcode))
+	}
+	return nil
+}
+
+// handleControl reads the payload of the control frame h and acts on it.
+func (c *Conn) handleControl(h frameHeader) error {
+	payload := make([]byte, h.length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return c.fail(unexpectedEOF(err))
+	}
+	if h.masked {
+		maskBytes(h.key, 0, payload)
+	}
+	switch h.opcode {
+	case opPing:
+		// A failed write surfaces on the next write or read.
+		c.writeControl(opPong, payload)
+		return nil
+	case opPong:
+		c.pingMu.Lock()
+		if ch, ok := c.pings[string(payload)]; ok {
+			close(ch)
+			delete(c.pings, string(payload))
+		}
+		c.pingMu.Unlock()
+		return nil
+	}
+
+	// opClose.
+	ce := &CloseError{Code: StatusNoStatusReceived}
+	switch {
+	case len(payload) == 1:
+		return c.fail(newProtocolError("invalid close payload"))
+	case len(payload) >= 2:
+		ce.Code = StatusCode(payload[0])<<8 | StatusCode(payload[1])
+		ce.Reason = string(payload[2:])
+		if !validSentCode(ce.Code) {
+			return c.fail(newProtocolError(fmt.Sprintf("invalid close code %d", ce.Code)))
+		}
+		if !utf8.ValidString(ce.Reason) {
+			return c.fail(&protocolError{StatusInvalidFramePayloadData, "invalid UTF-8 in close reason"})
+		}
+	}
+	c.readErr = ce
+	c.closeRecvOnce.Do(func() { close(c.closeRecv) })
+
+	// Echo the close frame, completing the closing handshake.
+	c.writeClose(ce.Code, "")
+	c.closeNow()
+	return ce
+}
+
+// readPayload reads from the payload of the current message into p,
+// reading the headers of continuation frames as needed. The caller
+// holds readMu.
+func (c *Conn) readPayload(p []byte) (int, error) {
+	for c.readRemain == 0 {
+		if c.readFinal {
+			return 0, io.EOF
+		}
+		h, err := c.nextFrame()
+		if err != nil {
+			return 0, err
+		}
+		if h.opcode != opContinuation {
+			return 0, c.fail(newProtocolError("expected continuation frame"))
+		}
+	}
+	if int64(len(p)) > c.readRemain {
+		p = p[:c.readRemain]
+	}
+	n, err := c.br.Read(p)
+	c.readRemain -= int64(n)
+	if c.isServer {
+		c.readPos = maskBytes(c.readKey, c.readPos, p[:n])
+	}
+	if err != nil {
+		return n, c.fail(unexpectedEOF(err))
+	}
+	return n, nil
+}
+
+// discardFrame discards the rest of the current frame's payload and
+// reads the header of the next frame of the message, if any.
+func (c *Conn) discardFrame() error {
+	if c.readRemain > 0 {
+		n, err := c.br.Discard(int(minInt64(c.readRemain, 1<<30)))
+		c.readRemain -= int64(n)
+		if err != nil {
+			return c.fail(unexpectedEOF(err))
+		}
+		return nil
+	}
+	if c.readFinal {
+		return nil
+	}
+	h, err := c.nextFrame()
+	if err != nil {
+		return err
+	}
+	if h.opcode != opContinuation {
+		return c.fail(newProtocolError("expected continuation frame"))
+	}
+	return nil
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// fail records err as the sticky read error. If err is a protocol
+// violation, the peer is sent a close frame with the corresponding
+// status code. The connection is then closed.
+func (c *Conn) fail(err error) error {
+	if c.readErr != nil {
+		return c.readErr
+	}
+	var pe *protocolError
+	switch {
+	case errors.As(err, &pe):
+		c.writeClose(pe.code, "")
+	case err == ErrReadLimit:
+		c.writeClose(StatusMessageTooBig, "")
+	}
+	select {
+	case <-c.closed:
+		// The connection was closed by Close or CloseNow;
+		// report that instead of the resulting I/O error.
+		if pe == nil && err != ErrReadLimit {
+			err = ErrClosed
+		}
+	default:
+	}
+	c.readErr = err
+	c.closeNow()
+	return err
+}
+
+// messageReader reads a single data message.
+type messageReader struct {
+	c     *Conn
+	typ   MessageType
+	src   io.Reader // payload, decompressed if needed
+	n     int64     // bytes returned so far
+	eof   bool      // the raw payload was read to its end
+	stale bool      // a later message was started
+	err   error
+	utf8  utf8Validator
+}
+
+// rawReader reads the payload of the message, across frames.
+type rawReader struct{ r *messageReader }
+
+func (rr rawReader) Read(p []byte) (int, error) {
+	r := rr.r
+	if r.eof {
+		return 0, io.EOF
+	}
+	n, err := r.c.readPayload(p)
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+func (r *messageReader) Read(p []byte) (int, error) {
+	c := r.c
+	c.lockRead()
+	defer c.unlockRead()
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.stale {
+		return 0, errors.New("websocket: read of message after NextReader")
+	}
+	if c.readErr != nil {
+		return 0, c.readErr
+	}
+	if rem := c.readLimit - r.n; int64(len(p)) > rem+1 {
+		// Read one byte more than the limit to detect
+		// messages that exceed it.
+		p = p[:rem+1]
+	}
+	n, err := r.src.Read(p)
+	r.n += int64(n)
+	if r.n > c.readLimit {
+		r.err = c.fail(ErrReadLimit)
+		return 0, r.err
+	}
+	if r.typ == TextMessage {
+		if !r.utf8.valid(p[:n], err == io.EOF) {
+			r.err = c.fail(&protocolError{StatusInvalidFramePayloadData, "invalid UTF-8 in text message"})
+			return 0, r.err
+		}
+	}
+	if err == io.EOF {
+		c.reader = nil
+	} else if err != nil {
+		if c.readErr != nil {
+			err = c.readErr
+		} else {
+			// A decompression error.
+			err = c.fail(&protocolError{StatusInvalidFramePayloadData, err.Error()})
+		}
+	}
+	r.err = err
+	return n, err
+}
+
+// utf8Validator incrementally checks that a text message is valid
+// UTF-8, allowing code points to be split across reads.
+type utf8Validator struct {
+	pending []byte // start of an incomplete rune from the previous read
+}
+
+func (v *utf8Validator) valid(p []byte, final bool) bool {
+	for len(v.pending) > 0 && len(p) > 0 && !utf8.FullRune(v.pending) {
+		v.pending = append(v.pending, p[0])
+		p = p[1:]
+	}
+	if len(v.pending) > 0 {
+		if !utf8.FullRune(v.pending) {
+			return !final
+		}
+		if !utf8.Valid(v.pending) {
+			return false
+		}
+		v.pending = v.pending[:0]
+	}
+	cut := len(p)
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	if !utf8.Valid(p[:cut]) {
+		return false
+	}
+	v.pending = append(v.pending, p[cut:]...)
+	return !final || len(v.pending) == 0
+}
+
+// NextWriter returns a writer for the next message to send. The message
+// is sent in one or more frames as data is written; the writer's Close
+// method sends the final frame. Only one message writer may be open at
+// a time: NextWriter blocks until the previous writer is closed.
+func (c *Conn) NextWriter(typ MessageType) (io.WriteCloser, error) {
+	if typ != TextMessage && typ != BinaryMessage {
+		return nil, errors.New("websocket: invalid message type")
+	}
+	c.msgMu.Lock()
+	if err := c.writeState(); err != nil {
+		c.msgMu.Unlock()
+		return nil, err
+	}
+	w := &messageWriter{
+		c:        c,
+		opcode:   byte(typ),
+		compress: c.compress,
+		buf:      make([]byte, 0, c.writeBufferSize),
+	}
+	if w.compress {
+		w.flate = c.compressor(&w.trim)
+		w.trim.w = w
+	}
+	return w, nil
+}
+
+// WriteMessage sends a complete message in a single frame.
+func (c *Conn) WriteMessage(typ MessageType, p []byte) error {
+	if typ != TextMessage && typ != BinaryMessage {
+		return errors.New("websocket: invalid message type")
+	}
+	if c.compress {
+		w, err := c.NextWriter(typ)
+		if err != nil {
+			return err
+		}
+		w.(*messageWriter).single = true
+		if _, err := w.Write(p); err != nil {
+			w.Close()
+			return err
+		}
+		return w.Close()
+	}
+	c.msgMu.Lock()
+	defer c.msgMu.Unlock()
+	return c.writeFrame(byte(typ), true, false, p)
+}
+
+// writeState returns the error any write would return now.
+func (c *Conn) writeState() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.writeErr != nil {
+		return c.writeErr
+	}
+	if c.closeSent {
+		return ErrClosed
+	}
+	return nil
+}
+
+// messageWriter writes a data message. It buffers up to
+// writeBufferSize bytes of payload before sending a frame.
+type messageWriter struct {
+	c        *Conn
+	opcode   byte // opcode of the next frame
+	buf      []byte
+	sent     bool // a frame was sent
+	single   bool // send the message in one frame
+	closed   bool
+	err      error
+	compress bool
+	flate    *flate.Writer
+	trim     trimWriter
+}
+
+func (w *messageWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("websocket: write to closed message writer")
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.compress {
+		n, err := w.flate.Write(p)
+		if err != nil && w.err == nil {
+			w.err = err
+		}
+		return n, err
+	}
+	return w.writeRaw(p)
+}
+
+// writeRaw buffers payload bytes, sending full frames as needed.
+func (w *messageWriter) writeRaw(p []byte) (int, error) {
+	nn := 0
+	for len(p) > 0 {
+		if len(w.buf) == cap(w.buf) && !w.single {
+			if err := w.flushFrame(false); err != nil {
+				return nn, err
+			}
+		}
+		n := len(p)
+		if !w.single && n > cap(w.buf)-len(w.buf) {
+			n = cap(w.buf) - len(w.buf)
+		}
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		nn += n
+	}
+	return nn, nil
+}
+
+func (w *messageWriter) flushFrame(final bool) error {
+	// Only the first frame of a compressed message has RSV1 set.
+	rsv1 := w.compress && !w.sent
+	err := w.c.writeFrame(w.opcode, final, rsv1, w.buf)
+	w.opcode = opContinuation
+	w.sent = true
+	w.buf = w.buf[:0]
+	if err != nil {
+		w.err = err
+	}
+	return err
+}
+
+// Close sends the final frame of the message.
+func (w *messageWriter) Close() error {
+	if w.closed {
+		return errors.New("websocket: close of closed message writer")
+	}
+	w.closed = true
+	defer w.c.msgMu.Unlock()
+	if w.compress && w.err == nil {
+		if err := w.flate.Flush(); err != nil && w.err == nil {
+			w.err = err
+		}
+		w.c.releaseCompressor(w.flate)
+		// The sync flush ends with 0x00 0x00 0xff 0xff, which is
+		// removed from the message (RFC 7692, section 7.2.1).
+		if w.err == nil && !w.trim.valid() {
+			w.err = errors.New("websocket: internal error: bad flate flush")
+		}
+	}
+	if w.err != nil {
+		return w.err
+	}
+	return w.flushFrame(true)
+}
+
+// writeFrame sends a single frame. Data frames of a message are
+// serialized by msgMu; control frames may be sent between them.
+func (c *Conn) writeFrame(opcode byte, fin, rsv1 bool, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.writeErr != nil {
+		return c.writeErr
+	}
+	if c.closeSent {
+		return ErrClosed
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+
+	h := frameHeader{
+		fin:    fin,
+		rsv1:   rsv1,
+		opcode: opcode,
+		masked: !c.isServer,
+		length: int64(len(payload)),
+	}
+	if h.masked {
+		if _, err := io.ReadFull(rand.Reader, h.key[:]); err != nil {
+			c.writeErr = err
+			return err
+		}
+	}
+	c.hdrBuf = appendFrameHeader(c.hdrBuf[:0], h)
+	c.bw.Write(c.hdrBuf)
+	if h.masked {
+		// Mask a copy of the payload; p belongs to the caller.
+		pos := 0
+		for len(payload) > 0 {
+			n := len(payload)
+			if n > defaultWriteBufferSize {
+				n = defaultWriteBufferSize
+			}
+			c.maskBuf = append(c.maskBuf[:0], payload[:n]...)
+			pos = maskBytes(h.key, pos, c.maskBuf)
+			c.bw.Write(c.maskBuf)
+			payload = payload[n:]
+		}
+	} else {
+		c.bw.Write(payload)
+	}
+	if err := c.bw.Flush(); err != nil {
+		select {
+		case <-c.closed:
+			err = ErrClosed
+		default:
+		}
+		c.writeErr = err
+		return err
+	}
+	return nil
+}
+
+// writeControl sends a control frame.
+func (c *Conn) writeControl(opcode byte, payload []byte) error {
+	return c.writeFrame(opcode, true, false, payload)
+}
+
+// writeClose sends a close frame, unless one was already sent.
+func (c *Conn) writeClose(code StatusCode, reason string) error {
+	var payload []byte
+	if code != StatusNoStatusReceived {
+		payload = make([]byte, 2, 2+len(reason))
+		payload[0] = byte(code >> 8)
+		payload[1] = byte(code)
+		payload = append(payload, reason...)
+	}
+	return c.writeControl(opClose, payload)
+}
+
+// Ping sends a ping frame to the peer and waits for the corresponding
+// pong frame. Pongs are processed by reads, so another goroutine must
+// be reading from the connection for Ping to return successfully.
+func (c *Conn) Ping(ctx context.Context) error {
+	var p [8]byte
+	if _, err := io.ReadFull(rand.Reader, p[:]); err != nil {
+		return err
+	}
+	ch := make(chan struct{})
+	c.pingMu.Lock()
+	c.pings[string(p[:])] = ch
+	c.pingMu.Unlock()
+	defer func() {
+		c.pingMu.Lock()
+		delete(c.pings, string(p[:]))
+		c.pingMu.Unlock()
+	}()
+
+	if err := c.writeControl(opPing, p[:]); err != nil {
+		return err
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-c.closed:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close performs the closing handshake: it sends a close frame with the
+// given status code and reason, waits for the peer's close frame and
+// then closes the underlying connection. If another goroutine is
+// reading from the connection, Close waits for it to receive the
+// peer's close frame; otherwise Close reads and discards messages
+// itself. Close gives up waiting after a few seconds.
+//
+// The reason must be at most 123 bytes long. To close a connection
+// without sending a status code, use StatusNoStatusReceived.
+func (c *Conn) Close(code StatusCode, reason string) error {
+	if code != StatusNoStatusReceived && !validSentCode(code) {
+		return fmt.Errorf("websocket: invalid close code %d", code)
+	}
+	if len(reason) > maxControlPayload-2 {
+		return errors.New("websocket: close reason too long")
+	}
+	if err := c.writeClose(code, reason); err != nil {
+		c.closeNow()
+		if err == ErrClosed {
+			// Already closing.
+			return nil
+		}
+		return err
+	}
+
+	timer := time.AfterFunc(closeTimeout, func() { c.closeNow() })
+	defer timer.Stop()
+	select {
+	case c.readMu <- struct{}{}:
+		// No read is in progress. Read until the peer's close
+		// frame or an error.
+		for {
+			if _, _, err := c.nextReader(); err != nil {
+				break
+			}
+		}
+		c.unlockRead()
+	case <-c.closeRecv:
+	case <-c.closed:
+	}
+	return c.closeNow()
+}
+
+// CloseNow closes the underlying connection without sending a close
+// frame.
+func (c *Conn) CloseNow() error {
+	return c.closeNow()
+}
+
+func (c *Conn) closeNow() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		err = c.rwc.Close()
+	})
+	return err
+}
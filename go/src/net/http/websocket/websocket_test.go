@@ -0,0 +1,425 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newEchoServer starts a server echoing every message it receives.
+func newEchoServer(t *testing.T, u *Upgrader) *httptest.Server {
+	ts := httptest.NewServer(echoHandler(u))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func echoHandler(u *Upgrader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := u.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.CloseNow()
+		for {
+			typ, r, err := c.NextReader()
+			if err != nil {
+				return
+			}
+			w, err := c.NextWriter(typ)
+			if err != nil {
+				return
+			}
+			if _, err := io.Copy(w, r); err != nil {
+				return
+			}
+			if err := w.Close(); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func wsURL(ts *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func dial(t *testing.T, d *Dialer, url string) *Conn {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, _, err := d.Dial(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.CloseNow() })
+	return c
+}
+
+func TestEcho(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		ts := newEchoServer(t, &Upgrader{EnableCompression: compress})
+		c := dial(t, &Dialer{EnableCompression: compress}, wsURL(ts))
+		if c.Compressed() != compress {
+			t.Fatalf("Compressed() = %v; want %v", c.Compressed(), compress)
+		}
+
+		messages := []struct {
+			typ MessageType
+			p   []byte
+		}{
+			{TextMessage, []byte("hello, world")},
+			{BinaryMessage, []byte{0, 1, 2, 0xff}},
+			{TextMessage, nil},
+			{BinaryMessage, bytes.Repeat([]byte("0123456789"), 10000)},
+		}
+		for _, m := range messages {
+			if err := c.WriteMessage(m.typ, m.p); err != nil {
+				t.Fatal(err)
+			}
+			typ, p, err := c.ReadMessage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if typ != m.typ || !bytes.Equal(p, m.p) {
+				t.Errorf("compress=%v: got %v message of %d bytes; want %v message of %d bytes", compress, typ, len(p), m.typ, len(m.p))
+			}
+		}
+	}
+}
+
+// tunnel is the client side of an extended CONNECT stream.
+type tunnel struct {
+	io.ReadCloser
+	io.Writer
+}
+
+func TestEchoHTTP2(t *testing.T) {
+	ts := httptest.NewUnstartedServer(echoHandler(&Upgrader{}))
+	ts.EnableHTTP2 = true
+	ts.Config.HTTP2 = &http.HTTP2Config{EnableExtendedConnectProtocol: true}
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest("CONNECT", ts.URL+"/chat", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Proto = "websocket"
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	res, err := ts.Client().Transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || res.ProtoMajor != 2 {
+		t.Fatalf("got %v over %v; want 200 over HTTP/2", res.Status, res.Proto)
+	}
+	c := newConn(tunnel{res.Body, pw}, nil, nil, false, "", false)
+	defer c.CloseNow()
+	if err := c.WriteMessage(TextMessage, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	typ, p, err := c.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != TextMessage || string(p) != "hello" {
+		t.Errorf("got %v message %q; want text message %q", typ, p, "hello")
+	}
+}
+
+func TestFragmentedMessage(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		ts := newEchoServer(t, &Upgrader{EnableCompression: compress})
+		c := dial(t, &Dialer{EnableCompression: compress}, wsURL(ts))
+
+		w, err := c.NextWriter(TextMessage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want strings.Builder
+		for i := 0; i < 1000; i++ {
+			s := strings.Repeat("héllo ", i%20)
+			want.WriteString(s)
+			if _, err := io.WriteString(w, s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		typ, p, err := c.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != TextMessage || string(p) != want.String() {
+			t.Errorf("compress=%v: echoed message differs; got %d bytes, want %d", compress, len(p), want.Len())
+		}
+	}
+}
+
+func TestPing(t *testing.T) {
+	ts := newEchoServer(t, &Upgrader{})
+	c := dial(t, &Dialer{}, wsURL(ts))
+	go func() {
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		if err := c.Ping(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCloseHandshake(t *testing.T) {
+	got := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := (&Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			got <- err
+			return
+		}
+		_, _, err = c.ReadMessage()
+		got <- err
+	}))
+	defer ts.Close()
+
+	c := dial(t, &Dialer{}, wsURL(ts))
+	if err := c.Close(StatusGoingAway, "bye"); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	err := <-got
+	var ce *CloseError
+	if !errors.As(err, &ce) || ce.Code != StatusGoingAway || ce.Reason != "bye" {
+		t.Fatalf("server read error = %v; want close 1001 with reason", err)
+	}
+	if err := c.WriteMessage(TextMessage, []byte("x")); err != ErrClosed {
+		t.Errorf("WriteMessage after Close = %v; want %v", err, ErrClosed)
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := (&Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		c.Close(StatusNormalClosure, "")
+	}))
+	defer ts.Close()
+
+	c := dial(t, &Dialer{}, wsURL(ts))
+	_, _, err := c.ReadMessage()
+	var ce *CloseError
+	if !errors.As(err, &ce) || ce.Code != StatusNormalClosure {
+		t.Fatalf("ReadMessage error = %v; want close 1000", err)
+	}
+}
+
+func TestSubprotocol(t *testing.T) {
+	ts := newEchoServer(t, &Upgrader{Subprotocols: []string{"v2", "v1"}})
+	c := dial(t, &Dialer{Subprotocols: []string{"v1", "v2"}}, wsURL(ts))
+	if got := c.Subprotocol(); got != "v2" {
+		t.Errorf("Subprotocol() = %q; want %q", got, "v2")
+	}
+	c = dial(t, &Dialer{Subprotocols: []string{"v3"}}, wsURL(ts))
+	if got := c.Subprotocol(); got != "" {
+		t.Errorf("Subprotocol() = %q; want none", got)
+	}
+}
+
+func TestBadHandshake(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not a websocket endpoint", http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	_, resp, err := Dial(context.Background(), wsURL(ts), nil)
+	if !errors.Is(err, ErrBadHandshake) {
+		t.Fatalf("Dial error = %v; want ErrBadHandshake", err)
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Dial response = %v; want 404 response", resp)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "not a websocket endpoint") {
+		t.Errorf("response body = %q", body)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	ts := newEchoServer(t, &Upgrader{})
+	h := http.Header{"Origin": {"http://evil.example"}}
+	_, resp, err := Dial(context.Background(), wsURL(ts), h)
+	if !errors.Is(err, ErrBadHandshake) || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Dial with foreign origin: err = %v, resp = %v; want 403", err, resp)
+	}
+	h = http.Header{"Origin": {ts.URL}}
+	c, _, err := Dial(context.Background(), wsURL(ts), h)
+	if err != nil {
+		t.Fatalf("Dial with same origin: %v", err)
+	}
+	c.CloseNow()
+}
+
+func TestUpgradeRejectsPlainRequest(t *testing.T) {
+	ts := newEchoServer(t, &Upgrader{})
+	res, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("StatusCode = %d; want %d", res.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestReadLimit(t *testing.T) {
+	got := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := (&Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			got <- err
+			return
+		}
+		c.SetReadLimit(10)
+		_, _, err = c.ReadMessage()
+		got <- err
+	}))
+	defer ts.Close()
+
+	c := dial(t, &Dialer{}, wsURL(ts))
+	if err := c.WriteMessage(BinaryMessage, make([]byte, 11)); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-got; err != ErrReadLimit {
+		t.Fatalf("server read error = %v; want %v", err, ErrReadLimit)
+	}
+	_, _, err := c.ReadMessage()
+	var ce *CloseError
+	if !errors.As(err, &ce) || ce.Code != StatusMessageTooBig {
+		t.Errorf("client read error = %v; want close 1009", err)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	got := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := (&Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			got <- err
+			return
+		}
+		_, _, err = c.ReadMessage()
+		got <- err
+	}))
+	defer ts.Close()
+
+	c := dial(t, &Dialer{}, wsURL(ts))
+	if err := c.WriteMessage(TextMessage, []byte("ok \xff")); err != nil {
+		t.Fatal(err)
+	}
+	var pe *protocolError
+	if err := <-got; !errors.As(err, &pe) || pe.code != StatusInvalidFramePayloadData {
+		t.Fatalf("server read error = %v; want invalid payload error", err)
+	}
+}
+
+func TestFrameHeaderRoundTrip(t *testing.T) {
+	for _, h := range []frameHeader{
+		{fin: true, opcode: opText, length: 0},
+		{fin: false, rsv1: true, opcode: opBinary, length: 125},
+		{fin: true, opcode: opContinuation, length: 126},
+		{fin: true, opcode: opPing, masked: true, key: [4]byte{1, 2, 3, 4}, length: 0xffff},
+		{fin: true, opcode: opBinary, masked: true, key: [4]byte{5, 6, 7, 8}, length: 1 << 40},
+	} {
+		b := appendFrameHeader(nil, h)
+		got, err := readFrameHeader(bufio.NewReader(bytes.NewReader(b)))
+		if err != nil {
+			t.Errorf("%+v: %v", h, err)
+			continue
+		}
+		if got != h {
+			t.Errorf("round trip of %+v = %+v", h, got)
+		}
+	}
+
+	// Lengths must use the minimal encoding.
+	for _, b := range [][]byte{
+		{0x82, 126, 0, 125},
+		{0x82, 127, 0, 0, 0, 0, 0, 0, 0xff, 0xff},
+		{0x82, 127, 0x80, 0, 0, 0, 0, 0, 0, 0},
+	} {
+		if _, err := readFrameHeader(bufio.NewReader(bytes.NewReader(b))); err != errInvalidLength {
+			t.Errorf("readFrameHeader(%x) error = %v; want %v", b, err, errInvalidLength)
+		}
+	}
+}
+
+func TestUTF8Validator(t *testing.T) {
+	tests := []struct {
+		chunks []string
+		valid  bool
+	}{
+		{[]string{"hello"}, true},
+		{[]string{"h\xc3", "\xa9llo"}, true},
+		{[]string{"\xe2", "\x82", "\xac"}, true},
+		{[]string{"\xf0\x9f", "\x98\x80", ""}, true},
+		{[]string{"h\xc3"}, false},
+		{[]string{"\xff"}, false},
+		{[]string{"\xe2\x82", "x"}, false},
+		{[]string{"\xed\xa0\x80"}, false}, // surrogate
+	}
+	for _, tt := range tests {
+		var v utf8Validator
+		ok := true
+		for i, c := range tt.chunks {
+			if !v.valid([]byte(c), i == len(tt.chunks)-1) {
+				ok = false
+				break
+			}
+		}
+		if ok != tt.valid {
+			t.Errorf("%q: valid = %v; want %v", tt.chunks, ok, tt.valid)
+		}
+	}
+}
+
+func TestComputeAcceptKey(t *testing.T) {
+	// Example from RFC 6455, section 1.3.
+	if got, want := computeAcceptKey("dGhlIHNhbXBsZSBub25jZQ=="), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("computeAcceptKey = %q; want %q", got, want)
+	}
+}
+
+func TestParseExtensions(t *testing.T) {
+	exts := parseExtensions([]string{`permessage-deflate; client_max_window_bits, permessage-deflate; server_max_window_bits="10"`, "x-foo"})
+	if len(exts) != 3 {
+		t.Fatalf("got %d extensions; want 3", len(exts))
+	}
+	if !acceptDeflateOffer(exts[0]) {
+		t.Errorf("offer %v rejected", exts[0])
+	}
+	if acceptDeflateOffer(exts[1]) {
+		t.Errorf("offer %v with small server window accepted", exts[1])
+	}
+	if exts[2].name != "x-foo" || acceptDeflateOffer(exts[2]) {
+		t.Errorf("unexpected extension %v accepted", exts[2])
+	}
+}
@@ -19,6 +19,7 @@ package net
 import (
 	"internal/bytealg"
 	"internal/itoa"
+	"net/netip"
 )
 
 // IP address lengths (bytes).
@@ -620,6 +621,37 @@ func (n *IPNet) String() string {
 	return nn.String() + "/" + itoa.Uitoa(uint(l))
 }
 
+// Prefix returns n as a netip.Prefix. It reports false if n is nil,
+// malformed, or its mask is not in the canonical form.
+func (n *IPNet) Prefix() (netip.Prefix, bool) {
+	if n == nil {
+		return netip.Prefix{}, false
+	}
+	nn, m := networkNumberAndMask(n)
+	if nn == nil || m == nil {
+		return netip.Prefix{}, false
+	}
+	l := simpleMaskLength(m)
+	if l == -1 {
+		return netip.Prefix{}, false
+	}
+	addr, ok := netip.AddrFromSlice(nn)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(addr, l), true
+}
+
+// IPNetFromPrefix returns p as an IPNet with the host bits of its
+// address cleared. It returns nil if p is not valid.
+func IPNetFromPrefix(p netip.Prefix) *IPNet {
+	if !p.IsValid() {
+		return nil
+	}
+	p = p.Masked()
+	return &IPNet{IP: p.Addr().AsSlice(), Mask: CIDRMask(p.Bits(), p.Addr().BitLen())}
+}
+
 // Parse IPv4 address (d.d.d.d).
 // 解析IPv4地址（d.d.d.d）。
 func parseIPv4(s string) IP {
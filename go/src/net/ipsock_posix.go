@@ -10,6 +10,7 @@ package net
 import (
 	"context"
 	"internal/poll"
+	"net/netip"
 	"runtime"
 	"syscall"
 )
@@ -189,3 +190,28 @@ func ipToSockaddr(family int, ip IP, port int, zone string) (syscall.Sockaddr, e
 	}
 	return nil, &AddrError{Err: "invalid address family", Addr: ip.String()}
 }
+
+// addrPortToSockaddrInet4 is like ipToSockaddr for AF_INET but
+// converts a netip.AddrPort without allocating.
+func addrPortToSockaddrInet4(ap netip.AddrPort) (syscall.SockaddrInet4, error) {
+	addr := ap.Addr().Unmap()
+	if !addr.Is4() {
+		return syscall.SockaddrInet4{}, &AddrError{Err: "non-IPv4 address", Addr: addr.String()}
+	}
+	return syscall.SockaddrInet4{Addr: addr.As4(), Port: int(ap.Port())}, nil
+}
+
+// addrPortToSockaddrInet6 is like ipToSockaddr for AF_INET6 but
+// converts a netip.AddrPort without allocating. IPv4 addresses are
+// converted to IPv4-mapped IPv6 addresses.
+func addrPortToSockaddrInet6(ap netip.AddrPort) (syscall.SockaddrInet6, error) {
+	addr := ap.Addr()
+	if !addr.IsValid() {
+		return syscall.SockaddrInet6{}, &AddrError{Err: "non-IPv6 address", Addr: addr.String()}
+	}
+	return syscall.SockaddrInet6{
+		Addr:   addr.As16(),
+		Port:   int(ap.Port()),
+		ZoneId: uint32(zoneCache.index(addr.Zone())),
+	}, nil
+}
@@ -148,6 +148,24 @@ type Resolver struct {
 	// If nil, the default dialer is used.
 	Dial func(ctx context.Context, network, address string) (Conn, error)
 
+	// Transport optionally specifies an encrypted transport, such
+	// as DNS-over-TLS or DNS-over-HTTPS, for use by Go's built-in
+	// DNS resolver in place of classic UDP and TCP. The resolver
+	// still applies the search list, ndots, timeout, attempts and
+	// rotate settings from the system configuration, calling
+	// Transport once per name server attempt. Dial is not used
+	// when Transport is set, and setting Transport implies PreferGo.
+	// See package net/securedns for implementations.
+	Transport DNSTransport
+
+	// Cache optionally specifies a cache of DNS answers. If set,
+	// answers are kept and reused for as long as their TTLs allow,
+	// by both Go's built-in resolver and the cgo-based resolver
+	// on Unix systems.
+	// A Cache may be shared by several Resolvers.
+	// If nil, every lookup queries the name servers.
+	Cache *DNSCache
+
 	// lookupGroup merges LookupIPAddr calls together for lookups for the same
 	// host. The lookupGroup key is the LookupIPAddr.host argument.
 	// The return values are ([]IPAddr, error).
@@ -157,9 +175,30 @@ type Resolver struct {
 	// TODO(bradfitz): Timeout time.Duration?
 }
 
-func (r *Resolver) preferGo() bool     { return r != nil && r.PreferGo }
+func (r *Resolver) preferGo() bool     { return r != nil && (r.PreferGo || r.Transport != nil) }
 func (r *Resolver) strictErrors() bool { return r != nil && r.StrictErrors }
 
+func (r *Resolver) cache() *DNSCache {
+	if r == nil {
+		return nil
+	}
+	return r.Cache
+}
+
+// A DNSTransport exchanges DNS messages with a name server on
+// behalf of Go's built-in DNS resolver.
+//
+// A DNSTransport must be safe for concurrent use by multiple goroutines.
+type DNSTransport interface {
+	// RoundTrip sends query, a DNS message in wire format without
+	// a length prefix, to the name server at address and returns
+	// the server's response in the same format. The host in address
+	// is always a literal IP address taken from the system
+	// configuration; implementations may use a different port or
+	// ignore address entirely. RoundTrip must not modify query.
+	RoundTrip(ctx context.Context, address string, query []byte) ([]byte, error)
+}
+
 func (r *Resolver) getLookupGroup() *singleflight.Group {
 	if r == nil {
 		return &DefaultResolver.lookupGroup
@@ -107,7 +107,7 @@ func (r *Resolver) dial(ctx context.Context, network, server string) (Conn, erro
 func (r *Resolver) lookupHost(ctx context.Context, host string) (addrs []string, err error) {
 	order := systemConf().hostLookupOrder(r, host)
 	if !r.preferGo() && order == hostLookupCgo {
-		if addrs, err, ok := cgoLookupHost(ctx, host); ok {
+		if addrs, err, ok := r.cachedCgoLookupHost(ctx, host); ok {
 			return addrs, err
 		}
 		// cgo not available (or netgo); fall back to Go's DNS resolver
@@ -122,7 +122,7 @@ func (r *Resolver) lookupIP(ctx context.Context, network, host string) (addrs []
 	}
 	order := systemConf().hostLookupOrder(r, host)
 	if order == hostLookupCgo {
-		if addrs, err, ok := cgoLookupIP(ctx, network, host); ok {
+		if addrs, err, ok := r.cachedCgoLookupIP(ctx, network, host); ok {
 			return addrs, err
 		}
 		// cgo not available (or netgo); fall back to Go's DNS resolver
@@ -150,7 +150,7 @@ func (r *Resolver) lookupPort(ctx context.Context, network, service string) (int
 
 func (r *Resolver) lookupCNAME(ctx context.Context, name string) (string, error) {
 	if !r.preferGo() && systemConf().canUseCgo() {
-		if cname, err, ok := cgoLookupCNAME(ctx, name); ok {
+		if cname, err, ok := r.cachedCgoLookupCNAME(ctx, name); ok {
 			return cname, err
 		}
 	}
@@ -350,13 +350,59 @@ func (r *Resolver) lookupTXT(ctx context.Context, name string) ([]string, error)
 
 func (r *Resolver) lookupAddr(ctx context.Context, addr string) ([]string, error) {
 	if !r.preferGo() && systemConf().canUseCgo() {
-		if ptrs, err, ok := cgoLookupPTR(ctx, addr); ok {
+		if ptrs, err, ok := r.cachedCgoLookupPTR(ctx, addr); ok {
 			return ptrs, err
 		}
 	}
 	return r.goLookupPTR(ctx, addr)
 }
 
+// cachedCgoLookupHost is like cgoLookupHost but answers from and
+// fills r's cache, if any.
+func (r *Resolver) cachedCgoLookupHost(ctx context.Context, host string) ([]string, error, bool) {
+	v, err, ok := r.cache().lookupSystem("host", host, func() (interface{}, error, bool) {
+		return cgoLookupHost(ctx, host)
+	}, copyStrings)
+	addrs, _ := v.([]string)
+	return addrs, err, ok
+}
+
+// cachedCgoLookupIP is like cgoLookupIP but answers from and fills
+// r's cache, if any.
+func (r *Resolver) cachedCgoLookupIP(ctx context.Context, network, host string) ([]IPAddr, error, bool) {
+	v, err, ok := r.cache().lookupSystem("ip:"+network, host, func() (interface{}, error, bool) {
+		return cgoLookupIP(ctx, network, host)
+	}, func(v interface{}) interface{} {
+		return append([]IPAddr(nil), v.([]IPAddr)...)
+	})
+	addrs, _ := v.([]IPAddr)
+	return addrs, err, ok
+}
+
+// cachedCgoLookupCNAME is like cgoLookupCNAME but answers from and
+// fills r's cache, if any.
+func (r *Resolver) cachedCgoLookupCNAME(ctx context.Context, name string) (string, error, bool) {
+	v, err, ok := r.cache().lookupSystem("cname", name, func() (interface{}, error, bool) {
+		return cgoLookupCNAME(ctx, name)
+	}, nil)
+	cname, _ := v.(string)
+	return cname, err, ok
+}
+
+// cachedCgoLookupPTR is like cgoLookupPTR but answers from and fills
+// r's cache, if any.
+func (r *Resolver) cachedCgoLookupPTR(ctx context.Context, addr string) ([]string, error, bool) {
+	v, err, ok := r.cache().lookupSystem("ptr", addr, func() (interface{}, error, bool) {
+		return cgoLookupPTR(ctx, addr)
+	}, copyStrings)
+	ptrs, _ := v.([]string)
+	return ptrs, err, ok
+}
+
+func copyStrings(v interface{}) interface{} {
+	return append([]string(nil), v.([]string)...)
+}
+
 // concurrentThreadsLimit returns the number of threads we permit to
 // run concurrently doing DNS lookups via cgo. A DNS lookup may use a
 // file descriptor so we limit this to less than the number of
@@ -9,6 +9,7 @@ import (
 	"io"
 	"log"
 	"net/mail"
+	"os"
 	"strings"
 )
 
@@ -75,3 +76,20 @@ Message body
 	// Subject: Gophers at Gophercon
 	// Message body
 }
+
+func ExampleNewMessage() {
+	h := make(mail.Header)
+	h.SetAddressList("From", []*mail.Address{{Name: "Gopher", Address: "gopher@example.com"}})
+	h.SetAddressList("To", []*mail.Address{{Name: "Zoë", Address: "zoe@example.com"}})
+	h.Set("Subject", "Hello")
+
+	m, err := mail.NewMessage(h, "Hello, Zoë!\n", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The message could be written to the io.Writer returned by
+	// the Data method of an smtp.Client instead.
+	if _, err := m.WriteTo(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
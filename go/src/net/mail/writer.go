@@ -0,0 +1,489 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineLen is the line length that header folding and body
+// encodings aim for, as recommended by RFC 5322 section 2.1.1.
+const maxLineLen = 78
+
+// maxHardLineLen is the longest line allowed by RFC 5322 section
+// 2.1.1, excluding the CRLF.
+const maxHardLineLen = 998
+
+// Set sets the header entries associated with key to the single
+// element value. The key is canonicalized by
+// textproto.CanonicalMIMEHeaderKey.
+func (h Header) Set(key, value string) {
+	textproto.MIMEHeader(h).Set(key, value)
+}
+
+// Add adds the value to the header entries associated with key.
+// The key is canonicalized by textproto.CanonicalMIMEHeaderKey.
+func (h Header) Add(key, value string) {
+	textproto.MIMEHeader(h).Add(key, value)
+}
+
+// Del deletes the values associated with key.
+func (h Header) Del(key string) {
+	textproto.MIMEHeader(h).Del(key)
+}
+
+// SetAddressList sets the header field key to the list of addresses,
+// each formatted by Address.String.
+func (h Header) SetAddressList(key string, list []*Address) {
+	s := make([]string, len(list))
+	for i, a := range list {
+		s[i] = a.String()
+	}
+	h.Set(key, strings.Join(s, ", "))
+}
+
+// SetDate sets the Date header field to t, in the format of RFC 5322
+// section 3.3.
+func (h Header) SetDate(t time.Time) {
+	h.Set("Date", t.Format("Mon, 02 Jan 2006 15:04:05 -0700"))
+}
+
+// addressFields holds the header fields whose values are address lists.
+var addressFields = map[string]bool{
+	"From":          true,
+	"Sender":        true,
+	"Reply-To":      true,
+	"To":            true,
+	"Cc":            true,
+	"Bcc":           true,
+	"Resent-From":   true,
+	"Resent-Sender": true,
+	"Resent-To":     true,
+	"Resent-Cc":     true,
+	"Resent-Bcc":    true,
+}
+
+// fieldOrder lists the header fields that WriteTo writes first, in
+// the order of the examples of RFC 5322 appendix A.
+var fieldOrder = []string{
+	"Date",
+	"From",
+	"Sender",
+	"Reply-To",
+	"To",
+	"Cc",
+	"Subject",
+	"Message-Id",
+	"In-Reply-To",
+	"References",
+	"Mime-Version",
+	"Content-Type",
+	"Content-Transfer-Encoding",
+}
+
+// WriteTo writes the message in RFC 5322 format to w: its header,
+// followed by a blank line and the content of Body, which is expected
+// to be encoded already, for example by NewMessage.
+//
+// The Date, From, Sender, Reply-To, To, Cc, Subject and MIME header
+// fields are written first, followed by the remaining fields in
+// sorted order. Address fields are parsed and written in the form of
+// Address.String, so that non-ASCII display names are encoded as
+// RFC 2047 encoded-words; other fields containing non-ASCII text are
+// encoded in the same way. Long lines are folded at whitespace; WriteTo
+// returns an error if a field holds a word too long to fit the line
+// length limit of RFC 5322. The Bcc field is not written: its
+// recipients are given to the mail transport only.
+//
+// WriteTo reads Body to its end.
+func (m *Message) WriteTo(w io.Writer) (n int64, err error) {
+	bw := bufio.NewWriter(w)
+	cw := &countWriter{w: bw}
+	if err := m.Header.write(cw); err != nil {
+		return cw.n, err
+	}
+	if _, err := io.WriteString(cw, "\r\n"); err != nil {
+		return cw.n, err
+	}
+	if m.Body != nil {
+		if _, err := io.Copy(cw, m.Body); err != nil {
+			return cw.n, err
+		}
+	}
+	err = bw.Flush()
+	return cw.n, err
+}
+
+// write writes the fields of h, each followed by CRLF.
+func (h Header) write(w io.Writer) error {
+	keys := make([]string, 0, len(h))
+	first := make(map[string]bool, len(fieldOrder))
+	for _, k := range fieldOrder {
+		if _, ok := h[k]; ok {
+			keys = append(keys, k)
+			first[k] = true
+		}
+	}
+	rest := len(keys)
+	for k := range h {
+		if !first[k] && textproto.CanonicalMIMEHeaderKey(k) != "Bcc" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys[rest:])
+
+	for _, k := range keys {
+		if !validHeaderFieldName(k) {
+			return errors.New("mail: invalid header field name " + quoteString(k))
+		}
+		for _, v := range h[k] {
+			v, err := encodeHeaderValue(k, v)
+			if err != nil {
+				return err
+			}
+			f, err := foldHeaderField(k, v)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encodeHeaderValue returns the value of the field key in the form in
+// which it is written.
+func encodeHeaderValue(key, value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", errors.New("mail: header field " + key + " contains a line break")
+	}
+	if addressFields[textproto.CanonicalMIMEHeaderKey(key)] {
+		if strings.TrimSpace(value) == "" {
+			return value, nil
+		}
+		list, err := ParseAddressList(value)
+		if err != nil {
+			return "", errors.New("mail: invalid address list in header field " + key + ": " + err.Error())
+		}
+		if len(list) == 0 {
+			// A group without members, such as
+			// "undisclosed-recipients:;".
+			return value, nil
+		}
+		s := make([]string, len(list))
+		for i, a := range list {
+			s[i] = a.String()
+		}
+		return strings.Join(s, ", "), nil
+	}
+	for i := 0; i < len(value); i++ {
+		if value[i] >= utf8.RuneSelf {
+			return mime.QEncoding.Encode("utf-8", value), nil
+		}
+	}
+	return value, nil
+}
+
+// foldHeaderField formats the header field "key: value", followed by
+// CRLF. Lines longer than maxLineLen are folded before whitespace
+// where possible, as described in RFC 5322 section 2.2.3. Encoded
+// words are already short enough to fit a line; any other word that
+// leaves a line longer than maxHardLineLen is an error.
+func foldHeaderField(key, value string) (string, error) {
+	var b strings.Builder
+	line := key + ":"
+	empty := true // whether line holds no word yet
+	flush := func() error {
+		if len(line) > maxHardLineLen {
+			return errors.New("mail: header field " + key + " has a line longer than 998 bytes")
+		}
+		b.WriteString(line)
+		b.WriteString("\r\n")
+		return nil
+	}
+	for _, word := range strings.Split(value, " ") {
+		if word != "" && !empty && len(line)+1+len(word) > maxLineLen {
+			if err := flush(); err != nil {
+				return "", err
+			}
+			line = ""
+		}
+		line += " " + word
+		empty = false
+	}
+	if err := flush(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// validHeaderFieldName reports whether name is a valid field name as
+// defined by RFC 5322 section 3.6.8.
+func validHeaderFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c <= ' ' || c == ':' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// An Attachment is a file attached to a message by NewMessage.
+type Attachment struct {
+	// Filename is the name under which the file is presented to
+	// the recipient. It may contain non-ASCII characters.
+	Filename string
+
+	// ContentType is the media type of the file. If empty, it is
+	// derived from the extension of Filename by mime.TypeByExtension,
+	// or is "application/octet-stream".
+	ContentType string
+
+	// Inline requests that the file be displayed within the message,
+	// rather than offered for download.
+	Inline bool
+
+	// ContentID, if not empty, is the Content-ID of the file, by
+	// which an HTML body may refer to it as "cid:" + ContentID.
+	ContentID string
+
+	// Data is the content of the file.
+	Data []byte
+}
+
+// NewMessage returns a message with the header fields in h and a body
+// made of the given plain text and HTML versions of its content,
+// followed by the attachments. Either text or html may be empty; if
+// both are given, they form a multipart/alternative body. Attachments
+// turn the body into a multipart/mixed one.
+//
+// NewMessage sets the MIME-Version, Content-Type and
+// Content-Transfer-Encoding fields of the returned message's header,
+// which otherwise holds a copy of h. Its Body can be written with the
+// header by Message.WriteTo, typically to the io.Writer returned by
+// the Data method of net/smtp.Client.
+func NewMessage(h Header, text, html string, attachments ...*Attachment) (*Message, error) {
+	hdr := make(Header, len(h)+3)
+	for k, v := range h {
+		hdr[textproto.CanonicalMIMEHeaderKey(k)] = append([]string(nil), v...)
+	}
+	hdr.Set("Mime-Version", "1.0")
+
+	var body bytes.Buffer
+	var err error
+	if len(attachments) == 0 {
+		err = writeContent(hdr, &body, text, html)
+	} else {
+		mw := multipart.NewWriter(&body)
+		hdr.Set("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mw.Boundary()}))
+		hdr.Del("Content-Transfer-Encoding")
+		err = writeMixed(mw, text, html, attachments)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Header: hdr, Body: &body}, nil
+}
+
+// A partHeader is the header of a message or of one of its parts.
+type partHeader interface {
+	Set(key, value string)
+	Del(key string)
+}
+
+// writeContent writes to w the text and html versions of the content,
+// and sets the corresponding fields of h.
+func writeContent(h partHeader, w io.Writer, text, html string) error {
+	switch {
+	case html == "":
+		return writeText(h, w, "text/plain", text)
+	case text == "":
+		return writeText(h, w, "text/html", html)
+	}
+	mw := multipart.NewWriter(w)
+	h.Set("Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": mw.Boundary()}))
+	h.Del("Content-Transfer-Encoding")
+	for _, alt := range []struct{ typ, s string }{{"text/plain", text}, {"text/html", html}} {
+		ph := make(textproto.MIMEHeader)
+		pw, err := mw.CreatePart(textHeader(ph, alt.typ, alt.s))
+		if err != nil {
+			return err
+		}
+		if err := encodeText(pw, alt.s); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// writeMixed writes a multipart/mixed body made of the content and the
+// attachments.
+func writeMixed(mw *multipart.Writer, text, html string, attachments []*Attachment) error {
+	if text != "" || html != "" {
+		var content bytes.Buffer
+		ph := make(textproto.MIMEHeader)
+		if err := writeContent(ph, &content, text, html); err != nil {
+			return err
+		}
+		pw, err := mw.CreatePart(ph)
+		if err != nil {
+			return err
+		}
+		if _, err := content.WriteTo(pw); err != nil {
+			return err
+		}
+	}
+	for _, a := range attachments {
+		pw, err := mw.CreatePart(a.header())
+		if err != nil {
+			return err
+		}
+		if err := encodeBase64(pw, a.Data); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func (a *Attachment) header() textproto.MIMEHeader {
+	typ := a.ContentType
+	if typ == "" {
+		typ = mime.TypeByExtension(filepath.Ext(a.Filename))
+	}
+	if typ == "" {
+		typ = "application/octet-stream"
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Type", typ)
+	disposition := "attachment"
+	if a.Inline {
+		disposition = "inline"
+	}
+	var params map[string]string
+	if a.Filename != "" {
+		params = map[string]string{"filename": a.Filename}
+	}
+	h.Set("Content-Disposition", mime.FormatMediaType(disposition, params))
+	h.Set("Content-Transfer-Encoding", "base64")
+	if a.ContentID != "" {
+		h.Set("Content-Id", "<"+a.ContentID+">")
+	}
+	return h
+}
+
+// writeText writes s, of media type typ, to w as a single part body
+// and sets the corresponding fields of h.
+func writeText(h partHeader, w io.Writer, typ, s string) error {
+	th := textHeader(make(textproto.MIMEHeader), typ, s)
+	for _, k := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+		h.Set(k, th.Get(k))
+	}
+	return encodeText(w, s)
+}
+
+// textHeader sets in h the fields describing the text s of media type
+// typ and returns h.
+func textHeader(h textproto.MIMEHeader, typ, s string) textproto.MIMEHeader {
+	h.Set("Content-Type", mime.FormatMediaType(typ, map[string]string{"charset": "utf-8"}))
+	if is7bit(s) {
+		h.Set("Content-Transfer-Encoding", "7bit")
+	} else {
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+	}
+	return h
+}
+
+// is7bit reports whether s can be sent without a content transfer
+// encoding: it is ASCII without NUL or bare CR characters, and its
+// lines are no longer than RFC 5322 allows.
+func is7bit(s string) bool {
+	lineLen := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\n':
+			lineLen = 0
+			continue
+		case c == '\r':
+			if i+1 >= len(s) || s[i+1] != '\n' {
+				return false
+			}
+			continue
+		case c == 0 || c >= utf8.RuneSelf:
+			return false
+		}
+		lineLen++
+		if lineLen > maxHardLineLen {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeText writes s to w in the encoding chosen by textHeader, with
+// CRLF line breaks.
+func encodeText(w io.Writer, s string) error {
+	if is7bit(s) {
+		s = strings.ReplaceAll(s, "\r\n", "\n")
+		_, err := io.WriteString(w, strings.ReplaceAll(s, "\n", "\r\n"))
+		return err
+	}
+	qw := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qw, s); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+// encodeBase64 writes data to w in base64, broken into lines of 76
+// characters as required by RFC 2045 section 6.8.
+func encodeBase64(w io.Writer, data []byte) error {
+	const lineLen = 76
+	buf := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(buf, data)
+	for len(buf) > 0 {
+		n := lineLen
+		if n > len(buf) {
+			n = len(buf)
+		}
+		if _, err := w.Write(buf[:n]); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\r\n"); err != nil {
+			return err
+		}
+		buf = buf[n:]
+	}
+	return nil
+}
+
+// countWriter counts the bytes written to w.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
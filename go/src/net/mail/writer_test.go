@@ -0,0 +1,224 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mail
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessageWriteTo(t *testing.T) {
+	h := make(Header)
+	h.SetDate(time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+	h.SetAddressList("From", []*Address{{Name: "Jörg Doe", Address: "joerg@example.com"}})
+	h.Set("To", "Alice <alice@example.com>,bob@example.com")
+	h.Set("Bcc", "eve@example.com")
+	h.Set("Subject", "Grüße")
+	h.Set("X-Mailer", "test")
+	m := &Message{Header: h, Body: strings.NewReader("Hi.\r\n")}
+
+	var b bytes.Buffer
+	n, err := m.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(b.Len()) {
+		t.Errorf("WriteTo returned %d; wrote %d bytes", n, b.Len())
+	}
+	want := "Date: Thu, 04 Mar 2021 05:06:07 +0000\r\n" +
+		"From: =?utf-8?q?J=C3=B6rg_Doe?= <joerg@example.com>\r\n" +
+		"To: \"Alice\" <alice@example.com>, <bob@example.com>\r\n" +
+		"Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=\r\n" +
+		"X-Mailer: test\r\n" +
+		"\r\n" +
+		"Hi.\r\n"
+	if got := b.String(); got != want {
+		t.Errorf("WriteTo wrote\n%q\nwant\n%q", got, want)
+	}
+
+	// The output must parse back to the same header.
+	rm, err := ReadMessage(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := rm.Header.AddressList("From")
+	if err != nil || len(list) != 1 || list[0].Name != "Jörg Doe" {
+		t.Errorf("From = %v, %v; want Jörg Doe", list, err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(rm.Header.Get("Subject"))
+	if err != nil || subject != "Grüße" {
+		t.Errorf("Subject = %q, %v; want %q", subject, err, "Grüße")
+	}
+}
+
+func TestMessageWriteToErrors(t *testing.T) {
+	tests := []Header{
+		{"Subject": {"a\r\nBcc: eve@example.com"}},
+		{"To": {"not an address"}},
+		{"Bad Key": {"x"}},
+		{"X-Long": {"a " + strings.Repeat("x", maxHardLineLen)}},
+	}
+	for _, h := range tests {
+		m := &Message{Header: h}
+		if _, err := m.WriteTo(io.Discard); err == nil {
+			t.Errorf("WriteTo of header %q succeeded", h)
+		}
+	}
+}
+
+func TestFoldHeaderField(t *testing.T) {
+	var list []*Address
+	for i := 0; i < 10; i++ {
+		list = append(list, &Address{Name: "Recipient", Address: "recipient@example.com"})
+	}
+	h := make(Header)
+	h.SetAddressList("To", list)
+	h.Set("Subject", strings.Repeat("ünïcödé ", 20))
+	h.Set("X-Long", strings.Repeat("x", 100))
+	h.Set("X-Encoded", strings.Repeat("ü", 600))
+	var b bytes.Buffer
+	if _, err := (&Message{Header: h}).WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\r\n\r\n"), "\r\n") {
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			t.Errorf("whitespace-only line in header")
+			continue
+		}
+		if strings.HasSuffix(words[0], ":") {
+			words = words[1:]
+		}
+		// Lines holding a single word cannot be folded.
+		if len(line) > maxLineLen && len(words) > 1 {
+			t.Errorf("line longer than %d bytes: %q", maxLineLen, line)
+		}
+	}
+
+	rm, err := ReadMessage(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := rm.Header.AddressList("To")
+	if err != nil || len(got) != len(list) {
+		t.Errorf("To = %d addresses, %v; want %d", len(got), err, len(list))
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(rm.Header.Get("Subject"))
+	if want := strings.Repeat("ünïcödé ", 20); err != nil || subject != want {
+		t.Errorf("Subject = %q, %v; want %q", subject, err, want)
+	}
+	encoded, err := new(mime.WordDecoder).DecodeHeader(rm.Header.Get("X-Encoded"))
+	if want := strings.Repeat("ü", 600); err != nil || encoded != want {
+		t.Errorf("X-Encoded = %q, %v; want %q", encoded, err, want)
+	}
+}
+
+func TestNewMessage(t *testing.T) {
+	h := Header{"From": {"a@example.com"}, "To": {"b@example.com"}}
+	m, err := NewMessage(h, "héllo\n", "<p>héllo</p>",
+		&Attachment{Filename: "notes.txt", Data: []byte("some notes")},
+		&Attachment{Filename: "résumé.bin", Data: bytes.Repeat([]byte{0xff}, 100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h["Content-Type"]; ok {
+		t.Error("NewMessage modified its header argument")
+	}
+	var b bytes.Buffer
+	if _, err := m.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	rm, err := ReadMessage(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := rm.Header.Get("Mime-Version"); v != "1.0" {
+		t.Errorf("MIME-Version = %q; want 1.0", v)
+	}
+	typ, params, err := mime.ParseMediaType(rm.Header.Get("Content-Type"))
+	if err != nil || typ != "multipart/mixed" {
+		t.Fatalf("Content-Type = %q, %v; want multipart/mixed", typ, err)
+	}
+	mr := multipart.NewReader(rm.Body, params["boundary"])
+
+	// The first part holds the alternative versions of the text.
+	p, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ, params, err = mime.ParseMediaType(p.Header.Get("Content-Type"))
+	if err != nil || typ != "multipart/alternative" {
+		t.Fatalf("first part Content-Type = %q, %v; want multipart/alternative", typ, err)
+	}
+	ar := multipart.NewReader(p, params["boundary"])
+	for _, want := range []struct{ typ, body string }{
+		{"text/plain", "héllo\r\n"},
+		{"text/html", "<p>héllo</p>"},
+	} {
+		ap, err := ar.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ, _, _ := mime.ParseMediaType(ap.Header.Get("Content-Type")); typ != want.typ {
+			t.Errorf("alternative Content-Type = %q; want %q", typ, want.typ)
+		}
+		// multipart.Reader decodes quoted-printable parts.
+		body, err := io.ReadAll(ap)
+		if err != nil || string(body) != want.body {
+			t.Errorf("%s body = %q, %v; want %q", want.typ, body, err, want.body)
+		}
+	}
+	if _, err := ar.NextPart(); err != io.EOF {
+		t.Errorf("extra alternative part: %v", err)
+	}
+
+	for _, want := range []struct{ filename, typ string }{
+		{"notes.txt", "text/plain"},
+		{"résumé.bin", "application/octet-stream"},
+	} {
+		p, err := mr.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.FileName() != want.filename {
+			t.Errorf("attachment file name = %q; want %q", p.FileName(), want.filename)
+		}
+		if typ, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type")); typ != want.typ {
+			t.Errorf("attachment %s Content-Type = %q; want %q", want.filename, typ, want.typ)
+		}
+		if enc := p.Header.Get("Content-Transfer-Encoding"); enc != "base64" {
+			t.Errorf("attachment %s Content-Transfer-Encoding = %q; want base64", want.filename, enc)
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("extra part: %v", err)
+	}
+}
+
+func TestNewMessageText(t *testing.T) {
+	m, err := NewMessage(Header{"Subject": {"x"}}, "line one\nline two\n", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if _, err := m.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "Subject: x\r\n" +
+		"Mime-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: 7bit\r\n" +
+		"\r\n" +
+		"line one\r\nline two\r\n"
+	if got := b.String(); got != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
@@ -93,6 +93,10 @@ join the two settings by a plus sign, as in GODEBUG=netdns=go+1.
 要强制一个特定的解析器，同时打印调试信息。
 用加号连接这两个设置，如 GODEBUG=netdns=go+1。
 
+The pure Go resolver can send its queries over DNS-over-TLS or
+DNS-over-HTTPS instead of plain UDP and TCP by setting the Transport
+field of a Resolver; see package net/securedns.
+
 On Plan 9, the resolver always accesses /net/cs and /net/dns.
 在Plan 9 中，解析器总是访问 /net/cs 和 /net/dns。
 
@@ -0,0 +1,128 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"errors"
+	"syscall"
+)
+
+var errInvalidGroupSize = errors.New("invalid group size")
+
+// ListenGroup announces n times on the same local network address,
+// using SO_REUSEPORT so that the operating system spreads incoming
+// connections across the returned listeners. Each listener is
+// typically served by its own accept loop.
+// ListenGroup在同一地址上创建n个监听器，由操作系统在其间分配连接。
+//
+// ListenGroup is only supported on Linux and on FreeBSD 12 or later,
+// where it uses SO_REUSEPORT_LB instead. On other systems, SO_REUSEPORT
+// does not reliably spread connections, if it exists at all, and
+// ListenGroup returns an error.
+//
+// The network must be "tcp", "tcp4" or "tcp6". If the port in the
+// address parameter is empty or "0", a port number is chosen
+// automatically and shared by all listeners of the group.
+//
+// If lc.Control is not nil, it is called for every listener of the
+// group. If lc.SteerByCPU is set, connections are distributed by the
+// CPU that received them instead of by a hash of their addresses.
+func (lc *ListenConfig) ListenGroup(ctx context.Context, network, address string, n int) ([]Listener, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: nil, Err: UnknownNetworkError(network)}
+	}
+	if n < 1 {
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: nil, Err: errInvalidGroupSize}
+	}
+	glc := lc.reusePortConfig()
+	ls := make([]Listener, 0, n)
+	closeAll := func() {
+		for _, l := range ls {
+			l.Close()
+		}
+	}
+	for i := 0; i < n; i++ {
+		l, err := glc.Listen(ctx, network, address)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		ls = append(ls, l)
+		if i == 0 {
+			// Later members must bind the port picked for
+			// the first one.
+			address = l.Addr().String()
+		}
+	}
+	if lc.SteerByCPU {
+		if err := steerByCPU(ls[0].(*TCPListener).fd, n); err != nil {
+			closeAll()
+			return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: ls[0].Addr(), Err: err}
+		}
+	}
+	return ls, nil
+}
+
+// ListenPacketGroup announces n times on the same local network
+// address, using SO_REUSEPORT so that the operating system spreads
+// incoming datagrams across the returned connections.
+//
+// The network must be "udp", "udp4" or "udp6". See ListenGroup for a
+// description of the remaining parameters and of the systems where
+// groups are supported.
+func (lc *ListenConfig) ListenPacketGroup(ctx context.Context, network, address string, n int) ([]PacketConn, error) {
+	switch network {
+	case "udp", "udp4", "udp6":
+	default:
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: nil, Err: UnknownNetworkError(network)}
+	}
+	if n < 1 {
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: nil, Err: errInvalidGroupSize}
+	}
+	glc := lc.reusePortConfig()
+	cs := make([]PacketConn, 0, n)
+	closeAll := func() {
+		for _, c := range cs {
+			c.Close()
+		}
+	}
+	for i := 0; i < n; i++ {
+		c, err := glc.ListenPacket(ctx, network, address)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		cs = append(cs, c)
+		if i == 0 {
+			address = c.LocalAddr().String()
+		}
+	}
+	if lc.SteerByCPU {
+		if err := steerByCPU(cs[0].(*UDPConn).fd, n); err != nil {
+			closeAll()
+			return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: cs[0].LocalAddr(), Err: err}
+		}
+	}
+	return cs, nil
+}
+
+// reusePortConfig returns a copy of lc whose Control function also
+// sets SO_REUSEPORT, or SO_REUSEPORT_LB on FreeBSD.
+func (lc *ListenConfig) reusePortConfig() *ListenConfig {
+	glc := *lc
+	control := lc.Control
+	glc.Control = func(network, address string, c syscall.RawConn) error {
+		if control != nil {
+			if err := control(network, address, c); err != nil {
+				return err
+			}
+		}
+		return setReusePort(c)
+	}
+	return &glc
+}
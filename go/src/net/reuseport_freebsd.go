@@ -0,0 +1,18 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"internal/syscall/unix"
+	"syscall"
+)
+
+// On FreeBSD, sockets bound with SO_REUSEPORT do not share incoming
+// connections; SO_REUSEPORT_LB, added in FreeBSD 12, does.
+const soReusePort = unix.SO_REUSEPORT_LB
+
+func steerByCPU(fd *netFD, n int) error {
+	return syscall.ENOPROTOOPT
+}
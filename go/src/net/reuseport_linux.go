@@ -0,0 +1,41 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"internal/syscall/unix"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+const soReusePort = unix.SO_REUSEPORT
+
+// Classic BPF definitions missing from package syscall on some
+// architectures.
+const (
+	bpfMOD   = 0x90
+	skfAdCPU = 0xfffff024 // SKF_AD_OFF + SKF_AD_CPU
+)
+
+// steerByCPU attaches to the SO_REUSEPORT group of fd, made of n
+// sockets, a program selecting the member whose index is the number
+// of the receiving CPU modulo n.
+func steerByCPU(fd *netFD, n int) error {
+	prog := []syscall.SockFilter{
+		{Code: syscall.BPF_LD | syscall.BPF_W | syscall.BPF_ABS, K: skfAdCPU},
+		{Code: syscall.BPF_ALU | bpfMOD | syscall.BPF_K, K: uint32(n)},
+		{Code: syscall.BPF_RET | syscall.BPF_A},
+	}
+	var serr error
+	err := fd.pfd.RawControl(func(s uintptr) {
+		serr = unix.AttachReuseportCBPF(int(s), prog)
+	})
+	runtime.KeepAlive(fd)
+	if err != nil {
+		return err
+	}
+	return os.NewSyscallError("setsockopt", serr)
+}
@@ -0,0 +1,25 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"testing"
+)
+
+func TestListenGroupSteerByCPU(t *testing.T) {
+	lc := ListenConfig{SteerByCPU: true}
+	ls, err := lc.ListenGroup(context.Background(), "tcp4", "127.0.0.1:0", 2)
+	if err != nil {
+		t.Skipf("ListenGroup with CPU steering: %v", err)
+	}
+	testListenerGroup(t, ls)
+
+	cs, err := lc.ListenPacketGroup(context.Background(), "udp4", "127.0.0.1:0", 2)
+	if err != nil {
+		t.Skipf("ListenPacketGroup with CPU steering: %v", err)
+	}
+	testPacketConnGroup(t, cs)
+}
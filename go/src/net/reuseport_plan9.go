@@ -0,0 +1,15 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import "syscall"
+
+func setReusePort(c syscall.RawConn) error {
+	return syscall.EPLAN9
+}
+
+func steerByCPU(fd *netFD, n int) error {
+	return syscall.EPLAN9
+}
@@ -0,0 +1,22 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || (js && wasm) || netbsd || openbsd || solaris || windows
+// +build aix darwin dragonfly js,wasm netbsd openbsd solaris windows
+
+package net
+
+import "syscall"
+
+// Where SO_REUSEPORT exists on these systems, it lets sockets share an
+// address without spreading incoming connections across them, the
+// last one bound receiving them all on some, so there is nothing to
+// build a group on.
+func setReusePort(c syscall.RawConn) error {
+	return syscall.ENOPROTOOPT
+}
+
+func steerByCPU(fd *netFD, n int) error {
+	return syscall.ENOPROTOOPT
+}
@@ -0,0 +1,29 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || netbsd || openbsd || solaris || windows
+// +build aix darwin dragonfly netbsd openbsd solaris windows
+
+package net
+
+import (
+	"context"
+	"testing"
+)
+
+func TestListenGroupUnsupported(t *testing.T) {
+	var lc ListenConfig
+	if ls, err := lc.ListenGroup(context.Background(), "tcp4", "127.0.0.1:0", 2); err == nil {
+		for _, l := range ls {
+			l.Close()
+		}
+		t.Error("ListenGroup succeeded on a system whose SO_REUSEPORT does not spread connections")
+	}
+	if cs, err := lc.ListenPacketGroup(context.Background(), "udp4", "127.0.0.1:0", 2); err == nil {
+		for _, c := range cs {
+			c.Close()
+		}
+		t.Error("ListenPacketGroup succeeded on a system whose SO_REUSEPORT does not spread datagrams")
+	}
+}
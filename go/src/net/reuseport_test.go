@@ -0,0 +1,157 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build freebsd || linux
+// +build freebsd linux
+
+package net
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestListenGroup(t *testing.T) {
+	const n = 4
+	var lc ListenConfig
+	ls, err := lc.ListenGroup(context.Background(), "tcp4", "127.0.0.1:0", n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ls) != n {
+		t.Fatalf("got %d listeners; want %d", len(ls), n)
+	}
+	testListenerGroup(t, ls)
+}
+
+func TestListenPacketGroup(t *testing.T) {
+	const n = 4
+	var lc ListenConfig
+	cs, err := lc.ListenPacketGroup(context.Background(), "udp4", "127.0.0.1:0", n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs) != n {
+		t.Fatalf("got %d connections; want %d", len(cs), n)
+	}
+	testPacketConnGroup(t, cs)
+}
+
+func TestListenGroupErrors(t *testing.T) {
+	var lc ListenConfig
+	if _, err := lc.ListenGroup(context.Background(), "udp", "127.0.0.1:0", 2); err == nil {
+		t.Error("ListenGroup succeeded with a packet network")
+	}
+	if _, err := lc.ListenGroup(context.Background(), "tcp", "127.0.0.1:0", 0); err == nil {
+		t.Error("ListenGroup succeeded with an empty group")
+	}
+	if _, err := lc.ListenPacketGroup(context.Background(), "tcp", "127.0.0.1:0", 2); err == nil {
+		t.Error("ListenPacketGroup succeeded with a stream network")
+	}
+
+	// Without SO_REUSEPORT, a second listener on the port of a
+	// group must fail.
+	ls, err := lc.ListenGroup(context.Background(), "tcp4", "127.0.0.1:0", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeListeners(ls)
+	if l, err := lc.Listen(context.Background(), "tcp4", ls[0].Addr().String()); err == nil {
+		l.Close()
+		t.Error("Listen succeeded on the address of a listener group")
+	}
+}
+
+func closeListeners(ls []Listener) {
+	for _, l := range ls {
+		l.Close()
+	}
+}
+
+// testListenerGroup checks that the members of ls share an address
+// and that connections to it are accepted.
+func testListenerGroup(t *testing.T, ls []Listener) {
+	t.Helper()
+	defer closeListeners(ls)
+	addr := ls[0].Addr().String()
+	for _, l := range ls[1:] {
+		if got := l.Addr().String(); got != addr {
+			t.Fatalf("got listener address %s; want %s", got, addr)
+		}
+	}
+
+	accepted := make(chan Conn)
+	for _, l := range ls {
+		go func(l Listener) {
+			for {
+				c, err := l.Accept()
+				if err != nil {
+					return
+				}
+				accepted <- c
+			}
+		}(l)
+	}
+	const conns = 16
+	for i := 0; i < conns; i++ {
+		c, err := Dial("tcp4", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		select {
+		case ac := <-accepted:
+			ac.Close()
+		case <-time.After(5 * time.Second):
+			t.Fatalf("connection %d was not accepted", i)
+		}
+	}
+}
+
+// testPacketConnGroup checks that the members of cs share an address
+// and that datagrams sent to it are received.
+func testPacketConnGroup(t *testing.T, cs []PacketConn) {
+	t.Helper()
+	defer func() {
+		for _, c := range cs {
+			c.Close()
+		}
+	}()
+	addr := cs[0].LocalAddr().String()
+	for _, c := range cs[1:] {
+		if got := c.LocalAddr().String(); got != addr {
+			t.Fatalf("got local address %s; want %s", got, addr)
+		}
+	}
+
+	received := make(chan struct{})
+	for _, c := range cs {
+		go func(c PacketConn) {
+			b := make([]byte, 16)
+			for {
+				if _, _, err := c.ReadFrom(b); err != nil {
+					return
+				}
+				received <- struct{}{}
+			}
+		}(c)
+	}
+	const datagrams = 16
+	for i := 0; i < datagrams; i++ {
+		c, err := Dial("udp4", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		if _, err := c.Write([]byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("datagram %d was not received", i)
+		}
+	}
+}
@@ -6,6 +6,7 @@ package rpc
 
 import (
 	"bufio"
+	"context"
 	"encoding/gob"
 	"errors"
 	"io"
@@ -13,6 +14,7 @@ import (
 	"net"
 	"net/http"
 	"sync"
+	"time"
 )
 
 // ServerError represents an error that has been returned from
@@ -32,6 +34,9 @@ type Call struct {
 	Reply         interface{} // The reply from the function (*struct).
 	Error         error       // After completion, the error status.
 	Done          chan *Call  // Receives *Call when Go is complete.
+
+	seq     uint64        // sequence number, once sent
+	timeout time.Duration // time left to the caller's deadline, if any
 }
 
 // Client represents an RPC Client.
@@ -68,6 +73,15 @@ type ClientCodec interface {
 	Close() error
 }
 
+// A callErrorCodec is a ClientCodec that can describe the error of the
+// response last read by ReadResponseHeader better than its text, for
+// example with an error code. The error it returns is given to the call
+// in place of a ServerError.
+type callErrorCodec interface {
+	ClientCodec
+	CallError() error
+}
+
 func (client *Client) send(call *Call) {
 	client.reqMutex.Lock()
 	defer client.reqMutex.Unlock()
@@ -83,11 +97,13 @@ func (client *Client) send(call *Call) {
 	seq := client.seq
 	client.seq++
 	client.pending[seq] = call
+	call.seq = seq
 	client.mutex.Unlock()
 
 	// Encode and send the request.
 	client.request.Seq = seq
 	client.request.ServiceMethod = call.ServiceMethod
+	client.request.Timeout = call.timeout
 	err := client.codec.WriteRequest(&client.request, call.Args)
 	if err != nil {
 		client.mutex.Lock()
@@ -101,6 +117,34 @@ func (client *Client) send(call *Call) {
 	}
 }
 
+// cancel asks the server to cancel the call with sequence number seq,
+// which the client no longer waits for. Cancellation notices are only
+// sent with the gob codec; a server using another codec could mistake
+// them for calls.
+func (client *Client) cancel(seq uint64) {
+	if _, ok := client.codec.(*gobClientCodec); !ok {
+		return
+	}
+	client.reqMutex.Lock()
+	defer client.reqMutex.Unlock()
+
+	client.mutex.Lock()
+	if client.shutdown || client.closing {
+		client.mutex.Unlock()
+		return
+	}
+	// The notice is not registered as pending: the server does not
+	// answer it, and input discards any error an older server sends.
+	nseq := client.seq
+	client.seq++
+	client.mutex.Unlock()
+
+	client.request.Seq = nseq
+	client.request.ServiceMethod = cancelServiceMethod
+	client.request.Timeout = 0
+	client.codec.WriteRequest(&client.request, seq)
+}
+
 func (client *Client) input() {
 	var err error
 	var response Response
@@ -132,6 +176,11 @@ func (client *Client) input() {
 			// any subsequent requests will get the ReadResponseBody
 			// error if there is one.
 			call.Error = ServerError(response.Error)
+			if codec, ok := client.codec.(callErrorCodec); ok {
+				if e := codec.CallError(); e != nil {
+					call.Error = e
+				}
+			}
 			err = client.codec.ReadResponseBody(nil)
 			if err != nil {
 				err = errors.New("reading error body: " + err.Error())
@@ -321,3 +370,52 @@ func (client *Client) Call(serviceMethod string, args interface{}, reply interfa
 	call := <-client.Go(serviceMethod, args, reply, make(chan *Call, 1)).Done
 	return call.Error
 }
+
+// CallContext is like Call but takes a context. The time left to the
+// context's deadline, if any, is sent to the server, which applies it
+// to the context of a method that takes one. If ctx is done before the
+// call completes, CallContext returns ctx.Err() and leaves the reply
+// untouched; if ctx was canceled, it also asks the server to cancel
+// the call.
+func (client *Client) CallContext(ctx context.Context, serviceMethod string, args interface{}, reply interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	call := &Call{
+		ServiceMethod: serviceMethod,
+		Args:          args,
+		Reply:         reply,
+		Done:          make(chan *Call, 1),
+	}
+	if d, ok := ctx.Deadline(); ok {
+		call.timeout = time.Until(d)
+		if call.timeout <= 0 {
+			return context.DeadlineExceeded
+		}
+	}
+	client.send(call)
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+	}
+
+	client.mutex.Lock()
+	pending := client.pending[call.seq] == call
+	if pending {
+		delete(client.pending, call.seq)
+	}
+	client.mutex.Unlock()
+	if !pending {
+		// The response arrived meanwhile and is being delivered.
+		<-call.Done
+		return call.Error
+	}
+	err := ctx.Err()
+	if err != context.DeadlineExceeded {
+		// An expired deadline needs no notice: the server
+		// applies the same one.
+		go client.cancel(call.seq)
+	}
+	return err
+}
@@ -0,0 +1,292 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type Args struct {
+	A, B int
+}
+
+type Reply struct {
+	C int
+}
+
+type Arith int
+
+func (t *Arith) Add(args *Args, reply *Reply) error {
+	reply.C = args.A + args.B
+	return nil
+}
+
+func (t *Arith) Div(args *Args, reply *Reply) error {
+	if args.B == 0 {
+		return errors.New("divide by zero")
+	}
+	reply.C = args.A / args.B
+	return nil
+}
+
+func (t *Arith) Sum(args []int, reply *int) error {
+	for _, a := range args {
+		*reply += a
+	}
+	return nil
+}
+
+// Log records its argument, for testing notifications.
+func (t *Arith) Log(arg string, reply *bool) error {
+	logged <- arg
+	return nil
+}
+
+var logged = make(chan string, 10)
+
+func init() {
+	rpc.Register(new(Arith))
+}
+
+type response struct {
+	Version string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *Error          `json:"error"`
+}
+
+// exchange sends req to a new server and returns the raw response.
+func exchange(t *testing.T, req string) json.RawMessage {
+	t.Helper()
+	cli, srv := net.Pipe()
+	defer cli.Close()
+	go ServeConn(srv)
+	fmt.Fprint(cli, req)
+	var msg json.RawMessage
+	if err := json.NewDecoder(cli).Decode(&msg); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	return msg
+}
+
+func TestServerParams(t *testing.T) {
+	for _, tt := range []struct {
+		req, result string
+	}{
+		{`{"jsonrpc": "2.0", "method": "Arith.Add", "params": [{"A": 1, "B": 2}], "id": 1}`, `{"C":3}`},
+		{`{"jsonrpc": "2.0", "method": "Arith.Add", "params": {"A": 1, "B": 2}, "id": 1}`, `{"C":3}`},
+		{`{"jsonrpc": "2.0", "method": "Arith.Add", "id": 1}`, `{"C":0}`},
+		{`{"jsonrpc": "2.0", "method": "Arith.Sum", "params": [1, 2, 3], "id": 1}`, `6`},
+		{`{"jsonrpc": "2.0", "method": "Arith.Sum", "params": [5], "id": 1}`, `5`},
+	} {
+		var resp response
+		if err := json.Unmarshal(exchange(t, tt.req), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error != nil {
+			t.Errorf("%s: error %v", tt.req, resp.Error)
+			continue
+		}
+		if resp.Version != "2.0" || string(resp.Id) != "1" || string(resp.Result) != tt.result {
+			t.Errorf("%s: got %+v, want result %s", tt.req, resp, tt.result)
+		}
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	for _, tt := range []struct {
+		req  string
+		id   string
+		code int
+	}{
+		{`{"jsonrpc": "2.0", "method": "Arith.Div", "params": [{"A": 1}], "id": "x"}`, `"x"`, CodeServerError},
+		{`{"jsonrpc": "2.0", "method": "Arith.Nope", "id": 2}`, `2`, CodeMethodNotFound},
+		{`{"jsonrpc": "2.0", "method": "Nope", "id": 2}`, `2`, CodeMethodNotFound},
+		{`{"jsonrpc": "2.0", "method": "Arith.Add", "params": ["a"], "id": 3}`, `3`, CodeInvalidParams},
+		{`{"jsonrpc": "2.0", "method": "Arith.Sum", "params": [[1, 2, 3]], "id": 3}`, `3`, CodeInvalidParams},
+		{`{"jsonrpc": "2.0", "method": "Arith.Add", "params": "a", "id": 4}`, `4`, CodeInvalidRequest},
+		{`{"jsonrpc": "1.0", "method": "Arith.Add", "id": 5}`, `5`, CodeInvalidRequest},
+		{`{"jsonrpc": "2.0", "method": 1, "id": 6}`, `null`, CodeInvalidRequest},
+		{`{"jsonrpc": "2.0", "method": "Arith.Add", "id": {}}`, `null`, CodeInvalidRequest},
+		{`[]`, `null`, CodeInvalidRequest},
+		{`{"jsonrpc": "2.0", "method": "Arith.Add", "params": [1}`, `null`, CodeParseError},
+	} {
+		var resp response
+		if err := json.Unmarshal(exchange(t, tt.req), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error == nil || resp.Error.Code != tt.code || string(resp.Id) != tt.id || resp.Result != nil {
+			t.Errorf("%s: got %+v, want error %d with id %s", tt.req, resp, tt.code, tt.id)
+		}
+	}
+}
+
+func TestServerBatch(t *testing.T) {
+	msg := exchange(t, `[
+		{"jsonrpc": "2.0", "method": "Arith.Add", "params": {"A": 1, "B": 2}, "id": 1},
+		{"jsonrpc": "2.0", "method": "Arith.Log", "params": ["batch"]},
+		{"jsonrpc": "2.0", "method": "Arith.Nope", "id": 2},
+		1,
+		{"jsonrpc": "2.0", "method": "Arith.Sum", "params": [3, 4], "id": 3}
+	]`)
+	var resps []response
+	if err := json.Unmarshal(msg, &resps); err != nil {
+		t.Fatalf("%s: %v", msg, err)
+	}
+	var got []string
+	for _, r := range resps {
+		s := string(r.Id) + " "
+		if r.Error != nil {
+			s += fmt.Sprint(r.Error.Code)
+		} else {
+			s += string(r.Result)
+		}
+		got = append(got, s)
+	}
+	sort.Strings(got)
+	want := []string{`1 {"C":3}`, `2 -32601`, `3 7`, `null -32600`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if s := <-logged; s != "batch" {
+		t.Errorf("logged %q, want %q", s, "batch")
+	}
+}
+
+func TestServerNotification(t *testing.T) {
+	cli, srv := net.Pipe()
+	defer cli.Close()
+	go ServeConn(srv)
+	dec := json.NewDecoder(cli)
+
+	// Notifications, even of unknown methods and in batches,
+	// get no response: the first one seen is for the request.
+	fmt.Fprint(cli, `{"jsonrpc": "2.0", "method": "Arith.Log", "params": ["one"]}`)
+	fmt.Fprint(cli, `{"jsonrpc": "2.0", "method": "Arith.Nope"}`)
+	fmt.Fprint(cli, `[{"jsonrpc": "2.0", "method": "Arith.Log", "params": ["two"]}]`)
+	fmt.Fprint(cli, `{"jsonrpc": "2.0", "method": "Arith.Add", "params": {"A": 1, "B": 2}, "id": null}`)
+	var resp response
+	if err := dec.Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Id) != "null" || string(resp.Result) != `{"C":3}` {
+		t.Errorf("got %+v, want the response to Arith.Add", resp)
+	}
+	// The notifications are served concurrently.
+	got := []string{<-logged, <-logged}
+	sort.Strings(got)
+	if want := []string{"one", "two"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("logged %q, want %q", got, want)
+	}
+}
+
+func TestClient(t *testing.T) {
+	cli, srv := net.Pipe()
+	go ServeConn(srv)
+
+	client := NewClient(cli)
+	defer client.Close()
+
+	args := &Args{7, 8}
+	reply := new(Reply)
+	if err := client.Call("Arith.Add", args, reply); err != nil {
+		t.Errorf("Add: %v", err)
+	}
+	if reply.C != args.A+args.B {
+		t.Errorf("Add: got %d expected %d", reply.C, args.A+args.B)
+	}
+
+	var sum int
+	if err := client.Call("Arith.Sum", []int{1, 2, 3}, &sum); err != nil {
+		t.Errorf("Sum: %v", err)
+	}
+	if sum != 6 {
+		t.Errorf("Sum: got %d expected 6", sum)
+	}
+	sum = 0
+	if err := client.Call("Arith.Sum", []int{5}, &sum); err != nil || sum != 5 {
+		t.Errorf("Sum: got %d, %v expected 5", sum, err)
+	}
+
+	var e *Error
+	err := client.Call("Arith.Div", &Args{7, 0}, reply)
+	if !errors.As(err, &e) || e.Code != CodeServerError || e.Message != "divide by zero" {
+		t.Errorf("Div: got error %v, expected divide by zero", err)
+	}
+	err = client.Call("Arith.Nope", args, reply)
+	if !errors.As(err, &e) || e.Code != CodeMethodNotFound {
+		t.Errorf("Nope: got error %v, expected method not found", err)
+	}
+}
+
+func TestClientNotify(t *testing.T) {
+	cli, srv := net.Pipe()
+	go ServeConn(srv)
+
+	client := NewClient(cli)
+	defer client.Close()
+
+	if err := client.Notify("Arith.Log", "note"); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if s := <-logged; s != "note" {
+		t.Errorf("logged %q, want %q", s, "note")
+	}
+	// The server did not answer the notification: the next response
+	// is for the call.
+	reply := new(Reply)
+	if err := client.Call("Arith.Add", &Args{1, 2}, reply); err != nil || reply.C != 3 {
+		t.Errorf("Add: got %d, %v expected 3", reply.C, err)
+	}
+}
+
+func TestClientBatchResponse(t *testing.T) {
+	cli, srv := net.Pipe()
+	client := NewClient(cli)
+	defer client.Close()
+
+	go func() {
+		dec := json.NewDecoder(srv)
+		var req [2]struct {
+			Id uint64 `json:"id"`
+		}
+		for i := range req {
+			dec.Decode(&req[i])
+		}
+		fmt.Fprintf(srv, `[{"jsonrpc": "2.0", "result": {"C": 1}, "id": %d}, {"jsonrpc": "2.0", "error": {"code": -32000, "message": "oops"}, "id": %d}]`, req[0].Id, req[1].Id)
+	}()
+	r1, r2 := new(Reply), new(Reply)
+	c1 := client.Go("Arith.Add", &Args{}, r1, nil)
+	c2 := client.Go("Arith.Add", &Args{}, r2, nil)
+	if c1 = <-c1.Done; c1.Error != nil || r1.C != 1 {
+		t.Errorf("first call: got %d, %v; want 1, nil", r1.C, c1.Error)
+	}
+	var e *Error
+	if c2 = <-c2.Done; !errors.As(c2.Error, &e) || e.Code != CodeServerError || e.Message != "oops" {
+		t.Errorf("second call: got error %v, want oops", c2.Error)
+	}
+}
+
+func TestClientMalformedResponse(t *testing.T) {
+	cli, srv := net.Pipe()
+	go fmt.Fprint(srv, `{"jsonrpc": "2.0", "error": {"code": -32700, "message": "parse error"}, "id": null}`)
+	go io.ReadAll(srv)
+	client := NewClient(cli)
+	defer client.Close()
+
+	err := client.Call("Arith.Add", &Args{}, new(Reply))
+	var e *Error
+	if !errors.As(err, &e) || e.Code != CodeParseError {
+		t.Errorf("got error %v, want parse error", err)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package jsonrpc2 implements a JSON-RPC 2.0 ClientCodec and ServerCodec
+// for the rpc package, as specified at https://www.jsonrpc.org/specification.
+//
+// The server accepts batch requests and notifications, which it serves
+// without answering. Params given by name, as a JSON object, are
+// decoded into the method's argument, typically a struct. Params given
+// by position are decoded into the argument as a whole if it is a slice
+// or array; otherwise there must be one, which is decoded into the
+// argument.
+//
+// Errors are reported as error objects with the standard codes. An error
+// returned by a method is reported with code CodeServerError and its text
+// as the message. A client using this package returns the error object
+// of a failed call as an *Error.
+//
+// The client sends an argument that encodes as a JSON object as named
+// params, one that encodes as a JSON array as positional params, and
+// any other argument as a single positional param. Its Notify method
+// sends notifications.
+package jsonrpc2
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/rpc"
+	"strconv"
+	"sync"
+)
+
+// Error codes defined by the specification.
+const (
+	CodeParseError     = -32700 // invalid JSON
+	CodeInvalidRequest = -32600 // not a valid request object
+	CodeMethodNotFound = -32601 // no such method
+	CodeInvalidParams  = -32602 // params do not fit the method
+	CodeInternalError  = -32603 // internal error
+	CodeServerError    = -32000 // error returned by the method
+)
+
+// An Error is a JSON-RPC 2.0 error object.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return "jsonrpc2: error " + strconv.Itoa(e.Code) + ": " + e.Message
+}
+
+type clientCodec struct {
+	dec *json.Decoder // for reading JSON values
+	enc *json.Encoder // for writing JSON values
+	c   io.Closer
+
+	// Responses read but not yet handed to rpc: the rest of a batch.
+	queue []clientResponse
+
+	// temporary work space
+	req  clientRequest
+	resp clientResponse
+
+	encMutex sync.Mutex // serializes requests and notifications
+
+	// JSON-RPC responses include the request id but not the request method.
+	// Package rpc expects both.
+	// We save the request method in pending when sending a request
+	// and then look it up by request ID when filling out the rpc Response.
+	mutex   sync.Mutex        // protects pending
+	pending map[uint64]string // map request id to method name
+}
+
+// NewClientCodec returns a new rpc.ClientCodec using JSON-RPC 2.0 on conn.
+func NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return &clientCodec{
+		dec:     json.NewDecoder(conn),
+		enc:     json.NewEncoder(conn),
+		c:       conn,
+		pending: make(map[uint64]string),
+	}
+}
+
+type clientRequest struct {
+	Version string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+	Id      *uint64     `json:"id,omitempty"` // nil for a notification
+}
+
+// setParams sets the params of r to param, encoded as b.
+func (r *clientRequest) setParams(param interface{}, b []byte) {
+	switch {
+	case param == nil:
+		r.Params = nil
+	case isKind(b, '{'), isKind(b, '['):
+		r.Params = json.RawMessage(b)
+	default:
+		r.Params = [1]json.RawMessage{b}
+	}
+}
+
+func (c *clientCodec) WriteRequest(r *rpc.Request, param interface{}) error {
+	b, err := json.Marshal(param)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	c.pending[r.Seq] = r.ServiceMethod
+	c.mutex.Unlock()
+	c.req.Version = "2.0"
+	c.req.Method = r.ServiceMethod
+	c.req.setParams(param, b)
+	seq := r.Seq
+	c.req.Id = &seq
+	c.encMutex.Lock()
+	defer c.encMutex.Unlock()
+	return c.enc.Encode(&c.req)
+}
+
+// notify sends a notification calling method with param.
+func (c *clientCodec) notify(method string, param interface{}) error {
+	b, err := json.Marshal(param)
+	if err != nil {
+		return err
+	}
+	req := clientRequest{Version: "2.0", Method: method}
+	req.setParams(param, b)
+	c.encMutex.Lock()
+	defer c.encMutex.Unlock()
+	return c.enc.Encode(&req)
+}
+
+type clientResponse struct {
+	Id     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+func (c *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	if len(c.queue) == 0 {
+		var msg json.RawMessage
+		if err := c.dec.Decode(&msg); err != nil {
+			return err
+		}
+		if isKind(msg, '[') {
+			if err := json.Unmarshal(msg, &c.queue); err != nil {
+				return err
+			}
+			if len(c.queue) == 0 {
+				return errors.New("jsonrpc2: empty batch response")
+			}
+		} else {
+			c.queue = append(c.queue, clientResponse{})
+			if err := json.Unmarshal(msg, &c.queue[0]); err != nil {
+				return err
+			}
+		}
+	}
+	c.resp = c.queue[0]
+	c.queue[0] = clientResponse{}
+	c.queue = c.queue[1:]
+
+	id, err := strconv.ParseUint(string(c.resp.Id), 10, 64)
+	if err != nil {
+		// The server could not tell which request it answers,
+		// typically because it could not parse it.
+		if c.resp.Error != nil {
+			return c.resp.Error
+		}
+		return errors.New("jsonrpc2: invalid response id " + string(c.resp.Id))
+	}
+
+	c.mutex.Lock()
+	r.ServiceMethod = c.pending[id]
+	delete(c.pending, id)
+	c.mutex.Unlock()
+
+	r.Error = ""
+	r.Seq = id
+	if c.resp.Error != nil || c.resp.Result == nil {
+		if c.resp.Error == nil {
+			return errors.New("jsonrpc2: response has neither result nor error")
+		}
+		x := c.resp.Error.Message
+		if x == "" {
+			x = "unspecified error"
+		}
+		r.Error = x
+	}
+	return nil
+}
+
+// CallError returns the error object of the response last read, which
+// rpc.Client gives to the call in place of an rpc.ServerError.
+func (c *clientCodec) CallError() error {
+	if c.resp.Error == nil {
+		return nil
+	}
+	return c.resp.Error
+}
+
+func (c *clientCodec) ReadResponseBody(x interface{}) error {
+	if x == nil {
+		return nil
+	}
+	return json.Unmarshal(c.resp.Result, x)
+}
+
+func (c *clientCodec) Close() error {
+	return c.c.Close()
+}
+
+// A Client is an rpc.Client using JSON-RPC 2.0, which can also send
+// notifications.
+type Client struct {
+	*rpc.Client
+	codec *clientCodec
+}
+
+// NewClient returns a new Client to handle requests to the
+// set of services at the other end of the connection.
+func NewClient(conn io.ReadWriteCloser) *Client {
+	codec := NewClientCodec(conn).(*clientCodec)
+	return &Client{rpc.NewClientWithCodec(codec), codec}
+}
+
+// Dial connects to a JSON-RPC 2.0 server at the specified network address.
+func Dial(network, address string) (*Client, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), err
+}
+
+// Notify sends a notification asking the server to call serviceMethod
+// with args. The server sends no response, so Notify returns once the
+// notification is written and reports only errors in writing it.
+func (client *Client) Notify(serviceMethod string, args interface{}) error {
+	return client.codec.notify(serviceMethod, args)
+}
@@ -0,0 +1,270 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/rpc"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+type serverCodec struct {
+	dec *json.Decoder // for reading JSON values
+	enc *json.Encoder // for writing JSON values
+	c   io.Closer
+
+	// Requests read but not yet handed to rpc: the rest of a batch.
+	queue []json.RawMessage
+	batch *batch // batch the queued requests belong to
+
+	// temporary work space
+	req    serverRequest
+	reqSeq uint64 // sequence number assigned to req
+
+	// JSON-RPC clients can use arbitrary json values as request IDs.
+	// Package rpc expects uint64 request IDs.
+	// We assign uint64 sequence numbers to incoming requests
+	// but save what is needed to answer them in the pending map.
+	mutex   sync.Mutex // protects seq, pending, batches and writing
+	seq     uint64
+	pending map[uint64]*serverCall
+}
+
+// A serverCall is a request being served.
+type serverCall struct {
+	id     json.RawMessage // nil for a notification
+	batch  *batch          // batch the request belongs to, if any
+	err    *Error          // error found by the codec, if any
+	notify bool            // no response is due
+}
+
+// A batch collects the responses to a batch request, which are sent
+// together once all of them are known.
+type batch struct {
+	due   int // responses still to come
+	resps []*serverResponse
+}
+
+// NewServerCodec returns a new rpc.ServerCodec using JSON-RPC 2.0 on conn.
+func NewServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	return &serverCodec{
+		dec:     json.NewDecoder(conn),
+		enc:     json.NewEncoder(conn),
+		c:       conn,
+		pending: make(map[uint64]*serverCall),
+	}
+}
+
+type serverRequest struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	Id      json.RawMessage `json:"id"`
+}
+
+type serverResponse struct {
+	Version string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	Id      json.RawMessage `json:"id"`
+}
+
+// parse parses msg into r and reports whether it is a valid request.
+func (r *serverRequest) parse(msg json.RawMessage) bool {
+	*r = serverRequest{}
+	if json.Unmarshal(msg, r) != nil {
+		*r = serverRequest{}
+		return false
+	}
+	if len(r.Id) > 0 && !validID(r.Id) {
+		r.Id = nil
+		return false
+	}
+	if len(r.Params) > 0 && !isKind(r.Params, '[') && !isKind(r.Params, '{') {
+		return false
+	}
+	return r.Version == "2.0" && r.Method != ""
+}
+
+// validID reports whether id is a string, a number or null.
+func validID(id json.RawMessage) bool {
+	switch id[0] {
+	case '"', 'n', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return true
+	}
+	return false
+}
+
+// isKind reports whether the JSON value v starts with the byte c.
+func isKind(v json.RawMessage, c byte) bool {
+	v = bytes.TrimLeft(v, " \t\r\n")
+	return len(v) > 0 && v[0] == c
+}
+
+// isList reports whether x points to a slice or array.
+func isList(x interface{}) bool {
+	t := reflect.TypeOf(x)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	if len(c.queue) == 0 {
+		c.batch = nil
+		var msg json.RawMessage
+		if err := c.dec.Decode(&msg); err != nil {
+			var serr *json.SyntaxError
+			if errors.As(err, &serr) {
+				c.mutex.Lock()
+				c.enc.Encode(&serverResponse{
+					Version: "2.0",
+					Error:   &Error{Code: CodeParseError, Message: "parse error"},
+				})
+				c.mutex.Unlock()
+			}
+			return err
+		}
+		if isKind(msg, '[') {
+			var msgs []json.RawMessage
+			json.Unmarshal(msg, &msgs)
+			if len(msgs) == 0 {
+				// An empty batch is answered by a single error.
+				msgs = append(msgs, nil)
+			} else {
+				// Count the responses due before serving any
+				// request, as they may complete in any order.
+				c.batch = new(batch)
+				var req serverRequest
+				for _, m := range msgs {
+					if !req.parse(m) || len(req.Id) > 0 {
+						c.batch.due++
+					}
+				}
+			}
+			c.queue = msgs
+		} else {
+			c.queue = append(c.queue, msg)
+		}
+	}
+	msg := c.queue[0]
+	c.queue[0] = nil
+	c.queue = c.queue[1:]
+
+	call := new(serverCall)
+	if c.req.parse(msg) {
+		r.ServiceMethod = c.req.Method
+		call.notify = len(c.req.Id) == 0
+	} else {
+		// Let rpc reject the request, then answer with the right error.
+		r.ServiceMethod = ""
+		call.err = &Error{Code: CodeInvalidRequest, Message: "invalid request"}
+	}
+	call.id = c.req.Id
+
+	c.mutex.Lock()
+	if !call.notify {
+		call.batch = c.batch
+	}
+	c.seq++
+	c.pending[c.seq] = call
+	r.Seq = c.seq
+	c.reqSeq = c.seq
+	c.mutex.Unlock()
+
+	return nil
+}
+
+func (c *serverCodec) ReadRequestBody(x interface{}) error {
+	if x == nil || len(c.req.Params) == 0 {
+		// Omitted params leave the argument zero.
+		return nil
+	}
+	var err error
+	if isKind(c.req.Params, '{') {
+		// Named params go into the argument, typically a struct.
+		err = json.Unmarshal(c.req.Params, x)
+	} else {
+		// Positional params: a single one is the argument,
+		// unless the argument is a slice or array, which holds
+		// all of them.
+		var params []json.RawMessage
+		if err = json.Unmarshal(c.req.Params, &params); err == nil {
+			if len(params) == 1 && !isList(x) {
+				err = json.Unmarshal(params[0], x)
+			} else {
+				err = json.Unmarshal(c.req.Params, x)
+			}
+		}
+	}
+	if err != nil {
+		c.mutex.Lock()
+		if call := c.pending[c.reqSeq]; call != nil {
+			call.err = &Error{Code: CodeInvalidParams, Message: "invalid params: " + err.Error()}
+		}
+		c.mutex.Unlock()
+	}
+	return err
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, x interface{}) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	call, ok := c.pending[r.Seq]
+	if !ok {
+		return errors.New("invalid sequence number in response")
+	}
+	delete(c.pending, r.Seq)
+
+	if call.notify {
+		// Notifications are never answered, not even with an error.
+		return nil
+	}
+	resp := &serverResponse{Version: "2.0", Id: call.id}
+	switch {
+	case call.err != nil:
+		resp.Error = call.err
+	case r.Error != "":
+		resp.Error = serverError(r.Error)
+	default:
+		resp.Result = x
+	}
+	if call.batch == nil {
+		return c.enc.Encode(resp)
+	}
+	b := call.batch
+	b.resps = append(b.resps, resp)
+	if b.due--; b.due > 0 {
+		return nil
+	}
+	return c.enc.Encode(b.resps)
+}
+
+// serverError returns the error object for the error reported by rpc
+// for a call.
+func serverError(msg string) *Error {
+	code := CodeServerError
+	if strings.HasPrefix(msg, "rpc: can't find ") || strings.HasPrefix(msg, "rpc: service/method request ill-formed") {
+		code = CodeMethodNotFound
+	}
+	return &Error{Code: code, Message: msg}
+}
+
+func (c *serverCodec) Close() error {
+	return c.c.Close()
+}
+
+// ServeConn runs the JSON-RPC 2.0 server on a single connection.
+// ServeConn blocks, serving the connection until the client hangs up.
+// The caller typically invokes ServeConn in a go statement.
+func ServeConn(conn io.ReadWriteCloser) {
+	rpc.ServeCodec(NewServerCodec(conn))
+}
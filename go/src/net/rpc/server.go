@@ -15,7 +15,8 @@
 
 		- the method's type is exported.
 		- the method is exported.
-		- the method has two arguments, both exported (or builtin) types.
+		- the method has two arguments, both exported (or builtin) types,
+		  optionally preceded by a context.Context.
 		- the method's second argument is a pointer.
 		- the method has return type error.
 
@@ -23,6 +24,10 @@
 
 		func (t *T) MethodName(argType T1, replyType *T2) error
 
+	or
+
+		func (t *T) MethodName(ctx context.Context, argType T1, replyType *T2) error
+
 	where T1 and T2 can be marshaled by encoding/gob.
 	These requirements apply even if a different codec is used.
 	(In the future, these requirements may soften for custom codecs.)
@@ -46,7 +51,11 @@
 
 	The Call method waits for the remote call to complete while the Go method
 	launches the call asynchronously and signals completion using the Call
-	structure's Done channel.
+	structure's Done channel. The CallContext method is like Call but stops
+	waiting when its context is done. With the default codec, the deadline of
+	the context travels with the request and its cancellation is forwarded
+	to the server, so a method that takes a context.Context sees both. That
+	context is also canceled when the client hangs up.
 
 	Unless an explicit codec is set up, package encoding/gob is used to
 	transport the data.
@@ -128,6 +137,7 @@ package rpc
 
 import (
 	"bufio"
+	"context"
 	"encoding/gob"
 	"errors"
 	"go/token"
@@ -138,6 +148,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -150,11 +161,19 @@ const (
 // because Typeof takes an empty interface value. This is annoying.
 var typeOfError = reflect.TypeOf((*error)(nil)).Elem()
 
+var typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// cancelServiceMethod is the service method of the requests a client
+// sends to ask the server to cancel a call. The body of such a request
+// is the sequence number of the call, and no response is sent.
+const cancelServiceMethod = "_goRPC_.Cancel"
+
 type methodType struct {
 	sync.Mutex // protects counters
 	method     reflect.Method
 	ArgType    reflect.Type
 	ReplyType  reflect.Type
+	withCtx    bool // method takes a context.Context first
 	numCalls   uint
 }
 
@@ -169,9 +188,10 @@ type service struct {
 // but documented here as an aid to debugging, such as when analyzing
 // network traffic.
 type Request struct {
-	ServiceMethod string   // format: "Service.Method"
-	Seq           uint64   // sequence number chosen by client
-	next          *Request // for free list in Server
+	ServiceMethod string        // format: "Service.Method"
+	Seq           uint64        // sequence number chosen by client
+	Timeout       time.Duration // time left to the client's deadline, if any
+	next          *Request      // for free list in Server
 }
 
 // Response is a header written before every RPC return. It is used internally
@@ -214,7 +234,8 @@ func isExportedOrBuiltinType(t reflect.Type) bool {
 // Register publishes in the server the set of methods of the
 // receiver value that satisfy the following conditions:
 //	- exported method of exported type
-//	- two arguments, both of exported type
+//	- two arguments, both of exported type, optionally
+//	  preceded by a context.Context
 //	- the second argument is a pointer
 //	- one return value, of type error
 // It returns an error if the receiver is not an exported type or has
@@ -286,15 +307,21 @@ func suitableMethods(typ reflect.Type, reportErr bool) map[string]*methodType {
 		if !method.IsExported() {
 			continue
 		}
-		// Method needs three ins: receiver, *args, *reply.
-		if mtype.NumIn() != 3 {
+		// Method needs three ins: receiver, *args, *reply,
+		// and may take a context before the arguments.
+		withCtx := mtype.NumIn() == 4 && mtype.In(1) == typeOfContext
+		in := 1
+		if withCtx {
+			in = 2
+		}
+		if mtype.NumIn() != in+2 {
 			if reportErr {
 				log.Printf("rpc.Register: method %q has %d input parameters; needs exactly three\n", mname, mtype.NumIn())
 			}
 			continue
 		}
 		// First arg need not be a pointer.
-		argType := mtype.In(1)
+		argType := mtype.In(in)
 		if !isExportedOrBuiltinType(argType) {
 			if reportErr {
 				log.Printf("rpc.Register: argument type of method %q is not exported: %q\n", mname, argType)
@@ -302,7 +329,7 @@ func suitableMethods(typ reflect.Type, reportErr bool) map[string]*methodType {
 			continue
 		}
 		// Second arg must be a pointer.
-		replyType := mtype.In(2)
+		replyType := mtype.In(in + 1)
 		if replyType.Kind() != reflect.Ptr {
 			if reportErr {
 				log.Printf("rpc.Register: reply type of method %q is not a pointer: %q\n", mname, replyType)
@@ -330,7 +357,7 @@ func suitableMethods(typ reflect.Type, reportErr bool) map[string]*methodType {
 			}
 			continue
 		}
-		methods[mname] = &methodType{method: method, ArgType: argType, ReplyType: replyType}
+		methods[mname] = &methodType{method: method, ArgType: argType, ReplyType: replyType, withCtx: withCtx}
 	}
 	return methods
 }
@@ -365,7 +392,9 @@ func (m *methodType) NumCalls() (n uint) {
 	return n
 }
 
-func (s *service) call(server *Server, sending *sync.Mutex, wg *sync.WaitGroup, mtype *methodType, req *Request, argv, replyv reflect.Value, codec ServerCodec) {
+// call serves req. If the method takes a context, ctx is its context
+// and done releases it once the method returns.
+func (s *service) call(server *Server, sending *sync.Mutex, wg *sync.WaitGroup, ctx context.Context, done context.CancelFunc, mtype *methodType, req *Request, argv, replyv reflect.Value, codec ServerCodec) {
 	if wg != nil {
 		defer wg.Done()
 	}
@@ -374,7 +403,13 @@ func (s *service) call(server *Server, sending *sync.Mutex, wg *sync.WaitGroup,
 	mtype.Unlock()
 	function := mtype.method.Func
 	// Invoke the method, providing a new value for the reply.
-	returnValues := function.Call([]reflect.Value{s.rcvr, argv, replyv})
+	var returnValues []reflect.Value
+	if mtype.withCtx {
+		returnValues = function.Call([]reflect.Value{s.rcvr, reflect.ValueOf(ctx), argv, replyv})
+		done()
+	} else {
+		returnValues = function.Call([]reflect.Value{s.rcvr, argv, replyv})
+	}
 	// The return value for the method is an error.
 	errInter := returnValues[0].Interface()
 	errmsg := ""
@@ -385,6 +420,59 @@ func (s *service) call(server *Server, sending *sync.Mutex, wg *sync.WaitGroup,
 	server.freeRequest(req)
 }
 
+// callContexts holds the contexts of the calls in flight on a
+// connection, so that a client can cancel them.
+type callContexts struct {
+	ctx    context.Context // parent of the call contexts
+	mu     sync.Mutex
+	cancel map[uint64]context.CancelFunc // by request sequence number
+}
+
+func newCallContexts(ctx context.Context) *callContexts {
+	return &callContexts{ctx: ctx, cancel: make(map[uint64]context.CancelFunc)}
+}
+
+// start returns the context for the call made by req, which expires
+// with the client's deadline, and a function to release it once the
+// method returns. A nil cc is valid for a call served on its own.
+func (cc *callContexts) start(req *Request) (context.Context, context.CancelFunc) {
+	parent := context.Background()
+	if cc != nil {
+		parent = cc.ctx
+	}
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if req.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, req.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	if cc == nil {
+		return ctx, cancel
+	}
+	seq := req.Seq
+	cc.mu.Lock()
+	cc.cancel[seq] = cancel
+	cc.mu.Unlock()
+	return ctx, func() {
+		cc.mu.Lock()
+		delete(cc.cancel, seq)
+		cc.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancelCall cancels the context of the call with sequence number seq,
+// if it is still in flight.
+func (cc *callContexts) cancelCall(seq uint64) {
+	cc.mu.Lock()
+	cancel := cc.cancel[seq]
+	cc.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
 type gobServerCodec struct {
 	rwc    io.ReadWriteCloser
 	dec    *gob.Decoder
@@ -454,6 +542,8 @@ func (server *Server) ServeConn(conn io.ReadWriteCloser) {
 func (server *Server) ServeCodec(codec ServerCodec) {
 	sending := new(sync.Mutex)
 	wg := new(sync.WaitGroup)
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := newCallContexts(ctx)
 	for {
 		service, mtype, req, argv, replyv, keepReading, err := server.readRequest(codec)
 		if err != nil {
@@ -470,11 +560,25 @@ func (server *Server) ServeCodec(codec ServerCodec) {
 			}
 			continue
 		}
+		if service == nil {
+			calls.cancelCall(argv.Uint())
+			server.freeRequest(req)
+			continue
+		}
+		var callCtx context.Context
+		var done context.CancelFunc
+		if mtype.withCtx {
+			// Register the call before starting it, so that a
+			// cancellation notice read next finds it.
+			callCtx, done = calls.start(req)
+		}
 		wg.Add(1)
-		go service.call(server, sending, wg, mtype, req, argv, replyv, codec)
+		go service.call(server, sending, wg, callCtx, done, mtype, req, argv, replyv, codec)
 	}
-	// We've seen that there are no more requests.
+	// We've seen that there are no more requests: the client has
+	// hung up, so cancel the calls still running.
 	// Wait for responses to be sent before closing codec.
+	cancel()
 	wg.Wait()
 	codec.Close()
 }
@@ -495,7 +599,19 @@ func (server *Server) ServeRequest(codec ServerCodec) error {
 		}
 		return err
 	}
-	service.call(server, sending, nil, mtype, req, argv, replyv, codec)
+	if service == nil {
+		// A cancellation notice. The call it refers to, if any,
+		// has already been served.
+		server.freeRequest(req)
+		return nil
+	}
+	var ctx context.Context
+	var done context.CancelFunc
+	if mtype.withCtx {
+		var calls *callContexts // nothing can cancel the call
+		ctx, done = calls.start(req)
+	}
+	service.call(server, sending, nil, ctx, done, mtype, req, argv, replyv, codec)
 	return nil
 }
 
@@ -549,6 +665,16 @@ func (server *Server) readRequest(codec ServerCodec) (service *service, mtype *m
 		codec.ReadRequestBody(nil)
 		return
 	}
+	if service == nil {
+		// A cancellation notice; the body is the sequence number
+		// of the call to cancel.
+		var seq uint64
+		if err = codec.ReadRequestBody(&seq); err != nil {
+			return
+		}
+		argv = reflect.ValueOf(seq)
+		return
+	}
 
 	// Decode the argument value.
 	argIsValue := false // if true, need to indirect before calling.
@@ -594,6 +720,12 @@ func (server *Server) readRequestHeader(codec ServerCodec) (svc *service, mtype
 	// we can still recover and move on to the next request.
 	keepReading = true
 
+	// Only the gob codec carries cancellation notices; with other
+	// codecs, the name is looked up like that of any other call.
+	if _, ok := codec.(*gobServerCodec); ok && req.ServiceMethod == cancelServiceMethod {
+		return
+	}
+
 	dot := strings.LastIndex(req.ServiceMethod, ".")
 	if dot < 0 {
 		err = errors.New("rpc: service/method request ill-formed: " + req.ServiceMethod)
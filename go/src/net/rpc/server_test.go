@@ -5,6 +5,9 @@
 package rpc
 
 import (
+	"bufio"
+	"context"
+	"encoding/gob"
 	"errors"
 	"fmt"
 	"io"
@@ -473,6 +476,12 @@ func testServeRequest(t *testing.T, server *Server) {
 	if err == nil {
 		t.Errorf("expected error calling Arith.Add with nil arg")
 	}
+
+	// Cancellation notices are only understood with the gob codec.
+	err = client.Call(cancelServiceMethod, nil, reply)
+	if err == nil || !strings.Contains(err.Error(), "can't find service") {
+		t.Errorf("%s: expected can't find service error, got %v", cancelServiceMethod, err)
+	}
 }
 
 type ReplyNotPointer int
@@ -837,3 +846,151 @@ func BenchmarkEndToEndAsync(b *testing.B) {
 func BenchmarkEndToEndAsyncHTTP(b *testing.B) {
 	benchmarkEndToEndAsync(dialHTTP, b)
 }
+
+// Waiter has methods that take a context.
+type Waiter struct {
+	errc chan error // receives the error of each context seen by Wait
+}
+
+// Wait blocks until its context is done.
+func (w *Waiter) Wait(ctx context.Context, args Args, reply *Reply) error {
+	<-ctx.Done()
+	w.errc <- ctx.Err()
+	return ctx.Err()
+}
+
+// Add reports in reply.C the sum of the arguments, provided the
+// context carries a deadline.
+func (w *Waiter) Add(ctx context.Context, args *Args, reply *Reply) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("no deadline")
+	}
+	reply.C = args.A + args.B
+	return nil
+}
+
+func newWaiterClient(t *testing.T) (*Client, *Waiter) {
+	w := &Waiter{errc: make(chan error, 1)}
+	server := NewServer()
+	if err := server.Register(w); err != nil {
+		t.Fatal(err)
+	}
+	cli, srv := net.Pipe()
+	go server.ServeConn(srv)
+	return NewClient(cli), w
+}
+
+func TestCallContextDeadline(t *testing.T) {
+	client, w := newWaiterClient(t)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	var reply Reply
+	if err := client.CallContext(ctx, "Waiter.Add", &Args{7, 8}, &reply); err != nil {
+		t.Fatalf("Waiter.Add: %v", err)
+	}
+	if reply.C != 15 {
+		t.Errorf("Waiter.Add: got %d, want 15", reply.C)
+	}
+	if err := client.Call("Waiter.Add", &Args{7, 8}, &reply); err == nil {
+		t.Error("Waiter.Add without a deadline succeeded")
+	}
+
+	// The deadline is propagated to the server.
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// Either side may notice the deadline first.
+	if err := client.CallContext(ctx, "Waiter.Wait", Args{}, &reply); err == nil || err.Error() != context.DeadlineExceeded.Error() {
+		t.Errorf("Waiter.Wait error = %v; want %v", err, context.DeadlineExceeded)
+	}
+	if err := <-w.errc; err != context.DeadlineExceeded {
+		t.Errorf("server context error = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestCallContextCancel(t *testing.T) {
+	client, w := newWaiterClient(t)
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	var reply Reply
+	if err := client.CallContext(ctx, "Waiter.Wait", Args{}, &reply); err != context.Canceled {
+		t.Errorf("Waiter.Wait error = %v; want %v", err, context.Canceled)
+	}
+	if err := <-w.errc; err != context.Canceled {
+		t.Errorf("server context error = %v; want %v", err, context.Canceled)
+	}
+
+	// The client is still usable.
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := client.CallContext(ctx, "Waiter.Add", &Args{1, 2}, &reply); err != nil || reply.C != 3 {
+		t.Errorf("Waiter.Add = %d, %v; want 3, nil", reply.C, err)
+	}
+}
+
+// A cancellation notice sent right after its call must not be read
+// before the server has registered the call.
+func TestCallContextCancelAtOnce(t *testing.T) {
+	w := &Waiter{errc: make(chan error, 1)}
+	server := NewServer()
+	if err := server.Register(w); err != nil {
+		t.Fatal(err)
+	}
+	cli, srv := net.Pipe()
+	go server.ServeConn(srv)
+	defer cli.Close()
+	encBuf := bufio.NewWriter(cli)
+	codec := &gobClientCodec{cli, gob.NewDecoder(cli), gob.NewEncoder(encBuf), encBuf}
+
+	for seq := uint64(1); seq < 200; seq += 2 {
+		if err := codec.WriteRequest(&Request{ServiceMethod: "Waiter.Wait", Seq: seq}, Args{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := codec.WriteRequest(&Request{ServiceMethod: cancelServiceMethod, Seq: seq + 1}, seq); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case err := <-w.errc:
+			if err != context.Canceled {
+				t.Fatalf("server context error = %v; want %v", err, context.Canceled)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("call %d was not canceled", seq)
+		}
+		var resp Response
+		if err := codec.ReadResponseHeader(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if err := codec.ReadResponseBody(nil); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Seq != seq || resp.Error != context.Canceled.Error() {
+			t.Fatalf("response = %+v; want call %d canceled", resp, seq)
+		}
+	}
+}
+
+func TestCallContextHangUp(t *testing.T) {
+	client, w := newWaiterClient(t)
+
+	go client.Call("Waiter.Wait", Args{}, new(Reply))
+	time.Sleep(10 * time.Millisecond)
+	client.Close()
+	if err := <-w.errc; err != context.Canceled {
+		t.Errorf("server context error = %v; want %v", err, context.Canceled)
+	}
+}
+
+func TestCallContextDone(t *testing.T) {
+	client, _ := newWaiterClient(t)
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := client.CallContext(ctx, "Waiter.Add", &Args{1, 2}, new(Reply)); err != context.Canceled {
+		t.Errorf("CallContext error = %v; want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securedns
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// mediaType is the DNS message media type registered by RFC 8484.
+const mediaType = "application/dns-message"
+
+// maxMessageSize is the largest DNS message a response body may hold.
+const maxMessageSize = 65535
+
+// defaultRoundTripper is used by HTTPSTransport when RoundTripper is
+// nil. It is configured like http.DefaultTransport, apart from its
+// resolver.
+var defaultRoundTripper http.RoundTripper = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Resolver:  new(net.Resolver),
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          100,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ExpectContinueTimeout: 1 * time.Second,
+}
+
+var errRecursiveLookup = errors.New("securedns: DNS-over-HTTPS endpoint looked up through its own transport")
+
+// inRoundTripKey is the key of the context value marking the
+// contexts of the HTTP requests sent by t.
+type inRoundTripKey struct{ t *HTTPSTransport }
+
+// HTTPSTransport is a net.DNSTransport that speaks DNS-over-HTTPS
+// (RFC 8484). Every query is sent to URL regardless of the name
+// server address chosen by the resolver.
+type HTTPSTransport struct {
+	// URL is the URL of the DNS-over-HTTPS endpoint, such as
+	// "https://dns.example.net/dns-query".
+	URL string
+
+	// RoundTripper performs the HTTP requests.
+	// If nil, a transport configured like http.DefaultTransport is
+	// used, except that it looks up the host of URL with a Resolver
+	// that has no Transport, over unencrypted DNS, so that the lookup
+	// does not come back to this HTTPSTransport. To avoid the lookup,
+	// use an IP address as the host of URL.
+	//
+	// A RoundTripper that looks up the host of URL with a Resolver
+	// using this HTTPSTransport would never complete a query. When
+	// RoundTrip is called back to make such a lookup, it fails
+	// instead.
+	RoundTripper http.RoundTripper
+
+	// UseGET selects the GET method, with the query carried in the
+	// "dns" URL parameter, instead of POST. GET responses are
+	// more easily cached by HTTP intermediaries.
+	UseGET bool
+}
+
+// RoundTrip implements net.DNSTransport.
+func (t *HTTPSTransport) RoundTrip(ctx context.Context, address string, query []byte) ([]byte, error) {
+	if len(query) < 2 {
+		return nil, errors.New("securedns: DNS message too short")
+	}
+	if len(query) > maxMessageSize {
+		return nil, errMessageTooLong
+	}
+	// A call made while t sends a request is from a lookup of the
+	// host of URL that goes through t.
+	key := inRoundTripKey{t}
+	if ctx.Value(key) != nil {
+		return nil, errRecursiveLookup
+	}
+	ctx = context.WithValue(ctx, key, true)
+
+	// RFC 8484 section 4.1 asks clients to use a message ID of 0
+	// to make responses cache friendly; the original ID is put
+	// back into the response below.
+	q := make([]byte, len(query))
+	copy(q, query)
+	q[0], q[1] = 0, 0
+
+	var req *http.Request
+	var err error
+	if t.UseGET {
+		sep := "?"
+		if strings.Contains(t.URL, "?") {
+			sep = "&"
+		}
+		u := t.URL + sep + "dns=" + base64.RawURLEncoding.EncodeToString(q)
+		req, err = http.NewRequestWithContext(ctx, "GET", u, nil)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, "POST", t.URL, bytes.NewReader(q))
+		if err == nil {
+			req.Header.Set("Content-Type", mediaType)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", mediaType)
+
+	rt := t.RoundTripper
+	if rt == nil {
+		rt = defaultRoundTripper
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("securedns: unexpected HTTP status " + resp.Status)
+	}
+	if ct, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err != nil || ct != mediaType {
+		return nil, errors.New("securedns: unexpected Content-Type " + resp.Header.Get("Content-Type"))
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxMessageSize {
+		return nil, errMessageTooLong
+	}
+	if len(b) >= 2 && b[0] == 0 && b[1] == 0 {
+		b[0], b[1] = query[0], query[1]
+	}
+	return b, nil
+}
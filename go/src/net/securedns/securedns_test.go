@@ -0,0 +1,256 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package securedns
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+var _ net.DNSTransport = (*TLSTransport)(nil)
+var _ net.DNSTransport = (*HTTPSTransport)(nil)
+
+func newQuery(t *testing.T, id uint16) []byte {
+	t.Helper()
+	m := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName("www.example.com."),
+			Type:  dnsmessage.TypeA,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	b, err := m.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// answer builds the response of a stand-in name server to query.
+func answer(query []byte) ([]byte, error) {
+	var q dnsmessage.Message
+	if err := q.Unpack(query); err != nil {
+		return nil, err
+	}
+	r := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: q.Header.ID, Response: true},
+		Questions: q.Questions,
+		Answers: []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{
+				Name:  q.Questions[0].Name,
+				Type:  dnsmessage.TypeA,
+				Class: dnsmessage.ClassINET,
+			},
+			Body: &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}},
+		}},
+	}
+	return r.Pack()
+}
+
+func checkAnswer(t *testing.T, resp []byte, id uint16) {
+	t.Helper()
+	var m dnsmessage.Message
+	if err := m.Unpack(resp); err != nil {
+		t.Fatal(err)
+	}
+	if m.Header.ID != id {
+		t.Errorf("response ID = %d; want %d", m.Header.ID, id)
+	}
+	if len(m.Answers) != 1 {
+		t.Fatalf("got %d answers; want 1", len(m.Answers))
+	}
+	if a, ok := m.Answers[0].Body.(*dnsmessage.AResource); !ok || a.A != [4]byte{192, 0, 2, 1} {
+		t.Errorf("answer = %v; want A 192.0.2.1", m.Answers[0].Body)
+	}
+}
+
+// newTLSConfigs returns server and client TLS configurations that
+// trust each other for the address 127.0.0.1.
+func newTLSConfigs(t *testing.T) (server, client *tls.Config) {
+	ts := httptest.NewUnstartedServer(nil)
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	server = &tls.Config{Certificates: ts.TLS.Certificates}
+	client = ts.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	return server, client
+}
+
+// serveDoT runs a DNS-over-TLS stand-in on ln. If once is set, each
+// connection is closed after answering a single query.
+func serveDoT(ln net.Listener, accepts *int32, once bool) {
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		atomic.AddInt32(accepts, 1)
+		go func() {
+			defer c.Close()
+			for {
+				var l [2]byte
+				if _, err := io.ReadFull(c, l[:]); err != nil {
+					return
+				}
+				q := make([]byte, int(l[0])<<8|int(l[1]))
+				if _, err := io.ReadFull(c, q); err != nil {
+					return
+				}
+				r, err := answer(q)
+				if err != nil {
+					return
+				}
+				c.Write(append([]byte{byte(len(r) >> 8), byte(len(r))}, r...))
+				if once {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func TestTLSTransport(t *testing.T) {
+	for _, once := range []bool{false, true} {
+		serverConfig, clientConfig := newTLSConfigs(t)
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		var accepts int32
+		go serveDoT(ln, &accepts, once)
+
+		_, port, _ := net.SplitHostPort(ln.Addr().String())
+		tr := &TLSTransport{Config: clientConfig, Port: port}
+		defer tr.CloseIdleConnections()
+		for id := uint16(1); id <= 3; id++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			resp, err := tr.RoundTrip(ctx, "127.0.0.1:53", newQuery(t, id))
+			cancel()
+			if err != nil {
+				t.Fatalf("once=%v: RoundTrip: %v", once, err)
+			}
+			checkAnswer(t, resp, id)
+		}
+		want := int32(1)
+		if once {
+			want = 3
+		}
+		if got := atomic.LoadInt32(&accepts); got != want {
+			t.Errorf("once=%v: server accepted %d connections; want %d", once, got, want)
+		}
+	}
+}
+
+func TestTLSTransportBadCertificate(t *testing.T) {
+	serverConfig, _ := newTLSConfigs(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	var accepts int32
+	go serveDoT(ln, &accepts, false)
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	tr := &TLSTransport{Port: port}
+	if _, err := tr.RoundTrip(context.Background(), "127.0.0.1:53", newQuery(t, 1)); err == nil {
+		t.Fatal("RoundTrip with an untrusted certificate succeeded")
+	}
+}
+
+func TestHTTPSTransport(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != mediaType {
+			http.Error(w, "bad Accept", http.StatusBadRequest)
+			return
+		}
+		var q []byte
+		var err error
+		switch r.Method {
+		case "GET":
+			q, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		case "POST":
+			if r.Header.Get("Content-Type") != mediaType {
+				http.Error(w, "bad Content-Type", http.StatusUnsupportedMediaType)
+				return
+			}
+			q, err = io.ReadAll(r.Body)
+		}
+		if err != nil || len(q) < 2 {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		if q[0] != 0 || q[1] != 0 {
+			http.Error(w, "nonzero message ID", http.StatusBadRequest)
+			return
+		}
+		resp, err := answer(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", mediaType)
+		w.Write(resp)
+	}))
+	defer ts.Close()
+
+	for _, get := range []bool{false, true} {
+		tr := &HTTPSTransport{
+			URL:          ts.URL + "/dns-query",
+			RoundTripper: ts.Client().Transport,
+			UseGET:       get,
+		}
+		resp, err := tr.RoundTrip(context.Background(), "192.0.2.53:53", newQuery(t, 0x1234))
+		if err != nil {
+			t.Fatalf("UseGET=%v: RoundTrip: %v", get, err)
+		}
+		checkAnswer(t, resp, 0x1234)
+	}
+}
+
+func TestHTTPSTransportBadResponse(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "<html></html>")
+	}))
+	defer ts.Close()
+
+	tr := &HTTPSTransport{URL: ts.URL, RoundTripper: ts.Client().Transport}
+	if _, err := tr.RoundTrip(context.Background(), "192.0.2.53:53", newQuery(t, 1)); err == nil {
+		t.Fatal("RoundTrip accepted a non-DNS response")
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestHTTPSTransportRecursiveLookup(t *testing.T) {
+	tr := &HTTPSTransport{URL: "https://dns.example.net/dns-query"}
+	var lookupErr error
+	tr.RoundTripper = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		// Look up the host of URL the way a dialer using a Resolver
+		// whose Transport is tr would.
+		_, lookupErr = tr.RoundTrip(req.Context(), "192.0.2.53:53", newQuery(t, 2))
+		return nil, lookupErr
+	})
+	if _, err := tr.RoundTrip(context.Background(), "192.0.2.53:53", newQuery(t, 1)); err == nil {
+		t.Fatal("RoundTrip succeeded")
+	}
+	if lookupErr != errRecursiveLookup {
+		t.Errorf("lookup through the same transport: got %v; want %v", lookupErr, errRecursiveLookup)
+	}
+}
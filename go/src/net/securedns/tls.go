@@ -0,0 +1,224 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package securedns implements encrypted transports for Go's built-in
+// DNS resolver: DNS-over-TLS (RFC 7858) and DNS-over-HTTPS (RFC 8484).
+//
+// A transport is installed by setting the Transport field of a
+// net.Resolver:
+//
+//	r := &net.Resolver{
+//		Transport: &securedns.TLSTransport{
+//			Config: &tls.Config{ServerName: "dns.example.net"},
+//		},
+//	}
+//	addrs, err := r.LookupHost(ctx, "golang.org")
+//
+// The resolver keeps choosing name servers, applying the search list
+// and retrying according to the system configuration (resolv.conf);
+// only the framing and encryption of each exchange changes.
+//
+// 包securedns为Go内置的DNS解析器实现了加密传输：
+// DNS-over-TLS（RFC 7858）和DNS-over-HTTPS（RFC 8484）。
+package securedns
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultTLSPort is the port used by TLSTransport when Port is empty.
+const DefaultTLSPort = "853"
+
+// defaultIdleTimeout is used by TLSTransport when IdleTimeout is zero.
+const defaultIdleTimeout = 10 * time.Second
+
+var errMessageTooLong = errors.New("securedns: DNS message too long")
+
+// TLSTransport is a net.DNSTransport that speaks DNS-over-TLS
+// (RFC 7858). Connections are kept open between queries and reused
+// for later queries to the same name server.
+//
+// A TLSTransport must not be copied after first use.
+type TLSTransport struct {
+	// Config is the TLS configuration used for new connections.
+	// If nil, the zero configuration is used. If Config.ServerName
+	// is empty, the certificate presented by the name server is
+	// verified against the server's IP address.
+	Config *tls.Config
+
+	// Port is the port connections are made to. The host is taken
+	// from the name server address chosen by the resolver.
+	// If empty, DefaultTLSPort is used.
+	Port string
+
+	// DialContext optionally specifies the dial function for
+	// creating the underlying TCP connections. If nil, a zero
+	// net.Dialer is used.
+	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// IdleTimeout is the maximum amount of time an idle connection
+	// remains open for reuse. If zero, 10 seconds is used. If
+	// negative, connections are not reused.
+	IdleTimeout time.Duration
+
+	mu   sync.Mutex
+	idle map[string][]*tlsConn // by dial address
+}
+
+// tlsConn is a DNS-over-TLS connection and the time it became idle.
+type tlsConn struct {
+	net.Conn
+	idleAt time.Time
+}
+
+// RoundTrip implements net.DNSTransport.
+func (t *TLSTransport) RoundTrip(ctx context.Context, address string, query []byte) ([]byte, error) {
+	if len(query) > 0xffff {
+		return nil, errMessageTooLong
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port := t.Port
+	if port == "" {
+		port = DefaultTLSPort
+	}
+	addr := net.JoinHostPort(host, port)
+
+	for {
+		c, reused := t.getIdle(addr)
+		if c == nil {
+			if c, err = t.dial(ctx, host, addr); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := exchangeStream(ctx, c, query)
+		if err != nil {
+			c.Close()
+			// The server may have closed an idle connection
+			// while it sat in the pool; retry on a new one.
+			if reused && ctx.Err() == nil {
+				continue
+			}
+			return nil, err
+		}
+		t.putIdle(addr, c)
+		return resp, nil
+	}
+}
+
+// CloseIdleConnections closes any connections that were kept open
+// for reuse by earlier queries.
+func (t *TLSTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	idle := t.idle
+	t.idle = nil
+	t.mu.Unlock()
+	for _, conns := range idle {
+		for _, c := range conns {
+			c.Close()
+		}
+	}
+}
+
+func (t *TLSTransport) idleTimeout() time.Duration {
+	if t.IdleTimeout == 0 {
+		return defaultIdleTimeout
+	}
+	return t.IdleTimeout
+}
+
+// getIdle returns an idle connection to addr, if one is available.
+// Connections idle for longer than the idle timeout are closed.
+func (t *TLSTransport) getIdle(addr string) (c *tlsConn, reused bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	conns := t.idle[addr]
+	for len(conns) > 0 {
+		c = conns[len(conns)-1]
+		conns[len(conns)-1] = nil
+		conns = conns[:len(conns)-1]
+		if time.Since(c.idleAt) < t.idleTimeout() {
+			t.idle[addr] = conns
+			return c, true
+		}
+		c.Close()
+	}
+	delete(t.idle, addr)
+	return nil, false
+}
+
+// putIdle returns c to the pool of idle connections to addr.
+func (t *TLSTransport) putIdle(addr string, c *tlsConn) {
+	if t.idleTimeout() < 0 {
+		c.Close()
+		return
+	}
+	c.SetDeadline(time.Time{})
+	c.idleAt = time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.idle == nil {
+		t.idle = make(map[string][]*tlsConn)
+	}
+	t.idle[addr] = append(t.idle[addr], c)
+}
+
+// dial opens a new TLS connection to addr and completes the handshake.
+func (t *TLSTransport) dial(ctx context.Context, host, addr string) (*tlsConn, error) {
+	dial := t.DialContext
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+	raw, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	var config *tls.Config
+	if t.Config == nil {
+		config = &tls.Config{}
+	} else {
+		config = t.Config.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	tc := tls.Client(raw, config)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return &tlsConn{Conn: tc}, nil
+}
+
+// exchangeStream writes query to c with the two-byte length prefix
+// of RFC 1035 section 4.2.2 and reads back a single response.
+func exchangeStream(ctx context.Context, c net.Conn, query []byte) ([]byte, error) {
+	if d, ok := ctx.Deadline(); ok {
+		c.SetDeadline(d)
+	}
+	b := make([]byte, 2+len(query))
+	b[0] = byte(len(query) >> 8)
+	b[1] = byte(len(query))
+	copy(b[2:], query)
+	if _, err := c.Write(b); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(c, b[:2]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, int(b[0])<<8|int(b[1]))
+	if _, err := io.ReadFull(c, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -4,9 +4,13 @@
 
 // Package smtp implements the Simple Mail Transfer Protocol as defined in RFC 5321.
 // It also implements the following extensions:
-//	8BITMIME  RFC 1652
-//	AUTH      RFC 2554
-//	STARTTLS  RFC 3207
+//	8BITMIME    RFC 1652
+//	AUTH        RFC 2554
+//	STARTTLS    RFC 3207
+//	PIPELINING  RFC 2920
+//	CHUNKING    RFC 3030
+//	DSN         RFC 3461
+//	SMTPUTF8    RFC 6531
 // Additional extensions may be handled by clients.
 //
 // The smtp package is frozen and is not accepting new features.
@@ -16,6 +20,7 @@
 package smtp
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/base64"
 	"errors"
@@ -24,6 +29,8 @@ import (
 	"net"
 	"net/textproto"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // A Client represents a client connection to an SMTP server.
@@ -57,6 +64,63 @@ func Dial(addr string) (*Client, error) {
 	return NewClient(conn, host)
 }
 
+// DialContext is like Dial but takes a context. The context bounds
+// the connection to the server and the reading of its greeting; once
+// DialContext returns, the context has no effect on the Client.
+func DialContext(ctx context.Context, addr string) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	stop := watchContext(ctx, conn)
+	c, err := NewClient(conn, host)
+	if ctxErr := stop(); ctxErr != nil {
+		if err == nil {
+			c.Close()
+		}
+		return nil, ctxErr
+	}
+	return c, err
+}
+
+// watchContext applies the deadline and cancellation of ctx to conn
+// until the returned function is called. That function reports the
+// context's error if the context ended the connection's I/O.
+func watchContext(ctx context.Context, conn net.Conn) (stop func() error) {
+	if ctx.Done() == nil {
+		return func() error { return nil }
+	}
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	}
+	done := make(chan struct{})
+	interrupted := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Unblock any I/O in progress.
+			conn.SetDeadline(aLongTimeAgo)
+			interrupted <- true
+		case <-done:
+			interrupted <- false
+		}
+	}()
+	return func() error {
+		close(done)
+		if <-interrupted {
+			return ctx.Err()
+		}
+		conn.SetDeadline(time.Time{})
+		return ctx.Err()
+	}
+}
+
+// aLongTimeAgo is a non-zero time, far in the past, used for
+// immediate cancellation of network operations.
+var aLongTimeAgo = time.Unix(1, 0)
+
 // NewClient returns a new Client using an existing connection and host as a
 // server name to be used when authenticating.
 func NewClient(conn net.Conn, host string) (*Client, error) {
@@ -275,6 +339,237 @@ func (c *Client) Rcpt(to string) error {
 	return err
 }
 
+// MailOptions holds the optional parameters of a MAIL command.
+type MailOptions struct {
+	// Return asks for delivery status notifications to include
+	// either the full message ("FULL") or only its header ("HDRS").
+	// It requires the DSN extension.
+	Return string
+
+	// EnvelopeID is an identifier of the message included in its
+	// delivery status notifications. It requires the DSN extension.
+	EnvelopeID string
+}
+
+// RcptOptions holds the optional parameters of a RCPT command.
+type RcptOptions struct {
+	// Notify lists the conditions for which a delivery status
+	// notification is requested: either "NEVER" alone, or any of
+	// "SUCCESS", "FAILURE" and "DELAY". It requires the DSN
+	// extension.
+	Notify []string
+
+	// OriginalRecipient is the address to which the message was
+	// originally sent, reported in delivery status notifications.
+	// It requires the DSN extension.
+	OriginalRecipient string
+}
+
+// MailWithOptions is like Mail but also sends the parameters in opts,
+// which may be nil. It returns an error if the parameters, or the
+// non-ASCII characters of from, require an extension the server does
+// not support.
+func (c *Client) MailWithOptions(from string, opts *MailOptions) error {
+	cmdStr, err := c.mailCmd(from, opts)
+	if err != nil {
+		return err
+	}
+	_, _, err = c.cmd(250, "%s", cmdStr)
+	return err
+}
+
+// RcptWithOptions is like Rcpt but also sends the parameters in opts,
+// which may be nil. It returns an error if the parameters, or the
+// non-ASCII characters of to, require an extension the server does
+// not support.
+func (c *Client) RcptWithOptions(to string, opts *RcptOptions) error {
+	cmdStr, err := c.rcptCmd(to, opts)
+	if err != nil {
+		return err
+	}
+	_, _, err = c.cmd(25, "%s", cmdStr)
+	return err
+}
+
+// mailCmd returns the MAIL command for from and opts.
+func (c *Client) mailCmd(from string, opts *MailOptions) (string, error) {
+	if err := validateLine(from); err != nil {
+		return "", err
+	}
+	if err := c.hello(); err != nil {
+		return "", err
+	}
+	if err := c.checkUTF8(from); err != nil {
+		return "", err
+	}
+	cmdStr := "MAIL FROM:<" + from + ">"
+	if c.ext != nil {
+		if _, ok := c.ext["8BITMIME"]; ok {
+			cmdStr += " BODY=8BITMIME"
+		}
+		if _, ok := c.ext["SMTPUTF8"]; ok {
+			cmdStr += " SMTPUTF8"
+		}
+	}
+	if opts == nil || opts.Return == "" && opts.EnvelopeID == "" {
+		return cmdStr, nil
+	}
+	if _, ok := c.ext["DSN"]; !ok {
+		return "", errors.New("smtp: server doesn't support DSN")
+	}
+	if opts.Return != "" {
+		ret := strings.ToUpper(opts.Return)
+		if ret != "FULL" && ret != "HDRS" {
+			return "", errors.New("smtp: invalid DSN return type " + opts.Return)
+		}
+		cmdStr += " RET=" + ret
+	}
+	if opts.EnvelopeID != "" {
+		if err := validateLine(opts.EnvelopeID); err != nil {
+			return "", err
+		}
+		cmdStr += " ENVID=" + xtext(opts.EnvelopeID)
+	}
+	return cmdStr, nil
+}
+
+// rcptCmd returns the RCPT command for to and opts.
+func (c *Client) rcptCmd(to string, opts *RcptOptions) (string, error) {
+	if err := validateLine(to); err != nil {
+		return "", err
+	}
+	if err := c.checkUTF8(to); err != nil {
+		return "", err
+	}
+	cmdStr := "RCPT TO:<" + to + ">"
+	if opts == nil || len(opts.Notify) == 0 && opts.OriginalRecipient == "" {
+		return cmdStr, nil
+	}
+	if _, ok := c.ext["DSN"]; !ok {
+		return "", errors.New("smtp: server doesn't support DSN")
+	}
+	if len(opts.Notify) > 0 {
+		notify := make([]string, len(opts.Notify))
+		for i, n := range opts.Notify {
+			n = strings.ToUpper(n)
+			switch n {
+			case "NEVER":
+				if len(opts.Notify) > 1 {
+					return "", errors.New("smtp: DSN notification NEVER combined with others")
+				}
+			case "SUCCESS", "FAILURE", "DELAY":
+			default:
+				return "", errors.New("smtp: invalid DSN notification " + opts.Notify[i])
+			}
+			notify[i] = n
+		}
+		cmdStr += " NOTIFY=" + strings.Join(notify, ",")
+	}
+	if opts.OriginalRecipient != "" {
+		if err := validateLine(opts.OriginalRecipient); err != nil {
+			return "", err
+		}
+		cmdStr += " ORCPT=rfc822;" + xtext(opts.OriginalRecipient)
+	}
+	return cmdStr, nil
+}
+
+// checkUTF8 returns an error if addr is not ASCII and the server does
+// not support the SMTPUTF8 extension.
+func (c *Client) checkUTF8(addr string) error {
+	for i := 0; i < len(addr); i++ {
+		if addr[i] >= utf8.RuneSelf {
+			if _, ok := c.ext["SMTPUTF8"]; !ok {
+				return errors.New("smtp: server doesn't support SMTPUTF8, required by address " + addr)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// xtext encodes s as defined by RFC 3461 section 4.
+func xtext(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '!' || c > '~' || c == '+' || c == '=' {
+			b.WriteByte('+')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xf])
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// Envelope starts a mail transaction from the address from to the
+// addresses in to, with the optional parameters mopts and ropts. If
+// the server supports the PIPELINING extension, the MAIL and RCPT
+// commands are sent without waiting for each other's responses.
+// Otherwise Envelope is equivalent to a call to MailWithOptions
+// followed by a call to RcptWithOptions for each recipient.
+//
+// Envelope returns the first error reported by the server; if a
+// recipient is rejected, the transaction should be reset.
+func (c *Client) Envelope(from string, mopts *MailOptions, to []string, ropts *RcptOptions) error {
+	if ok, _ := c.Extension("PIPELINING"); !ok {
+		if err := c.MailWithOptions(from, mopts); err != nil {
+			return err
+		}
+		for _, addr := range to {
+			if err := c.RcptWithOptions(addr, ropts); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	cmds := make([]string, 0, 1+len(to))
+	cmdStr, err := c.mailCmd(from, mopts)
+	if err != nil {
+		return err
+	}
+	cmds = append(cmds, cmdStr)
+	for _, addr := range to {
+		cmdStr, err := c.rcptCmd(addr, ropts)
+		if err != nil {
+			return err
+		}
+		cmds = append(cmds, cmdStr)
+	}
+	ids := make([]uint, len(cmds))
+	for i, cmdStr := range cmds {
+		if ids[i], err = c.Text.Cmd("%s", cmdStr); err != nil {
+			return err
+		}
+	}
+	// Every response must be read, even after a failure, to keep the
+	// connection in sync with the server.
+	var first error
+	for i, id := range ids {
+		expectCode := 25
+		if i == 0 {
+			expectCode = 250
+		}
+		c.Text.StartResponse(id)
+		_, _, err := c.Text.ReadResponse(expectCode)
+		c.Text.EndResponse(id)
+		if err != nil {
+			if _, ok := err.(*textproto.Error); !ok {
+				// The connection is broken.
+				return err
+			}
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
 type dataCloser struct {
 	c *Client
 	io.WriteCloser
@@ -298,6 +593,91 @@ func (c *Client) Data() (io.WriteCloser, error) {
 	return &dataCloser{c, c.Text.DotWriter()}, nil
 }
 
+// chunkSize is the size of the chunks sent by the writer returned
+// by DataChunked.
+const chunkSize = 64 << 10
+
+// DataChunked is like Data but sends the message with BDAT commands,
+// as defined by the CHUNKING extension. The message is sent without
+// dot-stuffing; as with Data, bare LF line endings are converted to
+// CRLF. Only servers that advertise the CHUNKING extension support
+// this method.
+func (c *Client) DataChunked() (io.WriteCloser, error) {
+	if ok, _ := c.Extension("CHUNKING"); !ok {
+		return nil, errors.New("smtp: server doesn't support CHUNKING")
+	}
+	return &bdatWriter{c: c}, nil
+}
+
+// A bdatWriter sends what is written to it in BDAT chunks.
+type bdatWriter struct {
+	c      *Client
+	buf    []byte
+	cr     bool // whether the last byte written was '\r'
+	err    error
+	closed bool
+}
+
+func (w *bdatWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("smtp: write after close")
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	for _, c := range p {
+		if c == '\n' && !w.cr {
+			w.buf = append(w.buf, '\r')
+		}
+		w.buf = append(w.buf, c)
+		w.cr = c == '\r'
+		if len(w.buf) >= chunkSize {
+			if w.err = w.c.bdat(w.buf, false); w.err != nil {
+				return 0, w.err
+			}
+			w.buf = w.buf[:0]
+		}
+	}
+	return len(p), nil
+}
+
+func (w *bdatWriter) Close() error {
+	if w.closed {
+		return errors.New("smtp: close after close")
+	}
+	w.closed = true
+	if w.err != nil {
+		return w.err
+	}
+	return w.c.bdat(w.buf, true)
+}
+
+// bdat sends a BDAT command carrying chunk and reads its response.
+func (c *Client) bdat(chunk []byte, last bool) error {
+	id := c.Text.Next()
+	c.Text.StartRequest(id)
+	var err error
+	if last {
+		_, err = fmt.Fprintf(c.Text.W, "BDAT %d LAST\r\n", len(chunk))
+	} else {
+		_, err = fmt.Fprintf(c.Text.W, "BDAT %d\r\n", len(chunk))
+	}
+	if err == nil {
+		_, err = c.Text.W.Write(chunk)
+	}
+	if err == nil {
+		err = c.Text.W.Flush()
+	}
+	c.Text.EndRequest(id)
+	if err != nil {
+		return err
+	}
+	c.Text.StartResponse(id)
+	defer c.Text.EndResponse(id)
+	_, _, err = c.Text.ReadResponse(250)
+	return err
+}
+
 var testHookStartTLS func(*tls.Config) // nil, except for tests
 
 // SendMail connects to the server at addr, switches to TLS if
@@ -321,6 +701,21 @@ var testHookStartTLS func(*tls.Config) // nil, except for tests
 // functionality. Higher-level packages exist outside of the standard
 // library.
 func SendMail(addr string, a Auth, from string, to []string, msg []byte) error {
+	return dialAndSendMail(context.Background(), addr, a, from, to, msg, false)
+}
+
+// SendMailContext is like SendMail but takes a context, which bounds
+// the whole exchange with the server. Unlike SendMail, it uses the
+// PIPELINING extension to send the envelope and the CHUNKING extension
+// to send msg if the server supports them.
+func SendMailContext(ctx context.Context, addr string, a Auth, from string, to []string, msg []byte) error {
+	return dialAndSendMail(ctx, addr, a, from, to, msg, true)
+}
+
+// dialAndSendMail implements SendMail and SendMailContext. If extended is
+// set, it sends the envelope with Envelope and the message with
+// DataChunked where the server allows.
+func dialAndSendMail(ctx context.Context, addr string, a Auth, from string, to []string, msg []byte, extended bool) (err error) {
 	if err := validateLine(from); err != nil {
 		return err
 	}
@@ -329,11 +724,17 @@ func SendMail(addr string, a Auth, from string, to []string, msg []byte) error {
 			return err
 		}
 	}
-	c, err := Dial(addr)
+	c, err := DialContext(ctx, addr)
 	if err != nil {
 		return err
 	}
 	defer c.Close()
+	stop := watchContext(ctx, c.conn)
+	defer func() {
+		if ctxErr := stop(); ctxErr != nil {
+			err = ctxErr
+		}
+	}()
 	if err = c.hello(); err != nil {
 		return err
 	}
@@ -354,15 +755,26 @@ func SendMail(addr string, a Auth, from string, to []string, msg []byte) error {
 			return err
 		}
 	}
-	if err = c.Mail(from); err != nil {
-		return err
-	}
-	for _, addr := range to {
-		if err = c.Rcpt(addr); err != nil {
+	if extended {
+		if err = c.Envelope(from, nil, to, nil); err != nil {
+			return err
+		}
+	} else {
+		if err = c.Mail(from); err != nil {
 			return err
 		}
+		for _, addr := range to {
+			if err = c.Rcpt(addr); err != nil {
+				return err
+			}
+		}
+	}
+	var w io.WriteCloser
+	if ok, _ := c.Extension("CHUNKING"); extended && ok {
+		w, err = c.DataChunked()
+	} else {
+		w, err = c.Data()
 	}
-	w, err := c.Data()
 	if err != nil {
 		return err
 	}
@@ -7,6 +7,7 @@ package smtp
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
@@ -501,6 +502,285 @@ QUIT
 	})
 }
 
+// newFakeClient returns a Client talking to a fake server that sends
+// the given responses, already greeted with EHLO, and a function
+// returning the commands the client sent.
+func newFakeClient(server string) (*Client, func() string) {
+	server = strings.Join(strings.Split(server, "\n"), "\r\n")
+	var cmdbuf strings.Builder
+	bcmdbuf := bufio.NewWriter(&cmdbuf)
+	var fake faker
+	fake.ReadWriter = bufio.NewReadWriter(bufio.NewReader(strings.NewReader(server)), bcmdbuf)
+	c := &Client{Text: textproto.NewConn(fake), localName: "localhost"}
+	return c, func() string {
+		bcmdbuf.Flush()
+		return strings.ReplaceAll(cmdbuf.String(), "\r\n", "\n")
+	}
+}
+
+func TestEnvelopePipelining(t *testing.T) {
+	const server = `250-mx.example.com at your service
+250-PIPELINING
+250-SMTPUTF8
+250 DSN
+250 Sender OK
+250 Receiver OK
+550 No such user
+250 Receiver OK
+250 Reset OK
+`
+	const client = `EHLO localhost
+MAIL FROM:<jörg@example.com> SMTPUTF8 RET=HDRS ENVID=id+2B1
+RCPT TO:<a@example.com> NOTIFY=FAILURE,DELAY ORCPT=rfc822;a+2Bx@example.com
+RCPT TO:<b@example.com> NOTIFY=FAILURE,DELAY ORCPT=rfc822;a+2Bx@example.com
+RCPT TO:<c@example.com> NOTIFY=FAILURE,DELAY ORCPT=rfc822;a+2Bx@example.com
+RSET
+`
+	c, cmds := newFakeClient(server)
+	err := c.Envelope("jörg@example.com", &MailOptions{Return: "hdrs", EnvelopeID: "id+1"},
+		[]string{"a@example.com", "b@example.com", "c@example.com"},
+		&RcptOptions{Notify: []string{"failure", "delay"}, OriginalRecipient: "a+x@example.com"})
+	if te, ok := err.(*textproto.Error); !ok || te.Code != 550 {
+		t.Errorf("Envelope error = %v; want 550 error", err)
+	}
+	// All responses were consumed: the connection is in sync.
+	if err := c.Reset(); err != nil {
+		t.Errorf("RSET failed: %v", err)
+	}
+	if got := cmds(); got != client {
+		t.Errorf("Got:\n%s\nExpected:\n%s", got, client)
+	}
+}
+
+func TestExtensionRequirements(t *testing.T) {
+	const server = `250-mx.example.com at your service
+250 8BITMIME
+`
+	c, cmds := newFakeClient(server)
+	if err := c.MailWithOptions("jörg@example.com", nil); err == nil {
+		t.Error("MAIL with a non-ASCII address succeeded without SMTPUTF8")
+	}
+	if err := c.RcptWithOptions("zoë@example.com", nil); err == nil {
+		t.Error("RCPT with a non-ASCII address succeeded without SMTPUTF8")
+	}
+	if err := c.MailWithOptions("a@example.com", &MailOptions{Return: "FULL"}); err == nil {
+		t.Error("MAIL with DSN parameters succeeded without DSN")
+	}
+	if err := c.RcptWithOptions("a@example.com", &RcptOptions{Notify: []string{"NEVER"}}); err == nil {
+		t.Error("RCPT with DSN parameters succeeded without DSN")
+	}
+	if _, err := c.DataChunked(); err == nil {
+		t.Error("DataChunked succeeded without CHUNKING")
+	}
+	if got, want := cmds(), "EHLO localhost\n"; got != want {
+		t.Errorf("Got:\n%s\nExpected:\n%s", got, want)
+	}
+}
+
+func TestMailNonASCIIWithoutSMTPUTF8(t *testing.T) {
+	const server = `250-mx.example.com at your service
+250 8BITMIME
+250 Sender OK
+250 Receiver OK
+`
+	const client = `EHLO localhost
+MAIL FROM:<jörg@example.com> BODY=8BITMIME
+RCPT TO:<zoë@example.com>
+`
+	// Mail and Rcpt leave it to the server to reject the addresses.
+	c, cmds := newFakeClient(server)
+	if err := c.Mail("jörg@example.com"); err != nil {
+		t.Errorf("MAIL failed: %v", err)
+	}
+	if err := c.Rcpt("zoë@example.com"); err != nil {
+		t.Errorf("RCPT failed: %v", err)
+	}
+	if got := cmds(); got != client {
+		t.Errorf("Got:\n%s\nExpected:\n%s", got, client)
+	}
+}
+
+func TestInvalidDSNOptions(t *testing.T) {
+	const server = `250-mx.example.com at your service
+250 DSN
+`
+	c, _ := newFakeClient(server)
+	if err := c.MailWithOptions("a@example.com", &MailOptions{Return: "ALL"}); err == nil {
+		t.Error("MAIL with RET=ALL succeeded")
+	}
+	if err := c.RcptWithOptions("a@example.com", &RcptOptions{Notify: []string{"NEVER", "DELAY"}}); err == nil {
+		t.Error("RCPT with NOTIFY=NEVER,DELAY succeeded")
+	}
+	if err := c.RcptWithOptions("a@example.com", &RcptOptions{Notify: []string{"SOMETIMES"}}); err == nil {
+		t.Error("RCPT with NOTIFY=SOMETIMES succeeded")
+	}
+}
+
+func TestDataChunked(t *testing.T) {
+	const server = `250-mx.example.com at your service
+250 CHUNKING
+250 Chunk OK
+250 Message OK
+`
+	c, cmds := newFakeClient(server)
+	w, err := c.DataChunked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	big := strings.Repeat("x", chunkSize+2)
+	for _, s := range []string{"Subject: hi\r", "\n\r\n", ".", big} {
+		if _, err := io.WriteString(w, s); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	msg := "Subject: hi\r\n\r\n." + big
+	want := fmt.Sprintf("EHLO localhost\r\nBDAT %d\r\n%sBDAT %d LAST\r\n%s",
+		chunkSize, msg[:chunkSize], len(msg)-chunkSize, msg[chunkSize:])
+	want = strings.ReplaceAll(want, "\r\n", "\n")
+	if got := cmds(); got != want {
+		t.Errorf("Got %d bytes of commands:\n%.200s\nExpected %d bytes:\n%.200s", len(got), got, len(want), want)
+	}
+}
+
+func TestSendMailContext(t *testing.T) {
+	l := newLocalListener(t)
+	defer l.Close()
+
+	// The server greets, then never answers.
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "220 hello\r\n")
+		io.Copy(io.Discard, conn)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := SendMailContext(ctx, l.Addr().String(), nil, "a@example.com", []string{"b@example.com"}, []byte("Subject: x\r\n\r\nhi\r\n"))
+	if err != context.DeadlineExceeded {
+		t.Errorf("SendMailContext error = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestSendMailPipeliningChunking(t *testing.T) {
+	testSendMailChunking(t, "Subject: x\r\n\r\n.hi\r\n", "Subject: x\r\n\r\n.hi\r\n")
+}
+
+func TestSendMailIgnoresPipeliningChunking(t *testing.T) {
+	l := newLocalListener(t)
+	defer l.Close()
+
+	errc := make(chan error, 1)
+	var got strings.Builder
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			errc <- err
+			return
+		}
+		defer conn.Close()
+		tc := textproto.NewConn(conn)
+		tc.PrintfLine("220 hello")
+		// Each command is answered before the next one is expected.
+		exchange := func(line, resp string) {
+			if err == nil {
+				var s string
+				s, err = tc.ReadLine()
+				got.WriteString(s + "\n")
+				if err == nil && s != line {
+					err = fmt.Errorf("got %q; want %q", s, line)
+				}
+			}
+			tc.PrintfLine("%s", resp)
+		}
+		exchange("EHLO localhost", "250-hello\r\n250-PIPELINING\r\n250 CHUNKING")
+		exchange("MAIL FROM:<a@example.com>", "250 Sender OK")
+		exchange("RCPT TO:<b@example.com>", "250 Receiver OK")
+		exchange("DATA", "354 Go ahead")
+		if err == nil {
+			var b []byte
+			if b, err = tc.ReadDotBytes(); err == nil && string(b) != "Subject: x\n\n.hi\n" {
+				err = fmt.Errorf("got message %q", b)
+			}
+		}
+		tc.PrintfLine("250 Message OK")
+		exchange("QUIT", "221 Bye")
+		errc <- err
+	}()
+
+	if err := SendMail(l.Addr().String(), nil, "a@example.com", []string{"b@example.com"}, []byte("Subject: x\r\n\r\n.hi\r\n")); err != nil {
+		t.Errorf("SendMail failed: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("server: %v; received:\n%s", err, got.String())
+	}
+}
+
+func TestSendMailChunkingBareLF(t *testing.T) {
+	testSendMailChunking(t, "Subject: x\n\n.hi\n", "Subject: x\r\n\r\n.hi\r\n")
+}
+
+// testSendMailChunking sends msg to a server supporting PIPELINING and
+// CHUNKING and checks that it receives want.
+func testSendMailChunking(t *testing.T, msg, want string) {
+	l := newLocalListener(t)
+	defer l.Close()
+
+	errc := make(chan error, 1)
+	var got strings.Builder
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			errc <- err
+			return
+		}
+		defer conn.Close()
+		tc := textproto.NewConn(conn)
+		tc.PrintfLine("220 hello")
+		expect := func(line string) {
+			if err == nil {
+				var s string
+				s, err = tc.ReadLine()
+				got.WriteString(s + "\n")
+				if err == nil && s != line {
+					err = fmt.Errorf("got %q; want %q", s, line)
+				}
+			}
+		}
+		expect("EHLO localhost")
+		tc.PrintfLine("250-hello\r\n250-PIPELINING\r\n250 CHUNKING")
+		// The envelope arrives before any response to it.
+		expect("MAIL FROM:<a@example.com>")
+		expect("RCPT TO:<b@example.com>")
+		expect("RCPT TO:<c@example.com>")
+		tc.PrintfLine("250 Sender OK\r\n250 Receiver OK\r\n250 Receiver OK")
+		expect(fmt.Sprintf("BDAT %d LAST", len(want)))
+		if err == nil {
+			b := make([]byte, len(want))
+			if _, err = io.ReadFull(tc.R, b); err == nil && string(b) != want {
+				err = fmt.Errorf("got message %q; want %q", b, want)
+			}
+		}
+		tc.PrintfLine("250 Message OK")
+		expect("QUIT")
+		tc.PrintfLine("221 Bye")
+		errc <- err
+	}()
+
+	if err := SendMailContext(context.Background(), l.Addr().String(), nil, "a@example.com", []string{"b@example.com", "c@example.com"}, []byte(msg)); err != nil {
+		t.Errorf("SendMailContext failed: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("server: %v; received:\n%s", err, got.String())
+	}
+}
+
 func TestNewClient(t *testing.T) {
 	server := strings.Join(strings.Split(newClientServer, "\n"), "\r\n")
 	client := strings.Join(strings.Split(newClientClient, "\n"), "\r\n")
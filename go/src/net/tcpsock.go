@@ -8,6 +8,7 @@ import (
 	"context"
 	"internal/itoa"
 	"io"
+	"net/netip"
 	"os"
 	"syscall"
 	"time"
@@ -29,6 +30,31 @@ type TCPAddr struct {
 // 网络返回地址的网络名称 "tcp"
 func (a *TCPAddr) Network() string { return "tcp" }
 
+// AddrPort returns the TCPAddr a as a netip.AddrPort.
+//
+// If a.Port does not fit in a uint16, it's silently truncated.
+//
+// If a is nil, a zero value is returned.
+func (a *TCPAddr) AddrPort() netip.AddrPort {
+	if a == nil {
+		return netip.AddrPort{}
+	}
+	na, _ := netip.AddrFromSlice(a.IP)
+	na = na.WithZone(a.Zone)
+	return netip.AddrPortFrom(na, uint16(a.Port))
+}
+
+// TCPAddrFromAddrPort returns addr as a TCPAddr. If addr.IsValid() is false,
+// then the returned TCPAddr will contain a nil IP field, indicating an
+// address family-agnostic unspecified address.
+func TCPAddrFromAddrPort(addr netip.AddrPort) *TCPAddr {
+	return &TCPAddr{
+		IP:   addr.Addr().AsSlice(),
+		Zone: addr.Addr().Zone(),
+		Port: int(addr.Port()),
+	}
+}
+
 func (a *TCPAddr) String() string {
 	if a == nil {
 		return "<nil>"
@@ -183,6 +209,77 @@ func (c *TCPConn) SetKeepAlivePeriod(d time.Duration) error {
 	return nil
 }
 
+// KeepAliveConfig contains TCP keep-alive options.
+//
+// If the Idle, Interval, or Count fields are zero, a default value
+// is chosen. If a field is negative, the corresponding socket-level
+// option is left unchanged.
+//
+// Not every operating system supports every option. On Windows,
+// Idle and Interval are always set together, and a negative value
+// for either is treated as zero; versions before Windows 10, version
+// 1709, keep their own probe count when Count is zero and do not
+// support setting another. Solaris supports Interval and Count
+// only as a pair, as a total time before the connection is dropped.
+// Plan 9 ignores Interval and Count, and OpenBSD supports none of
+// the three options.
+type KeepAliveConfig struct {
+	// If Enable is true, keep-alive probes are enabled.
+	// If false, SetKeepAliveConfig disables them and ignores the
+	// other fields, while Dialer and ListenConfig ignore the whole
+	// KeepAliveConfig and apply their KeepAlive field instead.
+	Enable bool
+
+	// Idle is the time that the connection must be idle before
+	// the first keep-alive probe is sent.
+	// If zero, a default value of 15 seconds is used.
+	Idle time.Duration
+
+	// Interval is the time between keep-alive probes.
+	// If zero, a default value of 15 seconds is used.
+	Interval time.Duration
+
+	// Count is the maximum number of keep-alive probes that
+	// can go unanswered before dropping a connection.
+	// If zero, a default value of 9 is used.
+	Count int
+}
+
+// withDefaults returns cfg with zero fields replaced by their
+// default values.
+func (cfg KeepAliveConfig) withDefaults() KeepAliveConfig {
+	if cfg.Idle == 0 {
+		cfg.Idle = defaultTCPKeepAlive
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = defaultTCPKeepAliveInterval
+	}
+	if cfg.Count == 0 {
+		cfg.Count = defaultTCPKeepAliveCount
+	}
+	return cfg
+}
+
+// SetKeepAliveConfig configures keep-alive messages sent by the
+// operating system. It enables or disables keep-alives and, when
+// enabled, sets the idle time, probe interval and probe count
+// described by config.
+func (c *TCPConn) SetKeepAliveConfig(config KeepAliveConfig) error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	if err := setKeepAlive(c.fd, config.Enable); err != nil {
+		return &OpError{Op: "set", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: err}
+	}
+	if !config.Enable {
+		return nil
+	}
+	if err := setKeepAliveConfig(c.fd, config.withDefaults()); err != nil {
+		return &OpError{Op: "set", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: err}
+	}
+	return nil
+}
+
 // SetNoDelay controls whether the operating system should delay
 // packet transmission in hopes of sending fewer packets (Nagle's
 // algorithm).  The default is true (no delay), meaning that data is
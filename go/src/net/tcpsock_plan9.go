@@ -50,7 +50,10 @@ func (ln *TCPListener) accept() (*TCPConn, error) {
 		return nil, err
 	}
 	tc := newTCPConn(fd)
-	if ln.lc.KeepAlive >= 0 {
+	if ln.lc.KeepAliveConfig.Enable {
+		setKeepAlive(fd, true)
+		setKeepAliveConfig(fd, ln.lc.KeepAliveConfig.withDefaults())
+	} else if ln.lc.KeepAlive >= 0 {
 		setKeepAlive(fd, true)
 		ka := ln.lc.KeepAlive
 		if ln.lc.KeepAlive == 0 {
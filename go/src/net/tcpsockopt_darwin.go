@@ -10,8 +10,12 @@ import (
 	"time"
 )
 
-// syscall.TCP_KEEPINTVL is missing on some darwin architectures.
-const sysTCP_KEEPINTVL = 0x101
+// syscall.TCP_KEEPINTVL and syscall.TCP_KEEPCNT are missing on
+// some darwin architectures.
+const (
+	sysTCP_KEEPINTVL = 0x101
+	sysTCP_KEEPCNT   = 0x102
+)
 
 func setKeepAlivePeriod(fd *netFD, d time.Duration) error {
 	// The kernel expects seconds so round to next highest second.
@@ -23,3 +27,26 @@ func setKeepAlivePeriod(fd *netFD, d time.Duration) error {
 	runtime.KeepAlive(fd)
 	return wrapSyscallError("setsockopt", err)
 }
+
+func setKeepAliveConfig(fd *netFD, cfg KeepAliveConfig) error {
+	// The kernel expects seconds so round to next highest second.
+	if cfg.Idle >= 0 {
+		secs := int(roundDurationUp(cfg.Idle, time.Second))
+		if err := fd.pfd.SetsockoptInt(syscall.IPPROTO_TCP, syscall.TCP_KEEPALIVE, secs); err != nil {
+			return wrapSyscallError("setsockopt", err)
+		}
+	}
+	if cfg.Interval >= 0 {
+		secs := int(roundDurationUp(cfg.Interval, time.Second))
+		if err := fd.pfd.SetsockoptInt(syscall.IPPROTO_TCP, sysTCP_KEEPINTVL, secs); err != nil {
+			return wrapSyscallError("setsockopt", err)
+		}
+	}
+	if cfg.Count >= 0 {
+		if err := fd.pfd.SetsockoptInt(syscall.IPPROTO_TCP, sysTCP_KEEPCNT, cfg.Count); err != nil {
+			return wrapSyscallError("setsockopt", err)
+		}
+	}
+	runtime.KeepAlive(fd)
+	return nil
+}
@@ -21,3 +21,27 @@ func setKeepAlivePeriod(fd *netFD, d time.Duration) error {
 	runtime.KeepAlive(fd)
 	return wrapSyscallError("setsockopt", err)
 }
+
+func setKeepAliveConfig(fd *netFD, cfg KeepAliveConfig) error {
+	// The kernel expects milliseconds so round to next highest
+	// millisecond.
+	if cfg.Idle >= 0 {
+		msecs := int(roundDurationUp(cfg.Idle, time.Millisecond))
+		if err := fd.pfd.SetsockoptInt(syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE, msecs); err != nil {
+			return wrapSyscallError("setsockopt", err)
+		}
+	}
+	if cfg.Interval >= 0 {
+		msecs := int(roundDurationUp(cfg.Interval, time.Millisecond))
+		if err := fd.pfd.SetsockoptInt(syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL, msecs); err != nil {
+			return wrapSyscallError("setsockopt", err)
+		}
+	}
+	if cfg.Count >= 0 {
+		if err := fd.pfd.SetsockoptInt(syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT, cfg.Count); err != nil {
+			return wrapSyscallError("setsockopt", err)
+		}
+	}
+	runtime.KeepAlive(fd)
+	return nil
+}
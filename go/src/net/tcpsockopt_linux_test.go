@@ -0,0 +1,123 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func getKeepAliveConfig(t *testing.T, c *TCPConn) (cfg KeepAliveConfig) {
+	t.Helper()
+	rc, err := c.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var on, idle, intvl, cnt int
+	var serr error
+	err = rc.Control(func(fd uintptr) {
+		get := func(level, opt int) int {
+			v, err := syscall.GetsockoptInt(int(fd), level, opt)
+			if err != nil && serr == nil {
+				serr = err
+			}
+			return v
+		}
+		on = get(syscall.SOL_SOCKET, syscall.SO_KEEPALIVE)
+		idle = get(syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE)
+		intvl = get(syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL)
+		cnt = get(syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT)
+	})
+	if err == nil {
+		err = serr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return KeepAliveConfig{
+		Enable:   on != 0,
+		Idle:     time.Duration(idle) * time.Second,
+		Interval: time.Duration(intvl) * time.Second,
+		Count:    cnt,
+	}
+}
+
+func TestKeepAliveConfig(t *testing.T) {
+	want := KeepAliveConfig{
+		Enable:   true,
+		Idle:     300 * time.Second,
+		Interval: 15 * time.Second,
+		Count:    4,
+	}
+	ln, err := (&ListenConfig{KeepAliveConfig: want}).Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan *TCPConn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			accepted <- nil
+			return
+		}
+		accepted <- c.(*TCPConn)
+	}()
+
+	// An enabled KeepAliveConfig takes precedence over a negative
+	// KeepAlive.
+	d := Dialer{KeepAlive: -1, KeepAliveConfig: want}
+	c, err := d.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if got := getKeepAliveConfig(t, c.(*TCPConn)); got != want {
+		t.Errorf("dialed connection: got %+v; want %+v", got, want)
+	}
+
+	ac := <-accepted
+	if ac == nil {
+		t.Fatal("Accept failed")
+	}
+	defer ac.Close()
+	if got := getKeepAliveConfig(t, ac); got != want {
+		t.Errorf("accepted connection: got %+v; want %+v", got, want)
+	}
+
+	// Zero fields take default values; negative ones are left alone.
+	if err := ac.SetKeepAliveConfig(KeepAliveConfig{Enable: true, Idle: -1}); err != nil {
+		t.Fatal(err)
+	}
+	want = KeepAliveConfig{
+		Enable:   true,
+		Idle:     300 * time.Second,
+		Interval: defaultTCPKeepAliveInterval,
+		Count:    defaultTCPKeepAliveCount,
+	}
+	if got := getKeepAliveConfig(t, ac); got != want {
+		t.Errorf("after SetKeepAliveConfig: got %+v; want %+v", got, want)
+	}
+
+	if err := ac.SetKeepAliveConfig(KeepAliveConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := getKeepAliveConfig(t, ac); got.Enable {
+		t.Errorf("keep-alives still enabled after SetKeepAliveConfig(KeepAliveConfig{})")
+	}
+
+	// A disabled KeepAliveConfig leaves KeepAlive in charge.
+	d = Dialer{KeepAlive: time.Hour, KeepAliveConfig: KeepAliveConfig{Idle: time.Minute}}
+	c2, err := d.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	if got := getKeepAliveConfig(t, c2.(*TCPConn)); !got.Enable || got.Idle != time.Hour {
+		t.Errorf("dialed with KeepAlive only: got %+v; want keep-alives enabled with Idle %v", got, time.Hour)
+	}
+}
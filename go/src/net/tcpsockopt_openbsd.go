@@ -14,3 +14,11 @@ func setKeepAlivePeriod(fd *netFD, d time.Duration) error {
 	// options.
 	return syscall.ENOPROTOOPT
 }
+
+func setKeepAliveConfig(fd *netFD, cfg KeepAliveConfig) error {
+	if cfg.Idle < 0 && cfg.Interval < 0 && cfg.Count < 0 {
+		return nil
+	}
+	// See setKeepAlivePeriod.
+	return syscall.ENOPROTOOPT
+}
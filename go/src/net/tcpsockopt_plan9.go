@@ -22,3 +22,12 @@ func setKeepAlivePeriod(fd *netFD, d time.Duration) error {
 	_, e := fd.ctl.WriteAt([]byte(cmd), 0)
 	return e
 }
+
+// Set keep alive idle time. Plan 9 has no settings for the probe
+// interval and count, so those are ignored.
+func setKeepAliveConfig(fd *netFD, cfg KeepAliveConfig) error {
+	if cfg.Idle < 0 {
+		return nil
+	}
+	return setKeepAlivePeriod(fd, cfg.Idle)
+}
@@ -30,3 +30,27 @@ func setKeepAlivePeriod(fd *netFD, d time.Duration) error {
 	runtime.KeepAlive(fd)
 	return wrapSyscallError("setsockopt", err)
 }
+
+func setKeepAliveConfig(fd *netFD, cfg KeepAliveConfig) error {
+	// The kernel expects milliseconds so round to next highest
+	// millisecond.
+	if cfg.Idle >= 0 {
+		msecs := int(roundDurationUp(cfg.Idle, time.Millisecond))
+		if err := fd.pfd.SetsockoptInt(syscall.IPPROTO_TCP, syscall.TCP_KEEPALIVE_THRESHOLD, msecs); err != nil {
+			return wrapSyscallError("setsockopt", err)
+		}
+	}
+	// Without TCP_KEEPINTVL and TCP_KEEPCNT (see setKeepAlivePeriod),
+	// the closest we can get is to drop the connection once the
+	// probes would have gone unanswered for Interval*Count.
+	if cfg.Interval >= 0 && cfg.Count >= 0 {
+		msecs := int(roundDurationUp(cfg.Interval, time.Millisecond)) * cfg.Count
+		if err := fd.pfd.SetsockoptInt(syscall.IPPROTO_TCP, syscall.TCP_KEEPALIVE_ABORT_THRESHOLD, msecs); err != nil {
+			return wrapSyscallError("setsockopt", err)
+		}
+	} else if cfg.Interval >= 0 || cfg.Count >= 0 {
+		return syscall.ENOPROTOOPT
+	}
+	runtime.KeepAlive(fd)
+	return nil
+}
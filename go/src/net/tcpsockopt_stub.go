@@ -19,3 +19,7 @@ func setNoDelay(fd *netFD, noDelay bool) error {
 func setKeepAlivePeriod(fd *netFD, d time.Duration) error {
 	return syscall.ENOPROTOOPT
 }
+
+func setKeepAliveConfig(fd *netFD, cfg KeepAliveConfig) error {
+	return syscall.ENOPROTOOPT
+}
@@ -23,3 +23,26 @@ func setKeepAlivePeriod(fd *netFD, d time.Duration) error {
 	runtime.KeepAlive(fd)
 	return wrapSyscallError("setsockopt", err)
 }
+
+func setKeepAliveConfig(fd *netFD, cfg KeepAliveConfig) error {
+	// The kernel expects seconds so round to next highest second.
+	if cfg.Idle >= 0 {
+		secs := int(roundDurationUp(cfg.Idle, time.Second))
+		if err := fd.pfd.SetsockoptInt(syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE, secs); err != nil {
+			return wrapSyscallError("setsockopt", err)
+		}
+	}
+	if cfg.Interval >= 0 {
+		secs := int(roundDurationUp(cfg.Interval, time.Second))
+		if err := fd.pfd.SetsockoptInt(syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL, secs); err != nil {
+			return wrapSyscallError("setsockopt", err)
+		}
+	}
+	if cfg.Count >= 0 {
+		if err := fd.pfd.SetsockoptInt(syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT, cfg.Count); err != nil {
+			return wrapSyscallError("setsockopt", err)
+		}
+	}
+	runtime.KeepAlive(fd)
+	return nil
+}
@@ -5,6 +5,7 @@
 package net
 
 import (
+	"internal/syscall/windows"
 	"os"
 	"runtime"
 	"syscall"
@@ -28,3 +29,41 @@ func setKeepAlivePeriod(fd *netFD, d time.Duration) error {
 	runtime.KeepAlive(fd)
 	return os.NewSyscallError("wsaioctl", err)
 }
+
+// sysTCP_KEEPCNT is the TCP_KEEPCNT socket option, available since
+// Windows 10, version 1709.
+const sysTCP_KEEPCNT = 16
+
+func setKeepAliveConfig(fd *netFD, cfg KeepAliveConfig) error {
+	// SIO_KEEPALIVE_VALS sets the idle time and the interval
+	// together, so neither can be left unchanged.
+	if cfg.Idle < 0 {
+		cfg.Idle = defaultTCPKeepAlive
+	}
+	if cfg.Interval < 0 {
+		cfg.Interval = defaultTCPKeepAliveInterval
+	}
+	ka := syscall.TCPKeepalive{
+		OnOff:    1,
+		Time:     uint32(roundDurationUp(cfg.Idle, time.Millisecond)),
+		Interval: uint32(roundDurationUp(cfg.Interval, time.Millisecond)),
+	}
+	ret := uint32(0)
+	size := uint32(unsafe.Sizeof(ka))
+	if err := fd.pfd.WSAIoctl(syscall.SIO_KEEPALIVE_VALS, (*byte)(unsafe.Pointer(&ka)), size, nil, 0, &ret, nil, 0); err != nil {
+		return os.NewSyscallError("wsaioctl", err)
+	}
+	if cfg.Count >= 0 {
+		err := fd.pfd.SetsockoptInt(syscall.IPPROTO_TCP, sysTCP_KEEPCNT, cfg.Count)
+		// Older versions of Windows lack TCP_KEEPCNT; they keep their
+		// own probe count, which stands in for the default one.
+		if err == windows.WSAENOPROTOOPT && cfg.Count == defaultTCPKeepAliveCount {
+			err = nil
+		}
+		if err != nil {
+			return wrapSyscallError("setsockopt", err)
+		}
+	}
+	runtime.KeepAlive(fd)
+	return nil
+}
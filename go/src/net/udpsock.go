@@ -7,11 +7,12 @@ package net
 import (
 	"context"
 	"internal/itoa"
+	"net/netip"
 	"syscall"
 )
 
-// BUG(mikio): On Plan 9, the ReadMsgUDP and
-// WriteMsgUDP methods of UDPConn are not implemented.
+// BUG(mikio): On Plan 9, the ReadMsgUDP, WriteMsgUDP, ReadBatch and
+// WriteBatch methods of UDPConn are not implemented.
 // BUG(mikio)：在Plan 9上，UDPConn的ReadMsgUDP和WriteMsgUDP方法没有实现。
 
 // BUG(mikio): On Windows, the File method of UDPConn is not
@@ -34,6 +35,31 @@ type UDPAddr struct {
 // Network 返回地址的网络名称 "udp"。
 func (a *UDPAddr) Network() string { return "udp" }
 
+// AddrPort returns the UDPAddr a as a netip.AddrPort.
+//
+// If a.Port does not fit in a uint16, it's silently truncated.
+//
+// If a is nil, a zero value is returned.
+func (a *UDPAddr) AddrPort() netip.AddrPort {
+	if a == nil {
+		return netip.AddrPort{}
+	}
+	na, _ := netip.AddrFromSlice(a.IP)
+	na = na.WithZone(a.Zone)
+	return netip.AddrPortFrom(na, uint16(a.Port))
+}
+
+// UDPAddrFromAddrPort returns addr as a UDPAddr. If addr.IsValid() is false,
+// then the returned UDPAddr will contain a nil IP field, indicating an
+// address family-agnostic unspecified address.
+func UDPAddrFromAddrPort(addr netip.AddrPort) *UDPAddr {
+	return &UDPAddr{
+		IP:   addr.Addr().AsSlice(),
+		Zone: addr.Addr().Zone(),
+		Port: int(addr.Port()),
+	}
+}
+
 // 返回网络地址字符串形式
 func (a *UDPAddr) String() string {
 	if a == nil {
@@ -132,6 +158,23 @@ func (c *UDPConn) readFromUDP(b []byte, addr *UDPAddr) (int, *UDPAddr, error) {
 	return n, addr, err
 }
 
+// ReadFromUDPAddrPort acts like ReadFrom but returns a netip.AddrPort.
+// Unlike ReadFromUDP it does not allocate.
+//
+// If c is bound to an unspecified address, the returned
+// netip.AddrPort's address might be an IPv4-mapped IPv6 address.
+// Use netip.Addr.Unmap to get the address without the IPv6 prefix.
+func (c *UDPConn) ReadFromUDPAddrPort(b []byte) (n int, addr netip.AddrPort, err error) {
+	if !c.ok() {
+		return 0, netip.AddrPort{}, syscall.EINVAL
+	}
+	n, addr, err = c.readFromAddrPort(b)
+	if err != nil {
+		err = &OpError{Op: "read", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: err}
+	}
+	return n, addr, err
+}
+
 // ReadFrom implements the PacketConn ReadFrom method.
 func (c *UDPConn) ReadFrom(b []byte) (int, Addr, error) {
 	n, addr, err := c.readFromUDP(b, &UDPAddr{})
@@ -163,6 +206,14 @@ func (c *UDPConn) ReadMsgUDP(b, oob []byte) (n, oobn, flags int, addr *UDPAddr,
 	return
 }
 
+// ReadMsgUDPAddrPort is like ReadMsgUDP but returns a netip.AddrPort
+// instead of a UDPAddr.
+func (c *UDPConn) ReadMsgUDPAddrPort(b, oob []byte) (n, oobn, flags int, addr netip.AddrPort, err error) {
+	var ua *UDPAddr
+	n, oobn, flags, ua, err = c.ReadMsgUDP(b, oob)
+	return n, oobn, flags, ua.AddrPort(), err
+}
+
 // WriteToUDP acts like WriteTo but takes a UDPAddr.
 func (c *UDPConn) WriteToUDP(b []byte, addr *UDPAddr) (int, error) {
 	if !c.ok() {
@@ -175,6 +226,19 @@ func (c *UDPConn) WriteToUDP(b []byte, addr *UDPAddr) (int, error) {
 	return n, err
 }
 
+// WriteToUDPAddrPort acts like WriteTo but takes a netip.AddrPort.
+// Unlike WriteToUDP it does not allocate.
+func (c *UDPConn) WriteToUDPAddrPort(b []byte, addr netip.AddrPort) (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	n, err := c.writeToAddrPort(b, addr)
+	if err != nil {
+		err = &OpError{Op: "write", Net: c.fd.net, Source: c.fd.laddr, Addr: UDPAddrFromAddrPort(addr), Err: err}
+	}
+	return n, err
+}
+
 // WriteTo implements the PacketConn WriteTo method.
 func (c *UDPConn) WriteTo(b []byte, addr Addr) (int, error) {
 	if !c.ok() {
@@ -210,6 +274,145 @@ func (c *UDPConn) WriteMsgUDP(b, oob []byte, addr *UDPAddr) (n, oobn int, err er
 	return
 }
 
+// WriteMsgUDPAddrPort is like WriteMsgUDP but takes a netip.AddrPort
+// instead of a UDPAddr. An invalid addr is treated like a nil UDPAddr.
+func (c *UDPConn) WriteMsgUDPAddrPort(b, oob []byte, addr netip.AddrPort) (n, oobn int, err error) {
+	var ua *UDPAddr
+	if addr.IsValid() {
+		ua = UDPAddrFromAddrPort(addr)
+	}
+	return c.WriteMsgUDP(b, oob, ua)
+}
+
+// A UDPMessage is a single datagram transferred by the ReadBatch and
+// WriteBatch methods of UDPConn.
+type UDPMessage struct {
+	// Buf holds the payload: the data to send for WriteBatch, or
+	// the buffer to receive into for ReadBatch.
+	Buf []byte
+
+	// OOB holds the associated out-of-band data, as for
+	// ReadMsgUDP and WriteMsgUDP.
+	OOB []byte
+
+	// Addr is the destination address for WriteBatch, and must be
+	// nil if the connection is connected. ReadBatch sets it to the
+	// source address of the datagram.
+	Addr *UDPAddr
+
+	// SegmentSize, if positive, is the size of the segments the
+	// payload is made of. For WriteBatch, Buf is sent as a series
+	// of datagrams of SegmentSize bytes each (the last one may be
+	// shorter), offloaded to the kernel (UDP_SEGMENT) on Linux.
+	// For ReadBatch, it is set when the kernel coalesced several
+	// datagrams into Buf (see SetGRO); OOB must then have room for
+	// the control message carrying it.
+	SegmentSize int
+
+	// N and NOOB are the number of bytes of Buf and OOB read or
+	// written, and Flags the flags set on a received message.
+	N, NOOB, Flags int
+}
+
+// ReadBatch reads up to len(ms) datagrams from c into ms. It blocks
+// until at least one datagram is available and returns the number of
+// messages filled in, together with any error that stopped it from
+// reading more. On Linux, all datagrams already queued are read with a
+// single recvmmsg system call; other systems read them one by one.
+func (c *UDPConn) ReadBatch(ms []UDPMessage) (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	n, err := c.readBatch(ms)
+	if err != nil {
+		err = &OpError{Op: "read", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: err}
+	}
+	return n, err
+}
+
+// WriteBatch writes the datagrams described by ms to c and returns
+// the number of messages sent. On Linux, messages are sent with as
+// few sendmmsg system calls as possible; other systems send them one
+// by one.
+func (c *UDPConn) WriteBatch(ms []UDPMessage) (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	n, err := c.writeBatch(ms)
+	if err != nil {
+		err = &OpError{Op: "write", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: err}
+	}
+	return n, err
+}
+
+// SetGRO sets whether the operating system may coalesce received
+// datagrams of the same flow into a single large message (generic
+// receive offload). The size of the original datagrams is reported
+// in UDPMessage.SegmentSize by ReadBatch. GRO is only supported on
+// Linux.
+func (c *UDPConn) SetGRO(enable bool) error {
+	if !c.ok() {
+		return syscall.EINVAL
+	}
+	if err := setGRO(c.fd, enable); err != nil {
+		return &OpError{Op: "set", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: err}
+	}
+	return nil
+}
+
+// readBatchLoop implements ReadBatch by reading one message at a
+// time. Only the first read blocks; the following ones read the
+// datagrams already queued, until ms is full or none is left.
+func (c *UDPConn) readBatchLoop(ms []UDPMessage) (int, error) {
+	if len(ms) == 0 {
+		return 0, nil
+	}
+	m := &ms[0]
+	var err error
+	m.N, m.NOOB, m.Flags, m.Addr, err = c.readMsg(m.Buf, m.OOB)
+	m.SegmentSize = 0
+	if err != nil {
+		return 0, err
+	}
+	for i := 1; i < len(ms); i++ {
+		ok, err := c.readQueuedMsg(&ms[i])
+		if err != nil {
+			return i, err
+		}
+		if !ok {
+			return i, nil
+		}
+	}
+	return len(ms), nil
+}
+
+// writeBatchLoop implements WriteBatch by writing one message, or
+// one segment of a message, at a time.
+func (c *UDPConn) writeBatchLoop(ms []UDPMessage) (int, error) {
+	for i := range ms {
+		m := &ms[i]
+		b := m.Buf
+		m.N, m.NOOB = 0, 0
+		for {
+			seg := b
+			if m.SegmentSize > 0 && len(seg) > m.SegmentSize {
+				seg = seg[:m.SegmentSize]
+			}
+			n, oobn, err := c.writeMsg(seg, m.OOB, m.Addr)
+			m.N += n
+			m.NOOB = oobn
+			if err != nil {
+				return i, err
+			}
+			b = b[len(seg):]
+			if len(b) == 0 {
+				break
+			}
+		}
+	}
+	return len(ms), nil
+}
+
 func newUDPConn(fd *netFD) *UDPConn { return &UDPConn{conn{fd}} }
 
 // DialUDP acts like Dial for UDP networks.
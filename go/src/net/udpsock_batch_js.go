@@ -0,0 +1,14 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build js && wasm
+// +build js,wasm
+
+package net
+
+// readQueuedMsg reports that no datagram is queued: the fake network
+// of js/wasm offers no way to read without waiting.
+func (c *UDPConn) readQueuedMsg(m *UDPMessage) (bool, error) {
+	return false, nil
+}
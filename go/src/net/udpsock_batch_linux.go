@@ -0,0 +1,265 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"internal/syscall/unix"
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// UDP-level socket options and control messages, missing from
+// package syscall.
+const (
+	sysSOL_UDP     = 0x11
+	sysUDP_SEGMENT = 0x67
+	sysUDP_GRO     = 0x68
+)
+
+// mmsgBuffers holds the headers, I/O vectors and addresses passed to
+// recvmmsg and sendmmsg for a batch of messages.
+type mmsgBuffers struct {
+	hs   []unix.Mmsghdr
+	iovs []syscall.Iovec
+	rsas []syscall.RawSockaddrAny
+}
+
+var mmsgPool = sync.Pool{New: func() interface{} { return new(mmsgBuffers) }}
+
+// getMmsgBuffers returns zeroed buffers for n messages.
+func getMmsgBuffers(n int) *mmsgBuffers {
+	b := mmsgPool.Get().(*mmsgBuffers)
+	if cap(b.hs) < n {
+		b.hs = make([]unix.Mmsghdr, n)
+		b.iovs = make([]syscall.Iovec, n)
+		b.rsas = make([]syscall.RawSockaddrAny, n)
+	}
+	b.hs, b.iovs, b.rsas = b.hs[:n], b.iovs[:n], b.rsas[:n]
+	return b
+}
+
+// putMmsgBuffers zeroes b, which drops its references to the caller's
+// buffers, and returns it to the pool.
+func putMmsgBuffers(b *mmsgBuffers) {
+	for i := range b.hs {
+		b.hs[i] = unix.Mmsghdr{}
+		b.iovs[i] = syscall.Iovec{}
+		b.rsas[i] = syscall.RawSockaddrAny{}
+	}
+	mmsgPool.Put(b)
+}
+
+func (c *UDPConn) readBatch(ms []UDPMessage) (int, error) {
+	if len(ms) == 0 {
+		return 0, nil
+	}
+	b := getMmsgBuffers(len(ms))
+	defer putMmsgBuffers(b)
+	hs, iovs, rsas := b.hs, b.iovs, b.rsas
+	for i := range ms {
+		m := &ms[i]
+		h := &hs[i].Hdr
+		h.Name = (*byte)(unsafe.Pointer(&rsas[i]))
+		h.Namelen = uint32(syscall.SizeofSockaddrAny)
+		if len(m.Buf) > 0 {
+			iovs[i].Base = &m.Buf[0]
+			iovs[i].SetLen(len(m.Buf))
+		}
+		h.Iov = &iovs[i]
+		h.Iovlen = 1
+		if len(m.OOB) > 0 {
+			h.Control = &m.OOB[0]
+			h.SetControllen(len(m.OOB))
+		}
+	}
+	n, err := c.fd.pfd.RecvMmsg(hs, 0)
+	runtime.KeepAlive(c.fd)
+	if err == syscall.ENOSYS {
+		return c.readBatchLoop(ms)
+	}
+	if err != nil {
+		return 0, os.NewSyscallError("recvmmsg", err)
+	}
+	for i := 0; i < n; i++ {
+		m := &ms[i]
+		h := &hs[i].Hdr
+		m.N = int(hs[i].Len)
+		m.NOOB = int(h.Controllen)
+		m.Flags = int(h.Flags)
+		m.Addr = rawSockaddrToUDPAddr(&rsas[i])
+		m.SegmentSize = groSegmentSize(m.OOB[:m.NOOB])
+	}
+	return n, nil
+}
+
+func (c *UDPConn) writeBatch(ms []UDPMessage) (int, error) {
+	if len(ms) == 0 {
+		return 0, nil
+	}
+	b := getMmsgBuffers(len(ms))
+	defer putMmsgBuffers(b)
+	hs, iovs, rsas := b.hs, b.iovs, b.rsas
+	// Messages with a segment size get a copy of their OOB data
+	// followed by a UDP_SEGMENT control message.
+	var oobs []byte
+	if size := segmentOOBSize(ms); size > 0 {
+		oobs = make([]byte, 0, size)
+	}
+	for i := range ms {
+		m := &ms[i]
+		h := &hs[i].Hdr
+		if c.fd.isConnected && m.Addr != nil {
+			return 0, ErrWriteToConnected
+		}
+		if !c.fd.isConnected {
+			if m.Addr == nil {
+				return 0, errMissingAddress
+			}
+			salen, err := udpAddrToRawSockaddr(c.fd.family, m.Addr, &rsas[i])
+			if err != nil {
+				return 0, err
+			}
+			h.Name = (*byte)(unsafe.Pointer(&rsas[i]))
+			h.Namelen = uint32(salen)
+		}
+		if len(m.Buf) > 0 {
+			iovs[i].Base = &m.Buf[0]
+			iovs[i].SetLen(len(m.Buf))
+		}
+		h.Iov = &iovs[i]
+		h.Iovlen = 1
+		oob := m.OOB
+		if m.SegmentSize > 0 && len(m.Buf) > m.SegmentSize {
+			if m.SegmentSize > 0xffff {
+				return 0, syscall.EINVAL
+			}
+			start := len(oobs)
+			oobs = append(oobs, m.OOB...)
+			oobs = appendSegmentSize(oobs, uint16(m.SegmentSize))
+			oob = oobs[start:len(oobs):len(oobs)]
+		}
+		if len(oob) > 0 {
+			h.Control = &oob[0]
+			h.SetControllen(len(oob))
+		}
+	}
+	n, err := c.fd.pfd.SendMmsg(hs, 0)
+	runtime.KeepAlive(c.fd)
+	if err == syscall.ENOSYS && n == 0 {
+		return c.writeBatchLoop(ms)
+	}
+	for i := 0; i < n; i++ {
+		ms[i].N = int(hs[i].Len)
+		ms[i].NOOB = len(ms[i].OOB)
+	}
+	if err != nil {
+		return n, os.NewSyscallError("sendmmsg", err)
+	}
+	return n, nil
+}
+
+func setGRO(fd *netFD, enable bool) error {
+	err := fd.pfd.SetsockoptInt(sysSOL_UDP, sysUDP_GRO, boolint(enable))
+	runtime.KeepAlive(fd)
+	return wrapSyscallError("setsockopt", err)
+}
+
+// segmentOOBSize returns the space needed for the OOB data of the
+// messages in ms that carry a UDP_SEGMENT control message.
+func segmentOOBSize(ms []UDPMessage) int {
+	size := 0
+	for i := range ms {
+		if ms[i].SegmentSize > 0 && len(ms[i].Buf) > ms[i].SegmentSize {
+			size += len(ms[i].OOB) + syscall.CmsgSpace(2)
+		}
+	}
+	return size
+}
+
+// appendSegmentSize appends a UDP_SEGMENT control message carrying
+// size to oob.
+func appendSegmentSize(oob []byte, size uint16) []byte {
+	start := len(oob)
+	for i := 0; i < syscall.CmsgSpace(2); i++ {
+		oob = append(oob, 0)
+	}
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&oob[start]))
+	h.Level = sysSOL_UDP
+	h.Type = sysUDP_SEGMENT
+	h.SetLen(syscall.CmsgLen(2))
+	*(*uint16)(unsafe.Pointer(&oob[start+syscall.CmsgLen(0)])) = size
+	return oob
+}
+
+// groSegmentSize returns the segment size carried by a UDP_GRO
+// control message in oob, or 0 if there is none.
+func groSegmentSize(oob []byte) int {
+	for len(oob) >= syscall.SizeofCmsghdr {
+		h := (*syscall.Cmsghdr)(unsafe.Pointer(&oob[0]))
+		l := int(h.Len)
+		if l < syscall.SizeofCmsghdr || l > len(oob) {
+			return 0
+		}
+		if h.Level == sysSOL_UDP && h.Type == sysUDP_GRO && l >= syscall.CmsgLen(4) {
+			return int(*(*int32)(unsafe.Pointer(&oob[syscall.CmsgLen(0)])))
+		}
+		space := syscall.CmsgSpace(l - syscall.CmsgLen(0))
+		if space > len(oob) {
+			return 0
+		}
+		oob = oob[space:]
+	}
+	return 0
+}
+
+// udpAddrToRawSockaddr stores addr in rsa in the form expected by the
+// kernel and returns its length.
+func udpAddrToRawSockaddr(family int, addr *UDPAddr, rsa *syscall.RawSockaddrAny) (int, error) {
+	sa, err := addr.sockaddr(family)
+	if err != nil {
+		return 0, err
+	}
+	switch sa := sa.(type) {
+	case *syscall.SockaddrInet4:
+		raw := (*syscall.RawSockaddrInet4)(unsafe.Pointer(rsa))
+		raw.Family = syscall.AF_INET
+		p := (*[2]byte)(unsafe.Pointer(&raw.Port))
+		p[0], p[1] = byte(sa.Port>>8), byte(sa.Port)
+		raw.Addr = sa.Addr
+		return syscall.SizeofSockaddrInet4, nil
+	case *syscall.SockaddrInet6:
+		raw := (*syscall.RawSockaddrInet6)(unsafe.Pointer(rsa))
+		raw.Family = syscall.AF_INET6
+		p := (*[2]byte)(unsafe.Pointer(&raw.Port))
+		p[0], p[1] = byte(sa.Port>>8), byte(sa.Port)
+		raw.Addr = sa.Addr
+		raw.Scope_id = sa.ZoneId
+		return syscall.SizeofSockaddrInet6, nil
+	}
+	return 0, &AddrError{Err: "invalid address family", Addr: addr.String()}
+}
+
+// rawSockaddrToUDPAddr returns the address stored by the kernel in
+// rsa, or nil if there is none.
+func rawSockaddrToUDPAddr(rsa *syscall.RawSockaddrAny) *UDPAddr {
+	switch rsa.Addr.Family {
+	case syscall.AF_INET:
+		raw := (*syscall.RawSockaddrInet4)(unsafe.Pointer(rsa))
+		p := (*[2]byte)(unsafe.Pointer(&raw.Port))
+		ip := make(IP, IPv4len)
+		copy(ip, raw.Addr[:])
+		return &UDPAddr{IP: ip, Port: int(p[0])<<8 | int(p[1])}
+	case syscall.AF_INET6:
+		raw := (*syscall.RawSockaddrInet6)(unsafe.Pointer(rsa))
+		p := (*[2]byte)(unsafe.Pointer(&raw.Port))
+		ip := make(IP, IPv6len)
+		copy(ip, raw.Addr[:])
+		return &UDPAddr{IP: ip, Port: int(p[0])<<8 | int(p[1]), Zone: zoneCache.name(int(raw.Scope_id))}
+	}
+	return nil
+}
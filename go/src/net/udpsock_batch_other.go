@@ -0,0 +1,22 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux && !plan9
+// +build !linux,!plan9
+
+package net
+
+import "syscall"
+
+func (c *UDPConn) readBatch(ms []UDPMessage) (int, error) {
+	return c.readBatchLoop(ms)
+}
+
+func (c *UDPConn) writeBatch(ms []UDPMessage) (int, error) {
+	return c.writeBatchLoop(ms)
+}
+
+func setGRO(fd *netFD, enable bool) error {
+	return syscall.ENOPROTOOPT
+}
@@ -0,0 +1,44 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package net
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// readQueuedMsg reads a datagram into m if one is queued on c, without
+// waiting for one to arrive, and reports whether it did.
+func (c *UDPConn) readQueuedMsg(m *UDPMessage) (bool, error) {
+	var n, oobn, flags int
+	var sa syscall.Sockaddr
+	var err error
+	rerr := c.fd.pfd.RawRead(func(s uintptr) bool {
+		for {
+			n, oobn, flags, sa, err = syscall.Recvmsg(int(s), m.Buf, m.OOB, 0)
+			if err != syscall.EINTR {
+				return true
+			}
+		}
+	})
+	runtime.KeepAlive(c.fd)
+	if rerr != nil {
+		return false, rerr
+	}
+	if err == syscall.EAGAIN {
+		return false, nil
+	}
+	if err != nil {
+		return false, os.NewSyscallError("recvmsg", err)
+	}
+	m.N, m.NOOB, m.Flags = n, oobn, flags
+	m.Addr, _ = sockaddrToUDP(sa).(*UDPAddr)
+	m.SegmentSize = 0
+	return true, nil
+}
@@ -0,0 +1,39 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"internal/syscall/windows"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// readQueuedMsg reads a datagram into m if one is queued on c, without
+// waiting for one to arrive, and reports whether it did. The socket is
+// asked how much data is queued before reading, so a concurrent reader
+// taking the datagram in between makes it wait for the next one.
+func (c *UDPConn) readQueuedMsg(m *UDPMessage) (bool, error) {
+	var queued, n uint32
+	var err error
+	rerr := c.fd.pfd.RawRead(func(s uintptr) bool {
+		err = syscall.WSAIoctl(syscall.Handle(s), windows.FIONREAD, nil, 0, (*byte)(unsafe.Pointer(&queued)), uint32(unsafe.Sizeof(queued)), &n, nil, 0)
+		return true
+	})
+	runtime.KeepAlive(c.fd)
+	if rerr != nil {
+		return false, rerr
+	}
+	if err != nil {
+		return false, os.NewSyscallError("wsaioctl", err)
+	}
+	if queued == 0 {
+		return false, nil
+	}
+	m.N, m.NOOB, m.Flags, m.Addr, err = c.readMsg(m.Buf, m.OOB)
+	m.SegmentSize = 0
+	return err == nil, err
+}
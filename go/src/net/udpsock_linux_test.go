@@ -0,0 +1,55 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestUDPBatchGRO(t *testing.T) {
+	server, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := server.SetGRO(true); err != nil {
+		t.Skipf("UDP_GRO not supported: %v", err)
+	}
+	server.SetDeadline(time.Now().Add(10 * time.Second))
+
+	out := []UDPMessage{{Buf: make([]byte, 3000), Addr: server.LocalAddr().(*UDPAddr), SegmentSize: 1000}}
+	if _, err := client.WriteBatch(out); err != nil {
+		t.Skipf("UDP_SEGMENT not supported: %v", err)
+	}
+
+	in := make([]UDPMessage, 4)
+	for i := range in {
+		in[i].Buf = make([]byte, 1<<16)
+		in[i].OOB = make([]byte, syscall.CmsgSpace(4))
+	}
+	total := 0
+	for total < 3000 {
+		n, err := server.ReadBatch(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range in[:n] {
+			total += m.N
+			if m.N > 1000 && m.SegmentSize != 1000 {
+				t.Errorf("received %d coalesced bytes with SegmentSize %d; want 1000", m.N, m.SegmentSize)
+			}
+		}
+	}
+	if total != 3000 {
+		t.Errorf("received %d bytes; want 3000", total)
+	}
+}
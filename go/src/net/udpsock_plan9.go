@@ -7,6 +7,7 @@ package net
 import (
 	"context"
 	"errors"
+	"net/netip"
 	"os"
 	"syscall"
 )
@@ -28,6 +29,15 @@ func (c *UDPConn) readFrom(b []byte, addr *UDPAddr) (int, *UDPAddr, error) {
 	return n, addr, nil
 }
 
+func (c *UDPConn) readFromAddrPort(b []byte) (int, netip.AddrPort, error) {
+	var addr UDPAddr
+	n, _, err := c.readFrom(b, &addr)
+	if err != nil {
+		return 0, netip.AddrPort{}, err
+	}
+	return n, addr.AddrPort(), nil
+}
+
 func (c *UDPConn) readMsg(b, oob []byte) (n, oobn, flags int, addr *UDPAddr, err error) {
 	return 0, 0, 0, nil, syscall.EPLAN9
 }
@@ -52,6 +62,13 @@ func (c *UDPConn) writeTo(b []byte, addr *UDPAddr) (int, error) {
 	return len(b), nil
 }
 
+func (c *UDPConn) writeToAddrPort(b []byte, addr netip.AddrPort) (int, error) {
+	if !addr.IsValid() {
+		return 0, errMissingAddress
+	}
+	return c.writeTo(b, UDPAddrFromAddrPort(addr))
+}
+
 func (c *UDPConn) writeMsg(b, oob []byte, addr *UDPAddr) (n, oobn int, err error) {
 	return 0, 0, syscall.EPLAN9
 }
@@ -152,3 +169,15 @@ func (sl *sysListener) listenMulticastUDP(ctx context.Context, ifi *Interface, g
 	}
 	return newUDPConn(fd), nil
 }
+
+func (c *UDPConn) readBatch(ms []UDPMessage) (int, error) {
+	return 0, syscall.EPLAN9
+}
+
+func (c *UDPConn) writeBatch(ms []UDPMessage) (int, error) {
+	return 0, syscall.EPLAN9
+}
+
+func setGRO(fd *netFD, enable bool) error {
+	return syscall.EPLAN9
+}
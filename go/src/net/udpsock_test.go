@@ -10,6 +10,7 @@ package net
 import (
 	"errors"
 	"internal/testenv"
+	"net/netip"
 	"os"
 	"reflect"
 	"runtime"
@@ -495,3 +496,224 @@ func BenchmarkWriteToReadFromUDP(b *testing.B) {
 		}
 	}
 }
+
+func TestUDPAddrPortRoundTrip(t *testing.T) {
+	for _, network := range []string{"udp4", "udp6"} {
+		if network == "udp6" && !supportsIPv6() {
+			continue
+		}
+		t.Run(network, func(t *testing.T) {
+			addr := "127.0.0.1:0"
+			if network == "udp6" {
+				addr = "[::1]:0"
+			}
+			c, err := ListenPacket(network, addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			uc := c.(*UDPConn)
+			dst := uc.LocalAddr().(*UDPAddr).AddrPort()
+
+			want := []byte("HELLO-R-U-THERE")
+			if n, err := uc.WriteToUDPAddrPort(want, dst); err != nil || n != len(want) {
+				t.Fatalf("WriteToUDPAddrPort = %d, %v", n, err)
+			}
+			b := make([]byte, 64)
+			n, from, err := uc.ReadFromUDPAddrPort(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b[:n]) != string(want) {
+				t.Errorf("got %q; want %q", b[:n], want)
+			}
+			if from != dst {
+				t.Errorf("got source %v; want %v", from, dst)
+			}
+
+			if testing.Short() || runtime.GOOS != "linux" {
+				return
+			}
+			allocs := testing.AllocsPerRun(100, func() {
+				if _, err := uc.WriteToUDPAddrPort(want, dst); err != nil {
+					t.Fatal(err)
+				}
+				if _, _, err := uc.ReadFromUDPAddrPort(b); err != nil {
+					t.Fatal(err)
+				}
+			})
+			if allocs > 0 {
+				t.Errorf("got %v allocs per round trip; want 0", allocs)
+			}
+		})
+	}
+}
+
+func TestAddrPortConversions(t *testing.T) {
+	ap := netip.MustParseAddrPort("[fe80::1%lo0]:53")
+	ua := UDPAddrFromAddrPort(ap)
+	if !ua.IP.Equal(ParseIP("fe80::1")) || ua.Port != 53 || ua.Zone != "lo0" {
+		t.Errorf("UDPAddrFromAddrPort(%v) = %v", ap, ua)
+	}
+	if got := ua.AddrPort(); got != ap {
+		t.Errorf("(%v).AddrPort() = %v; want %v", ua, got, ap)
+	}
+	ta := &TCPAddr{IP: IPv4(192, 0, 2, 1), Port: 80}
+	if got, want := ta.AddrPort(), netip.MustParseAddrPort("[::ffff:192.0.2.1]:80"); got != want {
+		t.Errorf("(%v).AddrPort() = %v; want %v", ta, got, want)
+	}
+	if got := TCPAddrFromAddrPort(netip.AddrPort{}); got.IP != nil || got.Port != 0 {
+		t.Errorf("TCPAddrFromAddrPort(zero) = %v", got)
+	}
+	if got := (*UDPAddr)(nil).AddrPort(); got.IsValid() {
+		t.Errorf("nil UDPAddr AddrPort = %v", got)
+	}
+
+	_, n, err := ParseCIDR("192.0.2.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := n.Prefix()
+	if !ok || p != netip.MustParsePrefix("192.0.2.0/24") {
+		t.Errorf("(%v).Prefix() = %v, %v", n, p, ok)
+	}
+	if n2 := IPNetFromPrefix(netip.MustParsePrefix("192.0.2.7/24")); n2.String() != n.String() {
+		t.Errorf("IPNetFromPrefix = %v; want %v", n2, n)
+	}
+	if _, ok := (&IPNet{IP: IPv4(192, 0, 2, 0), Mask: IPv4Mask(255, 0, 255, 0)}).Prefix(); ok {
+		t.Error("non-canonical mask converted to Prefix")
+	}
+}
+
+func TestUDPBatch(t *testing.T) {
+	if runtime.GOOS == "plan9" {
+		t.Skipf("not supported on %s", runtime.GOOS)
+	}
+	server, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	server.SetDeadline(time.Now().Add(10 * time.Second))
+	client.SetDeadline(time.Now().Add(10 * time.Second))
+
+	dst := server.LocalAddr().(*UDPAddr)
+	out := []UDPMessage{
+		{Buf: []byte("one"), Addr: dst},
+		{Buf: []byte("two"), Addr: dst},
+		{Buf: []byte("three"), Addr: dst},
+	}
+	n, err := client.WriteBatch(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(out) {
+		t.Fatalf("WriteBatch sent %d messages; want %d", n, len(out))
+	}
+	for _, m := range out {
+		if m.N != len(m.Buf) {
+			t.Errorf("WriteBatch wrote %d bytes of %q", m.N, m.Buf)
+		}
+	}
+
+	// Sending a payload in segments yields one datagram per segment.
+	seg := []UDPMessage{{Buf: []byte("fourfivesix"), Addr: dst, SegmentSize: 4}}
+	if _, err := client.WriteBatch(seg); err != nil {
+		if runtime.GOOS == "linux" {
+			// Kernels before 4.18 do not support UDP_SEGMENT.
+			t.Logf("UDP_SEGMENT not supported: %v", err)
+		} else {
+			t.Fatal(err)
+		}
+	} else {
+		out = append(out, UDPMessage{Buf: []byte("four")}, UDPMessage{Buf: []byte("five")}, UDPMessage{Buf: []byte("six")})
+	}
+
+	in := make([]UDPMessage, 8)
+	for i := range in {
+		in[i].Buf = make([]byte, 64)
+	}
+	var got []string
+	for len(got) < len(out) {
+		n, err := server.ReadBatch(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n < 1 || n > len(in) {
+			t.Fatalf("ReadBatch returned %d", n)
+		}
+		for _, m := range in[:n] {
+			if src := client.LocalAddr().(*UDPAddr); m.Addr == nil || !m.Addr.IP.Equal(src.IP) || m.Addr.Port != src.Port {
+				t.Errorf("ReadBatch source = %v; want %v", m.Addr, src)
+			}
+			got = append(got, string(m.Buf[:m.N]))
+		}
+	}
+	for i, m := range out {
+		if got[i] != string(m.Buf) {
+			t.Errorf("datagram %d = %q; want %q", i, got[i], m.Buf)
+		}
+	}
+}
+
+func TestUDPReadBatchQueued(t *testing.T) {
+	if runtime.GOOS == "plan9" {
+		t.Skipf("not supported on %s", runtime.GOOS)
+	}
+	for _, tt := range []struct {
+		name string
+		read func(*UDPConn, []UDPMessage) (int, error)
+	}{
+		{"ReadBatch", (*UDPConn).ReadBatch},
+		// The implementation used where recvmmsg is not available.
+		{"readBatchLoop", (*UDPConn).readBatchLoop},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := ListenUDP("udp4", &UDPAddr{IP: IPv4(127, 0, 0, 1)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+			client, err := DialUDP("udp4", nil, server.LocalAddr().(*UDPAddr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+			server.SetDeadline(time.Now().Add(10 * time.Second))
+
+			want := []string{"one", "two", "three"}
+			for _, s := range want {
+				if _, err := client.Write([]byte(s)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// All queued datagrams are read by one call, which returns
+			// without waiting to fill the last message.
+			in := make([]UDPMessage, len(want)+1)
+			for i := range in {
+				in[i].Buf = make([]byte, 64)
+			}
+			n, err := tt.read(server, in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != len(want) {
+				t.Fatalf("read %d datagrams in one call; want %d", n, len(want))
+			}
+			for i, m := range in[:n] {
+				if got := string(m.Buf[:m.N]); got != want[i] {
+					t.Errorf("datagram %d = %q; want %q", i, got, want[i])
+				}
+				if src := client.LocalAddr().(*UDPAddr); m.Addr == nil || m.Addr.Port != src.Port {
+					t.Errorf("datagram %d source = %v; want %v", i, m.Addr, src)
+				}
+			}
+		})
+	}
+}
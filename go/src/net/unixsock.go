@@ -164,6 +164,83 @@ func (c *UnixConn) ReadMsgUnix(b, oob []byte) (n, oobn, flags int, addr *UnixAdd
 	return
 }
 
+// ReadFiles reads a message from c into b along with up to maxFiles
+// open files passed by the sender, such as with WriteFiles. It returns
+// the number of bytes copied into b and the received files, which the
+// caller must close.
+//
+// If the sender passed more than maxFiles files, the ones that did
+// not fit are discarded by the operating system; ReadFiles then
+// closes the files it did receive and returns an error.
+//
+// Received files are marked close-on-exec.
+func (c *UnixConn) ReadFiles(b []byte, maxFiles int) (n int, files []*os.File, err error) {
+	if !c.ok() {
+		return 0, nil, syscall.EINVAL
+	}
+	n, files, err = c.readFiles(b, maxFiles)
+	if err != nil {
+		err = &OpError{Op: "read", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: err}
+	}
+	return
+}
+
+// WriteFiles writes b to c along with the open file descriptors of
+// files, which the receiving process can obtain with ReadFiles. It
+// returns the number of bytes of b written. The files remain open
+// and must not be closed until WriteFiles returns.
+//
+// As with WriteMsgUnix, a single byte is written if b is empty.
+func (c *UnixConn) WriteFiles(b []byte, files ...*os.File) (int, error) {
+	if !c.ok() {
+		return 0, syscall.EINVAL
+	}
+	n, err := c.writeFiles(b, files)
+	if err != nil {
+		err = &OpError{Op: "write", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: err}
+	}
+	return n, err
+}
+
+// UnixCredentials holds the credentials of the process at the other
+// end of a Unix domain socket, as recorded by the operating system
+// when the connection was established.
+type UnixCredentials struct {
+	Pid int // process ID
+	Uid int // user ID
+	Gid int // group ID
+}
+
+// PeerCredentials returns the credentials of the peer process of c.
+// It is implemented on Linux, Darwin, FreeBSD, NetBSD and OpenBSD.
+// On FreeBSD before version 13, the returned Pid is zero.
+func (c *UnixConn) PeerCredentials() (UnixCredentials, error) {
+	if !c.ok() {
+		return UnixCredentials{}, syscall.EINVAL
+	}
+	cred, err := c.peerCredentials()
+	if err != nil {
+		err = &OpError{Op: "get", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: err}
+	}
+	return cred, err
+}
+
+// PeerPidfd returns a process file descriptor (pidfd) referring to the
+// peer process of c. Unlike the process ID returned by
+// PeerCredentials, a pidfd cannot come to refer to another process
+// after the peer exits. The caller must close the returned file.
+// It is only implemented on Linux 6.5 and later.
+func (c *UnixConn) PeerPidfd() (*os.File, error) {
+	if !c.ok() {
+		return nil, syscall.EINVAL
+	}
+	f, err := c.peerPidfd()
+	if err != nil {
+		err = &OpError{Op: "get", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: err}
+	}
+	return f, err
+}
+
 // WriteToUnix acts like WriteTo but takes a UnixAddr.
 // WriteToUnix的作用类似于WriteTo，但需要一个UnixAddr。
 func (c *UnixConn) WriteToUnix(b []byte, addr *UnixAddr) (int, error) {
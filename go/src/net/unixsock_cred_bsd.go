@@ -0,0 +1,34 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || freebsd || netbsd || openbsd
+// +build darwin freebsd netbsd openbsd
+
+package net
+
+import (
+	"internal/syscall/unix"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+func (c *UnixConn) peerCredentials() (UnixCredentials, error) {
+	var pid, uid, gid int
+	var err error
+	if cerr := c.fd.pfd.RawControl(func(s uintptr) {
+		pid, uid, gid, err = unix.PeerCredentials(int(s))
+	}); cerr != nil {
+		return UnixCredentials{}, cerr
+	}
+	runtime.KeepAlive(c.fd)
+	if err != nil {
+		return UnixCredentials{}, os.NewSyscallError("getsockopt", err)
+	}
+	return UnixCredentials{Pid: pid, Uid: uid, Gid: gid}, nil
+}
+
+func (c *UnixConn) peerPidfd() (*os.File, error) {
+	return nil, syscall.ENOPROTOOPT
+}
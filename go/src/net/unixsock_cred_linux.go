@@ -0,0 +1,45 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// sysSO_PEERPIDFD is missing from package syscall.
+const sysSO_PEERPIDFD = 0x4d
+
+func (c *UnixConn) peerCredentials() (UnixCredentials, error) {
+	var cred *syscall.Ucred
+	var err error
+	if cerr := c.fd.pfd.RawControl(func(s uintptr) {
+		cred, err = syscall.GetsockoptUcred(int(s), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); cerr != nil {
+		return UnixCredentials{}, cerr
+	}
+	runtime.KeepAlive(c.fd)
+	if err != nil {
+		return UnixCredentials{}, os.NewSyscallError("getsockopt", err)
+	}
+	return UnixCredentials{Pid: int(cred.Pid), Uid: int(cred.Uid), Gid: int(cred.Gid)}, nil
+}
+
+func (c *UnixConn) peerPidfd() (*os.File, error) {
+	var fd int
+	var err error
+	if cerr := c.fd.pfd.RawControl(func(s uintptr) {
+		fd, err = syscall.GetsockoptInt(int(s), syscall.SOL_SOCKET, sysSO_PEERPIDFD)
+	}); cerr != nil {
+		return nil, cerr
+	}
+	runtime.KeepAlive(c.fd)
+	if err != nil {
+		return nil, os.NewSyscallError("getsockopt", err)
+	}
+	// The kernel creates the pidfd with O_CLOEXEC set.
+	return os.NewFile(uintptr(fd), "pidfd"), nil
+}
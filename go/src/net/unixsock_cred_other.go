@@ -0,0 +1,21 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !darwin && !freebsd && !linux && !netbsd && !openbsd && !plan9
+// +build !darwin,!freebsd,!linux,!netbsd,!openbsd,!plan9
+
+package net
+
+import (
+	"os"
+	"syscall"
+)
+
+func (c *UnixConn) peerCredentials() (UnixCredentials, error) {
+	return UnixCredentials{}, syscall.ENOPROTOOPT
+}
+
+func (c *UnixConn) peerPidfd() (*os.File, error) {
+	return nil, syscall.ENOPROTOOPT
+}
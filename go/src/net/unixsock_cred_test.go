@@ -0,0 +1,30 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || freebsd || linux || netbsd || openbsd
+// +build darwin freebsd linux netbsd openbsd
+
+package net
+
+import (
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestUnixConnPeerCredentials(t *testing.T) {
+	c, _ := unixConnPair(t)
+	cred, err := c.PeerCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := UnixCredentials{Pid: os.Getpid(), Uid: os.Getuid(), Gid: os.Getgid()}
+	if runtime.GOOS == "freebsd" && cred.Pid == 0 {
+		// FreeBSD only reports the process ID since version 13.
+		want.Pid = 0
+	}
+	if cred != want {
+		t.Errorf("PeerCredentials = %+v; want %+v", cred, want)
+	}
+}
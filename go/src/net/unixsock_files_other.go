@@ -0,0 +1,21 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build (js && wasm) || windows
+// +build js,wasm windows
+
+package net
+
+import (
+	"os"
+	"syscall"
+)
+
+func (c *UnixConn) readFiles(b []byte, maxFiles int) (int, []*os.File, error) {
+	return 0, nil, syscall.ENOPROTOOPT
+}
+
+func (c *UnixConn) writeFiles(b []byte, files []*os.File) (int, error) {
+	return 0, syscall.ENOPROTOOPT
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package net
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// unixConnPair returns a connected pair of stream UnixConns.
+func unixConnPair(t *testing.T) (*UnixConn, *UnixConn) {
+	t.Helper()
+	fds, err := syscall.Socketpair(syscall.AF_LOCAL, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("Socketpair: %v", err)
+	}
+	var conns [2]*UnixConn
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "socketpair")
+		c, err := FileConn(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("FileConn: %v", err)
+		}
+		t.Cleanup(func() { c.Close() })
+		c.SetDeadline(time.Now().Add(5 * time.Second))
+		conns[i] = c.(*UnixConn)
+	}
+	return conns[0], conns[1]
+}
+
+func TestUnixConnFiles(t *testing.T) {
+	if !testableNetwork("unix") {
+		t.Skip("not unix system")
+	}
+	cw, cr := unixConnPair(t)
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+
+	if n, err := cw.WriteFiles([]byte("hello"), pr, pw); err != nil || n != 5 {
+		t.Fatalf("WriteFiles = %d, %v; want 5, nil", n, err)
+	}
+	b := make([]byte, 16)
+	n, files, err := cr.ReadFiles(b, 2)
+	if err != nil {
+		t.Fatalf("ReadFiles: %v", err)
+	}
+	if string(b[:n]) != "hello" {
+		t.Errorf("ReadFiles read %q; want %q", b[:n], "hello")
+	}
+	if len(files) != 2 {
+		t.Fatalf("ReadFiles returned %d files; want 2", len(files))
+	}
+	defer files[0].Close()
+	defer files[1].Close()
+
+	// The received files refer to the same pipe.
+	if _, err := io.WriteString(files[1], "pipe"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := io.ReadFull(pr, b[:4]); err != nil || string(b[:n]) != "pipe" {
+		t.Errorf("read %q, %v from pipe; want %q", b[:n], err, "pipe")
+	}
+
+	// Messages without files are read normally.
+	if _, err := cw.WriteFiles([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if n, files, err := cr.ReadFiles(b, 2); err != nil || n != 1 || files != nil {
+		t.Errorf("ReadFiles = %d, %v, %v; want 1, nil, nil", n, files, err)
+	}
+}
+
+func TestUnixConnFilesTruncated(t *testing.T) {
+	if !testableNetwork("unix") {
+		t.Skip("not unix system")
+	}
+	cw, cr := unixConnPair(t)
+
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := cw.WriteFiles([]byte("x"), f, f, f, f); err != nil {
+		t.Fatal(err)
+	}
+	n, files, err := cr.ReadFiles(make([]byte, 1), 1)
+	if err == nil {
+		t.Fatalf("ReadFiles = %d, %v, nil; want error", n, files)
+	}
+	if files != nil {
+		t.Errorf("ReadFiles returned files %v with error %v", files, err)
+	}
+}
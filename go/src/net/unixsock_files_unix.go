@@ -0,0 +1,94 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package net
+
+import (
+	"errors"
+	"internal/itoa"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+var errTruncatedFiles = errors.New("too many files passed; excess files discarded")
+
+func (c *UnixConn) readFiles(b []byte, maxFiles int) (int, []*os.File, error) {
+	var oob []byte
+	if maxFiles > 0 {
+		oob = make([]byte, syscall.CmsgSpace(maxFiles*4))
+	}
+	n, oobn, flags, _, err := c.readMsg(b, oob)
+	if err != nil {
+		return n, nil, err
+	}
+	fds, err := parseUnixRights(oob[:oobn])
+	if err == nil && (flags&syscall.MSG_CTRUNC != 0 || len(fds) > maxFiles) {
+		err = errTruncatedFiles
+	}
+	if err != nil {
+		// Don't leak the descriptors that did arrive.
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+		return n, nil, err
+	}
+	var files []*os.File
+	for _, fd := range fds {
+		files = append(files, os.NewFile(uintptr(fd), "unix-rights:"+itoa.Itoa(fd)))
+	}
+	return n, files, nil
+}
+
+// parseUnixRights returns the file descriptors carried by the
+// SCM_RIGHTS control messages in oob.
+func parseUnixRights(oob []byte) ([]int, error) {
+	if len(oob) == 0 {
+		return nil, nil
+	}
+	scms, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, os.NewSyscallError("parse socket control message", err)
+	}
+	var fds []int
+	for i := range scms {
+		if scms[i].Header.Level != syscall.SOL_SOCKET || scms[i].Header.Type != syscall.SCM_RIGHTS {
+			continue
+		}
+		rights, err := syscall.ParseUnixRights(&scms[i])
+		if err != nil {
+			return fds, os.NewSyscallError("parse unix rights", err)
+		}
+		fds = append(fds, rights...)
+	}
+	return fds, nil
+}
+
+func (c *UnixConn) writeFiles(b []byte, files []*os.File) (int, error) {
+	var oob []byte
+	if len(files) > 0 {
+		fds := make([]int, len(files))
+		for i, f := range files {
+			if f == nil {
+				return 0, os.ErrInvalid
+			}
+			// Use the descriptor through SyscallConn rather than
+			// Fd, which would put the file in blocking mode.
+			rc, err := f.SyscallConn()
+			if err != nil {
+				return 0, err
+			}
+			if err := rc.Control(func(fd uintptr) { fds[i] = int(fd) }); err != nil {
+				return 0, err
+			}
+		}
+		oob = syscall.UnixRights(fds...)
+	}
+	n, _, err := c.writeMsg(b, oob, nil)
+	runtime.KeepAlive(files)
+	return n, err
+}
@@ -102,3 +102,12 @@ func TestUnixgramLinuxAbstractLongName(t *testing.T) {
 		t.Fatalf("got %v; want %v", b[:n], data[:])
 	}
 }
+
+func TestUnixConnPeerPidfd(t *testing.T) {
+	c, _ := unixConnPair(t)
+	f, err := c.PeerPidfd()
+	if err != nil {
+		t.Skipf("PeerPidfd not supported: %v", err)
+	}
+	f.Close()
+}
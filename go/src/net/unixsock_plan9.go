@@ -49,3 +49,19 @@ func (sl *sysListener) listenUnix(ctx context.Context, laddr *UnixAddr) (*UnixLi
 func (sl *sysListener) listenUnixgram(ctx context.Context, laddr *UnixAddr) (*UnixConn, error) {
 	return nil, syscall.EPLAN9
 }
+
+func (c *UnixConn) readFiles(b []byte, maxFiles int) (int, []*os.File, error) {
+	return 0, nil, syscall.EPLAN9
+}
+
+func (c *UnixConn) writeFiles(b []byte, files []*os.File) (int, error) {
+	return 0, syscall.EPLAN9
+}
+
+func (c *UnixConn) peerCredentials() (UnixCredentials, error) {
+	return UnixCredentials{}, syscall.EPLAN9
+}
+
+func (c *UnixConn) peerPidfd() (*os.File, error) {
+	return nil, syscall.EPLAN9
+}